@@ -0,0 +1,218 @@
+package epub
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+// Collection roles defined by EPUB specifications. Roles of other
+// specifications are absolute URLs.
+const (
+	CollectionIndex               = "index"
+	CollectionIndexGroup          = "index-group"
+	CollectionDictionary          = "dictionary"
+	CollectionDistributableObject = "distributable-object"
+	CollectionPreview             = "preview"
+	CollectionManifest            = "manifest"
+)
+
+// Collection is a group of related publication resources declared in the
+// package document, such as a preview, a dictionary or one volume of a
+// multi-volume set.
+type Collection struct {
+	ID   string `json:"id,omitempty"`
+	Role string `json:"role"`
+	Lang string `json:"lang,omitempty"`
+	Dir  string `json:"dir,omitempty"`
+
+	// Titles and Identifiers are the dc:title and dc:identifier elements
+	// of the collection metadata.
+	Titles      []string   `json:"titles,omitempty"`
+	Identifiers []string   `json:"identifiers,omitempty"`
+	Meta        []pkg.Meta `json:"meta,omitempty"`
+
+	Links       []CollectionLink `json:"links,omitempty"`
+	Collections []Collection     `json:"collections,omitempty"`
+}
+
+// CollectionLink is a resource of a collection.
+type CollectionLink struct {
+	// Href is relative to the package document.
+	Href       string `json:"href"`
+	MediaType  string `json:"mediaType,omitempty"`
+	Rel        string `json:"rel,omitempty"`
+	Properties string `json:"properties,omitempty"`
+
+	// ResourceID is the manifest item the link resolves to, empty for
+	// links to resources outside the manifest.
+	ResourceID string `json:"resourceId,omitempty"`
+	Fragment   string `json:"fragment,omitempty"`
+}
+
+// Title returns the first title of the collection.
+func (c Collection) Title() string {
+	if len(c.Titles) == 0 {
+		return ""
+	}
+	return c.Titles[0]
+}
+
+// Resources returns the manifest IDs of the collection links and of all
+// nested collections, in document order and without duplicates.
+func (c Collection) Resources() (ids []string) {
+	seen := map[string]bool{}
+	var collect func(collection Collection)
+	collect = func(collection Collection) {
+		for _, link := range collection.Links {
+			if link.ResourceID != "" && !seen[link.ResourceID] {
+				seen[link.ResourceID] = true
+				ids = append(ids, link.ResourceID)
+			}
+		}
+		for _, child := range collection.Collections {
+			collect(child)
+		}
+	}
+	collect(c)
+	return
+}
+
+// Collections returns the top level collections of the selected package,
+// with their links resolved to manifest items.
+func (r *Reader) Collections() (collections []Collection) {
+	packagePub := r.CurrentSelectedPackage()
+	packageDir := path.Dir(r.CurrentSelectedPackagePath())
+
+	ids := map[string]string{}
+	for _, item := range packagePub.Manifest.Items {
+		ids[path.Join(packageDir, unescapePath(item.Href))] = item.ID
+	}
+
+	for _, collection := range packagePub.Collections {
+		collections = append(collections, newCollection(collection, packageDir, ids))
+	}
+	return
+}
+
+// CollectionsByRole returns the collections with the given role, including
+// nested ones, in document order.
+func (r *Reader) CollectionsByRole(role string) (collections []Collection) {
+	var search func(list []Collection)
+	search = func(list []Collection) {
+		for _, collection := range list {
+			if collection.Role == role {
+				collections = append(collections, collection)
+			}
+			search(collection.Collections)
+		}
+	}
+	search(r.Collections())
+	return
+}
+
+func newCollection(c pkg.Collection, packageDir string, ids map[string]string) (collection Collection) {
+	collection = Collection{ID: c.ID, Role: c.Role, Lang: c.Lang, Dir: c.Dir}
+
+	if c.Metadata != nil {
+		for _, dc := range c.Metadata.DublinCore {
+			local := strings.TrimPrefix(dc.XMLName.Local, "dc:")
+			switch local {
+			case "title":
+				collection.Titles = append(collection.Titles, strings.TrimSpace(dc.Value))
+			case "identifier":
+				collection.Identifiers = append(collection.Identifiers, strings.TrimSpace(dc.Value))
+			}
+		}
+		collection.Meta = c.Metadata.Meta
+	}
+
+	for _, link := range c.Links {
+		containerPath, fragment := resolveHref(packageDir, unescapePath(link.Href))
+		collection.Links = append(collection.Links, CollectionLink{
+			Href:       link.Href,
+			MediaType:  link.MediaType,
+			Rel:        link.Rel,
+			Properties: link.Properties,
+			ResourceID: ids[containerPath],
+			Fragment:   fragment,
+		})
+	}
+
+	for _, child := range c.Collections {
+		collection.Collections = append(collection.Collections, newCollection(child, packageDir, ids))
+	}
+	return
+}
+
+// AddCollection declares a collection in the package document. Link
+// hrefs are relative to the package document; a link with only a
+// ResourceID uses the href of that manifest item.
+func (w *Writer) AddCollection(collection Collection) error {
+	c, err := w.packageCollection(collection)
+	if err != nil {
+		return err
+	}
+
+	packagePub := w.epub.SelectedPackage()
+	packagePub.Collections = append(packagePub.Collections, c)
+	return nil
+}
+
+func (w *Writer) packageCollection(collection Collection) (c pkg.Collection, err error) {
+	if collection.Role == "" {
+		return c, errors.New("collection role is required")
+	}
+
+	c = pkg.Collection{ID: collection.ID, Role: collection.Role, Lang: collection.Lang, Dir: collection.Dir}
+
+	if len(collection.Titles) > 0 || len(collection.Identifiers) > 0 || len(collection.Meta) > 0 {
+		c.Metadata = &pkg.CollectionMetadata{Meta: collection.Meta}
+		for _, title := range collection.Titles {
+			c.Metadata.DublinCore = append(c.Metadata.DublinCore, pkg.DCOptional{XMLName: xml.Name{Local: "dc:title"}, Value: title})
+		}
+		for _, identifier := range collection.Identifiers {
+			c.Metadata.DublinCore = append(c.Metadata.DublinCore, pkg.DCOptional{XMLName: xml.Name{Local: "dc:identifier"}, Value: identifier})
+		}
+		if len(c.Metadata.DublinCore) > 0 {
+			c.Metadata.XMLNSDC = pkg.NamespaceDC
+		}
+	}
+
+	for _, link := range collection.Links {
+		href := link.Href
+		if href == "" && link.ResourceID != "" {
+			res := w.resourceByID(link.ResourceID)
+			if res == nil {
+				return c, fmt.Errorf("resource %q not found", link.ResourceID)
+			}
+			href = res.Href
+			if link.Fragment != "" {
+				href += "#" + link.Fragment
+			}
+		}
+		if href == "" {
+			return c, fmt.Errorf("link of collection %q has no href", collection.Role)
+		}
+
+		c.Links = append(c.Links, pkg.Link{
+			Href:       href,
+			MediaType:  link.MediaType,
+			Rel:        link.Rel,
+			Properties: link.Properties,
+		})
+	}
+
+	for _, child := range collection.Collections {
+		nested, err := w.packageCollection(child)
+		if err != nil {
+			return c, err
+		}
+		c.Collections = append(c.Collections, nested)
+	}
+	return
+}
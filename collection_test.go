@@ -0,0 +1,104 @@
+package epub
+
+import (
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReader_Collections(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], "</package>", `<collection role="preview" id="preview">
+    <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+      <dc:title>Sample</dc:title>
+      <dc:identifier>urn:isbn:9780000000001</dc:identifier>
+    </metadata>
+    <collection role="manifest">
+      <link href="text/chapter-1.xhtml"/>
+      <link href="images/cover.png"/>
+    </collection>
+    <link href="text/chapter-1.xhtml#s1"/>
+  </collection>
+  <collection role="index">
+    <link href="text/chapter-2.xhtml"/>
+    <collection role="index-group"><link href="text/chapter-2.xhtml#a"/></collection>
+  </collection>
+</package>`, 1)
+
+	r := newTestReader(t, files)
+
+	collections := r.Collections()
+	if len(collections) != 2 {
+		t.Fatalf("expected two collections, got %+v", collections)
+	}
+
+	preview := collections[0]
+	if preview.Role != CollectionPreview || preview.Title() != "Sample" || preview.Identifiers[0] != "urn:isbn:9780000000001" {
+		t.Errorf("unexpected preview %+v", preview)
+	}
+	if link := preview.Links[0]; link.ResourceID != "chapter-1" || link.Fragment != "s1" {
+		t.Errorf("unexpected preview link %+v", link)
+	}
+	if ids := preview.Resources(); !reflect.DeepEqual(ids, []string{"chapter-1", "cover"}) {
+		t.Errorf("unexpected preview resources %v", ids)
+	}
+
+	groups := r.CollectionsByRole(CollectionIndexGroup)
+	if len(groups) != 1 || groups[0].Links[0].ResourceID != "chapter-2" {
+		t.Errorf("unexpected index groups %+v", groups)
+	}
+}
+
+func TestWriter_AddCollection(t *testing.T) {
+	w := New("urn:uuid:volumes")
+	w.Title("Complete Set")
+	w.Languages("en")
+	w.Cover([]byte("\x89PNG\r\n\x1a\n"))
+	page := []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>V</title></head><body><p>V</p></body></html>`)
+	w.AddContent("volume-1.xhtml", page)
+	w.AddContent("volume-2.xhtml", page)
+
+	if err := w.AddCollection(Collection{Links: []CollectionLink{{Href: "volume-1.xhtml"}}}); err == nil {
+		t.Errorf("expected error for a collection without role")
+	}
+	if err := w.AddCollection(Collection{Role: CollectionPreview, Links: []CollectionLink{{ResourceID: "missing"}}}); err == nil {
+		t.Errorf("expected error for a missing resource")
+	}
+
+	volume := Collection{
+		Role:   "http://example.com/roles/volume",
+		Titles: []string{"Volume 1"},
+		Links:  []CollectionLink{{ResourceID: "volume-1.xhtml"}},
+		Collections: []Collection{{
+			Role:  CollectionManifest,
+			Links: []CollectionLink{{Href: "volume-1.xhtml", MediaType: "application/xhtml+xml"}},
+		}},
+	}
+	if err := w.AddCollection(volume); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.TableOfContents("toc", TOC{Items: []TOC{{Title: "Volume 1", Href: "volume-1.xhtml"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+
+	collections := r.Collections()
+	if len(collections) != 1 {
+		t.Fatalf("expected one collection, got %+v", collections)
+	}
+
+	got := collections[0]
+	if got.Role != volume.Role || got.Title() != "Volume 1" || got.Links[0].Href != "volume-1.xhtml" || got.Links[0].ResourceID != "volume-1.xhtml" {
+		t.Errorf("unexpected collection %+v", got)
+	}
+	if manifest := got.Collections[0]; manifest.Role != CollectionManifest || manifest.Links[0].MediaType != "application/xhtml+xml" {
+		t.Errorf("unexpected nested collection %+v", manifest)
+	}
+
+	opf := string(r.epub.zipContainer.AllFiles()[r.CurrentSelectedPackagePath()])
+	if strings.Contains(opf, `rel=""`) {
+		t.Errorf("expected no empty rel attribute in %s", opf)
+	}
+}
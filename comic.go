@@ -0,0 +1,350 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+// ComicPage is one page image of a comic, in reading order.
+type ComicPage struct {
+	// Name is the original file name, used to keep the image extension.
+	Name    string
+	Content []byte
+}
+
+// ComicOptions describes the publication generated from comic pages.
+type ComicOptions struct {
+	// Identifier is required and becomes the dc:identifier.
+	Identifier string
+	Title      string
+	Author     string
+
+	// Language defaults to "en".
+	Language string
+
+	// RightToLeft sets a right-to-left page progression, as used by manga.
+	RightToLeft bool
+
+	// Spread defaults to pkg.SpreadLandscape.
+	Spread string
+}
+
+// comicInfo holds the fields of a ComicInfo.xml file used to fill options
+// left empty by the caller.
+type comicInfo struct {
+	Title       string `xml:"Title"`
+	Series      string `xml:"Series"`
+	Number      string `xml:"Number"`
+	Writer      string `xml:"Writer"`
+	LanguageISO string `xml:"LanguageISO"`
+	Manga       string `xml:"Manga"`
+}
+
+var comicImageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+
+// ComicToEPUB converts ordered page images into a fixed-layout EPUB. Every
+// image gets its own XHTML page whose viewport matches the image size. The
+// first image is the cover, pages alternate between spread sides starting
+// after it, and landscape images are treated as double-page spreads that
+// are centered on their own.
+func ComicToEPUB(pages []ComicPage, options ComicOptions) (w *Writer, err error) {
+	if len(pages) == 0 {
+		return nil, errors.New("comic has no pages")
+	}
+
+	if options.Identifier == "" {
+		return nil, errors.New("comic identifier is required")
+	}
+
+	if options.Language == "" {
+		options.Language = "en"
+	}
+	if options.Spread == "" {
+		options.Spread = pkg.SpreadLandscape
+	}
+	if options.Title == "" {
+		options.Title = "Untitled"
+	}
+
+	w = New(options.Identifier)
+	w.Title(options.Title)
+	w.Languages(options.Language)
+	if options.Author != "" {
+		w.Author(options.Author)
+	}
+
+	w.RenditionLayout(pkg.LayoutPrePaginated)
+	w.RenditionOrientation(pkg.OrientationAuto)
+	w.RenditionSpread(options.Spread)
+
+	// The spread side a page opens on, and the side that closes a spread.
+	openSide, closeSide := pkg.PageSpreadLeft, pkg.PageSpreadRight
+	if options.RightToLeft {
+		w.epub.SelectedPackage().Spine.PageProgressionDirection = pkg.SpineDirectionRTL
+		openSide, closeSide = closeSide, openSide
+	}
+
+	toc := TOC{Title: options.Title}
+	pageList := []Page{}
+	side := closeSide
+
+	for i, page := range pages {
+		config, _, err := image.DecodeConfig(bytes.NewReader(page.Content))
+		if err != nil {
+			return nil, fmt.Errorf("decode page %s: %w", page.Name, err)
+		}
+
+		number := i + 1
+		base := fmt.Sprintf("page-%04d", number)
+		imageName := base + strings.ToLower(path.Ext(page.Name))
+
+		if i == 0 {
+			w.addImageCover(imageName, page.Content)
+			w.MetaContent(map[string]string{"cover": imageName})
+			w.RenditionViewport(config.Width, config.Height)
+		} else {
+			w.AddImage(imageName, page.Content)
+		}
+
+		label := strconv.Itoa(number)
+		content := comicPageXHTML(
+			options.Language,
+			"Page "+label,
+			path.Join("..", w.imagesDir, imageName),
+			config.Width,
+			config.Height,
+		)
+		res := w.AddContent(path.Join(w.textDir, base+".xhtml"), content)
+
+		pageSpread := side
+		if config.Width > config.Height {
+			pageSpread = pkg.PageSpreadCenter
+			side = openSide
+		} else if side == openSide {
+			side = closeSide
+		} else {
+			side = openSide
+		}
+		if err = w.PageSpread(res.ID, pageSpread); err != nil {
+			return nil, err
+		}
+
+		title := "Page " + label
+		if i == 0 {
+			title = "Cover"
+		}
+		toc.Items = append(toc.Items, TOC{Title: title, Href: res.Href})
+		pageList = append(pageList, Page{Label: label, Href: res.Href})
+	}
+
+	if err = w.PageList(pageList, false); err != nil {
+		return nil, err
+	}
+
+	if err = w.TableOfContents("toc", toc); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func comicPageXHTML(lang string, title string, src string, width int, height int) []byte {
+	return fmt.Appendf(nil, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%[1]s" lang="%[1]s">
+<head>
+<meta charset="utf-8"/>
+<meta name="viewport" content="width=%[4]d, height=%[5]d"/>
+<title>%[2]s</title>
+<style>html, body { margin: 0; padding: 0; width: %[4]dpx; height: %[5]dpx; } img { display: block; width: %[4]dpx; height: %[5]dpx; }</style>
+</head>
+<body>
+<img src="%[3]s" alt="%[2]s"/>
+</body>
+</html>
+`, html.EscapeString(lang), html.EscapeString(title), html.EscapeString(src), width, height)
+}
+
+// CBZToEPUB converts a comic book zip archive into a fixed-layout EPUB.
+// Images are ordered by their path using natural sort order, and a
+// ComicInfo.xml file fills the title, author, language and manga direction
+// when they are not set in options.
+func CBZToEPUB(name string, options ComicOptions) (*Writer, error) {
+	archive, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	pages := []ComicPage{}
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || isHiddenComicPath(f.Name) {
+			continue
+		}
+
+		isInfo := strings.EqualFold(path.Base(f.Name), "ComicInfo.xml")
+		if !isInfo && !isComicImage(f.Name) {
+			continue
+		}
+
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		if isInfo {
+			applyComicInfo(&options, content)
+			continue
+		}
+		pages = append(pages, ComicPage{Name: f.Name, Content: content})
+	}
+
+	sortComicPages(pages)
+	return ComicToEPUB(pages, options)
+}
+
+// ImageDirToEPUB converts a directory of page images into a fixed-layout
+// EPUB, in the same way as CBZToEPUB.
+func ImageDirToEPUB(dir string, options ComicOptions) (*Writer, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := []ComicPage{}
+	for _, entry := range entries {
+		if entry.IsDir() || isHiddenComicPath(entry.Name()) {
+			continue
+		}
+
+		isInfo := strings.EqualFold(entry.Name(), "ComicInfo.xml")
+		if !isInfo && !isComicImage(entry.Name()) {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if isInfo {
+			applyComicInfo(&options, content)
+			continue
+		}
+		pages = append(pages, ComicPage{Name: entry.Name(), Content: content})
+	}
+
+	sortComicPages(pages)
+	return ComicToEPUB(pages, options)
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	// Same limit as the OCF reader uses against zip bombs.
+	const maxFileSize = 1024 * 1024 * 1024
+	if f.UncompressedSize64 > maxFileSize {
+		return nil, fmt.Errorf("file %s is too large: %d bytes", f.Name, f.UncompressedSize64)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(io.LimitReader(rc, int64(f.UncompressedSize64)))
+}
+
+func applyComicInfo(options *ComicOptions, content []byte) {
+	var info comicInfo
+	if err := xml.Unmarshal(content, &info); err != nil {
+		return
+	}
+
+	if options.Title == "" {
+		options.Title = info.Title
+		if options.Title == "" && info.Series != "" {
+			options.Title = strings.TrimSpace(info.Series + " " + info.Number)
+		}
+	}
+	if options.Author == "" {
+		options.Author = info.Writer
+	}
+	if options.Language == "" {
+		options.Language = info.LanguageISO
+	}
+	if info.Manga == "YesAndRightToLeft" {
+		options.RightToLeft = true
+	}
+}
+
+func isComicImage(name string) bool {
+	return slices.Contains(comicImageExtensions, strings.ToLower(path.Ext(name)))
+}
+
+// isHiddenComicPath reports whether the path is metadata left by archivers
+// or file managers, such as __MACOSX folders and dot files.
+func isHiddenComicPath(name string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+func sortComicPages(pages []ComicPage) {
+	slices.SortStableFunc(pages, func(a, b ComicPage) int {
+		return naturalCompare(a.Name, b.Name)
+	})
+}
+
+// naturalCompare compares strings case-insensitively, ordering runs of
+// digits by their numeric value so "page2" sorts before "page10".
+func naturalCompare(a string, b string) int {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	for a != "" && b != "" {
+		if unicode.IsDigit(rune(a[0])) && unicode.IsDigit(rune(b[0])) {
+			numberA, restA := splitDigits(a)
+			numberB, restB := splitDigits(b)
+
+			trimmedA, trimmedB := strings.TrimLeft(numberA, "0"), strings.TrimLeft(numberB, "0")
+			if len(trimmedA) != len(trimmedB) {
+				return len(trimmedA) - len(trimmedB)
+			}
+			if c := strings.Compare(trimmedA, trimmedB); c != 0 {
+				return c
+			}
+
+			a, b = restA, restB
+			continue
+		}
+
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func splitDigits(s string) (digits string, rest string) {
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
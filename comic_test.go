@@ -0,0 +1,153 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+func testPNG(t *testing.T, width int, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCBZToEPUB(t *testing.T) {
+	portrait := testPNG(t, 100, 150)
+	files := map[string][]byte{
+		"book/p10.png":           portrait,
+		"book/p2.png":            testPNG(t, 300, 150),
+		"book/p1.png":            testPNG(t, 120, 180),
+		"book/p3.png":            portrait,
+		"book/notes.txt":         []byte("not a page"),
+		"__MACOSX/book/._p1.png": []byte("resource fork"),
+		"book/ComicInfo.xml": []byte(`<?xml version="1.0"?>
+<ComicInfo><Series>Ninja</Series><Number>4</Number><Writer>Mangaka</Writer><LanguageISO>ja</LanguageISO><Manga>YesAndRightToLeft</Manga></ComicInfo>`),
+	}
+
+	name := filepath.Join(t.TempDir(), "book.cbz")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for fileName, content := range files {
+		f, err := zw.Create(fileName)
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", fileName, err)
+		}
+		f.Write(content)
+	}
+	zw.Close()
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write cbz: %v", err)
+	}
+
+	w, err := CBZToEPUB(name, ComicOptions{Identifier: "urn:uuid:comic"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+
+	if r.Title() != "Ninja 4" || r.Author() != "Mangaka" || r.Language() != "ja" {
+		t.Errorf("expected metadata from ComicInfo.xml, got %q, %q, %q", r.Title(), r.Author(), r.Language())
+	}
+
+	if !r.IsFixedLayout() || r.RenditionProperties().Spread != pkg.SpreadLandscape {
+		t.Errorf("unexpected rendition properties %+v", r.RenditionProperties())
+	}
+
+	if direction := r.CurrentSelectedPackage().Spine.PageProgressionDirection; direction != pkg.SpineDirectionRTL {
+		t.Errorf("expected rtl page progression, got %q", direction)
+	}
+
+	spreads := []string{}
+	for _, rendition := range r.SpineRenditions() {
+		spreads = append(spreads, rendition.PageSpread)
+	}
+	expectedSpreads := []string{pkg.PageSpreadLeft, pkg.PageSpreadCenter, pkg.PageSpreadRight, pkg.PageSpreadLeft}
+	if strings.Join(spreads, ",") != strings.Join(expectedSpreads, ",") {
+		t.Errorf("expected spreads %v, got %v", expectedSpreads, spreads)
+	}
+
+	viewport, ok := r.ContentViewport("page-0002.xhtml")
+	if !ok || viewport != (Viewport{Width: 300, Height: 150}) {
+		t.Errorf("unexpected viewport of the double page %+v", viewport)
+	}
+
+	if page := string(r.SelectResourceById("page-0001.xhtml").Content); !strings.Contains(page, `src="../images/page-0001.png"`) {
+		t.Errorf("expected the first page to show the cover image, got %s", page)
+	}
+
+	if cover := r.SelectResourceById("page-0001.png"); cover == nil || cover.Properties != pkg.CoverImageProperty {
+		t.Errorf("expected the first image to be the cover, got %+v", cover)
+	}
+
+	toc, err := r.TableOfContents()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(toc.Items) != 4 || toc.Items[0].Title != "Cover" || toc.Items[3].Title != "Page 4" {
+		t.Errorf("unexpected toc %+v", toc.Items)
+	}
+
+	if pages := r.PageList(); len(pages) != 4 || pages[3].Href != "text/page-0004.xhtml" {
+		t.Errorf("unexpected page list %+v", pages)
+	}
+}
+
+func TestImageDirToEPUB(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"001.png", "002.png", "010.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), testPNG(t, 10, 20), 0o644); err != nil {
+			t.Fatalf("failed to write page: %v", err)
+		}
+	}
+
+	w, err := ImageDirToEPUB(dir, ComicOptions{Identifier: "urn:uuid:dir", Title: "Pictures"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+
+	spreads := []string{}
+	for _, rendition := range r.SpineRenditions() {
+		spreads = append(spreads, rendition.PageSpread)
+	}
+	if strings.Join(spreads, ",") != "right,left,right" {
+		t.Errorf("unexpected ltr spreads %v", spreads)
+	}
+
+	if _, err := ImageDirToEPUB(t.TempDir(), ComicOptions{Identifier: "urn:uuid:empty"}); err == nil {
+		t.Errorf("expected error for a folder without images")
+	}
+}
+
+func TestNaturalCompare(t *testing.T) {
+	names := []string{"Page10.png", "page2.png", "page1.png", "page02b.png", "cover.png"}
+	pages := []ComicPage{}
+	for _, name := range names {
+		pages = append(pages, ComicPage{Name: name})
+	}
+	sortComicPages(pages)
+
+	sorted := []string{}
+	for _, page := range pages {
+		sorted = append(sorted, page.Name)
+	}
+
+	expected := "cover.png,page1.png,page2.png,page02b.png,Page10.png"
+	if strings.Join(sorted, ",") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(sorted, ","))
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"image"
 	"path/filepath"
-	"regexp"
 	"slices"
 	"strings"
 	"unicode"
@@ -29,8 +28,15 @@ import (
 // SelectPackageRendition changes the active package rendition by its
 // rendition identifier. Useful when multiple reading layouts are available.
 func (r *Reader) SelectPackageRendition(rendition string) {
+	if _, ok := r.epub.packagePubs[rendition]; !ok {
+		return
+	}
+
 	r.epub.rendition = rendition
+	r.epub.resources = nil
+	r.epub.navigationCenterEXtended = nil
 	r.parseResources()
+	r.parseMetadata()
 }
 
 // CurrentSelectedPackage returns the currently active package rendition.
@@ -139,29 +145,34 @@ func extractTitle(node *html.Node) string {
 	return title
 }
 
-func getTextByEpubType(node *html.Node, attributeValue string) (text string) {
-	for desc := range node.Descendants() {
-		attrIndex := slices.IndexFunc(desc.Attr, func(attr html.Attribute) bool {
-			matched, _ := regexp.MatchString(attributeValue, attr.Val)
-			return attr.Key == "epub:type" && matched
-		})
-
-		if attrIndex > -1 {
-			text = desc.FirstChild.Data
-		}
+// getTextByEpubType returns the text of the first element of node carrying
+// any of the given semantics.
+func getTextByEpubType(node *html.Node, semantics ...Semantic) (text string) {
+	prefixes := semanticPrefixes(node)
+	found := FindNode(node, func(n *html.Node) bool {
+		return hasAnySemantic(n, prefixes, semantics)
+	})
+	if found != nil {
+		text = GetTextContent(found)
 	}
 	return
 }
 
 // ContentDocumentMarkdown returns content documents converted into Markdown
-// form. The returned map is keyed by EPUB manifest item ID.
+// form. The returned map is keyed by EPUB manifest item ID. Elements
+// with display none are left out, and italic or bold faces given by style
+// sheets are rendered as emphasis.
 func (r *Reader) ContentDocumentMarkdown() (documents map[string]string) {
 	resourcesHtml := r.ContentDocumentXHTML()
 	documents = make(map[string]string)
+	styles := r.styleSource()
 
 	for resId, res := range resourcesHtml {
 		frontMatters := ""
 		title := extractTitle(res)
+		if resource := r.SelectResourceById(resId); resource != nil {
+			NewStyledDocument(res, styles.documentStyleSheets(*resource, res)).applyTextStyles()
+		}
 		cleanedHTML := cleanupHTML(res)
 		if title != "" {
 			frontMatters = fmt.Sprintf(`---
@@ -202,6 +213,7 @@ func (r *Reader) ReadContentHTMLById(id string) (doc *html.Node) {
 // ReadContentHTMLByHref returns the content document associated with the given
 // manifest href. The returned document is parsed into an html.Node tree.
 func (r *Reader) ReadContentHTMLByHref(href string) (doc *html.Node) {
+	href, _, _ = strings.Cut(href, "#")
 	contentIndex := slices.IndexFunc(r.epub.resources, func(r PublicationResource) bool {
 		return r.Href == href
 	})
@@ -321,6 +333,7 @@ func (r *Reader) ImageResources() (images map[string][]byte) {
 }
 
 // Spine returns publication's spines, ordered resources like table of contents.
+// Items are returned as declared; use RenderableSpine to resolve fallbacks.
 func (r *Reader) Spine() (orderedResources []PublicationResource) {
 	spineItems := r.CurrentSelectedPackage().Spine.ItemRefs
 	orderedResources = make([]PublicationResource, 0, len(spineItems))
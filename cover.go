@@ -0,0 +1,286 @@
+package epub
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+)
+
+// CoverSource tells how the cover of a publication was found.
+type CoverSource string
+
+// Cover detection methods, in the order they are tried.
+const (
+	// CoverFromProperty is the manifest item with the cover-image property.
+	CoverFromProperty CoverSource = "cover-image"
+	// CoverFromMeta is the manifest item named by the EPUB 2
+	// <meta name="cover"> element.
+	CoverFromMeta CoverSource = "meta"
+	// CoverFromGuide is the image of the guide cover reference.
+	CoverFromGuide CoverSource = "guide"
+	// CoverFromLandmark is the image of the cover landmark of the
+	// navigation document.
+	CoverFromLandmark CoverSource = "landmark"
+	// CoverFromSpine is the first image of the first spine item.
+	CoverFromSpine CoverSource = "spine"
+)
+
+// ErrNoCover is returned when a publication has no cover image.
+var ErrNoCover = errors.New("publication has no cover image")
+
+// CoverInfo describes the cover image of a publication.
+type CoverInfo struct {
+	// Resource is the manifest item of the cover image. Content and
+	// MediaType are its original bytes and media type.
+	Resource  PublicationResource
+	Content   []byte
+	MediaType string
+	Source    CoverSource
+
+	// Page is the XHTML cover page displaying the image, when there is one.
+	Page *PublicationResource
+}
+
+// IsSVG reports whether the cover is an SVG image, which Cover cannot
+// decode.
+func (c CoverInfo) IsSVG() bool {
+	return c.MediaType == pkg.MediaTypeSVG
+}
+
+// CoverInfo finds the cover image of the publication with the cover-image
+// manifest property, the EPUB 2 cover meta, the guide cover reference, the
+// cover landmark and the first spine item, in that order. ErrNoCover is
+// returned when none of them leads to an image.
+func (r *Reader) CoverInfo() (info *CoverInfo, err error) {
+	for _, find := range []func() (*PublicationResource, *PublicationResource, CoverSource){
+		r.coverFromProperty,
+		r.coverFromMeta,
+		r.coverFromGuide,
+		r.coverFromLandmark,
+		r.coverFromSpine,
+	} {
+		cover, page, source := find()
+		if cover == nil {
+			continue
+		}
+
+		if page == nil {
+			page = r.coverPage(cover)
+		}
+		return &CoverInfo{
+			Resource:  *cover,
+			Content:   cover.Content,
+			MediaType: cover.MIMEType,
+			Source:    source,
+			Page:      page,
+		}, nil
+	}
+	return nil, ErrNoCover
+}
+
+func (r *Reader) coverFromProperty() (cover *PublicationResource, page *PublicationResource, source CoverSource) {
+	for _, res := range r.epub.resources {
+		if hasManifestProperty(res.Properties, pkg.CoverImageProperty) && isImageResource(&res) {
+			return &res, nil, CoverFromProperty
+		}
+	}
+	return
+}
+
+func (r *Reader) coverFromMeta() (cover *PublicationResource, page *PublicationResource, source CoverSource) {
+	for _, meta := range r.CurrentSelectedPackage().Metadata.Meta {
+		if meta.Name != "cover" || meta.Content == "" {
+			continue
+		}
+
+		// Some publications name the image by href instead of ID.
+		cover = r.SelectResourceById(meta.Content)
+		if cover == nil {
+			cover = r.resourceByPackageHref(meta.Content)
+		}
+		if isImageResource(cover) {
+			return cover, nil, CoverFromMeta
+		}
+	}
+	return nil, nil, source
+}
+
+func (r *Reader) coverFromGuide() (cover *PublicationResource, page *PublicationResource, source CoverSource) {
+	guide := r.CurrentSelectedPackage().Guide
+	if guide == nil {
+		return
+	}
+
+	for _, ref := range guide.References {
+		if ref.Type != pkg.GuideRefCover {
+			continue
+		}
+		if cover, page = r.coverAt(ref.Href); cover != nil {
+			return cover, page, CoverFromGuide
+		}
+	}
+	return
+}
+
+func (r *Reader) coverFromLandmark() (cover *PublicationResource, page *PublicationResource, source CoverSource) {
+	navigation := r.Navigation("landmarks")
+	if navigation == nil {
+		return
+	}
+
+	for _, item := range navigation.Items {
+		if !slices.Contains(strings.Fields(item.Type), "cover") {
+			continue
+		}
+		if cover, page = r.coverAt(item.Href); cover != nil {
+			return cover, page, CoverFromLandmark
+		}
+	}
+	return
+}
+
+func (r *Reader) coverFromSpine() (cover *PublicationResource, page *PublicationResource, source CoverSource) {
+	spine := r.Spine()
+	if len(spine) == 0 {
+		return
+	}
+
+	if cover, page = r.coverAt(spine[0].Href); cover != nil {
+		return cover, page, CoverFromSpine
+	}
+	return
+}
+
+// coverAt returns the image at a package relative href, which is either
+// the image itself or a page displaying it.
+func (r *Reader) coverAt(href string) (cover *PublicationResource, page *PublicationResource) {
+	res := r.resourceByPackageHref(href)
+	if res == nil {
+		return
+	}
+	if isImageResource(res) {
+		return res, nil
+	}
+	if cover = r.pageImage(res); cover != nil {
+		page = res
+	}
+	return
+}
+
+// coverPage returns the first spine item displaying cover, when it is the
+// first linear one or the guide or landmarks point at it.
+func (r *Reader) coverPage(cover *PublicationResource) *PublicationResource {
+	var candidates []string
+	if landmark := r.Landmark("cover"); landmark != nil {
+		candidates = append(candidates, landmark.Href)
+	}
+	if spine := r.LinearSpine(); len(spine) > 0 {
+		candidates = append(candidates, spine[0].Href)
+	}
+
+	for _, href := range candidates {
+		page := r.resourceByPackageHref(href)
+		if page == nil || page.MIMEType != pkg.MediaTypeXHTML {
+			continue
+		}
+		if image := r.pageImage(page); image != nil && image.ID == cover.ID {
+			return page
+		}
+	}
+	return nil
+}
+
+// pageImage returns the first image displayed by an XHTML page, with an
+// img element or an SVG image element.
+func (r *Reader) pageImage(page *PublicationResource) *PublicationResource {
+	if page.MIMEType != pkg.MediaTypeXHTML && page.MIMEType != pkg.MediaTypeSVG {
+		return nil
+	}
+
+	doc, err := parseXHTML(page.Content)
+	if err != nil {
+		return nil
+	}
+
+	imageNode := FindNode(doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && imageHref(n) != ""
+	})
+	if imageNode == nil {
+		return nil
+	}
+
+	containerPath, _ := resolveHref(path.Dir(unescapePath(page.Filepath)), unescapePath(imageHref(imageNode)))
+	res := r.resourceByPath(containerPath)
+	if !isImageResource(res) {
+		return nil
+	}
+	return res
+}
+
+// imageHref returns the image referenced by an img element or an SVG
+// image element.
+func imageHref(n *html.Node) string {
+	switch n.Data {
+	case "img":
+		return getAttribute(n, "src")
+	case "image":
+		for _, attr := range n.Attr {
+			if attr.Key == "href" || attr.Key == "xlink:href" {
+				return attr.Val
+			}
+		}
+	}
+	return ""
+}
+
+func (r *Reader) resourceByPackageHref(href string) *PublicationResource {
+	containerPath, _ := resolveHref(path.Dir(r.CurrentSelectedPackagePath()), unescapePath(href))
+	return r.resourceByPath(containerPath)
+}
+
+func (r *Reader) resourceByPath(containerPath string) *PublicationResource {
+	if containerPath == "" {
+		return nil
+	}
+	for _, res := range r.epub.resources {
+		if unescapePath(res.Filepath) == containerPath {
+			return &res
+		}
+	}
+	return nil
+}
+
+func isImageResource(res *PublicationResource) bool {
+	return res != nil && slices.Contains(pkg.ImageMediaTypes, res.MIMEType)
+}
+
+// Cover returns the publication's cover image if present. SVG covers are
+// not decoded; use CoverInfo to read them.
+func (r *Reader) Cover() (cover *image.Image) {
+	info, err := r.CoverInfo()
+	if err != nil || info.IsSVG() {
+		return nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(info.Content))
+	if err != nil {
+		return nil
+	}
+	return &img
+}
+
+// CoverBytes returns the original bytes of the cover image. ErrNoCover is
+// returned if the publication does not define a cover.
+func (r *Reader) CoverBytes() (cover []byte, err error) {
+	info, err := r.CoverInfo()
+	if err != nil {
+		return nil, err
+	}
+	return info.Content, nil
+}
@@ -0,0 +1,125 @@
+package epub
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+func TestReader_CoverInfo(t *testing.T) {
+	t.Run("cover-image property", func(t *testing.T) {
+		r := newTestReader(t, testEPUB3Files)
+		info, err := r.CoverInfo()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Source != CoverFromProperty || info.Resource.ID != "cover" || info.MediaType != pkg.MediaTypePNG || info.Page != nil {
+			t.Errorf("unexpected cover %+v", info)
+		}
+	})
+
+	t.Run("EPUB 2 meta", func(t *testing.T) {
+		r := newTestReader(t, testEPUB2Files)
+		info, err := r.CoverInfo()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Source != CoverFromMeta || info.Resource.ID != "cover-image" || string(info.Content) != testEPUB2Files["OEBPS/images/cover.png"] {
+			t.Errorf("unexpected cover %+v", info)
+		}
+	})
+
+	t.Run("guide cover page", func(t *testing.T) {
+		files := maps.Clone(testEPUB2Files)
+		opf := strings.Replace(files["OEBPS/content.opf"], `<meta name="cover" content="cover-image"/>`, "", 1)
+		opf = strings.Replace(opf, "</manifest>", `<item id="cover-page" href="text/cover.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>`, 1)
+		files["OEBPS/content.opf"] = strings.Replace(opf, "<guide>", `<guide>
+    <reference type="cover" title="Cover" href="text/cover.xhtml"/>`, 1)
+		files["OEBPS/text/cover.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:xlink="http://www.w3.org/1999/xlink"><head><title>Cover</title></head>
+<body><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 600 800"><image width="600" height="800" xlink:href="../images/cover.png"/></svg></body></html>`
+
+		r := newTestReader(t, files)
+		info, err := r.CoverInfo()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Source != CoverFromGuide || info.Resource.ID != "cover-image" || info.Page == nil || info.Page.ID != "cover-page" {
+			t.Errorf("unexpected cover %+v", info)
+		}
+	})
+
+	t.Run("first spine image", func(t *testing.T) {
+		files := maps.Clone(testEPUB2Files)
+		files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], `<meta name="cover" content="cover-image"/>`, "", 1)
+		files["OEBPS/text/chapter-1.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Cover</title></head><body><img src="../images/cover.png" alt="Cover"/></body></html>`
+
+		r := newTestReader(t, files)
+		info, err := r.CoverInfo()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Source != CoverFromSpine || info.Resource.ID != "cover-image" || info.Page == nil || info.Page.ID != "chapter-1" {
+			t.Errorf("unexpected cover %+v", info)
+		}
+	})
+
+	t.Run("SVG cover", func(t *testing.T) {
+		files := maps.Clone(testEPUB3Files)
+		files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], `href="images/cover.png" media-type="image/png"`, `href="images/cover.svg" media-type="image/svg+xml"`, 1)
+		files["OEBPS/images/cover.svg"] = `<svg xmlns="http://www.w3.org/2000/svg" width="600" height="800"/>`
+
+		r := newTestReader(t, files)
+		info, err := r.CoverInfo()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !info.IsSVG() || string(info.Content) != files["OEBPS/images/cover.svg"] {
+			t.Errorf("unexpected cover %+v", info)
+		}
+		if cover := r.Cover(); cover != nil {
+			t.Errorf("expected SVG covers not to be decoded")
+		}
+	})
+
+	t.Run("no cover", func(t *testing.T) {
+		files := maps.Clone(testEPUB2Files)
+		files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], `<meta name="cover" content="cover-image"/>`, "", 1)
+
+		r := newTestReader(t, files)
+		if _, err := r.CoverInfo(); !errors.Is(err, ErrNoCover) {
+			t.Errorf("expected ErrNoCover, got %v", err)
+		}
+		if cover, err := r.CoverBytes(); cover != nil || !errors.Is(err, ErrNoCover) {
+			t.Errorf("expected ErrNoCover, got %v", err)
+		}
+		if cover := r.Cover(); cover != nil {
+			t.Errorf("expected no cover")
+		}
+	})
+}
+
+func TestWriter_Cover(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	w := New("urn:uuid:jpeg-cover")
+	w.Cover(buf.Bytes())
+	if res := w.resourceByHref("images/cover.jpeg"); res == nil || res.MIMEType != pkg.MediaTypeJPEG {
+		t.Errorf("expected a JPEG cover, got %+v", res)
+	}
+
+	w = New("urn:uuid:svg-cover")
+	w.Cover([]byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="6" height="8"/>`))
+	if res := w.resourceByHref("images/cover.svg"); res == nil || res.MIMEType != pkg.MediaTypeSVG {
+		t.Errorf("expected an SVG cover, got %+v", res)
+	}
+}
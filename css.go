@@ -0,0 +1,711 @@
+package epub
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+)
+
+// StyleSheet is a parsed CSS style sheet. Parsing is forgiving: malformed
+// rules are skipped, as reading systems do.
+type StyleSheet struct {
+	// Href and ResourceID identify the style sheet in the manifest. For
+	// style elements they identify the content document. Both are empty
+	// for style sheets parsed with ParseCSS.
+	Href       string `json:"href,omitempty"`
+	ResourceID string `json:"resourceId,omitempty"`
+
+	// Media is the media query of the link or style element, or of the
+	// @import rule, the style sheet applies to. Empty means all media.
+	Media string `json:"media,omitempty"`
+
+	// Imports are the urls of the @import rules, in order.
+	Imports []string `json:"imports,omitempty"`
+
+	Rules      []CSSRule      `json:"rules"`
+	FontFaces  []FontFace     `json:"fontFaces,omitempty"`
+	References []CSSReference `json:"references,omitempty"`
+
+	imports []cssImport
+}
+
+type cssImport struct {
+	url   string
+	media string
+}
+
+// CSSRule is a style rule.
+type CSSRule struct {
+	Selector string `json:"selector"`
+
+	// Media is the query of the enclosing @media rules, joined with
+	// "and". Empty outside @media.
+	Media string `json:"media,omitempty"`
+
+	Declarations []CSSDeclaration `json:"declarations"`
+}
+
+// CSSDeclaration is a property declaration. Property is lowercased.
+type CSSDeclaration struct {
+	Property  string `json:"property"`
+	Value     string `json:"value"`
+	Important bool   `json:"important,omitempty"`
+}
+
+// CSSReference is a url of an @import rule or of a declaration.
+type CSSReference struct {
+	// StyleSheet is the href of the style sheet, or of the content
+	// document for style elements and attributes.
+	StyleSheet string `json:"styleSheet,omitempty"`
+
+	// Property is the property of the declaration, or "@import".
+	Property string `json:"property"`
+	URL      string `json:"url"`
+
+	// Path is the container path the url resolves to, empty for remote
+	// urls, data urls and fragment identifiers. Href and ResourceID
+	// identify the manifest item at Path, and are empty when it is missing.
+	Path       string `json:"path,omitempty"`
+	Href       string `json:"href,omitempty"`
+	ResourceID string `json:"resourceId,omitempty"`
+}
+
+// Missing reports whether the reference points at a container path that
+// is not in the manifest.
+func (ref CSSReference) Missing() bool {
+	return ref.Path != "" && ref.ResourceID == ""
+}
+
+// CSSReferenceError reports a style sheet reference to a resource missing
+// from the manifest.
+type CSSReferenceError struct {
+	Reference CSSReference
+}
+
+func (e *CSSReferenceError) Error() string {
+	return fmt.Sprintf("style sheet %q references missing resource %q in %s", e.Reference.StyleSheet, e.Reference.URL, e.Reference.Property)
+}
+
+var cssImportantPattern = regexp.MustCompile(`(?i)!\s*important\s*$`)
+
+// ParseCSS parses a style sheet. Urls are left unresolved.
+func ParseCSS(css string) *StyleSheet {
+	sheet := &StyleSheet{Rules: []CSSRule{}}
+	sheet.parseRules(stripCSSComments(css), "")
+	return sheet
+}
+
+// ParseCSSDeclarations parses a declaration block, such as the value of a
+// style attribute.
+func ParseCSSDeclarations(block string) (declarations []CSSDeclaration) {
+	for _, declaration := range splitDeclarations(stripCSSComments(block)) {
+		property, value, found := strings.Cut(declaration, ":")
+		property = strings.TrimSpace(property)
+		if !found || property == "" {
+			continue
+		}
+		if !strings.HasPrefix(property, "--") {
+			property = strings.ToLower(property)
+		}
+
+		value = strings.TrimSpace(value)
+		important := false
+		if loc := cssImportantPattern.FindStringIndex(value); loc != nil {
+			value, important = strings.TrimSpace(value[:loc[0]]), true
+		}
+		declarations = append(declarations, CSSDeclaration{Property: property, Value: value, Important: important})
+	}
+	return
+}
+
+func (sheet *StyleSheet) parseRules(css string, media string) {
+	for i := 0; i < len(css); {
+		for i < len(css) && (isCSSSpace(css[i]) || css[i] == '}') {
+			i++
+		}
+		for _, marker := range []string{"<!--", "-->"} {
+			if strings.HasPrefix(css[i:], marker) {
+				i += len(marker)
+			}
+		}
+		if i >= len(css) {
+			return
+		}
+
+		end := scanCSS(css, i, "{;")
+		prelude := strings.TrimSpace(css[i:end])
+		if end == len(css) {
+			return
+		}
+		if css[end] == ';' {
+			if prelude != "" {
+				sheet.parseAtStatement(prelude, media)
+			}
+			i = end + 1
+			continue
+		}
+
+		closing := matchingBrace(css, end)
+		body := css[end+1 : closing]
+		i = closing + 1
+
+		if strings.HasPrefix(prelude, "@") {
+			sheet.parseAtRule(prelude, body, media)
+			continue
+		}
+
+		rule := CSSRule{
+			Selector:     strings.Join(strings.Fields(prelude), " "),
+			Media:        media,
+			Declarations: ParseCSSDeclarations(body),
+		}
+		sheet.Rules = append(sheet.Rules, rule)
+		sheet.addReferences(rule.Declarations)
+	}
+}
+
+// atKeyword splits an at-rule prelude into its lowercased keyword and the
+// rest.
+func atKeyword(prelude string) (keyword string, rest string) {
+	if prelude == "" {
+		return "", ""
+	}
+	end := 1
+	for end < len(prelude) && isIdentChar(prelude[end]) && prelude[end] != '\\' {
+		end++
+	}
+	return strings.ToLower(prelude[:end]), strings.TrimSpace(prelude[end:])
+}
+
+func (sheet *StyleSheet) parseAtStatement(prelude string, media string) {
+	keyword, rest := atKeyword(prelude)
+	if keyword != "@import" {
+		return
+	}
+
+	var importURL string
+	if urls := cssURLs(rest); len(urls) > 0 {
+		importURL = urls[0]
+		rest = rest[strings.Index(rest, ")")+1:]
+	} else if len(rest) > 0 && (rest[0] == '"' || rest[0] == '\'') {
+		end := strings.IndexByte(rest[1:], rest[0])
+		if end < 0 {
+			return
+		}
+		importURL, rest = rest[1:end+1], rest[end+2:]
+	}
+	if importURL == "" {
+		return
+	}
+
+	sheet.Imports = append(sheet.Imports, importURL)
+	sheet.imports = append(sheet.imports, cssImport{url: importURL, media: joinMedia(media, strings.TrimSpace(rest))})
+	sheet.References = append(sheet.References, CSSReference{Property: "@import", URL: importURL})
+}
+
+func (sheet *StyleSheet) parseAtRule(prelude string, body string, media string) {
+	keyword, rest := atKeyword(prelude)
+	switch keyword {
+	case "@media":
+		sheet.parseRules(body, joinMedia(media, rest))
+	case "@supports", "@document", "@-moz-document", "@layer", "@container":
+		sheet.parseRules(body, media)
+	case "@font-face":
+		declarations := ParseCSSDeclarations(body)
+		sheet.addReferences(declarations)
+
+		var face FontFace
+		for _, declaration := range declarations {
+			switch declaration.Property {
+			case "font-family":
+				face.Family = unquoteCSS(declaration.Value)
+			case "font-style":
+				face.Style = declaration.Value
+			case "font-weight":
+				face.Weight = declaration.Value
+			case "unicode-range":
+				face.UnicodeRange = declaration.Value
+			case "src":
+				face.Src = cssURLs(declaration.Value)
+			}
+		}
+		if face.Family != "" {
+			sheet.FontFaces = append(sheet.FontFaces, face)
+		}
+	}
+}
+
+func (sheet *StyleSheet) addReferences(declarations []CSSDeclaration) {
+	for _, declaration := range declarations {
+		for _, u := range cssURLs(declaration.Value) {
+			sheet.References = append(sheet.References, CSSReference{Property: declaration.Property, URL: u})
+		}
+	}
+}
+
+func joinMedia(outer string, inner string) string {
+	switch {
+	case outer == "":
+		return inner
+	case inner == "":
+		return outer
+	}
+	return outer + " and " + inner
+}
+
+// mediaApplies reports whether a media query list applies to a reading
+// system screen. Features are assumed to match.
+func mediaApplies(query string) bool {
+	if strings.TrimSpace(query) == "" {
+		return true
+	}
+
+	for _, part := range strings.Split(strings.ToLower(query), ",") {
+		words := strings.Fields(strings.ReplaceAll(part, "(", " ("))
+		negated := len(words) > 0 && words[0] == "not"
+		if len(words) > 0 && (words[0] == "not" || words[0] == "only") {
+			words = words[1:]
+		}
+
+		mediaType := "all"
+		if len(words) > 0 && !strings.HasPrefix(words[0], "(") {
+			mediaType = words[0]
+		}
+		if (mediaType == "all" || mediaType == "screen") != negated {
+			return true
+		}
+	}
+	return false
+}
+
+func isCSSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// scanCSS returns the index of the first byte of stops found outside
+// strings and parentheses from start, or len(css).
+func scanCSS(css string, start int, stops string) int {
+	depth := 0
+	for i := start; i < len(css); i++ {
+		switch c := css[i]; {
+		case c == '\\':
+			i++
+		case c == '"' || c == '\'':
+			i = skipCSSString(css, i)
+		case c == '(':
+			depth++
+		case c == ')':
+			depth = max(0, depth-1)
+		case depth == 0 && strings.IndexByte(stops, c) >= 0:
+			return i
+		}
+	}
+	return len(css)
+}
+
+// skipCSSString returns the index of the quote closing the string opened
+// at start, or the end of the line for unterminated strings.
+func skipCSSString(css string, start int) int {
+	for i := start + 1; i < len(css); i++ {
+		switch css[i] {
+		case '\\':
+			i++
+		case css[start], '\n':
+			return i
+		}
+	}
+	return len(css) - 1
+}
+
+// matchingBrace returns the index of the brace closing the block opened at
+// open, or len(css) for unterminated blocks.
+func matchingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch css[i] {
+		case '\\':
+			i++
+		case '"', '\'':
+			i = skipCSSString(css, i)
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(css)
+}
+
+func stripCSSComments(css string) string {
+	if !strings.Contains(css, "/*") {
+		return css
+	}
+
+	var stripped strings.Builder
+	for i := 0; i < len(css); i++ {
+		switch {
+		case css[i] == '"' || css[i] == '\'':
+			end := skipCSSString(css, i)
+			stripped.WriteString(css[i:min(end+1, len(css))])
+			i = end
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				return stripped.String()
+			}
+			stripped.WriteByte(' ')
+			i += end + 3
+		default:
+			stripped.WriteByte(css[i])
+		}
+	}
+	return stripped.String()
+}
+
+// splitDeclarations splits a declaration block on the semicolons outside
+// strings and parentheses.
+func splitDeclarations(block string) (declarations []string) {
+	for start := 0; start < len(block); {
+		end := scanCSS(block, start, ";")
+		declarations = append(declarations, block[start:end])
+		start = end + 1
+	}
+	return
+}
+
+func unquoteCSS(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// cssURLs returns the urls of the url() functions of a value.
+func cssURLs(value string) (urls []string) {
+	lower := strings.ToLower(value)
+	for i := 0; ; {
+		found := strings.Index(lower[i:], "url(")
+		if found < 0 {
+			return
+		}
+		start := i + found + len("url(")
+		if i+found > 0 && isIdentChar(lower[i+found-1]) {
+			i = start
+			continue
+		}
+
+		for start < len(value) && isCSSSpace(value[start]) {
+			start++
+		}
+		end := strings.IndexByte(value[start:], ')')
+		if start < len(value) && (value[start] == '"' || value[start] == '\'') {
+			end = skipCSSString(value, start) - start
+			if end < 1 || value[start+end] != value[start] {
+				return
+			}
+			if u := value[start+1 : start+end]; u != "" {
+				urls = append(urls, u)
+			}
+			i = start + end + 1
+			continue
+		}
+		if end < 0 {
+			return
+		}
+		if u := strings.TrimSpace(value[start : start+end]); u != "" {
+			urls = append(urls, u)
+		}
+		i = start + end + 1
+	}
+}
+
+// cssStrings returns the content of the strings of a value.
+func cssStrings(value string) (strs []string) {
+	for i := 0; i < len(value); i++ {
+		if value[i] != '"' && value[i] != '\'' {
+			continue
+		}
+		end := skipCSSString(value, i)
+		strs = append(strs, value[i+1:min(end, len(value))])
+		i = end
+	}
+	return
+}
+
+// isLocalURL reports whether a url points at a file of the container.
+func isLocalURL(u string) bool {
+	if u == "" || strings.HasPrefix(u, "#") {
+		return false
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return !strings.Contains(u, ":")
+	}
+	return parsed.Scheme == "" && parsed.Host == ""
+}
+
+// styleSource resolves style sheets against the resources of a Reader or
+// a Writer.
+type styleSource struct {
+	resources []PublicationResource
+	sheets    map[string]*StyleSheet
+}
+
+// resourceAt returns the resource an href found in baseDir points at.
+func (source *styleSource) resourceAt(baseDir string, href string) *PublicationResource {
+	containerPath, _ := resolveHref(baseDir, href)
+	return source.resourceByPath(unescapePath(containerPath))
+}
+
+func (source *styleSource) resourceByPath(containerPath string) *PublicationResource {
+	for i, res := range source.resources {
+		if unescapePath(res.Filepath) == containerPath {
+			return &source.resources[i]
+		}
+	}
+	return nil
+}
+
+// resolve resolves the urls of a style sheet found in baseDir.
+func (source *styleSource) resolve(sheet *StyleSheet, baseDir string, res *PublicationResource) {
+	if res != nil {
+		sheet.Href, sheet.ResourceID = res.Href, res.ID
+	}
+
+	for i := range sheet.References {
+		sheet.References[i] = source.resolveReference(sheet.References[i], baseDir, sheet.Href)
+	}
+	for i, face := range sheet.FontFaces {
+		sheet.FontFaces[i].StyleSheet = sheet.Href
+		for _, src := range face.Src {
+			if font := source.resourceAt(baseDir, src); isLocalURL(src) && isFontResource(font) {
+				sheet.FontFaces[i].ResourceID, sheet.FontFaces[i].Href = font.ID, font.Href
+				break
+			}
+		}
+	}
+}
+
+func (source *styleSource) resolveReference(ref CSSReference, baseDir string, styleSheet string) CSSReference {
+	ref.StyleSheet = styleSheet
+	if !isLocalURL(ref.URL) {
+		return ref
+	}
+
+	containerPath, _ := resolveHref(baseDir, ref.URL)
+	ref.Path = unescapePath(containerPath)
+	if res := source.resourceByPath(ref.Path); res != nil {
+		ref.Href, ref.ResourceID = res.Href, res.ID
+	}
+	return ref
+}
+
+// styleSheet returns the resolved style sheet resource at containerPath,
+// or nil when there is none.
+func (source *styleSource) styleSheet(containerPath string) *StyleSheet {
+	if sheet, found := source.sheets[containerPath]; found {
+		return sheet
+	}
+
+	var sheet *StyleSheet
+	if res := source.resourceByPath(containerPath); res != nil && res.MIMEType == pkg.MediaTypeCSS {
+		sheet = ParseCSS(string(res.Content))
+		source.resolve(sheet, path.Dir(containerPath), res)
+	}
+
+	if source.sheets == nil {
+		source.sheets = make(map[string]*StyleSheet)
+	}
+	source.sheets[containerPath] = sheet
+	return sheet
+}
+
+// appendImported appends the style sheets imported by sheet, then sheet,
+// in cascade order. Style sheets already seen are skipped.
+func (source *styleSource) appendImported(sheets []*StyleSheet, sheet *StyleSheet, baseDir string, seen map[string]bool) []*StyleSheet {
+	for _, imported := range sheet.imports {
+		containerPath, _ := resolveHref(baseDir, imported.url)
+		containerPath = unescapePath(containerPath)
+		if !isLocalURL(imported.url) || seen[containerPath] {
+			continue
+		}
+		seen[containerPath] = true
+
+		importedSheet := source.styleSheet(containerPath)
+		if importedSheet == nil {
+			continue
+		}
+		if imported.media != "" {
+			withMedia := *importedSheet
+			withMedia.Media = joinMedia(sheet.Media, imported.media)
+			importedSheet = &withMedia
+		}
+		sheets = source.appendImported(sheets, importedSheet, path.Dir(containerPath), seen)
+	}
+	return append(sheets, sheet)
+}
+
+// documentStyleSheets returns the style sheets of the link and style
+// elements of a parsed content document, with their imports, in cascade
+// order. Alternate style sheets are left out.
+func (source *styleSource) documentStyleSheets(res PublicationResource, doc *html.Node) (sheets []*StyleSheet) {
+	docDir := path.Dir(unescapePath(res.Filepath))
+	seen := make(map[string]bool)
+
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			switch node.Data {
+			case "link":
+				rel := strings.Fields(strings.ToLower(getAttribute(node, "rel")))
+				href := getAttribute(node, "href")
+				if !slices.Contains(rel, "stylesheet") || slices.Contains(rel, "alternate") || !isLocalURL(href) {
+					break
+				}
+
+				containerPath, _ := resolveHref(docDir, href)
+				containerPath = unescapePath(containerPath)
+				if seen[containerPath] {
+					break
+				}
+				seen[containerPath] = true
+
+				if sheet := source.styleSheet(containerPath); sheet != nil {
+					if media := getAttribute(node, "media"); media != "" {
+						withMedia := *sheet
+						withMedia.Media = media
+						sheet = &withMedia
+					}
+					sheets = source.appendImported(sheets, sheet, path.Dir(containerPath), seen)
+				}
+			case "style":
+				sheet := ParseCSS(GetTextContent(node))
+				sheet.Media = getAttribute(node, "media")
+				source.resolve(sheet, docDir, &res)
+				sheets = source.appendImported(sheets, sheet, docDir, seen)
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return
+}
+
+// styleAttributeReferences returns the references of the style
+// attributes of a parsed content document.
+func (source *styleSource) styleAttributeReferences(res PublicationResource, doc *html.Node) (references []CSSReference) {
+	docDir := path.Dir(unescapePath(res.Filepath))
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		if style := getAttribute(node, "style"); node.Type == html.ElementNode && style != "" {
+			for _, declaration := range ParseCSSDeclarations(style) {
+				for _, u := range cssURLs(declaration.Value) {
+					ref := CSSReference{Property: declaration.Property, URL: u}
+					references = append(references, source.resolveReference(ref, docDir, res.Href))
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return
+}
+
+func (r *Reader) styleSource() *styleSource {
+	return &styleSource{resources: r.epub.resources}
+}
+
+// StyleSheet returns the parsed style sheet resource with the given
+// manifest ID, with its urls resolved.
+func (r *Reader) StyleSheet(id string) (*StyleSheet, error) {
+	res := r.SelectResourceById(id)
+	if res == nil {
+		return nil, fmt.Errorf("resource %q not found", id)
+	}
+	if res.MIMEType != pkg.MediaTypeCSS {
+		return nil, fmt.Errorf("resource %q is not a style sheet but %s", id, res.MIMEType)
+	}
+	return r.styleSource().styleSheet(unescapePath(res.Filepath)), nil
+}
+
+// StyleSheets returns the style sheets of the content document with the
+// given manifest ID in cascade order: linked style sheets and style
+// elements in document order, each preceded by the style sheets it
+// imports. Alternate style sheets are left out.
+func (r *Reader) StyleSheets(id string) ([]*StyleSheet, error) {
+	res, doc, err := r.contentDocument(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.styleSource().documentStyleSheets(*res, doc), nil
+}
+
+// contentDocument returns the XHTML or SVG content document with the given
+// manifest ID, parsed.
+func (r *Reader) contentDocument(id string) (*PublicationResource, *html.Node, error) {
+	res := r.SelectResourceById(id)
+	if res == nil {
+		return nil, nil, fmt.Errorf("resource %q not found", id)
+	}
+	if res.MIMEType != pkg.MediaTypeXHTML && res.MIMEType != pkg.MediaTypeSVG {
+		return nil, nil, fmt.Errorf("resource %q is not a content document but %s", id, res.MIMEType)
+	}
+
+	doc, err := parseXHTML(res.Content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse %q: %w", id, err)
+	}
+	return res, doc, nil
+}
+
+// CSSReferences returns the urls of the style sheet resources, and of the
+// style elements and attributes of content documents, in manifest order.
+func (r *Reader) CSSReferences() (references []CSSReference) {
+	source := r.styleSource()
+	for _, res := range r.epub.resources {
+		switch res.MIMEType {
+		case pkg.MediaTypeCSS:
+			if sheet := source.styleSheet(unescapePath(res.Filepath)); sheet != nil {
+				references = append(references, sheet.References...)
+			}
+		case pkg.MediaTypeXHTML, pkg.MediaTypeSVG:
+			doc, err := parseXHTML(res.Content)
+			if err != nil {
+				continue
+			}
+			for _, sheet := range source.documentStyleSheets(res, doc) {
+				if sheet.ResourceID == res.ID {
+					references = append(references, sheet.References...)
+				}
+			}
+			references = append(references, source.styleAttributeReferences(res, doc)...)
+		}
+	}
+	return
+}
+
+// CheckStyleSheets reports, as *CSSReferenceError, every style sheet
+// reference to a resource missing from the manifest.
+func (r *Reader) CheckStyleSheets() error {
+	var errs []error
+	for _, ref := range r.CSSReferences() {
+		if ref.Missing() {
+			errs = append(errs, &CSSReferenceError{Reference: ref})
+		}
+	}
+	return errors.Join(errs...)
+}
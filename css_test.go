@@ -0,0 +1,209 @@
+package epub
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseCSS(t *testing.T) {
+	sheet := ParseCSS(`@charset "utf-8";
+@import url("base.css") print;
+@import 'more.css';
+/* p { color: red } */
+h1, h2 { FONT-WEIGHT: bold !important; content: "a;b" }
+@media screen and (min-width: 10em) {
+  @media (orientation: portrait) { p { text-align: justify } }
+  .x { background: url(../images/bg.png) no-repeat }
+}
+@font-face { font-family: "My Font"; src: local(My), url(../fonts/my.woff2) format("woff2"), url('../fonts/my.ttf'); }
+@page { margin: 0 }
+p { }
+`)
+
+	if !slices.Equal(sheet.Imports, []string{"base.css", "more.css"}) {
+		t.Errorf("unexpected imports %q", sheet.Imports)
+	}
+	if len(sheet.imports) != 2 || sheet.imports[0].media != "print" {
+		t.Errorf("unexpected import media %+v", sheet.imports)
+	}
+
+	if len(sheet.Rules) != 4 {
+		t.Fatalf("expected four rules, got %+v", sheet.Rules)
+	}
+	if rule := sheet.Rules[0]; rule.Selector != "h1, h2" || len(rule.Declarations) != 2 {
+		t.Errorf("unexpected rule %+v", rule)
+	} else if declaration := rule.Declarations[0]; declaration != (CSSDeclaration{Property: "font-weight", Value: "bold", Important: true}) {
+		t.Errorf("unexpected declaration %+v", declaration)
+	} else if declaration := rule.Declarations[1]; declaration.Value != `"a;b"` {
+		t.Errorf("unexpected declaration %+v", declaration)
+	}
+	if rule := sheet.Rules[1]; rule.Selector != "p" || rule.Media != "screen and (min-width: 10em) and (orientation: portrait)" {
+		t.Errorf("unexpected rule %+v", rule)
+	}
+	if rule := sheet.Rules[2]; rule.Selector != ".x" || rule.Media != "screen and (min-width: 10em)" {
+		t.Errorf("unexpected rule %+v", rule)
+	}
+
+	if len(sheet.FontFaces) != 1 || sheet.FontFaces[0].Family != "My Font" || !slices.Equal(sheet.FontFaces[0].Src, []string{"../fonts/my.woff2", "../fonts/my.ttf"}) {
+		t.Errorf("unexpected font faces %+v", sheet.FontFaces)
+	}
+
+	var urls []string
+	for _, ref := range sheet.References {
+		urls = append(urls, ref.Property+" "+ref.URL)
+	}
+	expected := []string{"@import base.css", "@import more.css", "background ../images/bg.png", "src ../fonts/my.woff2", "src ../fonts/my.ttf"}
+	if !slices.Equal(urls, expected) {
+		t.Errorf("expected references %q, got %q", expected, urls)
+	}
+}
+
+func TestParseCSSMalformed(t *testing.T) {
+	sheet := ParseCSS(`@charset "utf-8";; p { color: red }; h1 { color: blue }`)
+	if len(sheet.Rules) != 2 || sheet.Rules[1].Selector != "h1" {
+		t.Errorf("expected rules around stray semicolons, got %+v", sheet.Rules)
+	}
+
+	for _, css := range []string{`x{a:url("`, `x{a:url('a`, `x{a:url(`, `@`, `;`} {
+		ParseCSS(css)
+	}
+}
+
+func FuzzParseCSS(f *testing.F) {
+	for _, css := range []string{
+		`@charset "utf-8"; @import url("base.css") print; p { color: red }`,
+		`@font-face { font-family: "A"; src: url(a.woff2) format("woff2") }`,
+		`@media screen { h1 { background: url('a.png') } }`,
+		`p{color:red};`,
+		`x{a:url("`,
+	} {
+		f.Add(css)
+	}
+	f.Fuzz(func(t *testing.T, css string) {
+		ParseCSS(css)
+	})
+}
+
+func TestMediaApplies(t *testing.T) {
+	tests := map[string]bool{
+		"":                            true,
+		"all":                         true,
+		"screen and (color)":          true,
+		"(min-width: 30em)":           true,
+		"print":                       false,
+		"not print":                   true,
+		"print, screen":               true,
+		"only amzn-kf8":               false,
+		"not screen and (monochrome)": false,
+	}
+	for query, expected := range tests {
+		if got := mediaApplies(query); got != expected {
+			t.Errorf("%q: expected %v, got %v", query, expected, got)
+		}
+	}
+}
+
+func testStyleFiles() map[string]string {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], "</manifest>", `<item id="main-css" href="styles/main.css" media-type="text/css"/>
+    <item id="base-css" href="styles/base.css" media-type="text/css"/>
+    <item id="night-css" href="styles/night.css" media-type="text/css"/>
+    <item id="bg" href="images/bg%20one.png" media-type="image/png"/>
+  </manifest>`, 1)
+	files["OEBPS/text/chapter-1.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><head><title>Chapter 1</title>
+<link rel="stylesheet" href="../styles/main.css"/>
+<link rel="alternate stylesheet" href="../styles/night.css" title="Night"/>
+<style>.aside { display: none } .quote { font-style: italic } .missing { background: url(../images/gone.png) }</style>
+</head>
+<body class="book"><section epub:type="chapter">
+<h1 class="title">Chapter <span class="n">1</span></h1>
+<p class="first">Plain <span class="quote">quoted</span> text.</p>
+<p class="aside">Hidden aside.</p>
+<div class="quote"><p>Quoted paragraph.</p><p>Second <b>bold</b>.</p></div>
+<p style="font: bold 1em/1.2 'Inline Face', serif; break-before: page" class="strong">Inline</p>
+<p hidden="">Hidden attribute.</p>
+<p class="pic" style="background: url('../images/none.png')">Picture</p>
+</section></body></html>`
+	files["OEBPS/styles/main.css"] = `@import url(base.css);
+@import "base.css";
+@media print { .first { display: none } }
+h1 { text-align: center; page-break-after: avoid }
+.title { font-family: "Title Face", serif }
+p.first { text-align: justify !important }
+.pic { background: url("../images/bg%20one.png") }
+.strong { font-weight: normal !important }`
+	files["OEBPS/styles/base.css"] = `body { font-family: Body, serif; text-align: left }
+p { text-align: right; font-family: inherit }
+.missing::after { content: url(../images/lost.png) }`
+	files["OEBPS/styles/night.css"] = `body { display: none }`
+	files["OEBPS/images/bg one.png"] = "\x89PNG\r\n\x1a\n"
+	return files
+}
+
+func TestReader_StyleSheets(t *testing.T) {
+	r := newTestReader(t, testStyleFiles())
+
+	sheets, err := r.StyleSheets("chapter-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var hrefs []string
+	for _, sheet := range sheets {
+		hrefs = append(hrefs, sheet.Href)
+	}
+	expected := []string{"styles/base.css", "styles/main.css", "text/chapter-1.xhtml"}
+	if !slices.Equal(hrefs, expected) {
+		t.Fatalf("expected style sheets %q, got %q", expected, hrefs)
+	}
+	if sheets[2].ResourceID != "chapter-1" || len(sheets[2].Rules) != 3 {
+		t.Errorf("unexpected style element %+v", sheets[2])
+	}
+
+	sheet, err := r.StyleSheet("main-css")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ref := sheet.References[2]; ref.Path != "OEBPS/images/bg one.png" || ref.ResourceID != "bg" || ref.Missing() {
+		t.Errorf("unexpected reference %+v", ref)
+	}
+
+	if _, err := r.StyleSheet("chapter-1"); err == nil {
+		t.Errorf("expected error for a content document")
+	}
+	if _, err := r.StyleSheets("main-css"); err == nil {
+		t.Errorf("expected error for a style sheet")
+	}
+}
+
+func TestReader_CheckStyleSheets(t *testing.T) {
+	r := newTestReader(t, testStyleFiles())
+
+	var missing []string
+	for _, ref := range r.CSSReferences() {
+		if ref.Missing() {
+			missing = append(missing, ref.StyleSheet+" "+ref.Property+" "+ref.Path)
+		}
+	}
+	expected := []string{
+		"text/chapter-1.xhtml background OEBPS/images/gone.png",
+		"text/chapter-1.xhtml background OEBPS/images/none.png",
+		"styles/base.css content OEBPS/images/lost.png",
+	}
+	if !slices.Equal(missing, expected) {
+		t.Errorf("expected missing references %q, got %q", expected, missing)
+	}
+
+	err := r.CheckStyleSheets()
+	var referenceErr *CSSReferenceError
+	if !errors.As(err, &referenceErr) || referenceErr.Reference.URL != "../images/gone.png" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	valid := newTestReader(t, testEPUB3Files)
+	if err := valid.CheckStyleSheets(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,467 @@
+package epub
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Dictionary types of the dictionary-type property.
+const (
+	DictionaryMonolingual  = "monolingual"
+	DictionaryBilingual    = "bilingual"
+	DictionaryMultilingual = "multilingual"
+	DictionaryThesaurus    = "thesaurus"
+)
+
+// Dictionary is a dictionary or glossary of the publication, with its
+// Search Key Maps parsed into a lookup structure.
+type Dictionary struct {
+	Title string `json:"title,omitempty"`
+
+	// Type is one of the Dictionary constants, or empty.
+	Type            string   `json:"type,omitempty"`
+	SourceLanguage  string   `json:"sourceLanguage,omitempty"`
+	TargetLanguages []string `json:"targetLanguages,omitempty"`
+	Glossary        bool     `json:"glossary,omitempty"`
+
+	Entries []DictionaryEntry `json:"entries"`
+
+	index map[string][]int
+}
+
+// DictionaryEntry is a search key group of a Search Key Map: a headword
+// and its inflected forms pointing at an entry of a content document.
+type DictionaryEntry struct {
+	Headword string   `json:"headword"`
+	Forms    []string `json:"forms,omitempty"`
+
+	// Href is relative to the package document, without fragment.
+	Href       string `json:"href"`
+	Fragment   string `json:"fragment,omitempty"`
+	ResourceID string `json:"resourceId,omitempty"`
+}
+
+// searchKeyMap is a Search Key Map document.
+type searchKeyMap struct {
+	XMLName xml.Name         `xml:"http://www.idpf.org/2007/ops search-key-map"`
+	Lang    string           `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Groups  []searchKeyGroup `xml:"search-key-group"`
+}
+
+type searchKeyGroup struct {
+	Href    string           `xml:"href,attr"`
+	Matches []searchKeyMatch `xml:"match"`
+}
+
+type searchKeyMatch struct {
+	Value  string           `xml:"value,attr"`
+	Href   string           `xml:"href,attr,omitempty"`
+	Values []searchKeyValue `xml:"value"`
+}
+
+type searchKeyValue struct {
+	Value string `xml:"value,attr"`
+}
+
+// Lookup returns the entries whose headword or one of its forms matches
+// word, ignoring case and surrounding space.
+func (d *Dictionary) Lookup(word string) (entries []DictionaryEntry) {
+	if d.index == nil {
+		d.buildIndex()
+	}
+	for _, i := range d.index[searchKey(word)] {
+		entries = append(entries, d.Entries[i])
+	}
+	return
+}
+
+func (d *Dictionary) buildIndex() {
+	d.index = map[string][]int{}
+	for i, entry := range d.Entries {
+		keys := []string{searchKey(entry.Headword)}
+		for _, form := range entry.Forms {
+			if key := searchKey(form); !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			d.index[key] = append(d.index[key], i)
+		}
+	}
+}
+
+func searchKey(word string) string {
+	return strings.ToLower(strings.TrimSpace(word))
+}
+
+// Dictionaries returns the dictionaries of the publication, one for every
+// collection with the dictionary role. A publication without dictionary
+// collections but with dc:type dictionary is treated as one dictionary
+// described by the package metadata.
+func (r *Reader) Dictionaries() (dictionaries []*Dictionary, err error) {
+	for _, collection := range r.CollectionsByRole(CollectionDictionary) {
+		dictionary := &Dictionary{Title: collection.Title()}
+		applyDictionaryMeta(dictionary, collection.Meta)
+
+		for _, link := range collection.Links {
+			res := r.SelectResourceById(link.ResourceID)
+			if res == nil || res.MIMEType != pkg.MediaTypeSearchKeyMap {
+				continue
+			}
+			if err = r.addSearchKeyMap(dictionary, res); err != nil {
+				return
+			}
+		}
+		dictionaries = append(dictionaries, dictionary)
+	}
+
+	if len(dictionaries) > 0 || !r.isDictionary() {
+		return
+	}
+
+	dictionary := &Dictionary{Title: r.Title()}
+	applyDictionaryMeta(dictionary, r.CurrentSelectedPackage().Metadata.Meta)
+	for _, res := range r.epub.resources {
+		if res.MIMEType == pkg.MediaTypeSearchKeyMap && !hasManifestProperty(res.Properties, pkg.GlossaryProperty) {
+			if err = r.addSearchKeyMap(dictionary, &res); err != nil {
+				return
+			}
+		}
+	}
+	return []*Dictionary{dictionary}, nil
+}
+
+// Glossary returns the glossary of the publication built from the Search
+// Key Maps with the glossary property, or nil when there is none.
+func (r *Reader) Glossary() (glossary *Dictionary, err error) {
+	for _, res := range r.epub.resources {
+		if res.MIMEType != pkg.MediaTypeSearchKeyMap || !hasManifestProperty(res.Properties, pkg.GlossaryProperty) {
+			continue
+		}
+
+		if glossary == nil {
+			glossary = &Dictionary{Glossary: true}
+		}
+		if err = r.addSearchKeyMap(glossary, &res); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+func (r *Reader) isDictionary() bool {
+	for _, dc := range r.CurrentSelectedPackage().Metadata.OptionalDC {
+		if strings.TrimPrefix(dc.XMLName.Local, "dc:") == "type" && strings.TrimSpace(dc.Value) == "dictionary" {
+			return true
+		}
+	}
+	return false
+}
+
+func applyDictionaryMeta(dictionary *Dictionary, meta []pkg.Meta) {
+	for _, m := range meta {
+		if m.Refines != "" {
+			continue
+		}
+
+		value := strings.TrimSpace(m.Value)
+		switch m.Property {
+		case pkg.DictionaryType:
+			dictionary.Type = value
+		case pkg.SourceLanguage:
+			dictionary.SourceLanguage = value
+		case pkg.TargetLanguage:
+			dictionary.TargetLanguages = append(dictionary.TargetLanguages, value)
+		}
+	}
+}
+
+// addSearchKeyMap parses a Search Key Map resource and appends its groups
+// to the dictionary.
+func (r *Reader) addSearchKeyMap(dictionary *Dictionary, res *PublicationResource) error {
+	var skm searchKeyMap
+	if err := xml.Unmarshal(res.Content, &skm); err != nil {
+		return fmt.Errorf("parse search key map %q: %w", res.ID, err)
+	}
+
+	skmDir := path.Dir(res.Filepath)
+	packageDir := path.Dir(r.CurrentSelectedPackagePath())
+	ids := r.resourceIDsByPath()
+
+	for _, group := range skm.Groups {
+		for _, match := range group.Matches {
+			href := match.Href
+			if href == "" {
+				href = group.Href
+			}
+			containerPath, fragment := resolveHref(skmDir, unescapePath(href))
+
+			entry := DictionaryEntry{
+				Headword:   strings.TrimSpace(match.Value),
+				Href:       relativeHref(packageDir, containerPath, ""),
+				Fragment:   fragment,
+				ResourceID: ids[containerPath],
+			}
+			for _, value := range match.Values {
+				entry.Forms = append(entry.Forms, strings.TrimSpace(value.Value))
+			}
+			dictionary.Entries = append(dictionary.Entries, entry)
+		}
+	}
+
+	dictionary.index = nil
+	return nil
+}
+
+// DictionaryDefinition is an entry of a dictionary built by
+// DictionaryToEPUB.
+type DictionaryDefinition struct {
+	Headword string
+
+	// Forms are inflected forms which also find the entry.
+	Forms []string
+
+	// Definition is XHTML markup placed in the entry after the headword.
+	Definition string
+}
+
+// DictionaryOptions describes the dictionary generated by
+// DictionaryToEPUB.
+type DictionaryOptions struct {
+	// Identifier is required and becomes the dc:identifier.
+	Identifier string
+	Title      string
+	Author     string
+
+	// Language of the publication, defaulting to SourceLanguage.
+	Language string
+
+	// Type is one of the Dictionary constants, defaulting to
+	// DictionaryMonolingual, or DictionaryBilingual when target languages
+	// are given.
+	Type            string
+	SourceLanguage  string
+	TargetLanguages []string
+
+	// Cover is the cover image. Writer.Write needs a cover, so it must be
+	// set here or on the returned Writer.
+	Cover []byte
+}
+
+// DictionaryToEPUB generates a dictionary EPUB from definitions. Entries
+// are sorted by headword and grouped in one content document per initial
+// letter, a Search Key Map indexes headwords and forms, and a dictionary
+// collection with the source and target languages ties them together.
+func DictionaryToEPUB(definitions []DictionaryDefinition, options DictionaryOptions) (w *Writer, err error) {
+	if options.Identifier == "" {
+		return nil, errors.New("dictionary identifier is required")
+	}
+	if options.SourceLanguage == "" {
+		return nil, errors.New("dictionary source language is required")
+	}
+	if len(definitions) == 0 {
+		return nil, errors.New("dictionary has no entries")
+	}
+
+	if options.Language == "" {
+		options.Language = options.SourceLanguage
+	}
+	if options.Title == "" {
+		options.Title = "Dictionary"
+	}
+	if options.Type == "" {
+		options.Type = DictionaryMonolingual
+		if len(options.TargetLanguages) > 0 {
+			options.Type = DictionaryBilingual
+		}
+	}
+
+	definitions = slices.Clone(definitions)
+	slices.SortStableFunc(definitions, func(a, b DictionaryDefinition) int {
+		return strings.Compare(searchKey(a.Headword), searchKey(b.Headword))
+	})
+
+	w = New(options.Identifier)
+	w.Title(options.Title)
+	w.Languages(options.Language)
+	if options.Author != "" {
+		w.Author(options.Author)
+	}
+	if len(options.Cover) > 0 {
+		if err = w.Cover(options.Cover); err != nil {
+			return nil, err
+		}
+	}
+	w.DublinCores(map[string]string{"type": "dictionary"})
+
+	meta := []pkg.Meta{
+		{Property: pkg.DictionaryType, Value: options.Type},
+		{Property: pkg.SourceLanguage, Value: options.SourceLanguage},
+	}
+	for _, language := range options.TargetLanguages {
+		meta = append(meta, pkg.Meta{Property: pkg.TargetLanguage, Value: language})
+	}
+	for _, m := range meta {
+		w.Meta(m)
+	}
+
+	skmHref := "search-key-map.xml"
+	skm := searchKeyMap{Lang: options.SourceLanguage}
+	links := []CollectionLink{{Href: skmHref}}
+	toc := TOC{Title: options.Title}
+
+	type section struct {
+		letter  string
+		entries []string
+	}
+	sections := []section{}
+	for i, definition := range definitions {
+		if strings.TrimSpace(definition.Headword) == "" {
+			return nil, fmt.Errorf("entry %d has no headword", i+1)
+		}
+
+		letter := dictionaryLetter(definition.Headword)
+		if len(sections) == 0 || sections[len(sections)-1].letter != letter {
+			sections = append(sections, section{letter: letter})
+		}
+		current := &sections[len(sections)-1]
+
+		id := fmt.Sprintf("entry-%d", i+1)
+		href := path.Join(w.textDir, "entries-"+letterFileName(letter, len(sections))+".xhtml")
+
+		entry, err := dictionaryEntryXHTML(id, definition)
+		if err != nil {
+			return nil, fmt.Errorf("entry %q: %w", definition.Headword, err)
+		}
+		current.entries = append(current.entries, entry)
+
+		group := searchKeyGroup{Href: href + "#" + id, Matches: []searchKeyMatch{{Value: definition.Headword}}}
+		for _, form := range definition.Forms {
+			group.Matches[0].Values = append(group.Matches[0].Values, searchKeyValue{Value: form})
+		}
+		skm.Groups = append(skm.Groups, group)
+	}
+
+	for i, section := range sections {
+		href := path.Join(w.textDir, "entries-"+letterFileName(section.letter, i+1)+".xhtml")
+		content := dictionarySectionXHTML(options.Language, section.letter, section.entries)
+		res := w.AddContent(href, content)
+		w.addManifestProperty(res.ID, pkg.DictionaryProperty)
+
+		links = append(links, CollectionLink{Href: href})
+		toc.Items = append(toc.Items, TOC{Title: section.letter, Href: href})
+	}
+
+	data, err := xml.MarshalIndent(skm, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data = append([]byte(xml.Header), data...)
+	res := w.AddResource(skmHref, pkg.MediaTypeSearchKeyMap, data)
+	w.addManifestProperty(res.ID, pkg.SearchKeyMapProperty)
+
+	err = w.AddCollection(Collection{
+		Role:   CollectionDictionary,
+		Titles: []string{options.Title},
+		Meta:   meta,
+		Links:  links,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = w.TableOfContents("toc", toc); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// addManifestProperty adds a property to the manifest item and resource
+// with the given ID.
+func (w *Writer) addManifestProperty(id string, property pkg.ManifestProperty) {
+	add := func(properties pkg.ManifestProperty) pkg.ManifestProperty {
+		if hasManifestProperty(properties, property) {
+			return properties
+		}
+		return pkg.ManifestProperty(strings.TrimSpace(string(properties) + " " + string(property)))
+	}
+
+	items := w.epub.SelectedPackage().Manifest.Items
+	for i := range items {
+		if items[i].ID == id {
+			items[i].Properties = add(items[i].Properties)
+		}
+	}
+	for i := range w.epub.resources {
+		if w.epub.resources[i].ID == id {
+			w.epub.resources[i].Properties = add(w.epub.resources[i].Properties)
+		}
+	}
+}
+
+// dictionaryLetter returns the upper case initial of a headword, or "#"
+// for headwords starting with something else than a letter.
+func dictionaryLetter(headword string) string {
+	if r, _ := utf8.DecodeRuneInString(strings.TrimSpace(headword)); unicode.IsLetter(r) {
+		return string(unicode.ToUpper(r))
+	}
+	return "#"
+}
+
+// letterFileName returns a file name safe form of a section letter,
+// falling back to the section number for non ASCII letters.
+func letterFileName(letter string, number int) string {
+	if len(letter) == 1 && letter[0] >= 'A' && letter[0] <= 'Z' {
+		return strings.ToLower(letter)
+	}
+	return fmt.Sprintf("%03d", number)
+}
+
+func dictionaryEntryXHTML(id string, definition DictionaryDefinition) (string, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<article epub:type="dictentry" id="%s"><dfn>%s</dfn>`, id, html.EscapeString(definition.Headword))
+
+	if definition.Definition != "" {
+		body := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+		nodes, err := html.ParseFragment(strings.NewReader(definition.Definition), body)
+		if err != nil {
+			return "", err
+		}
+		for _, node := range nodes {
+			body.AppendChild(node)
+		}
+		if err := html.Render(&buf, body); err != nil {
+			return "", err
+		}
+	}
+
+	buf.WriteString("</article>")
+	return buf.String(), nil
+}
+
+func dictionarySectionXHTML(lang string, letter string, entries []string) []byte {
+	return fmt.Appendf(nil, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%[1]s" lang="%[1]s">
+<head>
+<meta charset="utf-8"/>
+<title>%[2]s</title>
+</head>
+<body epub:type="dictionary">
+<h1>%[2]s</h1>
+%[3]s
+</body>
+</html>
+`, html.EscapeString(lang), html.EscapeString(letter), strings.Join(entries, "\n"))
+}
@@ -0,0 +1,119 @@
+package epub
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+func TestReader_Glossary(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], "</manifest>", `<item id="glossary" href="text/glossary.xhtml" media-type="application/xhtml+xml" properties="glossary"/>
+    <item id="glossary-skm" href="glossary/skm.xml" media-type="application/vnd.epub.search-key-map+xml" properties="search-key-map glossary"/>
+  </manifest>`, 1)
+	files["OEBPS/text/glossary.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Glossary</title></head><body><dl><dt id="epub">EPUB</dt><dd>A format.</dd></dl></body></html>`
+	files["OEBPS/glossary/skm.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<search-key-map xmlns="http://www.idpf.org/2007/ops" xml:lang="en">
+  <search-key-group href="../text/glossary.xhtml#epub">
+    <match value="EPUB"><value value="EPUBs"/><value value="ePub"/></match>
+  </search-key-group>
+  <search-key-group href="../text/glossary.xhtml#epub">
+    <match value="e-book" href="../text/glossary.xhtml#ebook"/>
+  </search-key-group>
+</search-key-map>`
+
+	r := newTestReader(t, files)
+
+	if dictionaries, err := r.Dictionaries(); err != nil || len(dictionaries) != 0 {
+		t.Errorf("expected no dictionaries, got %+v, %v", dictionaries, err)
+	}
+
+	glossary, err := r.Glossary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if glossary == nil || !glossary.Glossary || len(glossary.Entries) != 2 {
+		t.Fatalf("unexpected glossary %+v", glossary)
+	}
+
+	entries := glossary.Lookup(" epubs ")
+	if len(entries) != 1 || entries[0].Href != "text/glossary.xhtml" || entries[0].Fragment != "epub" || entries[0].ResourceID != "glossary" {
+		t.Errorf("unexpected lookup result %+v", entries)
+	}
+
+	if entries := glossary.Lookup("E-Book"); len(entries) != 1 || entries[0].Fragment != "ebook" {
+		t.Errorf("expected the match href to override the group href, got %+v", entries)
+	}
+	if entries := glossary.Lookup("missing"); len(entries) != 0 {
+		t.Errorf("expected no entries, got %+v", entries)
+	}
+}
+
+func TestDictionaryToEPUB(t *testing.T) {
+	definitions := []DictionaryDefinition{
+		{Headword: "run", Forms: []string{"ran", "running"}, Definition: `<p lang="fr">courir</p>`},
+		{Headword: "Apple", Definition: "pomme"},
+		{Headword: "éclair", Definition: "<p>éclair"},
+		{Headword: "read", Forms: []string{"reading"}},
+	}
+
+	if _, err := DictionaryToEPUB(definitions, DictionaryOptions{Identifier: "urn:uuid:dict"}); err == nil {
+		t.Errorf("expected error without source language")
+	}
+
+	w, err := DictionaryToEPUB(definitions, DictionaryOptions{
+		Identifier:      "urn:uuid:dict",
+		Title:           "English-French",
+		SourceLanguage:  "en",
+		TargetLanguages: []string{"fr"},
+		Cover:           testPNG(t, 10, 10),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+
+	dictionaries, err := r.Dictionaries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(dictionaries) != 1 {
+		t.Fatalf("expected one dictionary, got %+v", dictionaries)
+	}
+
+	dictionary := dictionaries[0]
+	if dictionary.Title != "English-French" || dictionary.Type != DictionaryBilingual || dictionary.SourceLanguage != "en" || len(dictionary.TargetLanguages) != 1 {
+		t.Errorf("unexpected dictionary %+v", dictionary)
+	}
+
+	entries := dictionary.Lookup("Running")
+	if len(entries) != 1 || entries[0].Headword != "run" || entries[0].Href != "text/entries-r.xhtml" {
+		t.Fatalf("unexpected lookup result %+v", entries)
+	}
+
+	doc := string(r.SelectResourceById(entries[0].ResourceID).Content)
+	if !strings.Contains(doc, `id="`+entries[0].Fragment+`"><dfn>run</dfn><div><p lang="fr">courir</p></div>`) {
+		t.Errorf("expected the entry in %s", doc)
+	}
+
+	if entries := dictionary.Lookup("éclair"); len(entries) != 1 || entries[0].Href != "text/entries-003.xhtml" {
+		t.Errorf("unexpected non ascii entry %+v", entries)
+	}
+
+	skm := r.SelectResourceByHref("search-key-map.xml")
+	if skm == nil || skm.Properties != pkg.SearchKeyMapProperty {
+		t.Errorf("unexpected search key map resource %+v", skm)
+	} else if !strings.Contains(string(skm.Content), `<search-key-map xmlns="http://www.idpf.org/2007/ops" xml:lang="en">`) {
+		t.Errorf("expected the namespaced root element in %s", skm.Content)
+	}
+	if res := r.SelectResourceById(entries[0].ResourceID); res.Properties != pkg.DictionaryProperty {
+		t.Errorf("expected the dictionary property, got %q", res.Properties)
+	}
+
+	if toc, err := r.TableOfContents(); err != nil || len(toc.Items) != 3 || toc.Items[0].Title != "A" {
+		t.Errorf("unexpected toc %+v, %v", toc, err)
+	}
+}
@@ -0,0 +1,598 @@
+package epub
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/raitucarp/epub/ncx"
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// html5BlockElements are HTML5 elements without an XHTML 1.1 equivalent
+// that are downgraded to a div carrying the original name as class.
+var html5BlockElements = []string{
+	"article", "aside", "details", "dialog", "figcaption", "figure",
+	"footer", "header", "hgroup", "main", "nav", "section", "summary",
+}
+
+// html5InlineElements are downgraded to a span carrying the original name
+// as class.
+var html5InlineElements = []string{"bdi", "data", "mark", "meter", "output", "progress", "time"}
+
+// html5RemovedElements have no meaningful XHTML 1.1 fallback and are dropped.
+var html5RemovedElements = []string{"source", "template", "track", "wbr"}
+
+// html5RemovedAttributes are attributes XHTML 1.1 does not allow.
+var html5RemovedAttributes = []string{
+	"contenteditable", "draggable", "hidden", "role", "spellcheck", "translate", "xmlns:epub",
+}
+
+// xhtmlGlobalAttributes are the attributes common to all XHTML 1.1 elements.
+var xhtmlGlobalAttributes = []string{"class", "dir", "id", "lang", "style", "title", "xml:lang", "xml:space"}
+
+// DowngradeToEPUB2 converts an EPUB 3 publication into EPUB 2 for reading
+// systems that only support EPUB 2. The navigation document is turned into
+// an NCX, landmarks into guide references, refines into OPF 2 attributes,
+// and content documents are downgraded to XHTML 1.1.
+func DowngradeToEPUB2(r *Reader) (w *Writer, err error) {
+	if strings.HasPrefix(r.Version(), "2") {
+		return nil, fmt.Errorf("publication is already EPUB %s", r.Version())
+	}
+
+	navIndex := slices.IndexFunc(r.epub.resources, func(res PublicationResource) bool {
+		return hasManifestProperty(res.Properties, pkg.NavProperty)
+	})
+	if navIndex < 0 {
+		return nil, errors.New("publication has no navigation document to downgrade from")
+	}
+	navRes := r.epub.resources[navIndex]
+
+	navDoc := r.ReadContentHTMLById(navRes.ID)
+	if navDoc == nil {
+		return nil, fmt.Errorf("cannot parse navigation document %s", navRes.Href)
+	}
+
+	toc := TOC{}
+	err = toc.parseFromHTML(navDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := TOC{}
+	if pageList := findNavByType(navDoc, "page-list"); pageList != nil {
+		pages.parseNav(pageList)
+	}
+
+	w = newWriterFromReader(r)
+	packagePub := w.epub.SelectedPackage()
+	packagePub.Version = "2.0"
+	navDir := path.Dir(navRes.Filepath)
+
+	if guide := landmarksToGuide(navDoc, navDir, w.contentDir); guide != nil {
+		packagePub.Guide = guide
+	}
+
+	navigation := tocToNCX(
+		packageUID(packagePub),
+		firstTitle(packagePub),
+		firstCreator(packagePub),
+		toc.Items,
+		pages.Items,
+		w.readingPosition(navDir),
+	)
+	err = w.setNCX(navigation, navDir)
+	if err != nil {
+		return nil, err
+	}
+
+	downgradeRefines(&packagePub.Metadata)
+	downgradePackage(packagePub)
+
+	for _, res := range w.epub.resources {
+		if res.MIMEType != pkg.MediaTypeXHTML {
+			continue
+		}
+
+		content, err := downgradeXHTML(res.Content)
+		if err != nil {
+			return nil, fmt.Errorf("cannot downgrade %s: %w", res.Href, err)
+		}
+		w.replaceResourceContent(res.ID, content)
+	}
+	w.downgradeMediaItems()
+
+	return w, nil
+}
+
+// downgradeMediaItems handles the audio and video items without a fallback,
+// which OPF 2 does not allow as they are not OPS core media types. Items no
+// content document references any more, once audio and video elements are
+// replaced, are removed; the others fall back to an XHTML document linking
+// to them.
+func (w *Writer) downgradeMediaItems() {
+	referenced := make(map[string]bool)
+	for _, res := range w.epub.resources {
+		if res.MIMEType != pkg.MediaTypeXHTML {
+			continue
+		}
+		doc, err := parseXHTML(res.Content)
+		if err != nil {
+			continue
+		}
+
+		docDir := path.Dir(unescapePath(res.Filepath))
+		for node := range doc.Descendants() {
+			for _, attr := range node.Attr {
+				switch attr.Key {
+				case "src", "href", "data", "poster":
+					if isLocalURL(attr.Val) {
+						containerPath, _ := resolveHref(docDir, attr.Val)
+						referenced[unescapePath(containerPath)] = true
+					}
+				}
+			}
+		}
+	}
+
+	packagePub := w.epub.SelectedPackage()
+	for _, item := range slices.Clone(packagePub.Manifest.Items) {
+		isMedia := strings.HasPrefix(item.MediaType, "audio/") || strings.HasPrefix(item.MediaType, "video/")
+		res := w.resourceByID(item.ID)
+		if !isMedia || item.Fallback != "" || res == nil {
+			continue
+		}
+
+		if !referenced[unescapePath(res.Filepath)] {
+			w.removeResource(item.ID)
+			continue
+		}
+
+		name := path.Base(res.Href)
+		fallbackID := uniqueManifestID(packagePub, item.ID+"-fallback")
+		fallbackPath := strings.TrimSuffix(res.Filepath, path.Ext(res.Filepath)) + "-fallback.xhtml"
+		content := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.1//EN" "http://www.w3.org/TR/xhtml11/DTD/xhtml11.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>%[1]s</title></head>
+<body><p><a href="%[2]s">%[1]s</a></p></body></html>
+`, html.EscapeString(unescapePath(name)), html.EscapeString(name))
+		w.addResource(fallbackID, fallbackPath, relativeHref(w.contentDir, fallbackPath, ""), pkg.NotProperty, pkg.MediaTypeXHTML, []byte(content))
+
+		for i := range packagePub.Manifest.Items {
+			if packagePub.Manifest.Items[i].ID == item.ID {
+				packagePub.Manifest.Items[i].Fallback = fallbackID
+			}
+		}
+	}
+}
+
+// removeResource drops a resource from the manifest, the spine and the
+// container.
+func (w *Writer) removeResource(id string) {
+	res := w.resourceByID(id)
+	if res == nil {
+		return
+	}
+	w.epub.zipContainer.RemoveFile(res.Filepath)
+	w.epub.resources = slices.DeleteFunc(w.epub.resources, func(res PublicationResource) bool {
+		return res.ID == id
+	})
+
+	packagePub := w.epub.SelectedPackage()
+	packagePub.Manifest.Items = slices.DeleteFunc(packagePub.Manifest.Items, func(item pkg.Item) bool {
+		return item.ID == id
+	})
+	packagePub.Spine.ItemRefs = slices.DeleteFunc(packagePub.Spine.ItemRefs, func(itemRef pkg.ItemRef) bool {
+		return itemRef.IDRef == id
+	})
+}
+
+// hasManifestProperty reports whether a space separated manifest properties
+// value contains the given property.
+func hasManifestProperty(properties pkg.ManifestProperty, property pkg.ManifestProperty) bool {
+	return slices.Contains(strings.Fields(string(properties)), string(property))
+}
+
+func packageUID(packagePub *pkg.Package) string {
+	for _, identifier := range packagePub.Metadata.Identifiers {
+		if identifier.ID == packagePub.UniqueIdentifier {
+			return identifier.Value
+		}
+	}
+
+	if len(packagePub.Metadata.Identifiers) > 0 {
+		return packagePub.Metadata.Identifiers[0].Value
+	}
+	return ""
+}
+
+func firstTitle(packagePub *pkg.Package) string {
+	if len(packagePub.Metadata.Titles) > 0 {
+		return packagePub.Metadata.Titles[0].Value
+	}
+	return ""
+}
+
+func firstLanguage(packagePub *pkg.Package) string {
+	if len(packagePub.Metadata.Languages) > 0 {
+		return packagePub.Metadata.Languages[0].Value
+	}
+	return ""
+}
+
+func firstCreator(packagePub *pkg.Package) string {
+	for _, dc := range packagePub.Metadata.OptionalDC {
+		if dc.XMLName.Local == "dc:creator" {
+			return dc.Value
+		}
+	}
+	return ""
+}
+
+// setNCX stores the NCX in the publication, replacing the existing NCX or
+// adding a new one inside dir, and references it from the spine.
+func (w *Writer) setNCX(navigation *ncx.NCX, dir string) (err error) {
+	packagePub := w.epub.SelectedPackage()
+	ncxIndex := slices.IndexFunc(packagePub.Manifest.Items, func(item pkg.Item) bool {
+		return item.MediaType == pkg.MediaTypeNCX
+	})
+
+	var id string
+	var filePath string
+	if ncxIndex > -1 {
+		id = packagePub.Manifest.Items[ncxIndex].ID
+		filePath, _ = resolveHref(w.contentDir, packagePub.Manifest.Items[ncxIndex].Href)
+	} else {
+		id = uniqueManifestID(packagePub, "ncx")
+		filePath = path.Join(dir, "toc.ncx")
+	}
+
+	ncxDir := path.Dir(filePath)
+	rebaseNavPoints(navigation.NavMap.NavPoints, dir, ncxDir)
+	if navigation.PageList != nil {
+		for i := range navigation.PageList.PageTargets {
+			target := &navigation.PageList.PageTargets[i]
+			target.Content.Src = rebaseHref(target.Content.Src, dir, ncxDir)
+		}
+	}
+
+	content, err := marshalNCX(navigation)
+	if err != nil {
+		return
+	}
+
+	if ncxIndex > -1 {
+		w.replaceResourceContent(id, content)
+	} else {
+		w.addResource(
+			id,
+			filePath,
+			relativeHref(w.contentDir, filePath, ""),
+			pkg.NotProperty,
+			pkg.MediaTypeNCX,
+			content,
+		)
+	}
+
+	packagePub.Spine.TOC = id
+	w.epub.navigationCenterEXtended = navigation
+	return
+}
+
+func rebaseNavPoints(navPoints []ncx.NavPoint, fromDir string, toDir string) {
+	for i := range navPoints {
+		navPoints[i].Content.Src = rebaseHref(navPoints[i].Content.Src, fromDir, toDir)
+		rebaseNavPoints(navPoints[i].NavPoints, fromDir, toDir)
+	}
+}
+
+// rebaseHref rewrites an href relative to fromDir so it is relative to toDir.
+func rebaseHref(href string, fromDir string, toDir string) string {
+	if fromDir == toDir || href == "" || strings.HasPrefix(href, "#") || isRemoteHref(href) {
+		return href
+	}
+
+	containerPath, fragment := resolveHref(fromDir, href)
+	return relativeHref(toDir, containerPath, fragment)
+}
+
+// guideTypeForLandmark maps a landmarks epub:type to the EPUB 2 guide
+// reference type, using the "other." prefix for types without one.
+func guideTypeForLandmark(epubType string) pkg.GuideReferenceType {
+	for guideType, landmark := range guideLandmarkTypes {
+		if landmark == epubType {
+			return guideType
+		}
+	}
+	return pkg.GuideReferenceType("other." + epubType)
+}
+
+// landmarksToGuide converts the landmarks nav of the navigation document
+// into guide references relative to the package document.
+func landmarksToGuide(navDoc *html.Node, navDir string, packageDir string) *pkg.Guide {
+	landmarks := findNavByType(navDoc, "landmarks")
+	if landmarks == nil {
+		return nil
+	}
+
+	guide := &pkg.Guide{}
+	for node := range landmarks.Descendants() {
+		if node.Type != html.ElementNode || node.Data != "a" {
+			continue
+		}
+
+		href := getAttribute(node, "href")
+		epubTypes := strings.Fields(getAttribute(node, "epub:type"))
+		if href == "" || len(epubTypes) == 0 {
+			continue
+		}
+
+		guide.References = append(guide.References, pkg.GuideReference{
+			Type:  guideTypeForLandmark(epubTypes[0]),
+			Title: GetTextContent(node),
+			Href:  rebaseHref(href, navDir, packageDir),
+		})
+	}
+
+	if len(guide.References) == 0 {
+		return nil
+	}
+	return guide
+}
+
+// downgradeRefines turns role and file-as refines into OPF 2 attributes and
+// rewrites the remaining EPUB 3 meta elements into name/content pairs.
+func downgradeRefines(metadata *pkg.Metadata) {
+	dcIndexes := map[string]int{}
+	for i, dc := range metadata.OptionalDC {
+		if dc.ID != "" {
+			dcIndexes[dc.ID] = i
+		}
+	}
+
+	metas := []pkg.Meta{}
+	for _, meta := range metadata.Meta {
+		switch {
+		case meta.Refines != "":
+			index, ok := dcIndexes[strings.TrimPrefix(meta.Refines, "#")]
+			if !ok {
+				continue
+			}
+
+			switch meta.Property {
+			case "role":
+				metadata.OptionalDC[index].Role = meta.Value
+			case "file-as":
+				metadata.OptionalDC[index].FileAs = meta.Value
+			}
+
+		case meta.Property == "dcterms:modified":
+			metadata.OptionalDC = append(metadata.OptionalDC, pkg.DCOptional{
+				XMLName: xml.Name{Local: "dc:date"},
+				Event:   "modification",
+				Value:   meta.Value,
+			})
+
+		case meta.Property != "":
+			metas = append(metas, pkg.Meta{Name: meta.Property, Content: strings.TrimSpace(meta.Value)})
+
+		default:
+			metas = append(metas, meta)
+		}
+	}
+	metadata.Meta = metas
+
+	for i := range metadata.OptionalDC {
+		metadata.OptionalDC[i].Dir = ""
+	}
+	for i := range metadata.Titles {
+		metadata.Titles[i].Dir = ""
+	}
+}
+
+// downgradePackage removes attributes and elements that only exist in
+// EPUB 3 package documents, keeping the cover as an EPUB 2 cover meta.
+func downgradePackage(packagePub *pkg.Package) {
+	packagePub.Dir = ""
+	packagePub.Prefix = ""
+	packagePub.Lang = ""
+	packagePub.Bindings = nil
+	packagePub.Collections = nil
+	packagePub.Metadata.Links = nil
+
+	hasCoverMeta := slices.ContainsFunc(packagePub.Metadata.Meta, func(meta pkg.Meta) bool {
+		return meta.Name == "cover"
+	})
+
+	for i, item := range packagePub.Manifest.Items {
+		if hasManifestProperty(item.Properties, pkg.CoverImageProperty) && !hasCoverMeta {
+			packagePub.Metadata.Meta = append(packagePub.Metadata.Meta, pkg.Meta{Name: "cover", Content: item.ID})
+			hasCoverMeta = true
+		}
+
+		packagePub.Manifest.Items[i].Properties = pkg.NotProperty
+		packagePub.Manifest.Items[i].MediaOverlay = ""
+	}
+
+	packagePub.Spine.PageProgressionDirection = ""
+	for i := range packagePub.Spine.ItemRefs {
+		packagePub.Spine.ItemRefs[i].Properties = ""
+	}
+}
+
+// downgradeXHTML rewrites an XHTML5 content document into XHTML 1.1.
+func downgradeXHTML(content []byte) ([]byte, error) {
+	doc, err := parseXHTML(content)
+	if err != nil {
+		return nil, err
+	}
+
+	for c := doc.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.DoctypeNode {
+			doc.RemoveChild(c)
+		}
+		c = next
+	}
+
+	doc.InsertBefore(&html.Node{
+		Type: html.DoctypeNode,
+		Data: "html",
+		Attr: []html.Attribute{
+			{Key: "public", Val: "-//W3C//DTD XHTML 1.1//EN"},
+			{Key: "system", Val: "http://www.w3.org/TR/xhtml11/DTD/xhtml11.dtd"},
+		},
+	}, doc.FirstChild)
+
+	downgradeNode(doc)
+
+	root := FindNode(doc, func(n *html.Node) bool { return n.Type == html.ElementNode && n.Data == "html" })
+	if root != nil && getAttribute(root, "xmlns") == "" {
+		root.Attr = append([]html.Attribute{{Key: "xmlns", Val: "http://www.w3.org/1999/xhtml"}}, root.Attr...)
+	}
+
+	return renderXHTML(doc)
+}
+
+func downgradeNode(node *html.Node) {
+	for c := node.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && c.Namespace == "" {
+			downgradeElement(c)
+		}
+		c = next
+	}
+}
+
+func downgradeElement(node *html.Node) {
+	switch {
+	case slices.Contains(html5RemovedElements, node.Data):
+		node.Parent.RemoveChild(node)
+		return
+
+	case node.Data == "audio" || node.Data == "video":
+		replaceMediaWithFallback(node)
+		return
+
+	case node.Data == "picture" || node.Data == "canvas":
+		downgradeNode(node)
+		unwrapNode(node)
+		return
+
+	case node.Data == "meta" && getAttribute(node, "charset") != "":
+		node.Attr = []html.Attribute{
+			{Key: "http-equiv", Val: "Content-Type"},
+			{Key: "content", Val: "application/xhtml+xml; charset=utf-8"},
+		}
+
+	case slices.Contains(html5BlockElements, node.Data):
+		keepGlobalAttributes(node)
+		addClass(node, node.Data)
+		node.Data, node.DataAtom = "div", atom.Div
+
+	case slices.Contains(html5InlineElements, node.Data):
+		keepGlobalAttributes(node)
+		addClass(node, node.Data)
+		node.Data, node.DataAtom = "span", atom.Span
+	}
+
+	downgradeAttributes(node)
+	downgradeNode(node)
+}
+
+func downgradeAttributes(node *html.Node) {
+	attrs := make([]html.Attribute, 0, len(node.Attr))
+	classes := []string{}
+	hasXMLLang := getAttribute(node, "xml:lang") != ""
+
+	for _, attr := range node.Attr {
+		switch {
+		case attr.Key == "epub:type":
+			classes = append(classes, strings.Fields(attr.Val)...)
+		case attr.Key == "lang":
+			if !hasXMLLang {
+				attrs = append(attrs, html.Attribute{Key: "xml:lang", Val: attr.Val})
+				hasXMLLang = true
+			}
+		case slices.Contains(html5RemovedAttributes, attr.Key),
+			strings.HasPrefix(attr.Key, "aria-"),
+			strings.HasPrefix(attr.Key, "data-"):
+		default:
+			attrs = append(attrs, attr)
+		}
+	}
+
+	node.Attr = attrs
+	addClass(node, classes...)
+}
+
+// keepGlobalAttributes drops element specific attributes, such as datetime
+// on time, before an element is renamed to a div or span.
+func keepGlobalAttributes(node *html.Node) {
+	node.Attr = slices.DeleteFunc(node.Attr, func(attr html.Attribute) bool {
+		return !slices.Contains(xhtmlGlobalAttributes, attr.Key) &&
+			attr.Key != "epub:type" && !strings.HasPrefix(attr.Key, "on")
+	})
+}
+
+// replaceMediaWithFallback replaces an audio or video element with its
+// fallback content, or with its poster image when it has no fallback.
+func replaceMediaWithFallback(node *html.Node) {
+	for c := node.FirstChild; c != nil; {
+		next := c.NextSibling
+		isWhitespace := c.Type == html.TextNode && strings.TrimSpace(c.Data) == ""
+		if (c.Type == html.ElementNode && (c.Data == "source" || c.Data == "track")) || isWhitespace {
+			node.RemoveChild(c)
+		}
+		c = next
+	}
+
+	if node.FirstChild == nil {
+		if poster := getAttribute(node, "poster"); poster != "" {
+			node.AppendChild(&html.Node{
+				Type:     html.ElementNode,
+				Data:     "img",
+				DataAtom: atom.Img,
+				Attr: []html.Attribute{
+					{Key: "src", Val: poster},
+					{Key: "alt", Val: getAttribute(node, "title")},
+				},
+			})
+		}
+	}
+
+	downgradeNode(node)
+	unwrapNode(node)
+}
+
+// unwrapNode replaces a node with its children.
+func unwrapNode(node *html.Node) {
+	parent := node.Parent
+	for c := node.FirstChild; c != nil; {
+		next := c.NextSibling
+		node.RemoveChild(c)
+		parent.InsertBefore(c, node)
+		c = next
+	}
+	parent.RemoveChild(node)
+}
+
+// addClass appends class names to the class attribute of a node.
+func addClass(node *html.Node, classes ...string) {
+	if len(classes) == 0 {
+		return
+	}
+
+	for i, attr := range node.Attr {
+		if attr.Key == "class" {
+			node.Attr[i].Val = strings.Join(append(strings.Fields(attr.Val), classes...), " ")
+			return
+		}
+	}
+	node.Attr = append(node.Attr, html.Attribute{Key: "class", Val: strings.Join(classes, " ")})
+}
@@ -0,0 +1,240 @@
+package epub
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+var testEPUB3Files = map[string]string{
+	"mimetype":               "application/epub+zip",
+	"META-INF/container.xml": testContainerXML,
+	"OEBPS/content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid" dir="ltr" xml:lang="en">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:epub3-book</dc:identifier>
+    <dc:title id="title">Modern Book</dc:title>
+    <dc:language>en</dc:language>
+    <dc:creator id="author">Jane Writer</dc:creator>
+    <meta property="role" refines="#author" scheme="marc:relators">aut</meta>
+    <meta property="file-as" refines="#author">Writer, Jane</meta>
+    <meta property="dcterms:modified">2025-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav/nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="cover" href="images/cover.png" media-type="image/png" properties="cover-image"/>
+    <item id="chapter-1" href="text/chapter-1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="chapter-2" href="text/chapter-2.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine page-progression-direction="ltr">
+    <itemref idref="chapter-1" properties="page-spread-right"/>
+    <itemref idref="chapter-2"/>
+  </spine>
+</package>`,
+	"OEBPS/nav/nav.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>Nav</title></head>
+<body>
+<nav epub:type="toc" id="toc"><h2>Contents</h2><ol>
+  <li><a href="../text/chapter-1.xhtml">Chapter 1</a>
+    <ol>
+      <li><a href="../text/chapter-1.xhtml#s1">Section 1.1</a>
+        <ol><li><a href="../text/chapter-1.xhtml#s1-1">Section 1.1.1</a></li></ol>
+      </li>
+    </ol>
+  </li>
+  <li><a href="../text/chapter-2.xhtml">Chapter 2</a></li>
+</ol></nav>
+<nav epub:type="page-list" hidden=""><ol>
+  <li><a href="../text/chapter-1.xhtml#p1">1</a></li>
+  <li><a href="../text/chapter-2.xhtml#p2">2</a></li>
+</ol></nav>
+<nav epub:type="landmarks"><ol>
+  <li><a epub:type="bodymatter" href="../text/chapter-1.xhtml">Start</a></li>
+  <li><a epub:type="cover" href="../text/chapter-1.xhtml#cover">Cover</a></li>
+</ol></nav>
+</body></html>`,
+	"OEBPS/images/cover.png": "\x89PNG\r\n\x1a\n",
+	"OEBPS/text/chapter-1.xhtml": `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en">
+<head><meta charset="utf-8"/><title>Chapter 1</title></head>
+<body><section epub:type="chapter" role="doc-chapter" aria-label="One">
+<header><h1>Chapter 1</h1></header>
+<p>Text <mark>marked</mark> <time datetime="2020">2020</time>.</p>
+<aside epub:type="footnote" id="n1" hidden="">Note</aside>
+<audio src="a.mp3" controls=""><source src="a.ogg"/>Audio is not supported.</audio>
+<video src="v.mp4" poster="../images/cover.png" title="Clip"></video>
+</section></body></html>`,
+	"OEBPS/text/chapter-2.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter 2</title></head>
+<body><p id="p2">Second</p></body></html>`,
+}
+
+func TestDowngradeToEPUB2(t *testing.T) {
+	source := newTestReader(t, testEPUB3Files)
+
+	w, err := DowngradeToEPUB2(&source)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+	packagePub := r.CurrentSelectedPackage()
+
+	if r.Version() != "2.0" {
+		t.Errorf("expected version 2.0, got %q", r.Version())
+	}
+
+	t.Run("ncx", func(t *testing.T) {
+		navigation := r.NavigationCenterExtended()
+		if navigation == nil {
+			t.Fatalf("expected NCX")
+		}
+
+		navPoints := navigation.NavMap.NavPoints
+		if len(navPoints) != 2 || len(navPoints[0].NavPoints) != 1 || len(navPoints[0].NavPoints[0].NavPoints) != 1 {
+			t.Fatalf("unexpected navMap structure: %+v", navPoints)
+		}
+
+		deepest := navPoints[0].NavPoints[0].NavPoints[0]
+		if deepest.Content.Src != "../text/chapter-1.xhtml#s1-1" {
+			t.Errorf("unexpected src %q", deepest.Content.Src)
+		}
+
+		if navigation.PageList == nil || len(navigation.PageList.PageTargets) != 2 {
+			t.Fatalf("expected two page targets, got %+v", navigation.PageList)
+		}
+
+		// The first page is in chapter 1, between its last section and
+		// chapter 2 in reading order.
+		pageTargets := navigation.PageList.PageTargets
+		if deepest.PlayOrder != "3" || pageTargets[0].PlayOrder != "4" || navPoints[1].PlayOrder != "5" || pageTargets[1].PlayOrder != "6" {
+			t.Errorf("unexpected playOrder %q, %q, %q, %q", deepest.PlayOrder, pageTargets[0].PlayOrder, navPoints[1].PlayOrder, pageTargets[1].PlayOrder)
+		}
+
+		if packagePub.Spine.TOC == "" {
+			t.Errorf("expected spine toc attribute")
+		}
+	})
+
+	t.Run("guide", func(t *testing.T) {
+		if packagePub.Guide == nil || len(packagePub.Guide.References) != 2 {
+			t.Fatalf("expected two guide references, got %+v", packagePub.Guide)
+		}
+
+		ref := packagePub.Guide.References[0]
+		if ref.Type != pkg.GuideRefText || ref.Href != "text/chapter-1.xhtml" {
+			t.Errorf("unexpected guide reference %+v", ref)
+		}
+	})
+
+	t.Run("opf 2 attributes", func(t *testing.T) {
+		for _, dc := range packagePub.Metadata.OptionalDC {
+			if dc.XMLName.Local != "creator" {
+				continue
+			}
+			if dc.Role != "aut" || dc.FileAs != "Writer, Jane" {
+				t.Errorf("expected opf:role and opf:file-as, got %+v", dc)
+			}
+		}
+
+		for _, meta := range packagePub.Metadata.Meta {
+			if meta.Property != "" || meta.Refines != "" {
+				t.Errorf("unexpected EPUB 3 meta %+v", meta)
+			}
+		}
+
+		for _, item := range packagePub.Manifest.Items {
+			if item.Properties != pkg.NotProperty {
+				t.Errorf("unexpected properties on %s", item.ID)
+			}
+		}
+	})
+
+	t.Run("xhtml 1.1", func(t *testing.T) {
+		content := string(r.SelectResourceById("chapter-1").Content)
+
+		for _, expected := range []string{
+			`<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.1//EN"`,
+			`<div class="section chapter">`,
+			`<span class="mark">marked</span>`,
+			`<div id="n1" class="aside footnote">`,
+			`Audio is not supported.`,
+			`<img src="../images/cover.png" alt="Clip"/>`,
+			`xml:lang="en"`,
+		} {
+			if !strings.Contains(content, expected) {
+				t.Errorf("expected content to contain %s, got %s", expected, content)
+			}
+		}
+
+		for _, unexpected := range []string{"<section", "epub:type", "role=", "aria-", "<audio", "<video", "hidden", "<meta charset"} {
+			if strings.Contains(content, unexpected) {
+				t.Errorf("expected content not to contain %s", unexpected)
+			}
+		}
+	})
+}
+
+func TestUpgradeDowngradeRoundTrip(t *testing.T) {
+	source := newTestReader(t, testEPUB2Files)
+
+	upgraded, err := UpgradeToEPUB3(&source)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	epub3 := writeAndReopen(t, upgraded)
+
+	downgraded, err := DowngradeToEPUB2(&epub3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	epub2 := writeAndReopen(t, downgraded)
+
+	toc, err := epub2.TableOfContents()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(toc.Items) != 2 || toc.Items[0].Items[0].Href != "text/chapter-1.xhtml#s1" {
+		t.Errorf("unexpected toc after round trip: %+v", toc.Items)
+	}
+}
+
+func TestDowngradeToEPUB2_MediaItems(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], "</manifest>", `<item id="audio" href="text/a.mp3" media-type="audio/mpeg"/>
+    <item id="clip" href="media/clip%20one.mp4" media-type="video/mp4"/>
+    <item id="movie" href="media/movie.mp4" media-type="video/mp4" fallback="chapter-2"/>
+  </manifest>`, 1)
+	files["OEBPS/text/chapter-2.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter 2</title></head>
+<body><p id="p2"><a href="../media/clip%20one.mp4">Watch</a></p></body></html>`
+	files["OEBPS/text/a.mp3"] = "ID3"
+	files["OEBPS/media/clip one.mp4"] = "mp4"
+	files["OEBPS/media/movie.mp4"] = "mp4"
+	source := newTestReader(t, files)
+
+	w, err := DowngradeToEPUB2(&source)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := writeAndReopen(t, w)
+
+	if r.SelectResourceById("audio") != nil {
+		t.Errorf("expected the audio item replaced in content to be removed")
+	}
+	items := r.CurrentSelectedPackage().Manifest.Items
+	for _, item := range items {
+		switch item.ID {
+		case "clip":
+			fallback := r.SelectResourceById(item.Fallback)
+			if fallback == nil || fallback.MIMEType != pkg.MediaTypeXHTML || !strings.Contains(string(fallback.Content), `href="clip%20one.mp4"`) {
+				t.Errorf("expected an XHTML fallback for the linked video, got %+v", item)
+			}
+		case "movie":
+			if item.Fallback != "chapter-2" {
+				t.Errorf("expected the declared fallback to be kept, got %+v", item)
+			}
+		}
+	}
+}
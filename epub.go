@@ -13,6 +13,7 @@ type Epub struct {
 	packagePaths             map[string]string
 	zipContainer             *ocf.OCFZipContainer
 	rendition                string
+	renditions               []Rendition
 	resources                []PublicationResource
 	metadata                 map[string]any
 	navigationCenterEXtended *ncx.NCX
@@ -1,13 +1,8 @@
 package epub
 
 import (
-	"bytes"
-	"image"
-	"image/jpeg"
-	"image/png"
 	"net/url"
 	"regexp"
-	"slices"
 	"strings"
 	"unicode"
 
@@ -33,136 +28,6 @@ func (r *Reader) Version() (version string) {
 	return r.CurrentSelectedPackage().Version
 }
 
-var coverImagePattern = regexp.MustCompile("cover")
-
-func (r *Reader) getCoverInMetadata() (cover *image.Image) {
-	metadata := r.Metadata()
-	if meta, ok := metadata["meta"]; ok {
-		metaMap, ok := meta.(map[string]any)
-
-		if !ok {
-			return
-		}
-
-		for key, value := range metaMap {
-			if coverImagePattern.MatchString(key) {
-				resId := value.(string)
-				cover = r.ReadImageById(resId)
-			}
-		}
-		return
-	}
-
-	return
-}
-
-func (r *Reader) getCoverInResources() (cover *image.Image) {
-	resources := r.Resources()
-	for _, res := range resources {
-		if res.Properties == pkg.CoverImageProperty {
-			cover = r.ReadImageByHref(res.Href)
-		}
-
-		if cover == nil && coverImagePattern.MatchString(res.ID) {
-			cover = r.ReadImageByHref(res.Href)
-		}
-	}
-
-	return
-}
-
-func (r *Reader) getCoverInSpine() (cover *image.Image) {
-	for _, item := range r.Spine() {
-		if !coverImagePattern.MatchString(item.ID) {
-			continue
-		}
-		res := r.SelectResourceById(item.ID)
-		if res != nil {
-			cover = r.ReadImageByHref(res.Href)
-		}
-	}
-
-	return
-}
-
-func findFirstImg(n *html.Node) *html.Node {
-	return FindNode(n, func(node *html.Node) bool {
-		return node.Type == html.ElementNode && node.Data == "img"
-	})
-}
-
-func getImageSrc(imgNode *html.Node) (href string) {
-	srcIndex := slices.IndexFunc(imgNode.Attr, func(attr html.Attribute) bool { return attr.Key == "src" })
-	if srcIndex == -1 {
-		return
-	}
-	href = imgNode.Attr[srcIndex].Val
-	return
-}
-
-func (r *Reader) getCoverFromToc() (cover *image.Image) {
-	toc, err := r.TableOfContents()
-	if err != nil {
-		return
-	}
-
-	for _, item := range toc.Items {
-		if !coverImagePattern.MatchString(item.Href) {
-			continue
-		}
-
-		htmlNode := r.ReadContentHTMLByHref(item.Href)
-		if htmlNode == nil {
-			continue
-		}
-		firstImg := findFirstImg(htmlNode)
-		href := getImageSrc(firstImg)
-		cover = r.ReadImageByHref(href)
-
-	}
-
-	return
-}
-
-// Cover returns the publication's cover image if present.
-func (r *Reader) Cover() (cover *image.Image) {
-	cover = r.getCoverInMetadata()
-
-	if cover == nil {
-		cover = r.getCoverInResources()
-	}
-
-	if cover == nil {
-		cover = r.getCoverInSpine()
-	}
-
-	if cover == nil {
-		cover = r.getCoverFromToc()
-	}
-
-	return
-}
-
-// CoverBytes returns the raw byte representation of the cover image.
-// An error is returned if the publication does not define a cover.
-func (r *Reader) CoverBytes() (cover []byte, err error) {
-	coverImage := r.Cover()
-
-	buf := new(bytes.Buffer)
-
-	err = png.Encode(buf, *coverImage)
-	if err == nil {
-		return buf.Bytes(), err
-	}
-
-	err = jpeg.Encode(buf, *coverImage, &jpeg.Options{Quality: 70})
-	if err == nil {
-		return buf.Bytes(), err
-	}
-
-	return nil, err
-}
-
 var titlePattern = regexp.MustCompile("title")
 
 // Title returns the publication's title metadata.
@@ -188,7 +53,7 @@ func (r *Reader) Title() (title string) {
 				if err != nil {
 					continue
 				}
-				title = getTextByEpubType(htmlNode, "title")
+				title = getTextByEpubType(htmlNode, SemanticTitle)
 				return
 			}
 		}
@@ -201,9 +66,9 @@ func (r *Reader) Title() (title string) {
 	for _, ref := range r.epub.resources {
 		if titlePattern.MatchString(ref.ID) || titlePattern.MatchString(ref.Href) {
 			htmlNode, _ := r.parseHTML(ref.Content)
-			title = getTextByEpubType(htmlNode, "title")
+			title = getTextByEpubType(htmlNode, SemanticTitle)
 			if title == "" {
-				getTextByEpubType(htmlNode, "fulltitle")
+				title = getTextByEpubType(htmlNode, SemanticFullTitle)
 			}
 
 			if title != "" {
@@ -238,7 +103,7 @@ func (r *Reader) Author() (author string) {
 				if err != nil {
 					continue
 				}
-				author = getTextByEpubType(htmlNode, "author")
+				author = getTextByEpubType(htmlNode, "author", "z3998:author")
 				return
 			}
 		}
@@ -247,7 +112,7 @@ func (r *Reader) Author() (author string) {
 	for _, ref := range r.epub.resources {
 		if titlePattern.MatchString(ref.ID) || titlePattern.MatchString(ref.Href) {
 			htmlNode, _ := r.parseHTML(ref.Content)
-			author = getTextByEpubType(htmlNode, "author")
+			author = getTextByEpubType(htmlNode, "author", "z3998:author")
 
 			if author != "" {
 				return
@@ -326,11 +191,7 @@ func extractDescriptionFromOptionalMeta(metadata map[string]any) (description st
 
 func extractDescriptionFromEpubType(epubType string, htmlNode *html.Node) (description string) {
 	for desc := range htmlNode.Descendants() {
-		abstractIndex := slices.IndexFunc(desc.Attr, func(attr html.Attribute) bool {
-			return attr.Key == "epub:type" && attr.Val == epubType
-		})
-
-		if abstractIndex > -1 {
+		if HasSemantic(desc, Semantic(epubType)) {
 			descByte, err := htmltomarkdown.ConvertNode(desc)
 			if err != nil {
 				continue
@@ -345,19 +206,16 @@ var coverPagePattern = regexp.MustCompile("cover")
 
 func (r *Reader) extractDescriptionFromSpine() (description string) {
 	spine := r.Spine()
-	introTypes := []string{"abstract", "foreword", "introduction", "preamble", "preface", "prologue"}
+	introTypes := []Semantic{SemanticAbstract, SemanticForeword, SemanticIntroduction, SemanticPreamble, SemanticPreface, SemanticPrologue}
 	for _, res := range spine {
 		htmlNode := r.ReadContentHTMLByHref(res.Href)
 		if htmlNode == nil {
 			continue
 		}
 
+		prefixes := semanticPrefixes(htmlNode)
 		for desc := range htmlNode.Descendants() {
-			abstractIndex := slices.IndexFunc(desc.Attr, func(attr html.Attribute) bool {
-				return attr.Key == "epub:type" && slices.Contains(introTypes, attr.Val)
-			})
-
-			if abstractIndex > -1 {
+			if hasAnySemantic(desc, prefixes, introTypes) {
 				descByte, err := htmltomarkdown.ConvertNode(desc)
 				if err == nil {
 					return string(descByte)
@@ -418,7 +276,7 @@ func (r *Reader) extractDescriptionFromFirstFullContentTOCItem() (description st
 		return
 	}
 
-	content := r.ReadContentHTMLByHref(firstContentItem.Href)
+	content := firstContentItem.ReadContentHTML()
 
 	body := getBody(content)
 	markdownBody, _ := htmltomarkdown.ConvertNode(body)
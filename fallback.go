@@ -0,0 +1,230 @@
+package epub
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+// Reasons a fallback chain cannot be resolved.
+const (
+	FallbackCircular    = "circular"
+	FallbackBroken      = "broken"
+	FallbackUnsupported = "unsupported"
+)
+
+// FallbackError reports a manifest fallback chain that cannot be resolved.
+type FallbackError struct {
+	// ID is the manifest item the chain starts from.
+	ID string
+
+	// Chain lists the item IDs visited, in order. For a broken chain the
+	// last ID is the one missing from the manifest.
+	Chain []string
+
+	// Reason is FallbackCircular, FallbackBroken or FallbackUnsupported.
+	Reason string
+}
+
+func (e *FallbackError) Error() string {
+	switch e.Reason {
+	case FallbackCircular:
+		return fmt.Sprintf("fallback chain of %q is circular: %s", e.ID, strings.Join(e.Chain, " -> "))
+	case FallbackBroken:
+		return fmt.Sprintf("fallback chain of %q is broken: item %q not found", e.ID, e.Chain[len(e.Chain)-1])
+	default:
+		return fmt.Sprintf("fallback chain of %q has no supported media type: %s", e.ID, strings.Join(e.Chain, " -> "))
+	}
+}
+
+// FallbackChain returns the manifest item with the given ID followed by
+// its fallbacks, in order. A circular or broken chain is reported with a
+// *FallbackError along with the items found until then.
+func (r *Reader) FallbackChain(id string) (chain []pkg.Item, err error) {
+	return r.fallbackResolver().chain(id)
+}
+
+// ResolveFallback returns the first resource of the fallback chain of the
+// manifest item whose media type is one of mediaTypes, or a core media
+// type when none are given.
+func (r *Reader) ResolveFallback(id string, mediaTypes ...string) (*PublicationResource, error) {
+	return r.fallbackResolver().resolve(id, mediaTypes...)
+}
+
+// RenderableSpine returns, in reading order, the first XHTML or SVG content
+// document of the fallback chain of every spine item. Items that cannot be
+// resolved are left out and reported in the joined error.
+func (r *Reader) RenderableSpine() (resources []PublicationResource, err error) {
+	resolver := r.fallbackResolver()
+	var errs []error
+	for _, itemRef := range r.CurrentSelectedPackage().Spine.ItemRefs {
+		res, resolveErr := resolver.resolve(itemRef.IDRef, pkg.ContentDocumentMediaTypes...)
+		if resolveErr != nil {
+			errs = append(errs, resolveErr)
+			continue
+		}
+		resources = append(resources, *res)
+	}
+	return resources, errors.Join(errs...)
+}
+
+// fallbackResolver follows fallback chains through the manifest items and
+// resources of a package, indexed once by ID.
+type fallbackResolver struct {
+	items     map[string]pkg.Item
+	resources map[string]PublicationResource
+}
+
+func (r *Reader) fallbackResolver() *fallbackResolver {
+	resolver := &fallbackResolver{items: map[string]pkg.Item{}, resources: map[string]PublicationResource{}}
+	for _, item := range r.CurrentSelectedPackage().Manifest.Items {
+		resolver.items[item.ID] = item
+	}
+	for _, res := range r.epub.resources {
+		if _, ok := resolver.resources[res.ID]; !ok {
+			resolver.resources[res.ID] = res
+		}
+	}
+	return resolver
+}
+
+func (resolver *fallbackResolver) chain(id string) (chain []pkg.Item, err error) {
+	ids := []string{}
+	for next := id; next != ""; {
+		if slices.Contains(ids, next) {
+			return chain, &FallbackError{ID: id, Chain: append(ids, next), Reason: FallbackCircular}
+		}
+		ids = append(ids, next)
+
+		item, ok := resolver.items[next]
+		if !ok {
+			return chain, &FallbackError{ID: id, Chain: ids, Reason: FallbackBroken}
+		}
+		chain = append(chain, item)
+		next = item.Fallback
+	}
+	return
+}
+
+func (resolver *fallbackResolver) resolve(id string, mediaTypes ...string) (*PublicationResource, error) {
+	if len(mediaTypes) == 0 {
+		mediaTypes = pkg.CoreMediaTypes
+	}
+
+	chain, err := resolver.chain(id)
+	for _, item := range chain {
+		if isMediaTypeIn(item.MediaType, mediaTypes) {
+			if res, ok := resolver.resources[item.ID]; ok {
+				return &res, nil
+			}
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, item := range chain {
+		ids = append(ids, item.ID)
+	}
+	return nil, &FallbackError{ID: id, Chain: ids, Reason: FallbackUnsupported}
+}
+
+// CheckFallbacks reports every manifest item whose fallback chain is
+// circular or broken, and every spine item that does not resolve to a
+// content document.
+func (r *Reader) CheckFallbacks() error {
+	resolver := r.fallbackResolver()
+	var errs []error
+	for _, item := range r.CurrentSelectedPackage().Manifest.Items {
+		if item.Fallback == "" {
+			continue
+		}
+		if _, err := resolver.chain(item.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, itemRef := range r.CurrentSelectedPackage().Spine.ItemRefs {
+		_, err := resolver.resolve(itemRef.IDRef, pkg.ContentDocumentMediaTypes...)
+		var fallbackErr *FallbackError
+		if errors.As(err, &fallbackErr) && fallbackErr.Reason == FallbackUnsupported {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isMediaTypeIn reports whether mediaType, ignoring parameters such as
+// codecs, is one of types.
+func isMediaTypeIn(mediaType string, types []string) bool {
+	if parsed, _, err := mime.ParseMediaType(mediaType); err == nil {
+		mediaType = parsed
+	}
+	return slices.Contains(types, strings.ToLower(mediaType))
+}
+
+// AddResource adds a resource with the given href, relative to the
+// content directory, and media type to the manifest without adding it to
+// the spine. It is typically used for fallback documents.
+func (w *Writer) AddResource(href string, mediaType string, content []byte) (res PublicationResource) {
+	return w.addResource(
+		filepath.Base(href),
+		path.Join(w.contentDir, href),
+		href,
+		pkg.NotProperty,
+		mediaType,
+		content,
+	)
+}
+
+// AddForeignContent adds a spine item whose media type is not a content
+// document, such as a PDF, declaring fallbackID as its fallback. The
+// fallback chain must end at an XHTML or SVG content document.
+func (w *Writer) AddForeignContent(filename string, mediaType string, content []byte, fallbackID string) (res PublicationResource, err error) {
+	if w.resourceByID(fallbackID) == nil {
+		return res, fmt.Errorf("fallback %q not found", fallbackID)
+	}
+
+	res = w.AddResource(filename, mediaType, content)
+	if err = w.Fallback(res.ID, fallbackID); err != nil {
+		return
+	}
+
+	w.AddSpineItem(res)
+	return
+}
+
+// Fallback declares fallbackID as the fallback of the manifest item id.
+// Both items must exist and the chain may not become circular.
+func (w *Writer) Fallback(id string, fallbackID string) error {
+	items := w.epub.SelectedPackage().Manifest.Items
+	index := slices.IndexFunc(items, func(item pkg.Item) bool { return item.ID == id })
+	if index < 0 {
+		return fmt.Errorf("item %q not found", id)
+	}
+
+	chain := []string{id}
+	for next := fallbackID; next != ""; {
+		if slices.Contains(chain, next) {
+			return &FallbackError{ID: id, Chain: append(chain, next), Reason: FallbackCircular}
+		}
+		chain = append(chain, next)
+
+		nextIndex := slices.IndexFunc(items, func(item pkg.Item) bool { return item.ID == next })
+		if nextIndex < 0 {
+			return &FallbackError{ID: id, Chain: chain, Reason: FallbackBroken}
+		}
+		next = items[nextIndex].Fallback
+	}
+
+	items[index].Fallback = fallbackID
+	return nil
+}
@@ -0,0 +1,120 @@
+package epub
+
+import (
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+func TestReader_FallbackChain(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/content.opf"] = strings.NewReplacer(
+		`<item id="chapter-2" href="text/chapter-2.xhtml" media-type="application/xhtml+xml"/>`,
+		`<item id="chapter-2" href="text/chapter-2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="report" href="report.pdf" media-type="application/pdf" fallback="report-xml"/>
+    <item id="report-xml" href="report.xml" media-type="application/x-report+xml" fallback="report-html"/>
+    <item id="report-html" href="text/report.xhtml" media-type="application/xhtml+xml"/>
+    <item id="loop-a" href="a.bin" media-type="application/octet-stream" fallback="loop-b"/>
+    <item id="loop-b" href="b.bin" media-type="application/octet-stream" fallback="loop-a"/>
+    <item id="broken" href="c.bin" media-type="application/octet-stream" fallback="missing"/>
+    <item id="opus" href="audio.opus" media-type="audio/ogg; codecs=opus"/>`,
+		`<itemref idref="chapter-2"/>`,
+		`<itemref idref="chapter-2"/>
+    <itemref idref="report"/>
+    <itemref idref="loop-a"/>`,
+	).Replace(files["OEBPS/content.opf"])
+	files["OEBPS/text/report.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Report</title></head><body><p>Report</p></body></html>`
+
+	r := newTestReader(t, files)
+
+	chain, err := r.FallbackChain("report")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chain) != 3 || chain[2].ID != "report-html" {
+		t.Errorf("unexpected chain %+v", chain)
+	}
+
+	var fallbackErr *FallbackError
+	if _, err := r.FallbackChain("loop-a"); !errors.As(err, &fallbackErr) || fallbackErr.Reason != FallbackCircular {
+		t.Errorf("expected circular chain error, got %v", err)
+	}
+	if _, err := r.FallbackChain("broken"); !errors.As(err, &fallbackErr) || fallbackErr.Reason != FallbackBroken {
+		t.Errorf("expected broken chain error, got %v", err)
+	}
+
+	res, err := r.ResolveFallback("report")
+	if err != nil || res.ID != "report-html" {
+		t.Errorf("expected report-html, got %+v, %v", res, err)
+	}
+	if res, err := r.ResolveFallback("opus"); err != nil || res.ID != "opus" {
+		t.Errorf("expected opus to be a core media type, got %+v, %v", res, err)
+	}
+
+	spine, err := r.RenderableSpine()
+	if len(spine) != 3 || spine[2].ID != "report-html" {
+		t.Errorf("unexpected renderable spine %+v", spine)
+	}
+	if !errors.As(err, &fallbackErr) || fallbackErr.ID != "loop-a" {
+		t.Errorf("expected error for loop-a, got %v", err)
+	}
+
+	err = r.CheckFallbacks()
+	for _, id := range []string{`"loop-a"`, `"loop-b"`, `"broken"`} {
+		if err == nil || !strings.Contains(err.Error(), id) {
+			t.Errorf("expected %s to be reported, got %v", id, err)
+		}
+	}
+}
+
+func TestWriter_Fallback(t *testing.T) {
+	w := New("urn:uuid:fallbacks")
+	w.Title("Fallbacks")
+	w.Languages("en")
+	w.Cover([]byte("\x89PNG\r\n\x1a\n"))
+	w.AddContent("intro.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Intro</title></head><body><p>Intro</p></body></html>`))
+
+	fallback := w.AddResource("report.xhtml", pkg.MediaTypeXHTML, []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Report</title></head><body><p>Report</p></body></html>`))
+	if _, err := w.AddForeignContent("report.pdf", "application/pdf", []byte("%PDF-1.7"), "missing"); err == nil {
+		t.Errorf("expected error for a missing fallback")
+	}
+	if _, err := w.AddForeignContent("report.pdf", "application/pdf", []byte("%PDF-1.7"), fallback.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Fallback(fallback.ID, "report.pdf"); err == nil {
+		t.Errorf("expected error for a circular fallback")
+	}
+
+	if err := w.TableOfContents("toc", TOC{Items: []TOC{{Title: "Intro", Href: "intro.xhtml"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+
+	if err := r.CheckFallbacks(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	spine, err := r.RenderableSpine()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(spine) != 2 || spine[1].ID != "report.xhtml" {
+		t.Errorf("unexpected renderable spine %+v", spine)
+	}
+
+	if spine := r.Spine(); len(spine) != 2 || spine[1].ID != "report.pdf" || spine[1].MIMEType != "application/pdf" {
+		t.Errorf("expected the pdf in the spine, got %+v", spine)
+	}
+
+	documents := r.ContentDocumentXHTML()
+	if _, ok := documents["report.pdf"]; ok {
+		t.Errorf("expected no document keyed to the pdf")
+	}
+	if document := documents["report.xhtml"]; document == nil || !strings.Contains(GetTextContent(document), "Report") {
+		t.Errorf("expected the fallback document, got %v", documents)
+	}
+}
@@ -0,0 +1,281 @@
+package epub
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+)
+
+// RenditionProperties holds the fixed-layout rendition settings, either
+// declared for the whole package or in effect for a single spine item.
+type RenditionProperties struct {
+	// Layout is pkg.LayoutReflowable or pkg.LayoutPrePaginated.
+	Layout string `json:"layout"`
+
+	// Orientation is one of the pkg.Orientation values.
+	Orientation string `json:"orientation"`
+
+	// Spread is one of the pkg.Spread values.
+	Spread string `json:"spread"`
+
+	// Viewport is the deprecated rendition:viewport value, e.g.
+	// "width=1200, height=1600".
+	Viewport string `json:"viewport,omitempty"`
+}
+
+// SpineItemRendition is the rendition of one spine item after applying its
+// itemref overrides to the package settings.
+type SpineItemRendition struct {
+	RenditionProperties
+
+	IDRef string `json:"idref"`
+	Href  string `json:"href"`
+
+	// PageSpread is pkg.PageSpreadLeft, pkg.PageSpreadRight,
+	// pkg.PageSpreadCenter or empty when the item does not force a side.
+	PageSpread string `json:"pageSpread,omitempty"`
+}
+
+// Viewport is the initial containing block of a fixed-layout document.
+type Viewport struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// IsFixedLayout reports whether the selected package is pre-paginated.
+func (r *Reader) IsFixedLayout() bool {
+	return r.RenditionProperties().Layout == pkg.LayoutPrePaginated
+}
+
+// RenditionProperties returns the package level rendition settings of the
+// selected package, using the defaults of the specification for settings
+// that are not declared.
+func (r *Reader) RenditionProperties() (properties RenditionProperties) {
+	properties = RenditionProperties{
+		Layout:      pkg.LayoutReflowable,
+		Orientation: pkg.OrientationAuto,
+		Spread:      pkg.SpreadAuto,
+	}
+
+	for _, meta := range r.CurrentSelectedPackage().Metadata.Meta {
+		if meta.Refines != "" {
+			continue
+		}
+
+		value := strings.TrimSpace(meta.Value)
+		switch meta.Property {
+		case pkg.RenditionLayout:
+			properties.Layout = value
+		case pkg.RenditionOrientation:
+			properties.Orientation = value
+		case pkg.RenditionSpread:
+			properties.Spread = value
+		case pkg.RenditionViewport:
+			properties.Viewport = value
+		}
+	}
+	return
+}
+
+// SpineRenditions returns the rendition in effect for every spine item, in
+// reading order.
+func (r *Reader) SpineRenditions() (renditions []SpineItemRendition) {
+	packagePub := r.CurrentSelectedPackage()
+	defaults := r.RenditionProperties()
+
+	hrefs := map[string]string{}
+	for _, item := range packagePub.Manifest.Items {
+		hrefs[item.ID] = item.Href
+	}
+
+	for _, itemRef := range packagePub.Spine.ItemRefs {
+		rendition := SpineItemRendition{
+			RenditionProperties: defaults,
+			IDRef:               itemRef.IDRef,
+			Href:                hrefs[itemRef.IDRef],
+		}
+
+		for _, property := range strings.Fields(itemRef.Properties) {
+			property = strings.TrimPrefix(property, "rendition:")
+			name, value, _ := strings.Cut(property, "-")
+
+			switch name {
+			case "layout":
+				rendition.Layout = value
+			case "orientation":
+				rendition.Orientation = value
+			case "spread":
+				rendition.Spread = value
+			case "page":
+				if side, ok := strings.CutPrefix(value, "spread-"); ok {
+					rendition.PageSpread = side
+				}
+			}
+		}
+
+		renditions = append(renditions, rendition)
+	}
+	return
+}
+
+// ContentViewport returns the viewport declared by the meta viewport element
+// of the content document with the given manifest id.
+func (r *Reader) ContentViewport(id string) (viewport Viewport, ok bool) {
+	doc := r.ReadContentHTMLById(id)
+	if doc == nil {
+		return
+	}
+
+	meta := FindNode(doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "meta" && getAttribute(n, "name") == "viewport"
+	})
+	if meta == nil {
+		return
+	}
+
+	return parseViewport(getAttribute(meta, "content"))
+}
+
+// parseViewport reads the width and height of a viewport declaration such
+// as "width=1200, height=1600".
+func parseViewport(content string) (viewport Viewport, ok bool) {
+	for _, declaration := range strings.FieldsFunc(content, func(r rune) bool { return r == ',' || r == ';' }) {
+		name, value, _ := strings.Cut(declaration, "=")
+		number, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "px"))
+		if err != nil {
+			continue
+		}
+
+		switch strings.TrimSpace(name) {
+		case "width":
+			viewport.Width = number
+		case "height":
+			viewport.Height = number
+		}
+	}
+
+	return viewport, viewport.Width > 0 && viewport.Height > 0
+}
+
+// setPackageMeta sets a package level meta property, replacing any
+// previous declaration of the same property.
+func (w *Writer) setPackageMeta(property string, value string) {
+	metadata := &w.epub.SelectedPackage().Metadata
+	metadata.Meta = slices.DeleteFunc(metadata.Meta, func(meta pkg.Meta) bool {
+		return meta.Property == property && meta.Refines == ""
+	})
+	w.MetaProperty("", property, value)
+}
+
+// RenditionLayout sets the layout of the whole publication, usually
+// pkg.LayoutPrePaginated for fixed-layout books.
+func (w *Writer) RenditionLayout(layout string) {
+	w.setPackageMeta(pkg.RenditionLayout, layout)
+}
+
+// RenditionOrientation sets the orientation reading systems should use.
+func (w *Writer) RenditionOrientation(orientation string) {
+	w.setPackageMeta(pkg.RenditionOrientation, orientation)
+}
+
+// RenditionSpread sets when two pages are shown side by side.
+func (w *Writer) RenditionSpread(spread string) {
+	w.setPackageMeta(pkg.RenditionSpread, spread)
+}
+
+// RenditionViewport sets the deprecated package level viewport, which
+// older reading systems use when content documents declare none.
+func (w *Writer) RenditionViewport(width int, height int) {
+	w.setPackageMeta(pkg.RenditionViewport, fmt.Sprintf("width=%d, height=%d", width, height))
+}
+
+// SpineItemRendition overrides a rendition property for one spine item,
+// e.g. SpineItemRendition("page-1", "spread", pkg.SpreadNone).
+func (w *Writer) SpineItemRendition(idref string, property string, value string) error {
+	return w.setItemRefProperty(idref, "rendition:"+property+"-", value)
+}
+
+// PageSpread forces the spine item onto the left or right page of a
+// spread, or centers it, using pkg.PageSpreadLeft, pkg.PageSpreadRight or
+// pkg.PageSpreadCenter.
+func (w *Writer) PageSpread(idref string, spread string) error {
+	if spread == pkg.PageSpreadCenter {
+		return w.setItemRefProperty(idref, "rendition:page-spread-", spread)
+	}
+	return w.setItemRefProperty(idref, "page-spread-", spread)
+}
+
+// setItemRefProperty sets the itemref property prefix+value, removing
+// other values of the same property.
+func (w *Writer) setItemRefProperty(idref string, prefix string, value string) error {
+	spine := &w.epub.SelectedPackage().Spine
+	index := slices.IndexFunc(spine.ItemRefs, func(itemRef pkg.ItemRef) bool {
+		return itemRef.IDRef == idref
+	})
+	if index < 0 {
+		return fmt.Errorf("spine item %q does not exist", idref)
+	}
+
+	// Page spreads may be written with or without the rendition prefix.
+	unprefixed := strings.TrimPrefix(prefix, "rendition:")
+	properties := slices.DeleteFunc(strings.Fields(spine.ItemRefs[index].Properties), func(property string) bool {
+		return strings.HasPrefix(strings.TrimPrefix(property, "rendition:"), unprefixed)
+	})
+	spine.ItemRefs[index].Properties = strings.Join(append(properties, prefix+value), " ")
+	return nil
+}
+
+// ContentViewport sets the viewport meta element of the content document
+// with the given manifest id, replacing an existing declaration.
+func (w *Writer) ContentViewport(id string, width int, height int) (err error) {
+	index := slices.IndexFunc(w.epub.resources, func(res PublicationResource) bool {
+		return res.ID == id
+	})
+	if index < 0 {
+		return fmt.Errorf("resource %q does not exist", id)
+	}
+
+	content, err := setViewport(w.epub.resources[index].Content, width, height)
+	if err != nil {
+		return
+	}
+
+	w.replaceResourceContent(id, content)
+	return
+}
+
+func setViewport(content []byte, width int, height int) ([]byte, error) {
+	doc, err := parseXHTML(content)
+	if err != nil {
+		return nil, err
+	}
+
+	head := FindNode(doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "head"
+	})
+	if head == nil {
+		return nil, fmt.Errorf("content document has no head")
+	}
+
+	viewport := fmt.Sprintf("width=%d, height=%d", width, height)
+	meta := FindNode(head, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "meta" && getAttribute(n, "name") == "viewport"
+	})
+	if meta == nil {
+		meta = &html.Node{
+			Type: html.ElementNode,
+			Data: "meta",
+			Attr: []html.Attribute{{Key: "name", Val: "viewport"}},
+		}
+		head.InsertBefore(meta, head.FirstChild)
+	}
+
+	meta.Attr = slices.DeleteFunc(meta.Attr, func(attr html.Attribute) bool { return attr.Key == "content" })
+	meta.Attr = append(meta.Attr, html.Attribute{Key: "content", Val: viewport})
+
+	return renderXHTML(doc)
+}
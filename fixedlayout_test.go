@@ -0,0 +1,116 @@
+package epub
+
+import (
+	"testing"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+func TestReader_SpineRenditionsDefaults(t *testing.T) {
+	r := newTestReader(t, testEPUB3Files)
+
+	if r.IsFixedLayout() {
+		t.Errorf("expected a reflowable publication")
+	}
+
+	renditions := r.SpineRenditions()
+	if len(renditions) != 2 {
+		t.Fatalf("expected two spine items, got %+v", renditions)
+	}
+
+	first := renditions[0]
+	if first.Layout != pkg.LayoutReflowable || first.Spread != pkg.SpreadAuto || first.PageSpread != pkg.PageSpreadRight {
+		t.Errorf("unexpected first item rendition %+v", first)
+	}
+
+	if first.Href != "text/chapter-1.xhtml" || renditions[1].PageSpread != "" {
+		t.Errorf("unexpected spine renditions %+v", renditions)
+	}
+}
+
+func TestWriter_FixedLayout(t *testing.T) {
+	w := New("urn:uuid:comic")
+	w.Title("Comic")
+	w.Languages("ja")
+	w.Cover([]byte("\x89PNG\r\n\x1a\n"))
+
+	page := []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Page</title></head><body><img src="p.png" alt=""/></body></html>`)
+	w.AddContent("page-1.xhtml", page)
+	w.AddContent("page-2.xhtml", page)
+	w.AddContent("page-3.xhtml", page)
+	if err := w.TableOfContents("toc", TOC{Items: []TOC{{Title: "Start", Href: "page-1.xhtml"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w.RenditionLayout(pkg.LayoutReflowable)
+	w.RenditionLayout(pkg.LayoutPrePaginated)
+	w.RenditionOrientation(pkg.OrientationLandscape)
+	w.RenditionSpread(pkg.SpreadBoth)
+	w.RenditionViewport(1200, 1600)
+
+	for id, spread := range map[string]string{
+		"page-1.xhtml": pkg.PageSpreadRight,
+		"page-2.xhtml": pkg.PageSpreadCenter,
+	} {
+		if err := w.PageSpread(id, pkg.PageSpreadLeft); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := w.PageSpread(id, spread); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := w.SpineItemRendition("page-3.xhtml", "layout", pkg.LayoutReflowable); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.SpineItemRendition("page-3.xhtml", "spread", pkg.SpreadNone); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.PageSpread("missing", pkg.PageSpreadLeft); err == nil {
+		t.Errorf("expected error for a missing spine item")
+	}
+
+	if err := w.ContentViewport("page-1.xhtml", 1200, 1600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.ContentViewport("page-1.xhtml", 800, 600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+
+	if !r.IsFixedLayout() {
+		t.Fatalf("expected a fixed-layout publication")
+	}
+
+	expected := RenditionProperties{
+		Layout:      pkg.LayoutPrePaginated,
+		Orientation: pkg.OrientationLandscape,
+		Spread:      pkg.SpreadBoth,
+		Viewport:    "width=1200, height=1600",
+	}
+	if properties := r.RenditionProperties(); properties != expected {
+		t.Errorf("expected %+v, got %+v", expected, properties)
+	}
+
+	renditions := r.SpineRenditions()
+	if renditions[0].PageSpread != pkg.PageSpreadRight || renditions[1].PageSpread != pkg.PageSpreadCenter {
+		t.Errorf("unexpected page spreads %+v", renditions)
+	}
+	if third := renditions[2]; third.Layout != pkg.LayoutReflowable || third.Spread != pkg.SpreadNone || third.Orientation != pkg.OrientationLandscape {
+		t.Errorf("unexpected overrides %+v", third)
+	}
+
+	itemRefs := r.CurrentSelectedPackage().Spine.ItemRefs
+	if itemRefs[0].Properties != "page-spread-right" || itemRefs[1].Properties != "rendition:page-spread-center" {
+		t.Errorf("unexpected itemref properties %q, %q", itemRefs[0].Properties, itemRefs[1].Properties)
+	}
+
+	viewport, ok := r.ContentViewport("page-1.xhtml")
+	if !ok || viewport != (Viewport{Width: 800, Height: 600}) {
+		t.Errorf("unexpected viewport %+v", viewport)
+	}
+
+	if _, ok := r.ContentViewport("page-2.xhtml"); ok {
+		t.Errorf("expected no viewport on page 2")
+	}
+}
@@ -0,0 +1,567 @@
+package epub
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/raitucarp/epub/ocf"
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/net/html"
+)
+
+// FontFormat is the format of a font resource, detected from its content.
+type FontFormat string
+
+const (
+	FontTrueType   FontFormat = "ttf"
+	FontOpenType   FontFormat = "otf"
+	FontCollection FontFormat = "ttc"
+	FontWOFF       FontFormat = "woff"
+	FontWOFF2      FontFormat = "woff2"
+)
+
+// Font obfuscation algorithms declared in META-INF/encryption.xml.
+const (
+	FontObfuscationIDPF  = "http://www.idpf.org/2008/embedding"
+	FontObfuscationAdobe = "http://ns.adobe.com/pdf/enc#RC"
+)
+
+// fontMediaTypes are the font media types, including legacy ones.
+var fontMediaTypes = []string{
+	pkg.MediaTypeTTF,
+	pkg.MediaTypeOTF,
+	pkg.MediaTypeWOFF,
+	pkg.MediaTypeWOFF2,
+	"font/sfnt",
+	"font/collection",
+	"application/font-sfnt",
+	"application/vnd.ms-opentype",
+	"application/font-woff",
+	"application/x-font-ttf",
+	"application/x-font-truetype",
+	"application/x-font-otf",
+	"application/x-font-opentype",
+}
+
+var fontExtensions = []string{".ttf", ".otf", ".ttc", ".woff", ".woff2"}
+
+func isFontResource(res *PublicationResource) bool {
+	return res != nil && (slices.Contains(fontMediaTypes, res.MIMEType) ||
+		slices.Contains(fontExtensions, strings.ToLower(path.Ext(res.Href))))
+}
+
+// FontInfo describes an embedded font resource.
+type FontInfo struct {
+	ID        string `json:"id"`
+	Href      string `json:"href"`
+	MediaType string `json:"mediaType"`
+
+	// Format is detected from the content, after deobfuscation.
+	Format FontFormat `json:"format"`
+
+	// Family and Style are the typographic family and subfamily names of
+	// the name table, or the legacy ones when missing. Collections are
+	// described by their first font.
+	Family         string `json:"family,omitempty"`
+	Style          string `json:"style,omitempty"`
+	FullName       string `json:"fullName,omitempty"`
+	PostScriptName string `json:"postScriptName,omitempty"`
+	NumGlyphs      int    `json:"numGlyphs,omitempty"`
+
+	// Size is the byte size of the resource.
+	Size int `json:"size"`
+
+	// Obfuscated reports fonts obfuscated with the IDPF or Adobe algorithm
+	// in META-INF/encryption.xml. They are deobfuscated before parsing.
+	Obfuscated bool `json:"obfuscated,omitempty"`
+}
+
+// FontFace is an @font-face rule of a style sheet or a style element.
+type FontFace struct {
+	Family       string `json:"family"`
+	Style        string `json:"style,omitempty"`
+	Weight       string `json:"weight,omitempty"`
+	UnicodeRange string `json:"unicodeRange,omitempty"`
+
+	// Src are the urls of the src descriptor, in order.
+	Src []string `json:"src,omitempty"`
+
+	// ResourceID and Href identify the first font of src found in the
+	// manifest, and are empty when none is.
+	ResourceID string `json:"resourceId,omitempty"`
+	Href       string `json:"href,omitempty"`
+
+	// StyleSheet is the href of the style sheet, or of the content
+	// document for style elements.
+	StyleSheet string `json:"styleSheet"`
+}
+
+// FontUsage reports the characters of the content documents that may be
+// rendered with a font.
+type FontUsage struct {
+	ID   string `json:"id"`
+	Href string `json:"href"`
+
+	// Families are the font-family names given to the font by @font-face
+	// rules. Fonts without any are not used by style sheets.
+	Families []string `json:"families"`
+
+	// Runes are the characters of the content documents that link a style
+	// sheet declaring the font and referencing one of its families,
+	// restricted to the unicode-range of the rules, and of the content
+	// property strings of those style sheets. They are sorted.
+	Runes []rune `json:"runes"`
+
+	// Glyphs are the sorted glyph indices of Runes, and Missing the runes
+	// the font has no glyph for. Both are empty when the font cannot be
+	// parsed.
+	Glyphs  []sfnt.GlyphIndex `json:"glyphs,omitempty"`
+	Missing []rune            `json:"missing,omitempty"`
+}
+
+// FontInfo returns the description of the font resource with the given
+// manifest ID.
+func (r *Reader) FontInfo(id string) (*FontInfo, error) {
+	res := r.SelectResourceById(id)
+	if res == nil {
+		return nil, fmt.Errorf("resource %q not found", id)
+	}
+	if !isFontResource(res) {
+		return nil, fmt.Errorf("resource %q is not a font but %s", id, res.MIMEType)
+	}
+
+	info, err := r.fontSource().fontInfo(*res)
+	if err != nil {
+		return nil, fmt.Errorf("read font %q: %w", id, err)
+	}
+	return &info, nil
+}
+
+// Fonts returns the description of every font resource, in manifest
+// order. Fonts that cannot be parsed, such as WOFF2 font collections, are
+// listed without names.
+func (r *Reader) Fonts() (fonts []FontInfo) {
+	source := r.fontSource()
+	for _, res := range r.epub.resources {
+		if !isFontResource(&res) {
+			continue
+		}
+
+		info, _ := source.fontInfo(res)
+		fonts = append(fonts, info)
+	}
+	return
+}
+
+// FontFaces returns the @font-face rules of the style sheets and of the
+// style elements of content documents, in manifest order.
+func (r *Reader) FontFaces() []FontFace {
+	return r.fontSource().fontFaces()
+}
+
+// FontUsage computes the characters and glyphs of every font resource
+// used by the content documents, in manifest order. A font is used by the
+// documents that link, import or embed a style sheet with an @font-face
+// rule for it, and reference its family in a font or font-family
+// declaration or attribute. Selectors are not matched, so the result is
+// a superset of what reading systems render.
+func (r *Reader) FontUsage() []FontUsage {
+	return r.fontSource().fontUsage()
+}
+
+// fontSource holds what font inspection needs from a Reader or a Writer.
+type fontSource struct {
+	styleSource
+	files      map[string][]byte
+	identifier string
+}
+
+func (r *Reader) fontSource() *fontSource {
+	return &fontSource{
+		styleSource: styleSource{resources: r.epub.resources},
+		files:       r.epub.zipContainer.AllFiles(),
+		identifier:  r.uniqueIdentifier(),
+	}
+}
+
+// uniqueIdentifier returns the identifier the unique-identifier attribute
+// of the package references. Opened packages keep Dublin Core elements in
+// OptionalDC.
+func (r *Reader) uniqueIdentifier() string {
+	packagePub := r.CurrentSelectedPackage()
+	normalized := pkg.Package{UniqueIdentifier: packagePub.UniqueIdentifier}
+	normalized.Metadata.Identifiers = slices.Clone(packagePub.Metadata.Identifiers)
+	normalized.Metadata.OptionalDC = packagePub.Metadata.OptionalDC
+	normalizeDublinCore(&normalized.Metadata)
+	return packageUID(&normalized)
+}
+
+func (w *Writer) fontSource() *fontSource {
+	return &fontSource{
+		styleSource: styleSource{resources: w.epub.resources},
+		files:       w.epub.zipContainer.AllFiles(),
+		identifier:  packageUID(w.epub.SelectedPackage()),
+	}
+}
+
+func (source *fontSource) fontInfo(res PublicationResource) (info FontInfo, err error) {
+	info = FontInfo{ID: res.ID, Href: res.Href, MediaType: res.MIMEType, Size: len(res.Content)}
+
+	content, algorithm, err := source.deobfuscatedFont(res)
+	info.Obfuscated = algorithm != ""
+	if err != nil {
+		return
+	}
+
+	file, err := parseFontFile(content)
+	info.Format = file.format
+	if err != nil {
+		return
+	}
+
+	f, err := file.font()
+	if err != nil {
+		return
+	}
+
+	var buf sfnt.Buffer
+	info.Family = fontName(f, &buf, sfnt.NameIDTypographicFamily, sfnt.NameIDFamily)
+	info.Style = fontName(f, &buf, sfnt.NameIDTypographicSubfamily, sfnt.NameIDSubfamily)
+	info.FullName = fontName(f, &buf, sfnt.NameIDFull)
+	info.PostScriptName = fontName(f, &buf, sfnt.NameIDPostScript)
+	info.NumGlyphs = f.NumGlyphs()
+	return
+}
+
+// fontName returns the first of the given names found in the font.
+func fontName(f *sfnt.Font, buf *sfnt.Buffer, ids ...sfnt.NameID) string {
+	for _, id := range ids {
+		if name, err := f.Name(buf, id); err == nil && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// deobfuscatedFont returns the content of a font resource, deobfuscated
+// when encryption.xml obfuscates it, and the obfuscation algorithm.
+func (source *fontSource) deobfuscatedFont(res PublicationResource) ([]byte, string, error) {
+	algorithm := source.obfuscation(res)
+	if algorithm == "" {
+		return res.Content, "", nil
+	}
+
+	content, err := obfuscateFont(res.Content, algorithm, source.identifier)
+	return content, algorithm, err
+}
+
+// obfuscation returns the font obfuscation algorithm encryption.xml
+// declares for the resource, if any.
+func (source *fontSource) obfuscation(res PublicationResource) string {
+	data := source.files["META-INF/encryption.xml"]
+	if len(data) == 0 {
+		return ""
+	}
+
+	var encryption ocf.Encryption
+	if err := xml.Unmarshal(data, &encryption); err != nil {
+		return ""
+	}
+
+	resourcePath := unescapePath(res.Filepath)
+	for _, encrypted := range encryption.EncryptedData {
+		if encrypted.EncryptionMethod == nil || encrypted.CipherData.CipherReference == nil {
+			continue
+		}
+		if unescapePath(encrypted.CipherData.CipherReference.URI) != resourcePath {
+			continue
+		}
+
+		switch algorithm := encrypted.EncryptionMethod.Algorithm; algorithm {
+		case FontObfuscationIDPF, FontObfuscationAdobe:
+			return algorithm
+		}
+	}
+	return ""
+}
+
+// obfuscateFont applies an obfuscation algorithm keyed by the publication
+// unique identifier to a font. The algorithms are XOR masks of the start
+// of the file, so the same call deobfuscates.
+func obfuscateFont(content []byte, algorithm string, identifier string) ([]byte, error) {
+	var key []byte
+	var length int
+	switch algorithm {
+	case FontObfuscationIDPF:
+		identifier = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+				return -1
+			}
+			return r
+		}, identifier)
+		sum := sha1.Sum([]byte(identifier))
+		key, length = sum[:], 1040
+	case FontObfuscationAdobe:
+		uuid := strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(identifier), "urn:uuid:"), "-", "")
+		decoded, err := hex.DecodeString(uuid)
+		if err != nil || len(decoded) != 16 {
+			return nil, fmt.Errorf("identifier %q is not a UUID", identifier)
+		}
+		key, length = decoded, 1024
+	default:
+		return nil, fmt.Errorf("unsupported font obfuscation %q", algorithm)
+	}
+
+	result := slices.Clone(content)
+	for i := range min(length, len(result)) {
+		result[i] ^= key[i%len(key)]
+	}
+	return result, nil
+}
+
+// fontFamilies returns the lowercased family names of a font or
+// font-family value. The first item of a font shorthand keeps its size
+// and style keywords, matched as a suffix by referencesFamily.
+func fontFamilies(value string) (families []string) {
+	value = strings.NewReplacer(`"`, "", `'`, "", "!important", "").Replace(value)
+	for _, family := range strings.Split(value, ",") {
+		if family = strings.ToLower(strings.Join(strings.Fields(family), " ")); family != "" {
+			families = append(families, family)
+		}
+	}
+	return
+}
+
+func referencesFamily(families []string, family string) bool {
+	family = strings.ToLower(family)
+	for _, candidate := range families {
+		if candidate == family || strings.HasSuffix(candidate, " "+family) {
+			return true
+		}
+	}
+	return false
+}
+
+// declaredFamilies returns the family names of the font and font-family
+// declarations, and appends the strings of the content declarations to
+// content.
+func declaredFamilies(declarations []CSSDeclaration, content *strings.Builder) (families []string) {
+	for _, declaration := range declarations {
+		switch declaration.Property {
+		case "font", "font-family":
+			families = append(families, fontFamilies(declaration.Value)...)
+		case "content":
+			if content != nil {
+				content.WriteString(strings.Join(cssStrings(declaration.Value), ""))
+			}
+		}
+	}
+	return
+}
+
+// fontDocument is what font inspection reads from a content document.
+type fontDocument struct {
+	sheets   []*StyleSheet
+	families []string
+	text     string
+}
+
+func (source *fontSource) fontDocument(res PublicationResource) *fontDocument {
+	doc, err := parseXHTML(res.Content)
+	if err != nil {
+		return nil
+	}
+
+	document := &fontDocument{sheets: source.documentStyleSheets(res, doc)}
+	var text strings.Builder
+
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			text.WriteString(node.Data)
+		case html.ElementNode:
+			switch node.Data {
+			case "title", "script", "style":
+				return
+			}
+
+			if style := getAttribute(node, "style"); style != "" {
+				document.families = append(document.families, declaredFamilies(ParseCSSDeclarations(style), nil)...)
+			}
+			if family := getAttribute(node, "font-family"); family != "" {
+				document.families = append(document.families, fontFamilies(family)...)
+			}
+		}
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	document.text = text.String()
+	return document
+}
+
+func (source *fontSource) fontFaces() (faces []FontFace) {
+	for _, res := range source.resources {
+		switch res.MIMEType {
+		case pkg.MediaTypeCSS:
+			if sheet := source.styleSheet(unescapePath(res.Filepath)); sheet != nil {
+				faces = append(faces, sheet.FontFaces...)
+			}
+		case pkg.MediaTypeXHTML, pkg.MediaTypeSVG:
+			if !strings.Contains(strings.ToLower(string(res.Content)), "@font-face") {
+				continue
+			}
+			if document := source.fontDocument(res); document != nil {
+				for _, sheet := range document.sheets {
+					if sheet.ResourceID == res.ID {
+						faces = append(faces, sheet.FontFaces...)
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+func (source *fontSource) fontUsage() (usage []FontUsage) {
+	families := make(map[string][]string)
+	for _, face := range source.fontFaces() {
+		if face.ResourceID != "" && !slices.Contains(families[face.ResourceID], face.Family) {
+			families[face.ResourceID] = append(families[face.ResourceID], face.Family)
+		}
+	}
+
+	runes := make(map[string]map[rune]bool)
+	for _, res := range source.resources {
+		if res.MIMEType != pkg.MediaTypeXHTML && res.MIMEType != pkg.MediaTypeSVG {
+			continue
+		}
+
+		document := source.fontDocument(res)
+		if document == nil {
+			continue
+		}
+
+		referenced := slices.Clone(document.families)
+		var content strings.Builder
+		content.WriteString(document.text)
+		for _, sheet := range document.sheets {
+			for _, rule := range sheet.Rules {
+				referenced = append(referenced, declaredFamilies(rule.Declarations, &content)...)
+			}
+		}
+
+		for _, sheet := range document.sheets {
+			for _, face := range sheet.FontFaces {
+				if face.ResourceID == "" || !referencesFamily(referenced, face.Family) {
+					continue
+				}
+
+				ranges := parseUnicodeRange(face.UnicodeRange)
+				if runes[face.ResourceID] == nil {
+					runes[face.ResourceID] = make(map[rune]bool)
+				}
+				for _, r := range content.String() {
+					if !unicode.IsControl(r) && inUnicodeRange(ranges, r) {
+						runes[face.ResourceID][r] = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, res := range source.resources {
+		if !isFontResource(&res) {
+			continue
+		}
+
+		fontUsage := FontUsage{ID: res.ID, Href: res.Href, Families: families[res.ID], Runes: []rune{}}
+		for r := range runes[res.ID] {
+			fontUsage.Runes = append(fontUsage.Runes, r)
+		}
+		slices.Sort(fontUsage.Runes)
+
+		if f, err := source.parseFont(res); err == nil {
+			var buf sfnt.Buffer
+			glyphs := make(map[sfnt.GlyphIndex]bool)
+			for _, r := range fontUsage.Runes {
+				glyph, err := f.GlyphIndex(&buf, r)
+				if err != nil || glyph == 0 {
+					fontUsage.Missing = append(fontUsage.Missing, r)
+					continue
+				}
+				glyphs[glyph] = true
+			}
+			for glyph := range glyphs {
+				fontUsage.Glyphs = append(fontUsage.Glyphs, glyph)
+			}
+			slices.Sort(fontUsage.Glyphs)
+		}
+		usage = append(usage, fontUsage)
+	}
+	return
+}
+
+func (source *fontSource) parseFont(res PublicationResource) (*sfnt.Font, error) {
+	content, _, err := source.deobfuscatedFont(res)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := parseFontFile(content)
+	if err != nil {
+		return nil, err
+	}
+	return file.font()
+}
+
+// parseUnicodeRange parses a unicode-range descriptor into inclusive
+// ranges. An empty or invalid descriptor covers every code point.
+func parseUnicodeRange(value string) (ranges [][2]rune) {
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if !strings.HasPrefix(item, "U+") {
+			continue
+		}
+		item = item[2:]
+
+		low, high, found := strings.Cut(item, "-")
+		if !found {
+			low, high = strings.ReplaceAll(item, "?", "0"), strings.ReplaceAll(item, "?", "F")
+		}
+		start, err := strconv.ParseUint(low, 16, 32)
+		if err != nil {
+			continue
+		}
+		end, err := strconv.ParseUint(high, 16, 32)
+		if err != nil {
+			continue
+		}
+		ranges = append(ranges, [2]rune{rune(start), rune(end)})
+	}
+	return
+}
+
+func inUnicodeRange(ranges [][2]rune, r rune) bool {
+	if len(ranges) == 0 {
+		return true
+	}
+	for _, rng := range ranges {
+		if r >= rng[0] && r <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package epub
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func testWOFF(t *testing.T, ttf []byte) []byte {
+	t.Helper()
+	file, err := parseFontFile(ttf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.format = FontWOFF
+	woff, err := file.encodeWOFF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return woff
+}
+
+func testFontFiles(t *testing.T) map[string]string {
+	t.Helper()
+	bold, err := obfuscateFont(testWOFF(t, gobold.TTF), FontObfuscationIDPF, "urn:uuid:epub3-book")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], "</manifest>", `<item id="fonts-css" href="styles/fonts.css" media-type="text/css"/>
+    <item id="more-css" href="styles/more.css" media-type="text/css"/>
+    <item id="go" href="fonts/go.ttf" media-type="font/ttf"/>
+    <item id="bold" href="fonts/bold.woff" media-type="application/font-woff"/>
+    <item id="unused" href="fonts/unused.woff2" media-type="font/woff2"/>
+  </manifest>`, 1)
+	files["OEBPS/text/chapter-1.xhtml"] = strings.Replace(files["OEBPS/text/chapter-1.xhtml"], "<title>",
+		`<link rel="stylesheet" href="../styles/fonts.css"/><title>`, 1)
+	files["OEBPS/styles/fonts.css"] = `@import "more.css";
+@font-face { font-family: "Go Text"; src: url("../fonts/go.ttf") format("truetype"); }
+@font-face { font-family: Hidden; src: local(Hidden), url(../fonts/unused.woff2); }
+/* @font-face { font-family: Commented; src: url(../fonts/go.ttf); } */
+h1 { font: bold 2em "Go Text", serif; }
+p::before { content: "§"; }`
+	files["OEBPS/styles/more.css"] = `@font-face { font-family: GoBold; src: url(../fonts/bold.woff); unicode-range: U+0041-005A; }
+body { font-family: 'GoBold' }`
+	files["OEBPS/fonts/go.ttf"] = string(goregular.TTF)
+	files["OEBPS/fonts/bold.woff"] = string(bold)
+	files["OEBPS/fonts/unused.woff2"] = "wOF2\x00\x01\x00\x00"
+	files["META-INF/encryption.xml"] = `<?xml version="1.0"?>
+<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="http://www.idpf.org/2008/embedding"/>
+    <enc:CipherData><enc:CipherReference URI="OEBPS/fonts/bold.woff"/></enc:CipherData>
+  </enc:EncryptedData>
+</encryption>`
+	return files
+}
+
+func TestReader_Fonts(t *testing.T) {
+	r := newTestReader(t, testFontFiles(t))
+
+	fonts := r.Fonts()
+	if len(fonts) != 3 {
+		t.Fatalf("expected three fonts, got %+v", fonts)
+	}
+	if font := fonts[0]; font.ID != "go" || font.Format != FontTrueType || font.Family != "Go" || font.Style != "Regular" || font.PostScriptName != "GoRegular" || font.NumGlyphs == 0 || font.Obfuscated {
+		t.Errorf("unexpected font %+v", font)
+	}
+	if font := fonts[1]; font.Format != FontWOFF || font.Family != "Go" || font.Style != "Bold" || !font.Obfuscated {
+		t.Errorf("unexpected font %+v", font)
+	}
+	if font := fonts[2]; font.Format != FontWOFF2 || font.Family != "" || font.Size != 8 {
+		t.Errorf("unexpected font %+v", font)
+	}
+
+	if _, err := r.FontInfo("unused"); err == nil {
+		t.Errorf("expected error for a malformed WOFF2 font")
+	}
+	if _, err := r.FontInfo("chapter-1"); err == nil {
+		t.Errorf("expected error for a content document")
+	}
+}
+
+func TestReader_FontFaces(t *testing.T) {
+	r := newTestReader(t, testFontFiles(t))
+
+	faces := r.FontFaces()
+	if len(faces) != 3 {
+		t.Fatalf("expected three faces, got %+v", faces)
+	}
+	if face := faces[0]; face.Family != "Go Text" || face.ResourceID != "go" || face.Href != "fonts/go.ttf" || face.StyleSheet != "styles/fonts.css" {
+		t.Errorf("unexpected face %+v", face)
+	}
+	if face := faces[1]; face.Family != "Hidden" || face.ResourceID != "unused" {
+		t.Errorf("unexpected face %+v", face)
+	}
+	if face := faces[2]; face.Family != "GoBold" || face.UnicodeRange != "U+0041-005A" || face.StyleSheet != "styles/more.css" {
+		t.Errorf("unexpected face %+v", face)
+	}
+}
+
+func TestReader_FontUsage(t *testing.T) {
+	r := newTestReader(t, testFontFiles(t))
+
+	usage := r.FontUsage()
+	if len(usage) != 3 {
+		t.Fatalf("expected three fonts, got %+v", usage)
+	}
+
+	regular := usage[0]
+	if !slices.Equal(regular.Families, []string{"Go Text"}) || !slices.Contains(regular.Runes, 'C') || !slices.Contains(regular.Runes, '§') {
+		t.Errorf("unexpected usage %+v", regular)
+	}
+	// Only the second chapter, which links no style sheet, has an S.
+	if slices.Contains(regular.Runes, 'S') || slices.Contains(regular.Runes, '\n') {
+		t.Errorf("unexpected runes %q", string(regular.Runes))
+	}
+	if len(regular.Glyphs) != len(regular.Runes)-len(regular.Missing) {
+		t.Errorf("unexpected glyphs %v for %q", regular.Glyphs, string(regular.Runes))
+	}
+
+	if bold := usage[1]; string(bold.Runes) != "ACNT" || len(bold.Glyphs) != 4 {
+		t.Errorf("expected the unicode range to restrict runes, got %q %v", string(bold.Runes), bold.Glyphs)
+	}
+	if unused := usage[2]; !slices.Equal(unused.Families, []string{"Hidden"}) || len(unused.Runes) != 0 || unused.Glyphs != nil {
+		t.Errorf("unexpected usage %+v", unused)
+	}
+}
+
+func TestReader_FontFacesMalformedCSS(t *testing.T) {
+	files := testFontFiles(t)
+	files["OEBPS/styles/more.css"] = `@charset "utf-8";;
+@font-face { font-family: "Go}Bold"; src: url("../fonts/missing.woff"), url(../fonts/bold.woff); };
+@media screen { @font-face { font-family: Screen; src: url(../fonts/go.ttf) } }
+p { color: red };
+body { font-family: "Go}Bold", Screen }
+x { background: url("`
+	files["OEBPS/text/chapter-2.xhtml"] = strings.Replace(files["OEBPS/text/chapter-2.xhtml"], "<title>",
+		`<style>@font-face { font-family: Inline; src: url(../fonts/go.ttf) };</style><title>`, 1)
+	r := newTestReader(t, files)
+
+	faces := r.FontFaces()
+	if len(faces) != 5 {
+		t.Fatalf("expected five faces, got %+v", faces)
+	}
+	if face := faces[0]; face.Family != "Inline" || face.StyleSheet != "text/chapter-2.xhtml" {
+		t.Errorf("expected the face of the style element, got %+v", face)
+	}
+	if face := faces[3]; face.Family != "Go}Bold" || face.ResourceID != "bold" || !slices.Equal(face.Src, []string{"../fonts/missing.woff", "../fonts/bold.woff"}) {
+		t.Errorf("unexpected face %+v", face)
+	}
+	if face := faces[4]; face.Family != "Screen" || face.ResourceID != "go" {
+		t.Errorf("expected the face of the @media rule, got %+v", face)
+	}
+
+	usage := r.FontUsage()
+	if len(usage) != 3 || !slices.Equal(usage[0].Families, []string{"Inline", "Go Text", "Screen"}) || !slices.Equal(usage[1].Families, []string{"Go}Bold"}) {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
+
+func TestObfuscateFont(t *testing.T) {
+	font := testWOFF(t, goregular.TTF)
+	for _, algorithm := range []string{FontObfuscationIDPF, FontObfuscationAdobe} {
+		obfuscated, err := obfuscateFont(font, algorithm, "urn:uuid:0f2c6ef4-5a0c-4a43-9c4b-3d3d1f2ba0a1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(obfuscated[:4]) == "wOFF" || string(obfuscated[2000:]) != string(font[2000:]) {
+			t.Errorf("%s: unexpected obfuscation", algorithm)
+		}
+		if restored, _ := obfuscateFont(obfuscated, algorithm, "urn:uuid:0f2c6ef4-5a0c-4a43-9c4b-3d3d1f2ba0a1"); string(restored) != string(font) {
+			t.Errorf("%s: expected obfuscation to be reversible", algorithm)
+		}
+	}
+
+	if _, err := obfuscateFont(font, FontObfuscationAdobe, "isbn:9780000000000"); err == nil {
+		t.Errorf("expected error for an identifier that is not a UUID")
+	}
+}
+
+func TestParseUnicodeRange(t *testing.T) {
+	ranges := parseUnicodeRange("U+0025-00FF, u+4??, U+1F600")
+	for r, expected := range map[rune]bool{'%': true, 'ÿ': true, 0x400: true, 0x4ff: true, 0x500: false, 0x1f600: true, '$': false} {
+		if inUnicodeRange(ranges, r) != expected {
+			t.Errorf("unexpected %v for %U", !expected, r)
+		}
+	}
+	if !inUnicodeRange(parseUnicodeRange(""), 'x') {
+		t.Errorf("expected an empty range to cover every code point")
+	}
+}
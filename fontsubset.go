@@ -0,0 +1,751 @@
+package epub
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"slices"
+	"unicode"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// SubsetFont reports the subsetting of one font resource.
+type SubsetFont struct {
+	ID   string `json:"id"`
+	Href string `json:"href"`
+
+	// Glyphs and OriginalGlyphs count the glyphs with outlines, and are
+	// zero when the outlines cannot be read.
+	Glyphs         int `json:"glyphs"`
+	OriginalGlyphs int `json:"originalGlyphs"`
+
+	Size         int `json:"size"`
+	OriginalSize int `json:"originalSize"`
+
+	// Skipped is the reason the font was left untouched, and is empty
+	// when it was subset.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// subsetBaseRunes are kept in every subset, as reading systems generate
+// them for list markers, hyphenation and text-transform.
+var subsetBaseRunes = "\u00a0\u00ad\u2010\u2011\u2013\u2014\u2018\u2019\u201c\u201d\u2022\u2026\u25aa\u25e6\u3000"
+
+// Reasons fonts are left untouched by SubsetFonts.
+var (
+	errCFFOutlines    = errors.New("CFF outlines cannot be subset")
+	errVariableFont   = errors.New("variable fonts cannot be subset")
+	errFontCollection = errors.New("font collections cannot be subset")
+	errNoFontFace     = errors.New("no @font-face rule uses the font")
+	errSubsetLarger   = errors.New("the subset is not smaller than the font")
+)
+
+// SubsetFonts runs the font subsetting pass over an opened publication and
+// returns a Writer holding the result.
+func SubsetFonts(r *Reader) (w *Writer, report []SubsetFont, err error) {
+	w = newWriterFromReader(r)
+	report, err = w.SubsetFonts()
+	if err != nil {
+		return nil, nil, err
+	}
+	return w, report, nil
+}
+
+// SubsetFonts rewrites the fonts bound by @font-face rules to the glyphs
+// of the characters FontUsage reports, printable ASCII and a few
+// punctuation marks reading systems generate, with their case variants.
+// Glyph indices are kept and unused outlines emptied, so the cmap, metrics
+// and layout tables stay valid, and glyphs only reachable through layout
+// substitutions are kept. TrueType, WOFF and WOFF2 fonts with TrueType
+// outlines are subset, obfuscated fonts being obfuscated again. Fonts with
+// CFF outlines are not handled: they are left unchanged, and this includes
+// most CJK fonts, such as Source Han and Noto CJK. The report lists every
+// font, with the reason it was skipped for fonts with CFF outlines, variable
+// fonts, collections, malformed fonts and fonts no smaller once subset.
+func (w *Writer) SubsetFonts() (report []SubsetFont, err error) {
+	source := w.fontSource()
+	for _, usage := range source.fontUsage() {
+		res := w.resourceByID(usage.ID)
+		font := SubsetFont{ID: res.ID, Href: res.Href, Size: len(res.Content), OriginalSize: len(res.Content)}
+
+		subset, err := source.subsetFont(*res, usage, &font)
+		if err != nil {
+			return report, fmt.Errorf("subset %s: %w", res.Href, err)
+		}
+		if subset != nil {
+			font.Size = len(subset)
+			w.replaceResourceContent(res.ID, subset)
+		}
+		report = append(report, font)
+	}
+	return
+}
+
+// subsetFont returns the subset content of a font resource and counts its
+// glyphs in font, or sets the reason it is skipped and returns nil.
+func (source *fontSource) subsetFont(res PublicationResource, usage FontUsage, font *SubsetFont) ([]byte, error) {
+	skip := func(reason error) ([]byte, error) {
+		font.Skipped = reason.Error()
+		return nil, nil
+	}
+	if len(usage.Families) == 0 {
+		return skip(errNoFontFace)
+	}
+
+	content, algorithm, err := source.deobfuscatedFont(res)
+	if err != nil {
+		return skip(err)
+	}
+	file, err := parseFontFile(content)
+	if err != nil {
+		return skip(err)
+	}
+	font.Glyphs, font.OriginalGlyphs, err = file.subset(subsetRunes(usage.Runes))
+	if err != nil {
+		return skip(err)
+	}
+
+	subset, err := file.encode()
+	if err != nil {
+		return nil, err
+	}
+	if algorithm != "" {
+		if subset, err = obfuscateFont(subset, algorithm, source.identifier); err != nil {
+			return nil, err
+		}
+	}
+	if len(subset) >= len(res.Content) {
+		font.Glyphs = font.OriginalGlyphs
+		return skip(errSubsetLarger)
+	}
+	return subset, nil
+}
+
+// subsetRunes completes the used runes with their case variants and the
+// base runes.
+func subsetRunes(used []rune) map[rune]bool {
+	runes := make(map[rune]bool)
+	for r := rune(0x20); r < 0x7f; r++ {
+		runes[r] = true
+	}
+	for _, r := range subsetBaseRunes {
+		runes[r] = true
+	}
+	for _, r := range used {
+		runes[r] = true
+		runes[unicode.ToUpper(r)] = true
+		runes[unicode.ToLower(r)] = true
+		runes[unicode.ToTitle(r)] = true
+	}
+	return runes
+}
+
+// fontFile is a parsed font file, with the tables of SFNT and WOFF fonts.
+type fontFile struct {
+	format FontFormat
+	raw    []byte
+	flavor uint32
+	tables []fontTable
+
+	// wOFFVersion, wOFFMetadata and wOFFPrivate are the font version, the
+	// compressed extended metadata and the private data of WOFF and WOFF2
+	// fonts.
+	wOFFVersion        [2]uint16
+	wOFFMetadata       []byte
+	wOFFMetadataLength uint32
+	wOFFPrivate        []byte
+}
+
+type fontTable struct {
+	tag  string
+	data []byte
+}
+
+func parseFontFile(content []byte) (file fontFile, err error) {
+	if len(content) < 4 {
+		return file, errors.New("font file too short")
+	}
+
+	file.raw = content
+	switch string(content[:4]) {
+	case "\x00\x01\x00\x00", "true":
+		file.format = FontTrueType
+	case "OTTO":
+		file.format = FontOpenType
+	case "ttcf":
+		file.format = FontCollection
+		return file, nil
+	case "wOFF":
+		file.format = FontWOFF
+		return file, file.decodeWOFF(content)
+	case "wOF2":
+		file.format = FontWOFF2
+		return file, file.decodeWOFF2(content)
+	default:
+		return file, errors.New("unknown font format")
+	}
+
+	file.flavor, file.tables, err = parseSFNT(content)
+	return
+}
+
+// font parses the font, or the first font of a collection.
+func (file *fontFile) font() (*sfnt.Font, error) {
+	if file.format == FontCollection {
+		collection, err := sfnt.ParseCollection(file.raw)
+		if err != nil {
+			return nil, err
+		}
+		return collection.Font(0)
+	}
+	return sfnt.Parse(file.sfnt())
+}
+
+// sfnt returns the font as an SFNT file.
+func (file *fontFile) sfnt() []byte {
+	if file.raw != nil && file.format != FontWOFF && file.format != FontWOFF2 {
+		return file.raw
+	}
+	return buildSFNT(file.flavor, file.tables)
+}
+
+// encode returns the font in its original format.
+func (file *fontFile) encode() ([]byte, error) {
+	switch file.format {
+	case FontWOFF:
+		return file.encodeWOFF()
+	case FontWOFF2:
+		return file.encodeWOFF2()
+	}
+	return file.sfnt(), nil
+}
+
+func (file *fontFile) table(tag string) []byte {
+	for _, table := range file.tables {
+		if table.tag == tag {
+			return table.data
+		}
+	}
+	return nil
+}
+
+func (file *fontFile) setTable(tag string, data []byte) {
+	file.raw = nil
+	for i, table := range file.tables {
+		if table.tag == tag {
+			file.tables[i].data = data
+			return
+		}
+	}
+	file.tables = append(file.tables, fontTable{tag, data})
+}
+
+func (file *fontFile) removeTable(tag string) {
+	file.raw = nil
+	file.tables = slices.DeleteFunc(file.tables, func(table fontTable) bool { return table.tag == tag })
+}
+
+func parseSFNT(data []byte) (flavor uint32, tables []fontTable, err error) {
+	if len(data) < 12 {
+		return 0, nil, errors.New("malformed font header")
+	}
+
+	flavor = binary.BigEndian.Uint32(data)
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return 0, nil, errors.New("malformed font table directory")
+	}
+
+	for i := range numTables {
+		entry := data[12+16*i:]
+		offset := int(binary.BigEndian.Uint32(entry[8:]))
+		length := int(binary.BigEndian.Uint32(entry[12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return 0, nil, fmt.Errorf("malformed font table %q", entry[:4])
+		}
+		tables = append(tables, fontTable{string(entry[:4]), data[offset : offset+length]})
+	}
+	return
+}
+
+// buildSFNT assembles an SFNT file with its tables sorted by tag, and
+// updates the checksum adjustment of the head table.
+func buildSFNT(flavor uint32, tables []fontTable) []byte {
+	tables = slices.SortedFunc(slices.Values(tables), func(a, b fontTable) int {
+		return bytes.Compare([]byte(a.tag), []byte(b.tag))
+	})
+
+	numTables := len(tables)
+	entrySelector := 0
+	if numTables > 0 {
+		entrySelector = bits.Len(uint(numTables)) - 1
+	}
+	searchRange := (1 << entrySelector) * 16
+
+	font := binary.BigEndian.AppendUint32(nil, flavor)
+	font = binary.BigEndian.AppendUint16(font, uint16(numTables))
+	font = binary.BigEndian.AppendUint16(font, uint16(searchRange))
+	font = binary.BigEndian.AppendUint16(font, uint16(entrySelector))
+	font = binary.BigEndian.AppendUint16(font, uint16(numTables*16-searchRange))
+
+	offset := 12 + 16*numTables
+	headOffset := -1
+	var body []byte
+	for _, table := range tables {
+		data := table.data
+		if table.tag == "head" && len(data) >= 12 {
+			data = slices.Clone(data)
+			binary.BigEndian.PutUint32(data[8:], 0)
+			headOffset = offset + len(body)
+		}
+
+		font = append(font, table.tag...)
+		font = binary.BigEndian.AppendUint32(font, fontChecksum(data))
+		font = binary.BigEndian.AppendUint32(font, uint32(offset+len(body)))
+		font = binary.BigEndian.AppendUint32(font, uint32(len(data)))
+
+		body = append(body, data...)
+		body = append(body, make([]byte, pad4(len(data))-len(data))...)
+	}
+	font = append(font, body...)
+
+	if headOffset >= 0 {
+		binary.BigEndian.PutUint32(font[headOffset+8:], 0xb1b0afba-fontChecksum(font))
+	}
+	return font
+}
+
+func fontChecksum(data []byte) (sum uint32) {
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return
+}
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+func (file *fontFile) decodeWOFF(data []byte) error {
+	if len(data) < 44 {
+		return errors.New("malformed WOFF header")
+	}
+
+	file.flavor = binary.BigEndian.Uint32(data[4:])
+	numTables := int(binary.BigEndian.Uint16(data[12:]))
+	file.wOFFVersion = [2]uint16{binary.BigEndian.Uint16(data[20:]), binary.BigEndian.Uint16(data[22:])}
+	if len(data) < 44+20*numTables {
+		return errors.New("malformed WOFF table directory")
+	}
+
+	block := func(offset, length uint32) ([]byte, error) {
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, errors.New("malformed WOFF block")
+		}
+		return data[offset : offset+length], nil
+	}
+
+	var err error
+	if file.wOFFMetadata, err = block(binary.BigEndian.Uint32(data[24:]), binary.BigEndian.Uint32(data[28:])); err != nil {
+		return err
+	}
+	file.wOFFMetadataLength = binary.BigEndian.Uint32(data[32:])
+	if file.wOFFPrivate, err = block(binary.BigEndian.Uint32(data[36:]), binary.BigEndian.Uint32(data[40:])); err != nil {
+		return err
+	}
+
+	for i := range numTables {
+		entry := data[44+20*i:]
+		tag := string(entry[:4])
+		compLength := binary.BigEndian.Uint32(entry[8:])
+		origLength := binary.BigEndian.Uint32(entry[12:])
+
+		table, err := block(binary.BigEndian.Uint32(entry[4:]), compLength)
+		if err != nil {
+			return fmt.Errorf("malformed WOFF table %q", tag)
+		}
+		if compLength < origLength {
+			reader, err := zlib.NewReader(bytes.NewReader(table))
+			if err != nil {
+				return fmt.Errorf("WOFF table %q: %w", tag, err)
+			}
+			table, err = io.ReadAll(io.LimitReader(reader, int64(origLength)+1))
+			if err != nil {
+				return fmt.Errorf("WOFF table %q: %w", tag, err)
+			}
+		}
+		if uint32(len(table)) != origLength {
+			return fmt.Errorf("malformed WOFF table %q", tag)
+		}
+		file.tables = append(file.tables, fontTable{tag, table})
+	}
+	return nil
+}
+
+func (file *fontFile) encodeWOFF() ([]byte, error) {
+	// Round trip through SFNT for sorted tables and the head checksum.
+	_, tables, err := parseSFNT(buildSFNT(file.flavor, file.tables))
+	if err != nil {
+		return nil, err
+	}
+
+	totalSfntSize := 12 + 16*len(tables)
+	var directory, body []byte
+	offset := 44 + 20*len(tables)
+	for i, table := range tables {
+		totalSfntSize += pad4(len(table.data))
+
+		var compressed bytes.Buffer
+		writer, _ := zlib.NewWriterLevel(&compressed, zlib.BestCompression)
+		writer.Write(table.data)
+		writer.Close()
+
+		data := table.data
+		if compressed.Len() < len(data) {
+			data = compressed.Bytes()
+		}
+
+		directory = append(directory, table.tag...)
+		directory = binary.BigEndian.AppendUint32(directory, uint32(offset+len(body)))
+		directory = binary.BigEndian.AppendUint32(directory, uint32(len(data)))
+		directory = binary.BigEndian.AppendUint32(directory, uint32(len(table.data)))
+		directory = binary.BigEndian.AppendUint32(directory, fontChecksum(table.data))
+
+		body = append(body, data...)
+		if i < len(tables)-1 || len(file.wOFFMetadata) > 0 || len(file.wOFFPrivate) > 0 {
+			body = append(body, make([]byte, pad4(len(data))-len(data))...)
+		}
+	}
+
+	var metadataOffset, privateOffset int
+	if len(file.wOFFMetadata) > 0 {
+		metadataOffset = offset + len(body)
+		body = append(body, file.wOFFMetadata...)
+		if len(file.wOFFPrivate) > 0 {
+			body = append(body, make([]byte, pad4(len(file.wOFFMetadata))-len(file.wOFFMetadata))...)
+		}
+	}
+	if len(file.wOFFPrivate) > 0 {
+		privateOffset = offset + len(body)
+		body = append(body, file.wOFFPrivate...)
+	}
+
+	woff := []byte("wOFF")
+	woff = binary.BigEndian.AppendUint32(woff, file.flavor)
+	woff = binary.BigEndian.AppendUint32(woff, uint32(offset+len(body)))
+	woff = binary.BigEndian.AppendUint16(woff, uint16(len(tables)))
+	woff = binary.BigEndian.AppendUint16(woff, 0)
+	woff = binary.BigEndian.AppendUint32(woff, uint32(totalSfntSize))
+	woff = binary.BigEndian.AppendUint16(woff, file.wOFFVersion[0])
+	woff = binary.BigEndian.AppendUint16(woff, file.wOFFVersion[1])
+	woff = binary.BigEndian.AppendUint32(woff, uint32(metadataOffset))
+	woff = binary.BigEndian.AppendUint32(woff, uint32(len(file.wOFFMetadata)))
+	woff = binary.BigEndian.AppendUint32(woff, file.wOFFMetadataLength)
+	woff = binary.BigEndian.AppendUint32(woff, uint32(privateOffset))
+	woff = binary.BigEndian.AppendUint32(woff, uint32(len(file.wOFFPrivate)))
+	woff = append(woff, directory...)
+	return append(woff, body...), nil
+}
+
+// subset empties the outlines of the glyphs that the cmap maps only from
+// runes outside keep, and that no kept composite glyph uses. It returns
+// the number of glyphs with outlines after and before.
+func (file *fontFile) subset(keep map[rune]bool) (glyphs int, originalGlyphs int, err error) {
+	switch {
+	case file.format == FontCollection:
+		return 0, 0, errFontCollection
+	case file.table("CFF ") != nil || file.table("CFF2") != nil:
+		return 0, 0, errCFFOutlines
+	case file.table("gvar") != nil:
+		return 0, 0, errVariableFont
+	}
+	head, maxp, loca, glyf := file.table("head"), file.table("maxp"), file.table("loca"), file.table("glyf")
+	if glyf == nil || loca == nil {
+		return 0, 0, errors.New("no TrueType outlines")
+	}
+	if len(head) < 54 || len(maxp) < 6 {
+		return 0, 0, errors.New("malformed head or maxp table")
+	}
+
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	offsets, err := glyphOffsets(loca, int16(binary.BigEndian.Uint16(head[50:])) == 1, numGlyphs, len(glyf))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Glyphs the cmap does not map are kept, as layout tables may
+	// substitute them.
+	mapped := make([]bool, numGlyphs)
+	kept := make([]bool, numGlyphs)
+	err = cmapGlyphs(file.table("cmap"), func(r rune, glyph int) {
+		if glyph < numGlyphs {
+			mapped[glyph] = true
+			if keep[r] {
+				kept[glyph] = true
+			}
+		}
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var queue []int
+	for glyph := range numGlyphs {
+		if glyph == 0 || !mapped[glyph] {
+			kept[glyph] = true
+		}
+		if kept[glyph] {
+			queue = append(queue, glyph)
+		}
+	}
+	for len(queue) > 0 {
+		glyph := queue[0]
+		queue = queue[1:]
+
+		components, err := compositeComponents(glyf[offsets[glyph]:offsets[glyph+1]])
+		if err != nil {
+			return 0, 0, fmt.Errorf("glyph %d: %w", glyph, err)
+		}
+		for _, component := range components {
+			if component < numGlyphs && !kept[component] {
+				kept[component] = true
+				queue = append(queue, component)
+			}
+		}
+	}
+
+	var newGlyf, newLoca []byte
+	for glyph := range numGlyphs {
+		newLoca = binary.BigEndian.AppendUint32(newLoca, uint32(len(newGlyf)))
+		data := glyf[offsets[glyph]:offsets[glyph+1]]
+		if len(data) == 0 {
+			continue
+		}
+
+		originalGlyphs++
+		if kept[glyph] {
+			glyphs++
+			newGlyf = append(newGlyf, data...)
+			newGlyf = append(newGlyf, make([]byte, pad4(len(data))-len(data))...)
+		}
+	}
+	newLoca = binary.BigEndian.AppendUint32(newLoca, uint32(len(newGlyf)))
+
+	head = slices.Clone(head)
+	binary.BigEndian.PutUint16(head[50:], 1)
+	file.setTable("head", head)
+	file.setTable("loca", newLoca)
+	file.setTable("glyf", newGlyf)
+	file.removeTable("DSIG")
+	return glyphs, originalGlyphs, nil
+}
+
+// cmapGlyphs calls yield with every rune and glyph mapped by the Unicode
+// subtables of a cmap table, in formats 0, 4, 6, 10, 12 and 13. Glyphs only
+// reachable through other subtables are thus reported as unmapped.
+func cmapGlyphs(cmap []byte, yield func(r rune, glyph int)) error {
+	errMalformed := errors.New("malformed cmap table")
+	if len(cmap) < 4 {
+		return errMalformed
+	}
+
+	numTables := int(binary.BigEndian.Uint16(cmap[2:]))
+	if len(cmap) < 4+8*numTables {
+		return errMalformed
+	}
+	for i := range numTables {
+		record := cmap[4+8*i:]
+		platformID, encodingID := binary.BigEndian.Uint16(record), binary.BigEndian.Uint16(record[2:])
+		if platformID != 0 && (platformID != 3 || encodingID != 1 && encodingID != 10) {
+			continue
+		}
+		offset := int64(binary.BigEndian.Uint32(record[4:]))
+		if offset+2 > int64(len(cmap)) {
+			return errMalformed
+		}
+		if !cmapSubtableGlyphs(cmap[offset:], yield) {
+			return errMalformed
+		}
+	}
+	return nil
+}
+
+// cmapSubtableGlyphs calls yield with the mappings of one cmap subtable,
+// ignoring unsupported formats. It returns false when the subtable is
+// malformed.
+func cmapSubtableGlyphs(subtable []byte, yield func(r rune, glyph int)) bool {
+	u16 := func(at int) int { return int(binary.BigEndian.Uint16(subtable[at:])) }
+	u32 := func(at int) int64 { return int64(binary.BigEndian.Uint32(subtable[at:])) }
+
+	switch format := u16(0); format {
+	case 0:
+		if len(subtable) < 6+256 {
+			return false
+		}
+		for c, glyph := range subtable[6 : 6+256] {
+			yield(rune(c), int(glyph))
+		}
+
+	case 4:
+		if len(subtable) < 14 {
+			return false
+		}
+		segCount := u16(6) / 2
+		ends, starts, deltas, rangeOffsets := 14, 16+2*segCount, 16+4*segCount, 16+6*segCount
+		if len(subtable) < rangeOffsets+2*segCount {
+			return false
+		}
+		for i := range segCount {
+			start, end := u16(starts+2*i), u16(ends+2*i)
+			delta, rangeOffset := u16(deltas+2*i), u16(rangeOffsets+2*i)
+			for c := start; c <= end && c != 0xffff; c++ {
+				glyph := c
+				if rangeOffset != 0 {
+					at := rangeOffsets + 2*i + rangeOffset + 2*(c-start)
+					if at+2 > len(subtable) {
+						return false
+					}
+					if glyph = u16(at); glyph == 0 {
+						continue
+					}
+				}
+				yield(rune(c), (glyph+delta)&0xffff)
+			}
+		}
+
+	case 6:
+		if len(subtable) < 10 {
+			return false
+		}
+		first, count := u16(6), u16(8)
+		if len(subtable) < 10+2*count {
+			return false
+		}
+		for i := range count {
+			yield(rune(first+i), u16(10+2*i))
+		}
+
+	case 10:
+		if len(subtable) < 20 {
+			return false
+		}
+		first, count := u32(12), u32(16)
+		if int64(len(subtable)) < 20+2*count {
+			return false
+		}
+		for i := range count {
+			if c := first + i; c <= unicode.MaxRune {
+				yield(rune(c), u16(20+2*int(i)))
+			}
+		}
+
+	case 12, 13:
+		if len(subtable) < 16 {
+			return false
+		}
+		numGroups := u32(12)
+		if int64(len(subtable)) < 16+12*numGroups {
+			return false
+		}
+		for i := range int(numGroups) {
+			group := 16 + 12*i
+			start, end, glyph := u32(group), min(u32(group+4), unicode.MaxRune), u32(group+8)
+			for c := start; c <= end; c++ {
+				if format == 12 {
+					yield(rune(c), int(glyph+c-start))
+				} else {
+					yield(rune(c), int(glyph))
+				}
+			}
+		}
+	}
+	return true
+}
+
+// glyphOffsets reads the numGlyphs+1 glyph offsets of a loca table.
+func glyphOffsets(loca []byte, long bool, numGlyphs int, glyfLength int) ([]int, error) {
+	size := 2
+	if long {
+		size = 4
+	}
+	if len(loca) < (numGlyphs+1)*size {
+		return nil, errors.New("malformed loca table")
+	}
+
+	offsets := make([]int, numGlyphs+1)
+	for i := range offsets {
+		if long {
+			offsets[i] = int(binary.BigEndian.Uint32(loca[i*4:]))
+		} else {
+			offsets[i] = int(binary.BigEndian.Uint16(loca[i*2:])) * 2
+		}
+		if offsets[i] > glyfLength || i > 0 && offsets[i] < offsets[i-1] {
+			return nil, errors.New("malformed loca table")
+		}
+	}
+	return offsets, nil
+}
+
+// Composite glyph flags.
+const (
+	glyfArgsAreWords     = 0x0001
+	glyfHaveScale        = 0x0008
+	glyfMoreComponents   = 0x0020
+	glyfHaveXYScale      = 0x0040
+	glyfHaveTwoByTwo     = 0x0080
+	glyfHaveInstructions = 0x0100
+)
+
+// glyfHeaderSize is the size of the glyph header preceding outlines and
+// components.
+const glyfHeaderSize = 10
+
+// compositeComponents returns the glyphs a composite glyph is made of, or
+// nothing for simple glyphs.
+func compositeComponents(data []byte) (components []int, err error) {
+	if len(data) < glyfHeaderSize || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil, nil
+	}
+	_, components, _, err = compositeRecords(data[glyfHeaderSize:])
+	return
+}
+
+// compositeRecords reads the component records at the start of data, and
+// returns their length, the glyphs they use and whether instructions
+// follow them.
+func compositeRecords(data []byte) (length int, components []int, instructions bool, err error) {
+	for offset := 0; ; {
+		if offset+4 > len(data) {
+			return 0, nil, false, errors.New("malformed composite glyph")
+		}
+		flags := binary.BigEndian.Uint16(data[offset:])
+		components = append(components, int(binary.BigEndian.Uint16(data[offset+2:])))
+		instructions = instructions || flags&glyfHaveInstructions != 0
+
+		offset += 4 + 2
+		if flags&glyfArgsAreWords != 0 {
+			offset += 2
+		}
+		switch {
+		case flags&glyfHaveScale != 0:
+			offset += 2
+		case flags&glyfHaveXYScale != 0:
+			offset += 4
+		case flags&glyfHaveTwoByTwo != 0:
+			offset += 8
+		}
+		if flags&glyfMoreComponents == 0 {
+			if offset > len(data) {
+				return 0, nil, false, errors.New("malformed composite glyph")
+			}
+			return offset, components, instructions, nil
+		}
+	}
+}
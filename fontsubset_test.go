@@ -0,0 +1,230 @@
+package epub
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestSubsetFonts(t *testing.T) {
+	r := newTestReader(t, testFontFiles(t))
+
+	w, report, err := SubsetFonts(&r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report) != 3 || report[0].ID != "go" || report[1].ID != "bold" || report[2].ID != "unused" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for _, subset := range report[:2] {
+		if subset.Size >= subset.OriginalSize || subset.Glyphs >= subset.OriginalGlyphs || subset.Glyphs == 0 || subset.Skipped != "" {
+			t.Errorf("unexpected report %+v", subset)
+		}
+	}
+	if skipped := report[2]; skipped.Skipped != "malformed WOFF2 header" || skipped.Size != skipped.OriginalSize {
+		t.Errorf("unexpected report %+v", skipped)
+	}
+
+	source := w.fontSource()
+	for _, id := range []string{"go", "bold"} {
+		f, err := source.parseFont(*w.resourceByID(id))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", id, err)
+		}
+
+		var buf sfnt.Buffer
+		for r, used := range map[rune]bool{'C': true, 'z': true, '§': id == "go", 'é': false, 'Ж': false} {
+			glyph, err := f.GlyphIndex(&buf, r)
+			if err != nil || glyph == 0 {
+				t.Fatalf("%s: no glyph for %q: %v", id, r, err)
+			}
+			segments, err := f.LoadGlyph(&buf, glyph, 1000, nil)
+			if err != nil {
+				t.Fatalf("%s: unexpected error for %q: %v", id, r, err)
+			}
+			if used != (len(segments) > 0) {
+				t.Errorf("%s: unexpected outline of %q with %d segments", id, r, len(segments))
+			}
+		}
+	}
+
+	if sum := fontChecksum(w.resourceByID("go").Content); sum != 0xb1b0afba {
+		t.Errorf("unexpected font checksum %#x", sum)
+	}
+
+	if res := r.SelectResourceById("go"); len(res.Content) != len(goregular.TTF) {
+		t.Errorf("expected the opened book to be left untouched")
+	}
+	if unused := w.resourceByID("unused"); string(unused.Content) != "wOF2\x00\x01\x00\x00" {
+		t.Errorf("expected the WOFF2 font to be left untouched")
+	}
+}
+
+func TestCmapGlyphs(t *testing.T) {
+	file, err := parseFontFile(goregular.TTF)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	glyphs := map[rune]int{}
+	err = cmapGlyphs(file.table("cmap"), func(r rune, glyph int) {
+		if _, ok := glyphs[r]; !ok && glyph != 0 {
+			glyphs[r] = glyph
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf sfnt.Buffer
+	for _, r := range "Aaé€\u2603\U0001f600" {
+		glyph, err := f.GlyphIndex(&buf, r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if glyphs[r] != int(glyph) {
+			t.Errorf("%U: expected glyph %d, got %d", r, glyph, glyphs[r])
+		}
+	}
+
+	if err := cmapGlyphs(file.table("cmap")[:12], func(rune, int) {}); err == nil {
+		t.Errorf("expected an error for a truncated cmap table")
+	}
+}
+
+func TestWOFFRoundTrip(t *testing.T) {
+	woff := testWOFF(t, goregular.TTF)
+	if len(woff) >= len(goregular.TTF) {
+		t.Errorf("expected WOFF tables to be compressed")
+	}
+
+	file, err := parseFontFile(woff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file.format != FontWOFF || string(file.sfnt()) != string(buildSFNT(0x00010000, mustParseSFNT(t, goregular.TTF))) {
+		t.Errorf("expected the WOFF font to decode to the original tables")
+	}
+	if _, err := file.font(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSubsetFontsWOFF2(t *testing.T) {
+	files := testFontFiles(t)
+	files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"],
+		`href="fonts/go.ttf" media-type="font/ttf"`, `href="fonts/go.woff2" media-type="font/woff2"`, 1)
+	files["OEBPS/styles/fonts.css"] = strings.ReplaceAll(files["OEBPS/styles/fonts.css"], "go.ttf", "go.woff2")
+	delete(files, "OEBPS/fonts/go.ttf")
+	files["OEBPS/fonts/go.woff2"] = string(testWOFF2(t, goregular.TTF))
+	files["OEBPS/fonts/unused.woff2"] = string(testWOFF2(t, buildSFNT(0x4f54544f, []fontTable{{"CFF ", []byte{1, 0, 4, 1}}})))
+	r := newTestReader(t, files)
+
+	if font := r.Fonts()[0]; font.Format != FontWOFF2 || font.Family != "Go" || font.NumGlyphs == 0 {
+		t.Errorf("unexpected font %+v", font)
+	}
+
+	w, report, err := SubsetFonts(&r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report) != 3 || report[0].Skipped != "" || report[0].Size >= report[0].OriginalSize || report[0].Glyphs >= report[0].OriginalGlyphs {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if skipped := report[2]; skipped.Skipped != errCFFOutlines.Error() {
+		t.Errorf("unexpected report %+v", skipped)
+	}
+
+	file, err := parseFontFile(w.resourceByID("go").Content)
+	if err != nil || file.format != FontWOFF2 {
+		t.Fatalf("expected a WOFF2 subset, got %s: %v", file.format, err)
+	}
+	f, err := file.font()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf sfnt.Buffer
+	for r, used := range map[rune]bool{'C': true, '§': true, 'Ж': false} {
+		glyph, err := f.GlyphIndex(&buf, r)
+		if err != nil || glyph == 0 {
+			t.Fatalf("no glyph for %q: %v", r, err)
+		}
+		segments, err := f.LoadGlyph(&buf, glyph, 1000, nil)
+		if err != nil || used != (len(segments) > 0) {
+			t.Errorf("unexpected outline of %q with %d segments: %v", r, len(segments), err)
+		}
+	}
+}
+
+func TestWOFF2RoundTrip(t *testing.T) {
+	woff2 := testWOFF2(t, goregular.TTF)
+	if len(woff2) >= len(testWOFF(t, goregular.TTF)) {
+		t.Errorf("expected WOFF2 to be smaller than WOFF")
+	}
+
+	file, err := parseFontFile(woff2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file.format != FontWOFF2 {
+		t.Errorf("unexpected format %s", file.format)
+	}
+	// The glyf and loca tables are rebuilt with another encoding of the
+	// same outlines, and the head checksum changes with them.
+	for _, table := range mustParseSFNT(t, goregular.TTF) {
+		if table.tag != "glyf" && table.tag != "loca" && table.tag != "head" && string(file.table(table.tag)) != string(table.data) {
+			t.Errorf("expected table %q to decode to the original", table.tag)
+		}
+	}
+
+	original, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := file.font()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var originalBuf, decodedBuf sfnt.Buffer
+	for glyph := range sfnt.GlyphIndex(original.NumGlyphs()) {
+		want, err := original.LoadGlyph(&originalBuf, glyph, fixed.I(1000), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = slices.Clone(want)
+		got, err := decoded.LoadGlyph(&decodedBuf, glyph, fixed.I(1000), nil)
+		if err != nil || !slices.Equal(got, want) {
+			t.Fatalf("glyph %d differs: %v", glyph, err)
+		}
+	}
+}
+
+func testWOFF2(t *testing.T, ttf []byte) []byte {
+	t.Helper()
+	file, err := parseFontFile(ttf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	woff2, err := file.encodeWOFF2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return woff2
+}
+
+func mustParseSFNT(t *testing.T, data []byte) []fontTable {
+	t.Helper()
+	_, tables, err := parseSFNT(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tables
+}
@@ -1,6 +1,9 @@
 package epub
 
 import (
+	"bytes"
+	"encoding/xml"
+	"slices"
 	"strings"
 
 	"golang.org/x/net/html"
@@ -38,3 +41,56 @@ func GetTextContent(node *html.Node) string {
 	extractText(node)
 	return strings.TrimSpace(text.String())
 }
+
+// getAttribute returns the value of the attribute with the given key, or an
+// empty string if the node does not have it.
+func getAttribute(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// hasEpubType reports whether the node's space separated epub:type
+// attribute contains the given value.
+func hasEpubType(node *html.Node, epubType string) bool {
+	return slices.Contains(strings.Fields(getAttribute(node, "epub:type")), epubType)
+}
+
+// hasRole reports whether the node's space separated role attribute
+// contains the given value.
+func hasRole(node *html.Node, role string) bool {
+	return slices.Contains(strings.Fields(getAttribute(node, "role")), role)
+}
+
+// parseXHTML parses an XHTML document, dropping the XML declaration which
+// the HTML parser would otherwise keep as a comment.
+func parseXHTML(content []byte) (doc *html.Node, err error) {
+	doc, err = html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return
+	}
+
+	for c := doc.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode && strings.HasPrefix(c.Data, "?xml") {
+			doc.RemoveChild(c)
+		}
+		c = next
+	}
+	return
+}
+
+// renderXHTML serializes a document parsed by parseXHTML, restoring the
+// XML declaration.
+func renderXHTML(doc *html.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	err := html.Render(&buf, doc)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
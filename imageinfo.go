@@ -0,0 +1,228 @@
+package epub
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+// ImageInfo describes an image resource, read from its header without
+// decoding pixels.
+type ImageInfo struct {
+	ID        string `json:"id"`
+	Href      string `json:"href"`
+	MediaType string `json:"mediaType"`
+
+	// Format is the format found in the content, such as "jpeg", "png",
+	// "gif", "webp" or "svg", which may disagree with MediaType.
+	Format string `json:"format"`
+
+	// Width and Height are in pixels. SVG sizes are converted from their
+	// width and height attributes, or taken from the viewBox.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// ColorModel is nil for SVG images. ColorType names it, such as
+	// "ycbcr", "cmyk", "gray", "paletted" or "nrgba".
+	ColorModel color.Model `json:"-"`
+	ColorType  string      `json:"colorType,omitempty"`
+
+	// Size is the byte size of the resource.
+	Size int `json:"size"`
+
+	// ViewBox is the viewBox of SVG images: min-x, min-y, width and height.
+	ViewBox *[4]float64 `json:"viewBox,omitempty"`
+}
+
+// ImageInfo returns the header information of the image resource with the
+// given manifest ID.
+func (r *Reader) ImageInfo(id string) (*ImageInfo, error) {
+	res := r.SelectResourceById(id)
+	if res == nil {
+		return nil, fmt.Errorf("resource %q not found", id)
+	}
+	if !isImageResource(res) {
+		return nil, fmt.Errorf("resource %q is not an image but %s", id, res.MIMEType)
+	}
+
+	info, err := readImageInfo(*res)
+	if err != nil {
+		return nil, fmt.Errorf("read image %q: %w", id, err)
+	}
+	return &info, nil
+}
+
+// ImageInfos returns the header information of every image resource, in
+// manifest order. Images whose header cannot be read are listed with
+// their media type and size only.
+func (r *Reader) ImageInfos() (infos []ImageInfo) {
+	for _, res := range r.epub.resources {
+		if !isImageResource(&res) {
+			continue
+		}
+
+		info, err := readImageInfo(res)
+		if err != nil {
+			info = ImageInfo{ID: res.ID, Href: res.Href, MediaType: res.MIMEType, Size: len(res.Content)}
+		}
+		infos = append(infos, info)
+	}
+	return
+}
+
+func readImageInfo(res PublicationResource) (info ImageInfo, err error) {
+	info = ImageInfo{ID: res.ID, Href: res.Href, MediaType: res.MIMEType, Size: len(res.Content)}
+
+	if res.MIMEType == pkg.MediaTypeSVG {
+		info.Format = "svg"
+		info.Width, info.Height, info.ViewBox, err = svgSize(res.Content)
+		return
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(res.Content))
+	if err != nil {
+		return
+	}
+	info.Format = format
+	info.Width = config.Width
+	info.Height = config.Height
+	info.ColorModel = config.ColorModel
+	info.ColorType = colorType(config.ColorModel)
+	return
+}
+
+func colorType(model color.Model) string {
+	if _, ok := model.(color.Palette); ok {
+		return "paletted"
+	}
+
+	switch model {
+	case color.RGBAModel:
+		return "rgba"
+	case color.RGBA64Model:
+		return "rgba64"
+	case color.NRGBAModel:
+		return "nrgba"
+	case color.NRGBA64Model:
+		return "nrgba64"
+	case color.AlphaModel:
+		return "alpha"
+	case color.Alpha16Model:
+		return "alpha16"
+	case color.GrayModel:
+		return "gray"
+	case color.Gray16Model:
+		return "gray16"
+	case color.YCbCrModel:
+		return "ycbcr"
+	case color.NYCbCrAModel:
+		return "nycbcra"
+	case color.CMYKModel:
+		return "cmyk"
+	}
+	return ""
+}
+
+// svgSize reads the size of an SVG image from the attributes of its root
+// element. Relative widths and heights fall back to the viewBox.
+func svgSize(content []byte) (width int, height int, viewBox *[4]float64, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, 0, nil, errors.New("no svg element")
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "svg" {
+			return 0, 0, nil, fmt.Errorf("unexpected root element %q", start.Name.Local)
+		}
+
+		var widthAttr, heightAttr string
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "width":
+				widthAttr = attr.Value
+			case "height":
+				heightAttr = attr.Value
+			case "viewBox":
+				viewBox = parseViewBox(attr.Value)
+			}
+		}
+
+		w, wOK := svgLength(widthAttr)
+		h, hOK := svgLength(heightAttr)
+		if viewBox != nil && viewBox[2] > 0 && viewBox[3] > 0 {
+			// A missing dimension keeps the viewBox aspect ratio.
+			switch {
+			case !wOK && !hOK:
+				w, h = viewBox[2], viewBox[3]
+			case !wOK:
+				w = h * viewBox[2] / viewBox[3]
+			case !hOK:
+				h = w * viewBox[3] / viewBox[2]
+			}
+		}
+		return int(math.Round(w)), int(math.Round(h)), viewBox, nil
+	}
+}
+
+func parseViewBox(value string) *[4]float64 {
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' })
+	if len(fields) != 4 {
+		return nil
+	}
+
+	var viewBox [4]float64
+	for i, field := range fields {
+		number, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil
+		}
+		viewBox[i] = number
+	}
+	return &viewBox
+}
+
+// svgUnits are the pixel sizes of the absolute CSS units.
+var svgUnits = map[string]float64{
+	"":   1,
+	"px": 1,
+	"pt": 96.0 / 72,
+	"pc": 16,
+	"in": 96,
+	"cm": 96 / 2.54,
+	"mm": 96 / 25.4,
+}
+
+// svgLength converts an absolute SVG length into pixels.
+func svgLength(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	end := strings.LastIndexFunc(value, func(r rune) bool { return r >= '0' && r <= '9' || r == '.' })
+	if end < 0 {
+		return 0, false
+	}
+
+	scale, found := svgUnits[strings.ToLower(value[end+1:])]
+	if !found {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(value[:end+1], 64)
+	if err != nil || number <= 0 {
+		return 0, false
+	}
+	return number * scale, true
+}
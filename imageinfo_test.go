@@ -0,0 +1,87 @@
+package epub
+
+import (
+	"image"
+	"image/color"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestReader_ImageInfos(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/images/cover.png"] = string(encodeTestImage(t, image.NewGray(image.Rect(0, 0, 1600, 2560)), "png"))
+	files["OEBPS/images/photo.jpg"] = string(encodeTestImage(t, image.NewRGBA(image.Rect(0, 0, 40, 30)), "jpeg"))
+	files["OEBPS/images/map.svg"] = `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="2in" viewBox="0 0 300 150"/>`
+	files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], `</manifest>`,
+		`<item id="photo" href="images/photo.jpg" media-type="image/jpeg"/><item id="map" href="images/map.svg" media-type="image/svg+xml"/></manifest>`, 1)
+
+	r := newTestReader(t, files)
+	infos := r.ImageInfos()
+	if len(infos) != 3 {
+		t.Fatalf("expected three images, got %+v", infos)
+	}
+
+	cover := infos[0]
+	if cover.ID != "cover" || cover.Format != "png" || cover.Width != 1600 || cover.Height != 2560 || cover.ColorModel != color.GrayModel || cover.ColorType != "gray" || cover.Size != len(files["OEBPS/images/cover.png"]) {
+		t.Errorf("unexpected cover info %+v", cover)
+	}
+
+	photo, err := r.ImageInfo("photo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if photo.Format != "jpeg" || photo.Width != 40 || photo.Height != 30 || photo.ColorType != "ycbcr" {
+		t.Errorf("unexpected photo info %+v", photo)
+	}
+
+	svg, err := r.ImageInfo("map")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if svg.Format != "svg" || svg.Width != 192 || svg.Height != 96 || svg.ColorModel != nil || svg.ViewBox == nil || *svg.ViewBox != [4]float64{0, 0, 300, 150} {
+		t.Errorf("unexpected svg info %+v", svg)
+	}
+
+	if _, err := r.ImageInfo("chapter-1"); err == nil {
+		t.Errorf("expected error for a content document")
+	}
+	if _, err := r.ImageInfo("missing"); err == nil {
+		t.Errorf("expected error for a missing resource")
+	}
+}
+
+func TestReader_ImageInfosUndecodable(t *testing.T) {
+	// The fixture cover is only a PNG signature.
+	r := newTestReader(t, testEPUB3Files)
+	infos := r.ImageInfos()
+	if len(infos) != 1 || infos[0].Format != "" || infos[0].Width != 0 || infos[0].Size != 8 {
+		t.Errorf("unexpected infos %+v", infos)
+	}
+	if _, err := r.ImageInfo("cover"); err == nil {
+		t.Errorf("expected error for an undecodable image")
+	}
+}
+
+func TestSVGSize(t *testing.T) {
+	tests := []struct {
+		svg           string
+		width, height int
+	}{
+		{`<svg width="120" height="80px"/>`, 120, 80},
+		{`<svg width="72pt" height="1in"/>`, 96, 96},
+		{`<svg width="100%" height="100%" viewBox="0,0,600,800"/>`, 600, 800},
+		{`<svg height="200" viewBox="0 0 600 800"/>`, 150, 200},
+		{`<svg/>`, 0, 0},
+	}
+	for _, test := range tests {
+		width, height, _, err := svgSize([]byte(test.svg))
+		if err != nil || width != test.width || height != test.height {
+			t.Errorf("%s: got %dx%d, %v", test.svg, width, height, err)
+		}
+	}
+
+	if _, _, _, err := svgSize([]byte(`<html/>`)); err == nil {
+		t.Errorf("expected error for a non SVG document")
+	}
+}
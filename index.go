@@ -0,0 +1,443 @@
+package epub
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+)
+
+// Index is a back-of-book index marked up with the EPUB Indexes vocabulary.
+type Index struct {
+	Title string `json:"title,omitempty"`
+
+	// Href is the content document holding the index, relative to the
+	// package document.
+	Href    string       `json:"href"`
+	Entries []IndexEntry `json:"entries"`
+}
+
+// IndexEntry is an index term with its locators, cross-references and
+// subentries.
+type IndexEntry struct {
+	// ID is the id of the entry element, targeted by cross-references.
+	ID   string `json:"id,omitempty"`
+	Term string `json:"term"`
+
+	// Group is the heading of the index group holding a top level entry,
+	// such as a letter.
+	Group string `json:"group,omitempty"`
+
+	Locators []IndexLocator        `json:"locators,omitempty"`
+	See      []IndexCrossReference `json:"see,omitempty"`
+	SeeAlso  []IndexCrossReference `json:"seeAlso,omitempty"`
+	Entries  []IndexEntry          `json:"entries,omitempty"`
+}
+
+// IndexLocator points at the place of the publication an index term
+// refers to.
+type IndexLocator struct {
+	Label string `json:"label"`
+
+	// Href is relative to the package document, without fragment.
+	Href       string `json:"href"`
+	Fragment   string `json:"fragment,omitempty"`
+	ResourceID string `json:"resourceId,omitempty"`
+
+	// SpineIndex is the spine position of the target, or -1.
+	SpineIndex int `json:"spineIndex"`
+
+	// End is the end of a locator range, such as "12–15".
+	End *IndexLocator `json:"end,omitempty"`
+}
+
+// IndexCrossReference is a "see" or "see also" reference to another term.
+type IndexCrossReference struct {
+	Term string `json:"term"`
+
+	// Href and Fragment locate the referenced entry, when linked. Href is
+	// relative to the package document.
+	Href     string `json:"href,omitempty"`
+	Fragment string `json:"fragment,omitempty"`
+}
+
+// Index returns the first index of the publication in spine order, or nil
+// when there is none.
+func (r *Reader) Index() *Index {
+	if indexes := r.Indexes(); len(indexes) > 0 {
+		return &indexes[0]
+	}
+	return nil
+}
+
+// Indexes returns every index of the publication in spine order, such as
+// separate name and subject indexes.
+func (r *Reader) Indexes() (indexes []Index) {
+	packageDir := path.Dir(r.CurrentSelectedPackagePath())
+	ids := r.resourceIDsByPath()
+
+	spineIndexes := map[string]int{}
+	for i, itemRef := range r.CurrentSelectedPackage().Spine.ItemRefs {
+		if _, found := spineIndexes[itemRef.IDRef]; !found {
+			spineIndexes[itemRef.IDRef] = i
+		}
+	}
+
+	var navID string
+	if navRes := r.navigationResource(); navRes != nil {
+		navID = navRes.ID
+	}
+
+	for _, res := range r.Spine() {
+		if res.ID == navID || res.MIMEType != pkg.MediaTypeXHTML || !bytes.Contains(res.Content, []byte("index")) {
+			continue
+		}
+
+		doc, err := parseXHTML(res.Content)
+		if err != nil {
+			continue
+		}
+
+		parser := indexParser{
+			docPath:      unescapePath(res.Filepath),
+			packageDir:   packageDir,
+			ids:          ids,
+			spineIndexes: spineIndexes,
+		}
+
+		var find func(n *html.Node)
+		find = func(n *html.Node) {
+			if n.Type == html.ElementNode {
+				// Links and navigation, such as a landmark pointing to the
+				// index, are not the index itself.
+				if n.Data == "a" || n.Data == "nav" {
+					return
+				}
+				if isIndexContainer(n) {
+					indexes = append(indexes, parser.index(n, res.Href))
+					return
+				}
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				find(c)
+			}
+		}
+		find(doc)
+	}
+	return
+}
+
+// isIndexContainer reports whether n is a section or body typed as an index
+// that holds index entries.
+func isIndexContainer(n *html.Node) bool {
+	if (n.Data != "section" && n.Data != "body") || !hasEpubType(n, "index") {
+		return false
+	}
+	return FindNode(n, func(c *html.Node) bool {
+		return c.Type == html.ElementNode && (hasEpubType(c, "index-entry") || hasEpubType(c, "index-entry-list"))
+	}) != nil
+}
+
+// indexParser resolves the links of an index found in docPath.
+type indexParser struct {
+	docPath      string
+	packageDir   string
+	ids          map[string]string
+	spineIndexes map[string]int
+}
+
+func (p indexParser) index(node *html.Node, href string) (index Index) {
+	index = Index{Href: href}
+
+	heading := FindNode(node, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && isHeading(n) && !insideIndexGroup(n, node)
+	})
+	if heading != nil {
+		index.Title = normalizeSpace(GetTextContent(heading))
+	}
+
+	index.Entries = p.entries(node, "")
+	return
+}
+
+// entries collects the index entries below n that are not nested in
+// another entry.
+func (p indexParser) entries(n *html.Node, group string) (entries []IndexEntry) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+
+		switch {
+		case hasEpubType(c, "index-entry"):
+			entry := p.entry(c)
+			entry.Group = group
+			if entry.Term != "" {
+				entries = append(entries, entry)
+			}
+		case hasEpubType(c, "index-group"):
+			label := group
+			if heading := FindNode(c, isHeading); heading != nil {
+				label = normalizeSpace(GetTextContent(heading))
+			}
+			entries = append(entries, p.entries(c, label)...)
+		default:
+			entries = append(entries, p.entries(c, group)...)
+		}
+	}
+	return
+}
+
+func (p indexParser) entry(node *html.Node) (entry IndexEntry) {
+	entry.ID = getAttribute(node, "id")
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+
+			switch {
+			case hasEpubType(c, "index-entry"):
+				if sub := p.entry(c); sub.Term != "" {
+					entry.Entries = append(entry.Entries, sub)
+				}
+			case hasEpubType(c, "index-term"):
+				if entry.Term == "" {
+					entry.Term = normalizeSpace(GetTextContent(c))
+				}
+			case hasEpubType(c, "index-locator-range"):
+				anchors := findAnchors(c)
+				if len(anchors) > 0 {
+					locator := p.locator(anchors[0])
+					if len(anchors) > 1 {
+						end := p.locator(anchors[len(anchors)-1])
+						locator.End = &end
+					}
+					entry.Locators = append(entry.Locators, locator)
+				}
+			case hasEpubType(c, "index-locator"):
+				entry.Locators = append(entry.Locators, p.locator(c))
+			case hasEpubType(c, "index-xref-preferred"):
+				entry.See = append(entry.See, p.crossReferences(c)...)
+			case hasEpubType(c, "index-xref-related"):
+				entry.SeeAlso = append(entry.SeeAlso, p.crossReferences(c)...)
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(node)
+	return
+}
+
+func (p indexParser) resolve(href string) (packageHref string, fragment string, containerPath string) {
+	containerPath, fragment = resolveHref(path.Dir(p.docPath), unescapePath(href))
+	if containerPath == "" {
+		containerPath = p.docPath
+	}
+	return relativeHref(p.packageDir, containerPath, ""), fragment, containerPath
+}
+
+func (p indexParser) locator(anchor *html.Node) (locator IndexLocator) {
+	href, fragment, containerPath := p.resolve(getAttribute(anchor, "href"))
+	locator = IndexLocator{
+		Label:      normalizeSpace(GetTextContent(anchor)),
+		Href:       href,
+		Fragment:   fragment,
+		ResourceID: p.ids[containerPath],
+		SpineIndex: -1,
+	}
+	if index, ok := p.spineIndexes[locator.ResourceID]; ok {
+		locator.SpineIndex = index
+	}
+	return
+}
+
+// crossReferences returns the terms a see or see also reference points
+// to, one per link, or the reference text when it has no link.
+func (p indexParser) crossReferences(node *html.Node) (references []IndexCrossReference) {
+	for _, anchor := range findAnchors(node) {
+		href, fragment, _ := p.resolve(getAttribute(anchor, "href"))
+		references = append(references, IndexCrossReference{
+			Term:     normalizeSpace(GetTextContent(anchor)),
+			Href:     href,
+			Fragment: fragment,
+		})
+	}
+
+	if len(references) == 0 {
+		term := normalizeSpace(GetTextContent(node))
+		for _, prefix := range []string{"See also", "see also", "See", "see"} {
+			if trimmed, ok := strings.CutPrefix(term, prefix); ok {
+				term = strings.TrimSpace(trimmed)
+				break
+			}
+		}
+		references = append(references, IndexCrossReference{Term: term})
+	}
+	return
+}
+
+func findAnchors(node *html.Node) (anchors []*html.Node) {
+	for n := range node.Descendants() {
+		if n.Type == html.ElementNode && n.Data == "a" && getAttribute(n, "href") != "" {
+			anchors = append(anchors, n)
+		}
+	}
+	return
+}
+
+func isHeading(n *html.Node) bool {
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return n.Type == html.ElementNode
+	}
+	return false
+}
+
+// insideIndexGroup reports whether n is nested in an index group or entry
+// below root.
+func insideIndexGroup(n *html.Node, root *html.Node) bool {
+	for p := n.Parent; p != nil && p != root; p = p.Parent {
+		if hasEpubType(p, "index-group") || hasEpubType(p, "index-entry") {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// AddIndex renders index as an EPUB Indexes content document, adds it to
+// the spine and declares it with an index collection. Locator and
+// cross-reference hrefs are relative to the package document; a
+// cross-reference with only a fragment targets an entry of the index.
+// Entries of the same Group are wrapped in an index group.
+func (w *Writer) AddIndex(filename string, index Index) (res PublicationResource, err error) {
+	if len(index.Entries) == 0 {
+		return res, errors.New("index has no entries")
+	}
+	if index.Title == "" {
+		index.Title = "Index"
+	}
+
+	indexDir := path.Dir(path.Join(w.contentDir, filename))
+	href := func(packageHref string, fragment string) string {
+		if packageHref == "" {
+			return "#" + fragment
+		}
+		return relativeHref(indexDir, path.Join(w.contentDir, packageHref), fragment)
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "<section epub:type=\"index\">\n<header><h1>%s</h1></header>\n", html.EscapeString(index.Title))
+
+	for start := 0; start < len(index.Entries); {
+		group := index.Entries[start].Group
+		end := start + 1
+		for end < len(index.Entries) && index.Entries[end].Group == group {
+			end++
+		}
+
+		if group != "" {
+			fmt.Fprintf(&body, "<section epub:type=\"index-group\">\n<h2>%s</h2>\n", html.EscapeString(group))
+		}
+		writeIndexEntries(&body, index.Entries[start:end], href)
+		if group != "" {
+			body.WriteString("</section>\n")
+		}
+		start = end
+	}
+	body.WriteString("</section>")
+
+	lang := firstLanguage(w.epub.SelectedPackage())
+	content := fmt.Appendf(nil, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%[1]s" lang="%[1]s">
+<head>
+<meta charset="utf-8"/>
+<title>%[2]s</title>
+</head>
+<body>
+%[3]s
+</body>
+</html>
+`, html.EscapeString(lang), html.EscapeString(index.Title), body.String())
+
+	res = w.AddContent(filename, content)
+	err = w.AddCollection(Collection{
+		Role:  CollectionIndex,
+		Links: []CollectionLink{{ResourceID: res.ID}},
+	})
+	return
+}
+
+func writeIndexEntries(buf *bytes.Buffer, entries []IndexEntry, href func(string, string) string) {
+	buf.WriteString("<ul epub:type=\"index-entry-list\">\n")
+	for _, entry := range entries {
+		buf.WriteString("<li epub:type=\"index-entry\"")
+		if entry.ID != "" {
+			fmt.Fprintf(buf, " id=\"%s\"", html.EscapeString(entry.ID))
+		}
+		fmt.Fprintf(buf, "><span epub:type=\"index-term\">%s</span>", html.EscapeString(entry.Term))
+
+		for _, locator := range entry.Locators {
+			buf.WriteString(", ")
+			if locator.End == nil {
+				writeIndexAnchor(buf, "index-locator", href(locator.Href, locator.Fragment), locator.Label)
+				continue
+			}
+
+			buf.WriteString("<span epub:type=\"index-locator-range\">")
+			writeIndexAnchor(buf, "", href(locator.Href, locator.Fragment), locator.Label)
+			buf.WriteString("–")
+			writeIndexAnchor(buf, "", href(locator.End.Href, locator.End.Fragment), locator.End.Label)
+			buf.WriteString("</span>")
+		}
+
+		writeIndexCrossReferences(buf, "index-xref-preferred", "See", entry.See, href)
+		writeIndexCrossReferences(buf, "index-xref-related", "See also", entry.SeeAlso, href)
+
+		if len(entry.Entries) > 0 {
+			buf.WriteString("\n")
+			writeIndexEntries(buf, entry.Entries, href)
+		}
+		buf.WriteString("</li>\n")
+	}
+	buf.WriteString("</ul>\n")
+}
+
+func writeIndexCrossReferences(buf *bytes.Buffer, epubType string, label string, references []IndexCrossReference, href func(string, string) string) {
+	if len(references) == 0 {
+		return
+	}
+
+	fmt.Fprintf(buf, ". <span epub:type=\"%s\">%s ", epubType, label)
+	for i, reference := range references {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		if reference.Href == "" && reference.Fragment == "" {
+			buf.WriteString(html.EscapeString(reference.Term))
+			continue
+		}
+		writeIndexAnchor(buf, "", href(reference.Href, reference.Fragment), reference.Term)
+	}
+	buf.WriteString("</span>")
+}
+
+func writeIndexAnchor(buf *bytes.Buffer, epubType string, href string, label string) {
+	buf.WriteString("<a")
+	if epubType != "" {
+		fmt.Fprintf(buf, " epub:type=\"%s\"", epubType)
+	}
+	fmt.Fprintf(buf, " href=\"%s\">%s</a>", html.EscapeString(href), html.EscapeString(label))
+}
@@ -0,0 +1,145 @@
+package epub
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestReader_Index(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/text/chapter-2.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><head><title>Index</title></head>
+<body>
+<section epub:type="index">
+  <header><h1>Subject Index</h1></header>
+  <section epub:type="index-group">
+    <h2>A</h2>
+    <ul epub:type="index-entry-list">
+      <li epub:type="index-entry" id="apple"><span epub:type="index-term">Apple</span>,
+        <a epub:type="index-locator" href="chapter-1.xhtml#p1">1</a>,
+        <span epub:type="index-locator-range"><a href="chapter-1.xhtml#p3">3</a>–<a href="chapter-1.xhtml#p5">5</a></span>
+        <ul epub:type="index-entry-list">
+          <li epub:type="index-entry"><span epub:type="index-term">green</span>, <a epub:type="index-locator" href="chapter-1.xhtml#p4">4</a></li>
+        </ul>
+      </li>
+    </ul>
+  </section>
+  <section epub:type="index-group">
+    <h2>F</h2>
+    <ul epub:type="index-entry-list">
+      <li epub:type="index-entry"><span epub:type="index-term">Fruit</span>.
+        <span epub:type="index-xref-preferred">See <a href="#apple">Apple</a></span>.
+        <span epub:type="index-xref-related">See also vegetables</span></li>
+    </ul>
+  </section>
+</section>
+</body></html>`
+
+	files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], `<itemref idref="chapter-1"`, `<itemref idref="nav"/>
+    <itemref idref="chapter-1"`, 1)
+	files["OEBPS/nav/nav.xhtml"] = strings.Replace(files["OEBPS/nav/nav.xhtml"], `<nav epub:type="landmarks"><ol>`, `<nav epub:type="landmarks"><ol>
+  <li><a epub:type="index" href="../text/chapter-2.xhtml">Index</a></li>`, 1)
+	files["OEBPS/text/chapter-1.xhtml"] = strings.Replace(files["OEBPS/text/chapter-1.xhtml"], "</body>", `<aside epub:type="index"><p>See the index at the end.</p></aside>
+<section epub:type="index"><p>The index follows.</p></section></body>`, 1)
+
+	r := newTestReader(t, files)
+
+	if indexes := r.Indexes(); len(indexes) != 1 {
+		t.Fatalf("expected the landmark and entry-less sections to be skipped, got %+v", indexes)
+	}
+
+	index := r.Index()
+	if index == nil {
+		t.Fatal("expected an index")
+	}
+	if index.Title != "Subject Index" || index.Href != "text/chapter-2.xhtml" || len(index.Entries) != 2 {
+		t.Fatalf("unexpected index %+v", index)
+	}
+
+	apple := index.Entries[0]
+	if apple.ID != "apple" || apple.Term != "Apple" || apple.Group != "A" || len(apple.Locators) != 2 {
+		t.Fatalf("unexpected entry %+v", apple)
+	}
+	if locator := apple.Locators[0]; locator.Label != "1" || locator.Href != "text/chapter-1.xhtml" || locator.Fragment != "p1" || locator.ResourceID != "chapter-1" || locator.SpineIndex != 1 {
+		t.Errorf("unexpected locator %+v", locator)
+	}
+	if locator := apple.Locators[1]; locator.Fragment != "p3" || locator.End == nil || locator.End.Label != "5" || locator.End.Fragment != "p5" {
+		t.Errorf("unexpected locator range %+v", locator)
+	}
+	if len(apple.Entries) != 1 || apple.Entries[0].Term != "green" || apple.Entries[0].Locators[0].Label != "4" {
+		t.Errorf("unexpected subentries %+v", apple.Entries)
+	}
+
+	fruit := index.Entries[1]
+	if fruit.Group != "F" || len(fruit.See) != 1 || fruit.See[0].Term != "Apple" || fruit.See[0].Href != "text/chapter-2.xhtml" || fruit.See[0].Fragment != "apple" {
+		t.Errorf("unexpected see reference %+v", fruit)
+	}
+	if len(fruit.SeeAlso) != 1 || fruit.SeeAlso[0].Term != "vegetables" || fruit.SeeAlso[0].Href != "" {
+		t.Errorf("unexpected see also reference %+v", fruit.SeeAlso)
+	}
+
+	plain := newTestReader(t, testEPUB3Files)
+	if indexes := plain.Indexes(); len(indexes) != 0 {
+		t.Errorf("expected no index, got %+v", indexes)
+	}
+}
+
+func TestWriter_AddIndex(t *testing.T) {
+	w := New("urn:uuid:indexed")
+	w.Title("Indexed")
+	w.Languages("en")
+	w.Cover(testPNG(t, 10, 10))
+	w.AddContent("chapter.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>C</title></head><body><p id="p1">Apples</p><p id="p2">Pears</p></body></html>`))
+
+	if _, err := w.AddIndex("index.xhtml", Index{}); err == nil {
+		t.Errorf("expected error for an empty index")
+	}
+
+	_, err := w.AddIndex("index.xhtml", Index{Entries: []IndexEntry{
+		{
+			ID: "apple", Term: "Apple", Group: "A",
+			Locators: []IndexLocator{
+				{Label: "1", Href: "chapter.xhtml", Fragment: "p1"},
+				{Label: "1", Href: "chapter.xhtml", Fragment: "p1", End: &IndexLocator{Label: "2", Href: "chapter.xhtml", Fragment: "p2"}},
+			},
+			Entries: []IndexEntry{{Term: "red & green", Locators: []IndexLocator{{Label: "2", Href: "chapter.xhtml", Fragment: "p2"}}}},
+		},
+		{Term: "Pomme", Group: "P", See: []IndexCrossReference{{Term: "Apple", Fragment: "apple"}}, SeeAlso: []IndexCrossReference{{Term: "Fruit"}}},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.TableOfContents("toc", TOC{Items: []TOC{{Title: "Chapter", Href: "chapter.xhtml"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+
+	index := r.Index()
+	if index == nil || index.Title != "Index" || len(index.Entries) != 2 {
+		t.Fatalf("unexpected index %+v", index)
+	}
+
+	apple := index.Entries[0]
+	if apple.Group != "A" || len(apple.Locators) != 2 || apple.Locators[0].ResourceID != "chapter.xhtml" || apple.Locators[1].End == nil || apple.Locators[1].End.Fragment != "p2" {
+		t.Errorf("unexpected entry %+v", apple)
+	}
+	if len(apple.Entries) != 1 || apple.Entries[0].Term != "red & green" {
+		t.Errorf("unexpected subentries %+v", apple.Entries)
+	}
+
+	pomme := index.Entries[1]
+	if len(pomme.See) != 1 || pomme.See[0].Fragment != "apple" || pomme.See[0].Href != index.Href || len(pomme.SeeAlso) != 1 || pomme.SeeAlso[0].Term != "Fruit" {
+		t.Errorf("unexpected cross references %+v", pomme)
+	}
+
+	collections := r.CollectionsByRole(CollectionIndex)
+	if len(collections) != 1 || collections[0].Links[0].Href != index.Href {
+		t.Errorf("unexpected index collection %+v", collections)
+	}
+
+	doc := string(r.SelectResourceByHref(index.Href).Content)
+	if !strings.Contains(doc, `xml:lang="en"`) {
+		t.Errorf("expected the publication language in %s", doc)
+	}
+}
@@ -0,0 +1,98 @@
+package brotli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestDecode(t *testing.T) {
+	// Streams written by the reference encoder at qualities 2 and 11, the
+	// latter using context modeling and the static dictionary.
+	tests := []struct {
+		name    string
+		encoded string
+		want    string
+	}{
+		{
+			name:    "quality 2",
+			encoded: "c209000050555555fd9faea47703bb1cec607703b0c3651333b595cdd44ccdd46c000ec682484c1203dc6993686b6f9c71ea16daa0776750628edb71a1c702b737d6e6ffe835e6e800ba025d385c2a659a8972",
+			want:    "The quick brown fox jumps over the lazy dog. The Quick Brown Fox, the lazy dog!",
+		},
+		{
+			name:    "quality 11",
+			encoded: "c209009423c18e1d59e790d12315151ac4f2483fef1208f2c06b83cd26db2aeccd0d4e030f0cb340f2329af1de784027b3d1e9883a5e1272107cdb7fd469d824cb8523ae1216",
+			want:    "The quick brown fox jumps over the lazy dog. The Quick Brown Fox, the lazy dog!",
+		},
+		{
+			name:    "repeated quality 2",
+			encoded: "624d000050555555fddfe57212e0c34545f5a027153ddd055414160005053d29800ec0c15810894962803b6daedb0365ec7f272f57c404367f765a576411844a9a00",
+			want:    strings.Repeat("<glyph id=\"12\" advance=\"600\"/>\n", 20),
+		},
+		{
+			name:    "repeated quality 11",
+			encoded: "624d00bfe8dc3a25b187021048404b6cea66b05a0dd5562e2c855cf7bfcd670ae638113f10aa9026",
+			want:    strings.Repeat("<glyph id=\"12\" advance=\"600\"/>\n", 20),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := hex.DecodeString(tt.encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(encoded, len(tt.want))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Decode() = %q, want %q", got, tt.want)
+			}
+
+			if _, err := Decode(encoded, len(tt.want)-1); !errors.Is(err, ErrTooLarge) {
+				t.Errorf("Decode() with a smaller limit error = %v, want ErrTooLarge", err)
+			}
+			if _, err := Decode(encoded[:len(encoded)/2], len(tt.want)); !errors.Is(err, ErrFormat) {
+				t.Errorf("Decode() of truncated data error = %v, want ErrFormat", err)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	random := make([]byte, 1<<16)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "single byte", data: []byte("a")},
+		{name: "text", data: []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100))},
+		{name: "font", data: goregular.TTF},
+		{name: "random", data: random},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := Encode(tt.data)
+			got, err := Decode(encoded, len(tt.data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("Decode(Encode()) differs from the input")
+			}
+			if len(tt.data) > 1000 && tt.name != "random" && len(encoded) > len(tt.data)/2 {
+				t.Errorf("Encode() = %d bytes for %d input bytes", len(encoded), len(tt.data))
+			}
+		})
+	}
+}
@@ -0,0 +1,747 @@
+// Package brotli implements the Brotli compressed data format of RFC 7932,
+// which WOFF2 fonts use for their tables.
+package brotli
+
+import (
+	"errors"
+)
+
+var (
+	// ErrFormat reports malformed compressed data.
+	ErrFormat = errors.New("brotli: malformed data")
+
+	// ErrTooLarge reports data that decompresses to more than the limit
+	// given to Decode.
+	ErrTooLarge = errors.New("brotli: decompressed data exceeds the limit")
+)
+
+// Lengths of the alphabets of the prefix codes.
+const (
+	literalAlphabet    = 256
+	commandAlphabet    = 704
+	blockCountAlphabet = 26
+	codeLengthAlphabet = 18
+	maxCodeLength      = 15
+	numDistanceShort   = 16
+	numTransforms      = len(transforms)
+	defaultCodeLength  = 8
+)
+
+// codeLengthOrder is the order code length code lengths are stored in.
+var codeLengthOrder = [codeLengthAlphabet]int{1, 2, 3, 4, 0, 5, 17, 6, 16, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Base values and extra bits of the insert lengths, copy lengths and
+// block counts, indexed by their codes.
+var (
+	insertLengthBase  = [24]int{0, 1, 2, 3, 4, 5, 6, 8, 10, 14, 18, 26, 34, 50, 66, 98, 130, 194, 322, 578, 1090, 2114, 6210, 22594}
+	insertLengthExtra = [24]uint{0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 12, 14, 24}
+	copyLengthBase    = [24]int{2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 14, 18, 22, 30, 38, 54, 70, 102, 134, 198, 326, 582, 1094, 2118}
+	copyLengthExtra   = [24]uint{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 24}
+	blockCountBase    = [blockCountAlphabet]int{1, 5, 9, 13, 17, 25, 33, 41, 49, 65, 81, 97, 113, 145, 177, 209, 241, 305, 369, 497, 753, 1265, 2289, 4337, 8433, 16625}
+	blockCountExtra   = [blockCountAlphabet]uint{2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 6, 6, 7, 8, 9, 10, 11, 12, 13, 24}
+)
+
+// commandCells maps the 64 symbol cells of the insert-and-copy alphabet to
+// the first insert and copy length codes they cover. The first two cells
+// reuse the last distance.
+var commandCells = [11][2]int{{0, 0}, {0, 8}, {0, 0}, {0, 8}, {8, 0}, {8, 8}, {0, 16}, {16, 0}, {8, 16}, {16, 8}, {16, 16}}
+
+// Decode decompresses a Brotli stream, failing with ErrTooLarge when it
+// holds more than limit bytes.
+func Decode(data []byte, limit int) ([]byte, error) {
+	d := decoder{br: bitReader{data: data}, limit: limit, distances: [4]int{4, 11, 15, 16}}
+	if err := d.decode(); err != nil {
+		return nil, err
+	}
+	return d.out, nil
+}
+
+type decoder struct {
+	br    bitReader
+	out   []byte
+	limit int
+
+	// maxBackward is the largest distance allowed by the window size.
+	maxBackward int
+
+	// distances are the last four distances, the last one first.
+	distances [4]int
+}
+
+func (d *decoder) decode() error {
+	windowBits, err := d.windowBits()
+	if err != nil {
+		return err
+	}
+	d.maxBackward = 1<<windowBits - 16
+
+	for {
+		last, err := d.metaBlock()
+		if err != nil {
+			return err
+		}
+		if d.br.overrun {
+			return ErrFormat
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+func (d *decoder) windowBits() (uint, error) {
+	if d.br.read(1) == 0 {
+		return 16, nil
+	}
+	if n := d.br.read(3); n != 0 {
+		return 17 + uint(n), nil
+	}
+	switch n := d.br.read(3); n {
+	case 0:
+		return 17, nil
+	case 1:
+		return 0, ErrFormat
+	default:
+		return 8 + uint(n), nil
+	}
+}
+
+// metaBlock decodes one meta-block and reports whether it was the last.
+func (d *decoder) metaBlock() (last bool, err error) {
+	br := &d.br
+	last = br.read(1) == 1
+	if last && br.read(1) == 1 {
+		return true, nil
+	}
+
+	nibbles := int(br.read(2)) + 4
+	if nibbles == 7 {
+		// A metadata block, skipped.
+		if br.read(1) != 0 {
+			return false, ErrFormat
+		}
+		skipBytes := int(br.read(2))
+		skip := 0
+		for i := range skipBytes {
+			b := int(br.read(8))
+			if i > 0 && i == skipBytes-1 && b == 0 {
+				return false, ErrFormat
+			}
+			skip |= b << (8 * i)
+		}
+		if skipBytes > 0 {
+			skip++
+		}
+		if !br.align() || br.bytes(skip) == nil {
+			return false, ErrFormat
+		}
+		return last, nil
+	}
+
+	length := 0
+	for i := range nibbles {
+		nibble := int(br.read(4))
+		if i > 3 && i == nibbles-1 && nibble == 0 {
+			return false, ErrFormat
+		}
+		length |= nibble << (4 * i)
+	}
+	length++
+	if len(d.out)+length > d.limit {
+		return false, ErrTooLarge
+	}
+
+	if !last && br.read(1) == 1 {
+		if !br.align() {
+			return false, ErrFormat
+		}
+		data := br.bytes(length)
+		if data == nil {
+			return false, ErrFormat
+		}
+		d.out = append(d.out, data...)
+		return false, nil
+	}
+	return last, d.compressedBlock(length)
+}
+
+// blockState tracks the block type and remaining count of one category of
+// a meta-block.
+type blockState struct {
+	types     int
+	typeCode  prefixCode
+	countCode prefixCode
+
+	current, previous int
+	count             int
+}
+
+func (d *decoder) readBlockState() (state blockState, err error) {
+	state.types = d.readVarLength()
+	state.previous = 1
+	state.count = 1 << 28
+	if state.types < 2 {
+		return
+	}
+
+	if state.typeCode, err = d.readPrefixCode(state.types + 2); err != nil {
+		return
+	}
+	if state.countCode, err = d.readPrefixCode(blockCountAlphabet); err != nil {
+		return
+	}
+	state.count, err = d.readBlockCount(&state)
+	return
+}
+
+func (d *decoder) readBlockCount(state *blockState) (int, error) {
+	symbol, err := state.countCode.decode(&d.br)
+	if err != nil {
+		return 0, err
+	}
+	return blockCountBase[symbol] + int(d.br.read(blockCountExtra[symbol])), nil
+}
+
+// next consumes one item of the category, switching block type first
+// when the current block is exhausted.
+func (d *decoder) next(state *blockState) error {
+	if state.count == 0 {
+		symbol, err := state.typeCode.decode(&d.br)
+		if err != nil {
+			return err
+		}
+
+		var blockType int
+		switch symbol {
+		case 0:
+			blockType = state.previous
+		case 1:
+			blockType = (state.current + 1) % state.types
+		default:
+			blockType = symbol - 2
+		}
+		if blockType >= state.types {
+			return ErrFormat
+		}
+		state.previous, state.current = state.current, blockType
+
+		if state.count, err = d.readBlockCount(state); err != nil {
+			return err
+		}
+	}
+	state.count--
+	return nil
+}
+
+// readVarLength reads the number of block types or of prefix trees.
+func (d *decoder) readVarLength() int {
+	if d.br.read(1) == 0 {
+		return 1
+	}
+	n := uint(d.br.read(3))
+	if n == 0 {
+		return 2
+	}
+	return 1<<n + int(d.br.read(n)) + 1
+}
+
+func (d *decoder) compressedBlock(length int) error {
+	br := &d.br
+
+	literals, err := d.readBlockState()
+	if err != nil {
+		return err
+	}
+	commands, err := d.readBlockState()
+	if err != nil {
+		return err
+	}
+	distances, err := d.readBlockState()
+	if err != nil {
+		return err
+	}
+
+	postfixBits := uint(br.read(2))
+	directCodes := int(br.read(4)) << postfixBits
+
+	modes := make([]int, literals.types)
+	for i := range modes {
+		modes[i] = int(br.read(2))
+	}
+
+	literalMap, literalTrees, err := d.readContextMap(64 * literals.types)
+	if err != nil {
+		return err
+	}
+	distanceMap, distanceTrees, err := d.readContextMap(4 * distances.types)
+	if err != nil {
+		return err
+	}
+
+	literalCodes, err := d.readPrefixCodes(literalTrees, literalAlphabet)
+	if err != nil {
+		return err
+	}
+	commandCodes, err := d.readPrefixCodes(commands.types, commandAlphabet)
+	if err != nil {
+		return err
+	}
+	distanceCodes, err := d.readPrefixCodes(distanceTrees, numDistanceShort+directCodes+48<<postfixBits)
+	if err != nil {
+		return err
+	}
+
+	for length > 0 {
+		if br.overrun {
+			return ErrFormat
+		}
+
+		if err := d.next(&commands); err != nil {
+			return err
+		}
+		symbol, err := commandCodes[commands.current].decode(br)
+		if err != nil {
+			return err
+		}
+		cell := commandCells[symbol>>6]
+		insertCode, copyCode := cell[0]+symbol>>3&7, cell[1]+symbol&7
+		insertLength := insertLengthBase[insertCode] + int(br.read(insertLengthExtra[insertCode]))
+		copyLength := copyLengthBase[copyCode] + int(br.read(copyLengthExtra[copyCode]))
+
+		if insertLength > length {
+			return ErrFormat
+		}
+		for range insertLength {
+			if err := d.next(&literals); err != nil {
+				return err
+			}
+			var p1, p2 byte
+			if n := len(d.out); n > 1 {
+				p1, p2 = d.out[n-1], d.out[n-2]
+			} else if n == 1 {
+				p1 = d.out[0]
+			}
+			tree := literalMap[64*literals.current+literalContext(modes[literals.current], p1, p2)]
+			literal, err := literalCodes[tree].decode(br)
+			if err != nil {
+				return err
+			}
+			d.out = append(d.out, byte(literal))
+		}
+		length -= insertLength
+		if length == 0 {
+			break
+		}
+
+		distanceCode := 0
+		if symbol >= 128 {
+			if err := d.next(&distances); err != nil {
+				return err
+			}
+			tree := distanceMap[4*distances.current+min(copyLength, 5)-2]
+			if distanceCode, err = distanceCodes[tree].decode(br); err != nil {
+				return err
+			}
+		}
+
+		distance, err := d.distance(distanceCode, postfixBits, directCodes)
+		if err != nil {
+			return err
+		}
+
+		maxDistance := min(d.maxBackward, len(d.out))
+		if distance > maxDistance {
+			n, err := d.dictionaryWord(distance-maxDistance-1, copyLength)
+			if err != nil {
+				return err
+			}
+			if n > length {
+				return ErrFormat
+			}
+			length -= n
+			continue
+		}
+
+		if copyLength > length {
+			return ErrFormat
+		}
+		if distanceCode != 0 {
+			d.distances = [4]int{distance, d.distances[0], d.distances[1], d.distances[2]}
+		}
+		start := len(d.out) - distance
+		for i := range copyLength {
+			d.out = append(d.out, d.out[start+i])
+		}
+		length -= copyLength
+	}
+	return nil
+}
+
+// distance resolves a distance code, reading its extra bits.
+func (d *decoder) distance(code int, postfixBits uint, directCodes int) (int, error) {
+	if code < numDistanceShort {
+		last, secondLast := d.distances[0], d.distances[1]
+		var distance int
+		switch {
+		case code < 4:
+			distance = d.distances[code]
+		case code < 10:
+			distance = last + distanceShortDelta(code-4)
+		default:
+			distance = secondLast + distanceShortDelta(code-10)
+		}
+		if distance <= 0 {
+			return 0, ErrFormat
+		}
+		return distance, nil
+	}
+	if code < numDistanceShort+directCodes {
+		return code - numDistanceShort + 1, nil
+	}
+
+	code -= numDistanceShort + directCodes
+	extraBits := 1 + uint(code)>>(postfixBits+1)
+	high := code >> postfixBits
+	low := code & (1<<postfixBits - 1)
+	offset := (2+high&1)<<extraBits - 4
+	return (offset+int(d.br.read(extraBits)))<<postfixBits + low + directCodes + 1, nil
+}
+
+// distanceShortDelta returns -1, +1, -2, +2, -3, +3 for 0 to 5.
+func distanceShortDelta(i int) int {
+	delta := i/2 + 1
+	if i%2 == 0 {
+		return -delta
+	}
+	return delta
+}
+
+// dictionaryWord appends the transformed static dictionary word a
+// distance beyond the window references, and returns its length.
+func (d *decoder) dictionaryWord(wordID int, length int) (int, error) {
+	if length < 4 || length > 24 {
+		return 0, ErrFormat
+	}
+	bits := dictionarySizeBits[length]
+	index := wordID & (1<<bits - 1)
+	transform := wordID >> bits
+	if transform >= numTransforms {
+		return 0, ErrFormat
+	}
+
+	offset := dictionaryOffsets[length] + index*length
+	start := len(d.out)
+	d.out = transformWord(d.out, dictionary[offset:offset+length], transform)
+	return len(d.out) - start, nil
+}
+
+func literalContext(mode int, p1, p2 byte) int {
+	switch mode {
+	case 0:
+		return int(p1 & 0x3f)
+	case 1:
+		return int(p1 >> 2)
+	case 2:
+		return int(lut0[p1] | lut1[p2])
+	default:
+		return int(lut2[p1]<<3 | lut2[p2])
+	}
+}
+
+// readContextMap reads the number of prefix trees and, when there are
+// several, the map of contexts to trees.
+func (d *decoder) readContextMap(size int) (contextMap []byte, trees int, err error) {
+	br := &d.br
+	trees = d.readVarLength()
+	contextMap = make([]byte, size)
+	if trees < 2 {
+		return contextMap, trees, nil
+	}
+
+	maxRunLength := 0
+	if br.read(1) == 1 {
+		maxRunLength = int(br.read(4)) + 1
+	}
+	code, err := d.readPrefixCode(trees + maxRunLength)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := 0; i < size; {
+		symbol, err := code.decode(br)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch {
+		case symbol == 0:
+			i++
+		case symbol <= maxRunLength:
+			run := 1<<symbol + int(br.read(uint(symbol)))
+			if i+run > size {
+				return nil, 0, ErrFormat
+			}
+			i += run
+		default:
+			contextMap[i] = byte(symbol - maxRunLength)
+			i++
+		}
+	}
+
+	if br.read(1) == 1 {
+		var mtf [256]byte
+		for i := range mtf {
+			mtf[i] = byte(i)
+		}
+		for i, index := range contextMap {
+			value := mtf[index]
+			contextMap[i] = value
+			copy(mtf[1:index+1], mtf[:index])
+			mtf[0] = value
+		}
+	}
+	return contextMap, trees, nil
+}
+
+func (d *decoder) readPrefixCodes(n int, alphabet int) ([]prefixCode, error) {
+	codes := make([]prefixCode, n)
+	for i := range codes {
+		var err error
+		if codes[i], err = d.readPrefixCode(alphabet); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// readPrefixCode reads a simple or complex prefix code of RFC 7932
+// section 3.4 and 3.5.
+func (d *decoder) readPrefixCode(alphabet int) (prefixCode, error) {
+	br := &d.br
+	lengths := make([]uint8, alphabet)
+
+	skip := int(br.read(2))
+	if skip == 1 {
+		alphabetBits := uint(0)
+		for (alphabet-1)>>alphabetBits != 0 {
+			alphabetBits++
+		}
+
+		symbols := make([]int, br.read(2)+1)
+		for i := range symbols {
+			symbols[i] = int(br.read(alphabetBits))
+			if symbols[i] >= alphabet || lengths[symbols[i]] != 0 {
+				return prefixCode{}, ErrFormat
+			}
+			lengths[symbols[i]] = 1
+		}
+
+		switch len(symbols) {
+		case 1:
+			return prefixCode{single: symbols[0], symbols: symbols}, nil
+		case 3:
+			lengths[symbols[1]], lengths[symbols[2]] = 2, 2
+		case 4:
+			for _, symbol := range symbols {
+				lengths[symbol] = 2
+			}
+			if br.read(1) == 1 {
+				lengths[symbols[0]], lengths[symbols[1]], lengths[symbols[2]], lengths[symbols[3]] = 1, 2, 3, 3
+			}
+		}
+		return newPrefixCode(lengths)
+	}
+
+	var codeLengths [codeLengthAlphabet]uint8
+	space, used := 32, 0
+	for _, symbol := range codeLengthOrder[skip:] {
+		length := br.readCodeLengthCodeLength()
+		codeLengths[symbol] = length
+		if length != 0 {
+			space -= 32 >> length
+			used++
+			if space <= 0 {
+				break
+			}
+		}
+	}
+	if used != 1 && space != 0 {
+		return prefixCode{}, ErrFormat
+	}
+	codeLengthCode, err := newPrefixCode(codeLengths[:])
+	if err != nil {
+		return prefixCode{}, err
+	}
+
+	previous, repeat, repeatLength := uint8(defaultCodeLength), 0, uint8(0)
+	space = 1 << maxCodeLength
+	for symbol := 0; symbol < alphabet && space > 0; {
+		code, err := codeLengthCode.decode(br)
+		if err != nil {
+			return prefixCode{}, err
+		}
+
+		if code < 16 {
+			repeat = 0
+			lengths[symbol] = uint8(code)
+			symbol++
+			if code != 0 {
+				previous = uint8(code)
+				space -= 1 << maxCodeLength >> code
+			}
+			continue
+		}
+
+		extraBits, length := uint(2), previous
+		if code == 17 {
+			extraBits, length = 3, 0
+		}
+		if repeatLength != length {
+			repeat, repeatLength = 0, length
+		}
+		old := repeat
+		if repeat > 0 {
+			repeat = (repeat - 2) << extraBits
+		}
+		repeat += int(br.read(extraBits)) + 3
+
+		count := repeat - old
+		if symbol+count > alphabet {
+			return prefixCode{}, ErrFormat
+		}
+		for range count {
+			lengths[symbol] = length
+			symbol++
+		}
+		if length != 0 {
+			space -= count << maxCodeLength >> length
+		}
+	}
+	if space != 0 {
+		return prefixCode{}, ErrFormat
+	}
+	return newPrefixCode(lengths)
+}
+
+// prefixCode decodes canonical prefix codes bit by bit.
+type prefixCode struct {
+	// counts is the number of codes of each length, and symbols the
+	// symbols in code order.
+	counts  [maxCodeLength + 1]int
+	symbols []int
+
+	// single is the symbol of a code with only one, which takes no bits.
+	single int
+}
+
+func newPrefixCode(lengths []uint8) (prefixCode, error) {
+	var code prefixCode
+	for _, length := range lengths {
+		code.counts[length]++
+	}
+	code.counts[0] = 0
+
+	var offsets [maxCodeLength + 2]int
+	for length := 1; length <= maxCodeLength; length++ {
+		offsets[length+1] = offsets[length] + code.counts[length]
+	}
+	code.symbols = make([]int, offsets[maxCodeLength+1])
+	for symbol, length := range lengths {
+		if length != 0 {
+			code.symbols[offsets[length]] = symbol
+			offsets[length]++
+		}
+	}
+
+	switch len(code.symbols) {
+	case 0:
+		return code, ErrFormat
+	case 1:
+		code.single = code.symbols[0]
+		code.counts = [maxCodeLength + 1]int{}
+	}
+	return code, nil
+}
+
+func (code *prefixCode) decode(br *bitReader) (int, error) {
+	if len(code.symbols) == 1 {
+		return code.single, nil
+	}
+
+	value, first, index := 0, 0, 0
+	for length := 1; length <= maxCodeLength; length++ {
+		value |= int(br.read(1))
+		count := code.counts[length]
+		if value-first < count {
+			return code.symbols[index+value-first], nil
+		}
+		index += count
+		first = (first + count) << 1
+		value <<= 1
+	}
+	return 0, ErrFormat
+}
+
+// bitReader reads bits from the least significant bit of each byte.
+type bitReader struct {
+	data []byte
+	pos  int
+	bits uint64
+	n    uint
+
+	// overrun reports reads past the end of data, which return zeros.
+	overrun bool
+}
+
+func (br *bitReader) read(n uint) uint32 {
+	for br.n < n {
+		if br.pos < len(br.data) {
+			br.bits |= uint64(br.data[br.pos]) << br.n
+		} else {
+			br.overrun = true
+		}
+		br.pos++
+		br.n += 8
+	}
+	value := uint32(br.bits & (1<<n - 1))
+	br.bits >>= n
+	br.n -= n
+	return value
+}
+
+// readCodeLengthCodeLength reads the fixed code of RFC 7932 section 3.5.
+func (br *bitReader) readCodeLengthCodeLength() uint8 {
+	switch br.read(2) {
+	case 0:
+		return 0
+	case 1:
+		return 4
+	case 2:
+		return 3
+	}
+	if br.read(1) == 0 {
+		return 2
+	}
+	if br.read(1) == 0 {
+		return 1
+	}
+	return 5
+}
+
+// align skips to the next byte boundary, reporting whether the padding
+// bits are zero as required.
+func (br *bitReader) align() bool {
+	return br.read(br.n%8) == 0
+}
+
+// bytes returns the next n bytes of a byte aligned reader, or nil when
+// there are not enough.
+func (br *bitReader) bytes(n int) []byte {
+	buffered := int(br.n / 8)
+	start := br.pos - buffered
+	if n < 0 || start+n > len(br.data) {
+		br.overrun = true
+		return nil
+	}
+	br.pos, br.bits, br.n = start+n, 0, 0
+	return br.data[start : start+n]
+}
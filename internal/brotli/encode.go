@@ -0,0 +1,460 @@
+package brotli
+
+import (
+	"math/bits"
+	"slices"
+)
+
+// Parameters of the encoder. Matches are searched in a hash chain of the
+// positions starting with the same four bytes.
+const (
+	encodeWindowBits = 22
+	encodeBlockSize  = 1 << 24
+	hashBits         = 16
+	minMatch         = 4
+	maxMatch         = 1 << 16
+	maxChain         = 48
+)
+
+// Encode compresses data into a Brotli stream. It finds repeated strings
+// greedily and codes every meta-block with a single prefix code per
+// alphabet, which is fast and compresses within a few percent of zlib.
+func Encode(data []byte) []byte {
+	var w bitWriter
+	// WBITS 22 is coded as 1 followed by the three bits 101.
+	w.write(1, 1)
+	w.write(encodeWindowBits-17, 3)
+
+	m := newMatcher(data)
+	for start := 0; start < len(data); start += encodeBlockSize {
+		end := min(start+encodeBlockSize, len(data))
+		writeMetaBlock(&w, data[start:end], m.commands(start, end))
+	}
+
+	// ISLAST and ISLASTEMPTY.
+	w.write(1, 1)
+	w.write(1, 1)
+	return w.flush()
+}
+
+// command inserts literals, then copies copyLength bytes from distance
+// bytes back. The last command of a meta-block may have no copy.
+type command struct {
+	insertLength int
+	copyLength   int
+	distance     int
+}
+
+type matcher struct {
+	data []byte
+	head []int32
+	prev []int32
+}
+
+func newMatcher(data []byte) *matcher {
+	m := &matcher{data: data, head: make([]int32, 1<<hashBits), prev: make([]int32, len(data))}
+	for i := range m.head {
+		m.head[i] = -1
+	}
+	return m
+}
+
+func (m *matcher) hash(i int) uint32 {
+	v := uint32(m.data[i]) | uint32(m.data[i+1])<<8 | uint32(m.data[i+2])<<16 | uint32(m.data[i+3])<<24
+	return v * 0x1e35a7bd >> (32 - hashBits)
+}
+
+func (m *matcher) insert(i int) {
+	if i+minMatch <= len(m.data) {
+		h := m.hash(i)
+		m.prev[i] = m.head[h]
+		m.head[h] = int32(i)
+	}
+}
+
+// commands parses data[start:end] into commands, matching strings of the
+// whole window before them.
+func (m *matcher) commands(start, end int) (commands []command) {
+	maxDistance := 1<<encodeWindowBits - 16
+	literals := start
+	for i := start; i < end; {
+		length, distance := 0, 0
+		if i+minMatch <= end {
+			limit := min(end-i, maxMatch)
+			chain := 0
+			for candidate := int(m.head[m.hash(i)]); candidate >= 0 && chain < maxChain; candidate = int(m.prev[candidate]) {
+				if i-candidate > maxDistance {
+					break
+				}
+				n := 0
+				for n < limit && m.data[candidate+n] == m.data[i+n] {
+					n++
+				}
+				if n > length {
+					length, distance = n, i-candidate
+					if n == limit {
+						break
+					}
+				}
+				chain++
+			}
+		}
+
+		if length < minMatch {
+			m.insert(i)
+			i++
+			continue
+		}
+
+		commands = append(commands, command{insertLength: i - literals, copyLength: length, distance: distance})
+		for j := i; j < i+length; j++ {
+			m.insert(j)
+		}
+		i += length
+		literals = i
+	}
+	if literals < end {
+		commands = append(commands, command{insertLength: end - literals})
+	}
+	return
+}
+
+// writeMetaBlock writes a compressed meta-block holding one block type per
+// category and no context modeling.
+func writeMetaBlock(w *bitWriter, data []byte, commands []command) {
+	var literalCounts [literalAlphabet]int
+	var commandCounts [commandAlphabet]int
+	var distanceCounts [numDistanceShort + 48]int
+
+	symbols := make([]encodedCommand, len(commands))
+	position := 0
+	for i, c := range commands {
+		for _, b := range data[position : position+c.insertLength] {
+			literalCounts[b]++
+		}
+		position += c.insertLength + c.copyLength
+
+		symbols[i] = encodeCommand(c)
+		commandCounts[symbols[i].symbol]++
+		if c.copyLength > 0 {
+			distanceCounts[symbols[i].distanceSymbol]++
+		}
+	}
+
+	// ISLAST, MNIBBLES and MLEN - 1, ISUNCOMPRESSED.
+	w.write(0, 1)
+	nibbles := max(4, (bits.Len(uint(len(data)-1))+3)/4)
+	w.write(uint64(nibbles-4), 2)
+	w.write(uint64(len(data)-1), uint(4*nibbles))
+	w.write(0, 1)
+
+	// One block type for literals, commands and distances, NPOSTFIX and
+	// NDIRECT of zero, the LSB6 context mode and one tree per category.
+	w.write(0, 3)
+	w.write(0, 2+4)
+	w.write(0, 2)
+	w.write(0, 2)
+
+	literalCode := newHuffmanCode(literalCounts[:], maxCodeLength)
+	commandCode := newHuffmanCode(commandCounts[:], maxCodeLength)
+	distanceCode := newHuffmanCode(distanceCounts[:], maxCodeLength)
+	literalCode.writeTo(w)
+	commandCode.writeTo(w)
+	distanceCode.writeTo(w)
+
+	position = 0
+	for i, c := range commands {
+		s := symbols[i]
+		commandCode.writeSymbol(w, s.symbol)
+		w.write(uint64(s.insertExtra), insertLengthExtra[s.insertCode])
+		w.write(uint64(s.copyExtra), copyLengthExtra[s.copyCode])
+		for _, b := range data[position : position+c.insertLength] {
+			literalCode.writeSymbol(w, int(b))
+		}
+		position += c.insertLength + c.copyLength
+
+		if c.copyLength > 0 {
+			distanceCode.writeSymbol(w, s.distanceSymbol)
+			w.write(uint64(s.distanceExtra), s.distanceBits)
+		}
+	}
+}
+
+type encodedCommand struct {
+	symbol                 int
+	insertCode, copyCode   int
+	insertExtra, copyExtra int
+	distanceSymbol         int
+	distanceExtra          int
+	distanceBits           uint
+}
+
+// encodeCommand finds the insert-and-copy symbol and distance code of a
+// command, always coding the distance explicitly.
+func encodeCommand(c command) (s encodedCommand) {
+	s.insertCode = lengthCode(insertLengthBase[:], c.insertLength)
+	s.insertExtra = c.insertLength - insertLengthBase[s.insertCode]
+	copyLength := max(c.copyLength, 2)
+	s.copyCode = lengthCode(copyLengthBase[:], copyLength)
+	s.copyExtra = copyLength - copyLengthBase[s.copyCode]
+
+	cell := slices.Index(commandCells[2:], [2]int{s.insertCode &^ 7, s.copyCode &^ 7}) + 2
+	s.symbol = cell<<6 | (s.insertCode&7)<<3 | s.copyCode&7
+
+	if c.copyLength > 0 {
+		d := c.distance + 3
+		s.distanceBits = uint(bits.Len(uint(d)) - 2)
+		high := d >> s.distanceBits & 1
+		s.distanceSymbol = numDistanceShort + 2*(int(s.distanceBits)-1) + high
+		s.distanceExtra = d - (2+high)<<s.distanceBits
+	}
+	return
+}
+
+// lengthCode returns the last code whose base is at most length.
+func lengthCode(bases []int, length int) int {
+	code, _ := slices.BinarySearch(bases, length+1)
+	return code - 1
+}
+
+// huffmanCode is a canonical prefix code with the codes bit reversed, as
+// they are written least significant bit first.
+type huffmanCode struct {
+	lengths []uint8
+	codes   []uint16
+
+	// single is the only symbol used, coded with zero bits, or -1 when
+	// several are.
+	single int
+}
+
+// newHuffmanCode builds a prefix code for the symbol counts whose codes
+// are at most maxLength bits long.
+func newHuffmanCode(counts []int, maxLength int) huffmanCode {
+	code := huffmanCode{lengths: huffmanLengths(counts, maxLength), codes: make([]uint16, len(counts)), single: -1}
+	if slices.Max(code.lengths) == 0 {
+		code.single = max(0, slices.IndexFunc(counts, func(count int) bool { return count > 0 }))
+		return code
+	}
+
+	var lengthCounts [maxCodeLength + 2]int
+	for _, length := range code.lengths {
+		lengthCounts[length]++
+	}
+	lengthCounts[0] = 0
+
+	var next [maxCodeLength + 2]int
+	for length, value := 1, 0; length <= maxCodeLength; length++ {
+		value = (value + lengthCounts[length-1]) << 1
+		next[length] = value
+	}
+	for symbol, length := range code.lengths {
+		if length != 0 {
+			code.codes[symbol] = uint16(bits.Reverse16(uint16(next[length])) >> (16 - length))
+			next[length]++
+		}
+	}
+	return code
+}
+
+// huffmanLengths returns Huffman code lengths limited to maxLength bits,
+// flattening the counts until the tree is shallow enough. A single used
+// symbol gets no length, as it is coded with zero bits.
+func huffmanLengths(counts []int, maxLength int) []uint8 {
+	lengths := make([]uint8, len(counts))
+	var used []int
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) < 2 {
+		return lengths
+	}
+
+	for floor := 1; ; floor *= 2 {
+		type node struct {
+			count         int
+			left, right   int
+			symbol, depth int
+		}
+		nodes := make([]node, 0, 2*len(used))
+		for _, symbol := range used {
+			nodes = append(nodes, node{count: max(counts[symbol], floor), left: -1, right: -1, symbol: symbol})
+		}
+		slices.SortStableFunc(nodes, func(a, b node) int { return a.count - b.count })
+
+		// Merge the two lightest of the sorted leaves and of the internal
+		// nodes, which are created in increasing weight.
+		leaf, internal := 0, len(nodes)
+		lightest := func() int {
+			if leaf < len(used) && (internal == len(nodes) || nodes[leaf].count <= nodes[internal].count) {
+				leaf++
+				return leaf - 1
+			}
+			internal++
+			return internal - 1
+		}
+		for range len(used) - 1 {
+			a := lightest()
+			b := lightest()
+			nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, left: a, right: b})
+		}
+
+		deepest := 0
+		for i := len(nodes) - 1; i >= 0; i-- {
+			n := nodes[i]
+			if n.left < 0 {
+				lengths[n.symbol] = uint8(n.depth)
+				deepest = max(deepest, n.depth)
+				continue
+			}
+			nodes[n.left].depth = n.depth + 1
+			nodes[n.right].depth = n.depth + 1
+		}
+		if deepest <= maxLength {
+			return lengths
+		}
+	}
+}
+
+func (code *huffmanCode) writeSymbol(w *bitWriter, symbol int) {
+	w.write(uint64(code.codes[symbol]), uint(code.lengths[symbol]))
+}
+
+// writeTo writes the code lengths, as a simple prefix code when a single
+// symbol is used and as a complex one otherwise.
+func (code *huffmanCode) writeTo(w *bitWriter) {
+	if code.single >= 0 {
+		// HSKIP 1 marks a simple prefix code, here with one symbol.
+		w.write(1, 2)
+		w.write(0, 2)
+		w.write(uint64(code.single), uint(bits.Len(uint(len(code.lengths)-1))))
+		return
+	}
+
+	last := len(code.lengths) - 1
+	for code.lengths[last] == 0 {
+		last--
+	}
+
+	var tokens, extra []int
+	for i := 0; i <= last; {
+		length := code.lengths[i]
+		run := 1
+		for i+run <= last && code.lengths[i+run] == length {
+			run++
+		}
+		i += run
+
+		if length != 0 {
+			for range run {
+				tokens = append(tokens, int(length))
+				extra = append(extra, 0)
+			}
+			continue
+		}
+		tokens, extra = appendZeroRun(tokens, extra, run)
+	}
+
+	var tokenCounts [codeLengthAlphabet]int
+	for _, token := range tokens {
+		tokenCounts[token]++
+	}
+	tokenCode := newHuffmanCode(tokenCounts[:], 5)
+	tokenLengths := slices.Clone(tokenCode.lengths)
+	end := codeLengthAlphabet
+	if tokenCode.single >= 0 {
+		// A single code length symbol takes zero bits whatever its
+		// length, and the decoder then reads all the lengths.
+		tokenLengths[tokenCode.single] = 1
+	} else {
+		// Trailing zero lengths are left out once the code is complete.
+		for tokenLengths[codeLengthOrder[end-1]] == 0 {
+			end--
+		}
+	}
+
+	skip := 0
+	for skip < 3 && tokenLengths[codeLengthOrder[skip]] == 0 {
+		skip++
+	}
+	if skip == 1 {
+		skip = 0
+	}
+
+	w.write(uint64(skip), 2)
+	for _, symbol := range codeLengthOrder[skip:end] {
+		length := tokenLengths[symbol]
+		w.write(uint64(codeLengthCodeBits[length]), codeLengthCodeSizes[length])
+	}
+
+	for i, token := range tokens {
+		tokenCode.writeSymbol(w, token)
+		if token == 17 {
+			w.write(uint64(extra[i]), 3)
+		}
+	}
+}
+
+// The fixed code of the code length code lengths 0 to 5, bit reversed.
+var (
+	codeLengthCodeBits  = [6]uint32{0, 7, 3, 2, 1, 15}
+	codeLengthCodeSizes = [6]uint{2, 4, 3, 2, 2, 4}
+)
+
+// appendZeroRun codes a run of zero code lengths with repeat codes, whose
+// consecutive occurrences the decoder combines as base 8 digits.
+func appendZeroRun(tokens, extra []int, run int) ([]int, []int) {
+	if run == 11 {
+		tokens = append(tokens, 0)
+		extra = append(extra, 0)
+		run--
+	}
+	if run < 3 {
+		for range run {
+			tokens = append(tokens, 0)
+			extra = append(extra, 0)
+		}
+		return tokens, extra
+	}
+
+	start := len(tokens)
+	run -= 3
+	for {
+		tokens = append(tokens, 17)
+		extra = append(extra, run&7)
+		run >>= 3
+		if run == 0 {
+			break
+		}
+		run--
+	}
+	slices.Reverse(tokens[start:])
+	slices.Reverse(extra[start:])
+	return tokens, extra
+}
+
+// bitWriter writes bits from the least significant bit of each byte.
+type bitWriter struct {
+	out  []byte
+	bits uint64
+	n    uint
+}
+
+func (w *bitWriter) write(value uint64, n uint) {
+	w.bits |= value << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.out = append(w.out, byte(w.bits))
+		w.bits >>= 8
+		w.n -= 8
+	}
+}
+
+func (w *bitWriter) flush() []byte {
+	if w.n > 0 {
+		w.out = append(w.out, byte(w.bits))
+	}
+	return w.out
+}
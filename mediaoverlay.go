@@ -0,0 +1,580 @@
+package epub
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/raitucarp/epub/pkg"
+	"github.com/raitucarp/epub/smil"
+	"golang.org/x/net/html"
+)
+
+// MediaOverlay is the parsed media overlay of a content document, with
+// text and audio references resolved to publication resources.
+type MediaOverlay struct {
+	// ResourceID is the manifest ID of the SMIL document.
+	ResourceID string `json:"resourceId"`
+	Path       string `json:"path"`
+
+	// Duration is the media:duration declared for this overlay, or zero.
+	Duration time.Duration `json:"duration"`
+
+	Body MediaOverlayItem `json:"body"`
+}
+
+// MediaOverlayItem is a seq or a par of a media overlay. A seq has Items
+// and an optional Text from its epub:textref, a par has Text and Audio.
+type MediaOverlayItem struct {
+	ID    string             `json:"id,omitempty"`
+	Type  string             `json:"type,omitempty"`
+	Par   bool               `json:"par,omitempty"`
+	Text  *MediaOverlayText  `json:"text,omitempty"`
+	Audio *MediaOverlayClip  `json:"audio,omitempty"`
+	Items []MediaOverlayItem `json:"items,omitempty"`
+}
+
+// MediaOverlayText references an element of a content document.
+type MediaOverlayText struct {
+	Path       string `json:"path"`
+	ResourceID string `json:"resourceId,omitempty"`
+	Fragment   string `json:"fragment,omitempty"`
+}
+
+// MediaOverlayClip is a clip of an audio resource. End is zero when the
+// clip plays to the end of the file.
+type MediaOverlayClip struct {
+	Path       string        `json:"path"`
+	ResourceID string        `json:"resourceId,omitempty"`
+	Begin      time.Duration `json:"begin"`
+	End        time.Duration `json:"end,omitempty"`
+}
+
+// MediaOverlayMetadata holds the package level media overlay metadata.
+type MediaOverlayMetadata struct {
+	// Duration is the total duration of all media overlays.
+	Duration  time.Duration `json:"duration"`
+	Narrators []string      `json:"narrators,omitempty"`
+
+	// ActiveClass is the CSS class reading systems apply to the element
+	// currently being read.
+	ActiveClass string `json:"activeClass,omitempty"`
+
+	// PlaybackActiveClass is the CSS class applied to the document while
+	// the overlay plays.
+	PlaybackActiveClass string `json:"playbackActiveClass,omitempty"`
+}
+
+// Pars returns every par of the overlay in playback order, flattening
+// nested seqs.
+func (m *MediaOverlay) Pars() (pars []MediaOverlayItem) {
+	var collect func(item MediaOverlayItem)
+	collect = func(item MediaOverlayItem) {
+		if item.Par {
+			pars = append(pars, item)
+			return
+		}
+		for _, child := range item.Items {
+			collect(child)
+		}
+	}
+	collect(m.Body)
+	return
+}
+
+// MediaOverlay returns the media overlay of the manifest item with the
+// given ID, usually a spine item.
+func (r *Reader) MediaOverlay(spineItemID string) (overlay *MediaOverlay, err error) {
+	var overlayID string
+	found := false
+	for _, item := range r.CurrentSelectedPackage().Manifest.Items {
+		if item.ID == spineItemID {
+			overlayID, found = item.MediaOverlay, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("item %q not found", spineItemID)
+	}
+	if overlayID == "" {
+		return nil, fmt.Errorf("item %q has no media overlay", spineItemID)
+	}
+
+	res := r.SelectResourceById(overlayID)
+	if res == nil {
+		return nil, fmt.Errorf("media overlay %q not found", overlayID)
+	}
+	if res.MIMEType != pkg.MediaTypeSMIL {
+		return nil, fmt.Errorf("media overlay %q has media type %q", overlayID, res.MIMEType)
+	}
+
+	doc, err := smil.Parse(res.Content)
+	if err != nil {
+		return nil, fmt.Errorf("parse media overlay %q: %w", overlayID, err)
+	}
+
+	overlay = &MediaOverlay{ResourceID: res.ID, Path: res.Filepath}
+	for _, meta := range r.CurrentSelectedPackage().Metadata.Meta {
+		if meta.Property == pkg.MediaDuration && meta.Refines == "#"+res.ID {
+			overlay.Duration, _ = smil.ParseClockValue(meta.Value)
+		}
+	}
+
+	resolver := mediaOverlayResolver{dir: path.Dir(res.Filepath), ids: r.resourceIDsByPath()}
+	if overlay.Body, err = resolver.seq(&doc.Body); err != nil {
+		return nil, fmt.Errorf("media overlay %q: %w", overlayID, err)
+	}
+	return overlay, nil
+}
+
+// MediaOverlayMetadata returns the media overlay metadata of the selected
+// package.
+func (r *Reader) MediaOverlayMetadata() (metadata MediaOverlayMetadata) {
+	for _, meta := range r.CurrentSelectedPackage().Metadata.Meta {
+		if meta.Refines != "" {
+			continue
+		}
+
+		value := strings.TrimSpace(meta.Value)
+		switch meta.Property {
+		case pkg.MediaDuration:
+			metadata.Duration, _ = smil.ParseClockValue(value)
+		case pkg.MediaNarrator:
+			metadata.Narrators = append(metadata.Narrators, value)
+		case pkg.MediaActiveClass:
+			metadata.ActiveClass = value
+		case pkg.MediaPlaybackActiveClass:
+			metadata.PlaybackActiveClass = value
+		}
+	}
+	return
+}
+
+func (r *Reader) resourceIDsByPath() map[string]string {
+	ids := map[string]string{}
+	for _, res := range r.epub.resources {
+		ids[unescapePath(res.Filepath)] = res.ID
+	}
+	return ids
+}
+
+// mediaOverlayResolver resolves the references of a SMIL document located
+// in dir to container paths and manifest IDs.
+type mediaOverlayResolver struct {
+	dir string
+	ids map[string]string
+}
+
+func (m mediaOverlayResolver) text(src string) *MediaOverlayText {
+	containerPath, fragment := resolveHref(m.dir, unescapePath(src))
+	return &MediaOverlayText{
+		Path:       containerPath,
+		ResourceID: m.ids[containerPath],
+		Fragment:   fragment,
+	}
+}
+
+func (m mediaOverlayResolver) seq(seq *smil.Seq) (item MediaOverlayItem, err error) {
+	item = MediaOverlayItem{ID: seq.ID, Type: seq.Type}
+	if seq.TextRef != "" {
+		item.Text = m.text(seq.TextRef)
+	}
+
+	for _, child := range seq.Items {
+		var childItem MediaOverlayItem
+		switch {
+		case child.Seq != nil:
+			childItem, err = m.seq(child.Seq)
+		case child.Par != nil:
+			childItem, err = m.par(child.Par)
+		}
+		if err != nil {
+			return
+		}
+		item.Items = append(item.Items, childItem)
+	}
+	return
+}
+
+func (m mediaOverlayResolver) par(par *smil.Par) (item MediaOverlayItem, err error) {
+	if par.Text.Src == "" {
+		return item, fmt.Errorf("par %q has no text", par.ID)
+	}
+
+	item = MediaOverlayItem{ID: par.ID, Type: par.Type, Par: true, Text: m.text(par.Text.Src)}
+	if par.Audio == nil {
+		return
+	}
+
+	containerPath, _ := resolveHref(m.dir, unescapePath(par.Audio.Src))
+	clip := &MediaOverlayClip{Path: containerPath, ResourceID: m.ids[containerPath]}
+	if par.Audio.ClipBegin != "" {
+		if clip.Begin, err = smil.ParseClockValue(par.Audio.ClipBegin); err != nil {
+			return
+		}
+	}
+	if par.Audio.ClipEnd != "" {
+		if clip.End, err = smil.ParseClockValue(par.Audio.ClipEnd); err != nil {
+			return
+		}
+	}
+	item.Audio = clip
+	return
+}
+
+// MediaOverlayTiming synchronizes an element of a content document with a
+// clip of an audio resource.
+type MediaOverlayTiming struct {
+	// TextID is the id of the element in the content document.
+	TextID string `json:"textId"`
+
+	// Audio is the manifest ID of an audio resource added with AddAudio.
+	Audio     string        `json:"audio"`
+	ClipBegin time.Duration `json:"clipBegin"`
+	ClipEnd   time.Duration `json:"clipEnd"`
+}
+
+// ParseMediaOverlayTimings reads timings from CSV records of text element
+// id, audio resource ID, clipBegin and clipEnd. Clip times may use any SMIL
+// clock value syntax, and a leading header record is skipped.
+func ParseMediaOverlayTimings(r io.Reader) (timings []MediaOverlayTiming, err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, record := range records {
+		begin, err := smil.ParseClockValue(record[2])
+		if err != nil && i == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+
+		end, err := smil.ParseClockValue(record[3])
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+
+		timings = append(timings, MediaOverlayTiming{
+			TextID:    strings.TrimSpace(record[0]),
+			Audio:     strings.TrimSpace(record[1]),
+			ClipBegin: begin,
+			ClipEnd:   end,
+		})
+	}
+	return
+}
+
+// AddAudio adds an audio resource from raw bytes to the publication.
+func (w *Writer) AddAudio(name string, content []byte) (res PublicationResource) {
+	href := path.Join(w.audioDir, name)
+	filePath := path.Join(w.contentDir, href)
+	base := filepath.Base(href)
+	return w.addResource(
+		base,
+		filePath,
+		href,
+		pkg.NotProperty,
+		audioMediaType(name, content),
+		content,
+	)
+}
+
+func audioMediaType(name string, content []byte) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".mp3":
+		return pkg.MediaTypeMP3
+	case ".m4a", ".mp4":
+		return pkg.MediaTypeMP4
+	case ".ogg", ".oga", ".opus":
+		return pkg.MediaTypeOgg
+	}
+	return http.DetectContentType(content)
+}
+
+// MediaOverlay generates the SMIL media overlay of a content document from
+// timings, in the given order, and links it with the media-overlay
+// attribute of the manifest item. The duration of the overlay and the
+// total duration of all overlays are written as media:duration metadata.
+// Calling it again for the same document replaces its overlay.
+func (w *Writer) MediaOverlay(contentID string, timings []MediaOverlayTiming) (err error) {
+	if len(timings) == 0 {
+		return fmt.Errorf("media overlay of %q has no timings", contentID)
+	}
+
+	packagePub := w.epub.SelectedPackage()
+	itemIndex := slices.IndexFunc(packagePub.Manifest.Items, func(item pkg.Item) bool {
+		return item.ID == contentID
+	})
+	content := w.resourceByID(contentID)
+	if itemIndex < 0 || content == nil {
+		return fmt.Errorf("content %q not found", contentID)
+	}
+	if content.MIMEType != pkg.MediaTypeXHTML {
+		return fmt.Errorf("content %q is not an XHTML document", contentID)
+	}
+
+	doc, err := parseXHTML(content.Content)
+	if err != nil {
+		return err
+	}
+
+	overlayID := strings.TrimSuffix(contentID, path.Ext(contentID)) + ".smil"
+	overlayHref := path.Join(path.Dir(content.Href), overlayID)
+	overlayPath := path.Join(w.contentDir, overlayHref)
+	overlayDir := path.Dir(overlayPath)
+
+	existing := w.resourceByID(overlayID)
+	if existing != nil && packagePub.Manifest.Items[itemIndex].MediaOverlay != overlayID {
+		return fmt.Errorf("resource %q already exists", overlayID)
+	}
+
+	body := smil.Seq{TextRef: relativeHref(overlayDir, content.Filepath, "")}
+	var duration time.Duration
+	for i, timing := range timings {
+		if findElementByID(doc, timing.TextID) == nil {
+			return fmt.Errorf("element %q not found in %q", timing.TextID, contentID)
+		}
+
+		audio := w.resourceByID(timing.Audio)
+		if audio == nil || !strings.HasPrefix(audio.MIMEType, "audio/") {
+			return fmt.Errorf("audio %q not found", timing.Audio)
+		}
+
+		if timing.ClipEnd <= timing.ClipBegin {
+			return fmt.Errorf("clip of %q ends before it begins", timing.TextID)
+		}
+		duration += timing.ClipEnd - timing.ClipBegin
+
+		body.Items = append(body.Items, smil.Item{Par: &smil.Par{
+			ID:   "par-" + strconv.Itoa(i+1),
+			Text: smil.Text{Src: relativeHref(overlayDir, content.Filepath, timing.TextID)},
+			Audio: &smil.Audio{
+				Src:       relativeHref(overlayDir, audio.Filepath, ""),
+				ClipBegin: smil.FormatClockValue(timing.ClipBegin),
+				ClipEnd:   smil.FormatClockValue(timing.ClipEnd),
+			},
+		}})
+	}
+
+	data, err := smil.Marshal(&smil.SMIL{Version: "3.0", Body: body})
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		w.replaceResourceContent(overlayID, data)
+	} else {
+		w.addResource(overlayID, overlayPath, overlayHref, pkg.NotProperty, pkg.MediaTypeSMIL, data)
+	}
+	packagePub.Manifest.Items[itemIndex].MediaOverlay = overlayID
+
+	w.updateMediaDurations(overlayID, duration)
+	return
+}
+
+// updateMediaDurations sets the media:duration of an overlay and
+// recomputes the total duration of the package.
+func (w *Writer) updateMediaDurations(overlayID string, duration time.Duration) {
+	metadata := &w.epub.SelectedPackage().Metadata
+	metadata.Meta = slices.DeleteFunc(metadata.Meta, func(meta pkg.Meta) bool {
+		return meta.Property == pkg.MediaDuration && (meta.Refines == "" || meta.Refines == "#"+overlayID)
+	})
+	w.Meta(pkg.Meta{Property: pkg.MediaDuration, Refines: "#" + overlayID, Value: smil.FormatClockValue(duration)})
+
+	var total time.Duration
+	for _, meta := range metadata.Meta {
+		if meta.Property == pkg.MediaDuration && meta.Refines != "" {
+			value, _ := smil.ParseClockValue(meta.Value)
+			total += value
+		}
+	}
+	w.setPackageMeta(pkg.MediaDuration, smil.FormatClockValue(total))
+}
+
+// MediaOverlayNarrator adds a narrator of the media overlays.
+func (w *Writer) MediaOverlayNarrator(name string) {
+	w.MetaProperty("", pkg.MediaNarrator, name)
+}
+
+// MediaOverlayActiveClass sets the CSS classes reading systems apply to
+// the element being read and to the document while playing. Empty values
+// are not declared.
+func (w *Writer) MediaOverlayActiveClass(activeClass string, playbackActiveClass string) {
+	if activeClass != "" {
+		w.setPackageMeta(pkg.MediaActiveClass, activeClass)
+	}
+	if playbackActiveClass != "" {
+		w.setPackageMeta(pkg.MediaPlaybackActiveClass, playbackActiveClass)
+	}
+}
+
+// resourceByID returns the writer resource with the given manifest ID.
+func (w *Writer) resourceByID(id string) *PublicationResource {
+	for i, res := range w.epub.resources {
+		if res.ID == id {
+			return &w.epub.resources[i]
+		}
+	}
+	return nil
+}
+
+// WrapSentences wraps every sentence of a content document in a span with
+// a generated id, so media overlay timings can reference it. Ids are
+// idPrefix followed by a number, skipping ids already used in the
+// document, and are returned in document order. Sentences end after ".",
+// "!", "?" or "…" followed by white space; inline elements are never split.
+func (w *Writer) WrapSentences(contentID string, idPrefix string) (ids []string, err error) {
+	content := w.resourceByID(contentID)
+	if content == nil || content.MIMEType != pkg.MediaTypeXHTML {
+		return nil, fmt.Errorf("content %q not found", contentID)
+	}
+
+	doc, err := parseXHTML(content.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	body := FindNode(doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "body"
+	})
+	if body == nil {
+		return nil, fmt.Errorf("content %q has no body", contentID)
+	}
+
+	used := map[string]bool{}
+	FindNode(doc, func(n *html.Node) bool {
+		if id := getAttribute(n, "id"); id != "" {
+			used[id] = true
+		}
+		return false
+	})
+
+	next := 0
+	newSpan := func() *html.Node {
+		id := ""
+		for id == "" || used[id] {
+			next++
+			id = idPrefix + strconv.Itoa(next)
+		}
+		used[id] = true
+		ids = append(ids, id)
+		return &html.Node{Type: html.ElementNode, Data: "span", Attr: []html.Attribute{{Key: "id", Val: id}}}
+	}
+	wrapSentenceBlock(body, newSpan)
+
+	rendered, err := renderXHTML(doc)
+	if err != nil {
+		return nil, err
+	}
+	w.replaceResourceContent(contentID, rendered)
+	return ids, nil
+}
+
+var sentenceInlineElements = []string{
+	"a", "abbr", "b", "bdi", "bdo", "br", "cite", "code", "data", "del", "dfn",
+	"em", "i", "img", "ins", "kbd", "mark", "math", "q", "ruby", "s", "samp",
+	"small", "span", "strong", "sub", "sup", "svg", "time", "u", "var", "wbr",
+}
+
+var sentenceSkippedElements = []string{"script", "style", "template", "noscript"}
+
+// wrapSentenceBlock wraps the sentences formed by the text and inline
+// children of block, and recurses into its block children.
+func wrapSentenceBlock(block *html.Node, newSpan func() *html.Node) {
+	var span *html.Node
+	for c := block.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		switch {
+		case c.Type == html.TextNode:
+			for rest := c.Data; rest != ""; {
+				trimmed := strings.TrimLeftFunc(rest, unicode.IsSpace)
+				if lead := rest[:len(rest)-len(trimmed)]; lead != "" {
+					text := &html.Node{Type: html.TextNode, Data: lead}
+					if span != nil {
+						span.AppendChild(text)
+					} else {
+						block.InsertBefore(text, c)
+					}
+					rest = trimmed
+					continue
+				}
+
+				end, ended := sentenceEnd(rest)
+				if span == nil {
+					span = newSpan()
+					block.InsertBefore(span, c)
+				}
+				span.AppendChild(&html.Node{Type: html.TextNode, Data: rest[:end]})
+				if ended {
+					span = nil
+				}
+				rest = rest[end:]
+			}
+			block.RemoveChild(c)
+
+		case c.Type == html.ElementNode && slices.Contains(sentenceInlineElements, c.Data):
+			if span == nil {
+				if strings.TrimSpace(GetTextContent(c)) == "" {
+					break
+				}
+				span = newSpan()
+				block.InsertBefore(span, c)
+			}
+			block.RemoveChild(c)
+			span.AppendChild(c)
+
+		case c.Type == html.ElementNode && !slices.Contains(sentenceSkippedElements, c.Data):
+			span = nil
+			wrapSentenceBlock(c, newSpan)
+
+		default:
+			span = nil
+		}
+
+		c = next
+	}
+}
+
+// sentenceEnd returns the end of the first sentence of text, and whether
+// the sentence is terminated within text.
+func sentenceEnd(text string) (end int, ended bool) {
+	for i, r := range text {
+		if !strings.ContainsRune(".!?…", r) {
+			continue
+		}
+
+		end = i + utf8.RuneLen(r)
+		for end < len(text) {
+			next, size := utf8.DecodeRuneInString(text[end:])
+			if !strings.ContainsRune(".!?…\"'”’)]", next) {
+				break
+			}
+			end += size
+		}
+
+		if end == len(text) {
+			return end, true
+		}
+		if next, _ := utf8.DecodeRuneInString(text[end:]); unicode.IsSpace(next) {
+			return end, true
+		}
+	}
+	return len(text), false
+}
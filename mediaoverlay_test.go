@@ -0,0 +1,157 @@
+package epub
+
+import (
+	"maps"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReader_MediaOverlay(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	opf := files["OEBPS/content.opf"]
+	opf = strings.Replace(opf, "</metadata>", `<meta property="media:duration">0:00:05</meta>
+    <meta property="media:duration" refines="#chapter-1-overlay">0:00:03.500</meta>
+    <meta property="media:narrator">Joe Reader</meta>
+    <meta property="media:active-class">-epub-media-overlay-active</meta>
+  </metadata>`, 1)
+	opf = strings.Replace(opf, `<item id="chapter-1" href="text/chapter-1.xhtml" media-type="application/xhtml+xml"/>`,
+		`<item id="chapter-1" href="text/chapter-1.xhtml" media-type="application/xhtml+xml" media-overlay="chapter-1-overlay"/>
+    <item id="chapter-1-overlay" href="smil/chapter-1.smil" media-type="application/smil+xml"/>
+    <item id="chapter-1-audio" href="audio/chapter%201.mp3" media-type="audio/mpeg"/>`, 1)
+	files["OEBPS/content.opf"] = opf
+	files["OEBPS/audio/chapter 1.mp3"] = "ID3"
+	files["OEBPS/smil/chapter-1.smil"] = `<smil xmlns="http://www.w3.org/ns/SMIL" xmlns:epub="http://www.idpf.org/2007/ops" version="3.0">
+  <body epub:textref="../text/chapter-1.xhtml">
+    <par id="p1"><text src="../text/chapter-1.xhtml#s1"/><audio src="../audio/chapter%201.mp3" clipBegin="0:00:00.000" clipEnd="0:00:01.500"/></par>
+    <seq id="aside" epub:type="aside" epub:textref="../text/chapter-1.xhtml#aside">
+      <par id="p2"><text src="../text/chapter-1.xhtml#s1-1"/><audio src="../audio/chapter%201.mp3" clipBegin="1.5s" clipEnd="3500ms"/></par>
+    </seq>
+  </body>
+</smil>`
+
+	r := newTestReader(t, files)
+
+	metadata := r.MediaOverlayMetadata()
+	if metadata.Duration != 5*time.Second || len(metadata.Narrators) != 1 || metadata.Narrators[0] != "Joe Reader" || metadata.ActiveClass != "-epub-media-overlay-active" {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+
+	overlay, err := r.MediaOverlay("chapter-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if overlay.ResourceID != "chapter-1-overlay" || overlay.Duration != 3500*time.Millisecond {
+		t.Errorf("unexpected overlay %+v", overlay)
+	}
+
+	if text := overlay.Body.Text; text == nil || text.ResourceID != "chapter-1" {
+		t.Errorf("unexpected body textref %+v", text)
+	}
+
+	pars := overlay.Pars()
+	if len(pars) != 2 {
+		t.Fatalf("expected two pars, got %+v", pars)
+	}
+
+	first := pars[0]
+	if first.Text.Path != "OEBPS/text/chapter-1.xhtml" || first.Text.Fragment != "s1" || first.Text.ResourceID != "chapter-1" {
+		t.Errorf("unexpected text %+v", first.Text)
+	}
+	if first.Audio.ResourceID != "chapter-1-audio" || first.Audio.Begin != 0 || first.Audio.End != 1500*time.Millisecond {
+		t.Errorf("unexpected audio %+v", first.Audio)
+	}
+
+	aside := overlay.Body.Items[1]
+	if aside.Par || aside.Type != "aside" || aside.Text.Fragment != "aside" || pars[1].Audio.End != 3500*time.Millisecond {
+		t.Errorf("unexpected aside %+v", aside)
+	}
+
+	if _, err := r.MediaOverlay("chapter-2"); err == nil {
+		t.Errorf("expected error for an item without media overlay")
+	}
+	if _, err := r.MediaOverlay("missing"); err == nil {
+		t.Errorf("expected error for a missing item")
+	}
+}
+
+func TestWriter_MediaOverlay(t *testing.T) {
+	w := New("urn:uuid:read-along")
+	w.Title("Read Along")
+	w.Languages("en")
+	w.Cover([]byte("\x89PNG\r\n\x1a\n"))
+	w.AddContent("text/story.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Story</title></head><body>
+<h1>The Fox</h1>
+<p>A <em>quick</em> fox jumped. Did it land? “Yes!” it said.</p>
+</body></html>`))
+	w.AddAudio("story.mp3", []byte("ID3"))
+	w.MediaOverlayNarrator("Joe Reader")
+	w.MediaOverlayActiveClass("-epub-media-overlay-active", "")
+
+	ids, err := w.WrapSentences("story.xhtml", "s")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(ids, ",") != "s1,s2,s3,s4,s5" {
+		t.Fatalf("unexpected sentence ids %v", ids)
+	}
+
+	story := string(w.resourceByID("story.xhtml").Content)
+	for _, span := range []string{
+		`<span id="s1">The Fox</span>`,
+		`<span id="s2">A <em>quick</em> fox jumped.</span>`,
+		`<span id="s3">Did it land?</span>`,
+		`<span id="s4">“Yes!”</span>`,
+		`<span id="s5">it said.</span>`,
+	} {
+		if !strings.Contains(story, span) {
+			t.Errorf("expected %s in %s", span, story)
+		}
+	}
+
+	csv := "text,audio,begin,end\ns1,story.mp3,0s,1.2s\ns2, story.mp3, 1.2s, 0:00:03.000\n"
+	timings, err := ParseMediaOverlayTimings(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(timings) != 2 || timings[1].Audio != "story.mp3" || timings[1].ClipEnd != 3*time.Second {
+		t.Fatalf("unexpected timings %+v", timings)
+	}
+
+	if err := w.MediaOverlay("story.xhtml", []MediaOverlayTiming{{TextID: "missing", Audio: "story.mp3", ClipEnd: time.Second}}); err == nil {
+		t.Errorf("expected error for a missing element")
+	}
+	if err := w.MediaOverlay("story.xhtml", timings[:1]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.MediaOverlay("story.xhtml", timings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.TableOfContents("toc", TOC{Items: []TOC{{Title: "Story", Href: "text/story.xhtml"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+
+	metadata := r.MediaOverlayMetadata()
+	if metadata.Duration != 3*time.Second || metadata.ActiveClass != "-epub-media-overlay-active" || len(metadata.Narrators) != 1 {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+
+	overlay, err := r.MediaOverlay("story.xhtml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if overlay.Path != "epub/text/story.smil" || overlay.Duration != 3*time.Second {
+		t.Errorf("unexpected overlay %+v", overlay)
+	}
+
+	pars := overlay.Pars()
+	if len(pars) != 2 || pars[1].Text.Fragment != "s2" || pars[1].Text.ResourceID != "story.xhtml" {
+		t.Fatalf("unexpected pars %+v", pars)
+	}
+	if audio := pars[1].Audio; audio.ResourceID != "story.mp3" || audio.Begin != 1200*time.Millisecond || audio.End != 3*time.Second {
+		t.Errorf("unexpected audio %+v", audio)
+	}
+}
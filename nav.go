@@ -0,0 +1,226 @@
+package epub
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+)
+
+// Navigation is a nav element of the EPUB 3 navigation document, such as
+// the toc, landmarks, page-list, loi, lot, loa, lov or a custom list.
+type Navigation struct {
+	// Type is the epub:type of the nav element.
+	Type   string           `json:"type"`
+	ID     string           `json:"id,omitempty"`
+	Title  string           `json:"title,omitempty"`
+	Hidden bool             `json:"hidden,omitempty"`
+	Items  []NavigationItem `json:"items,omitempty"`
+}
+
+// NavigationItem is an entry of a navigation list. Href is relative to the
+// package document, like PublicationResource.Href.
+type NavigationItem struct {
+	Title string `json:"title"`
+	Href  string `json:"href,omitempty"`
+
+	// Type is the epub:type of the entry link, e.g. "bodymatter" in the
+	// landmarks nav.
+	Type  string           `json:"type,omitempty"`
+	Items []NavigationItem `json:"items,omitempty"`
+}
+
+// Landmark points at a major structural part of the publication.
+type Landmark struct {
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+	Href  string `json:"href"`
+}
+
+// Navigations returns every nav element of the navigation document in
+// document order. It returns nil for publications without one.
+func (r *Reader) Navigations() (navigations []Navigation) {
+	navRes := r.navigationResource()
+	if navRes == nil {
+		return
+	}
+
+	doc := r.ReadContentHTMLById(navRes.ID)
+	if doc == nil {
+		return
+	}
+
+	navDir := path.Dir(navRes.Filepath)
+	packageDir := path.Dir(r.CurrentSelectedPackagePath())
+
+	for node := range doc.Descendants() {
+		if node.Type != html.ElementNode || node.Data != "nav" {
+			continue
+		}
+
+		navigation := Navigation{
+			Type: getAttribute(node, "epub:type"),
+			ID:   getAttribute(node, "id"),
+		}
+		navigation.Hidden = slices.ContainsFunc(node.Attr, func(attr html.Attribute) bool {
+			return attr.Key == "hidden"
+		})
+
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+
+			if c.Data == "ol" || c.Data == "ul" {
+				navigation.Items = parseNavigationList(c, navDir, packageDir)
+				break
+			}
+
+			if navigation.Title == "" {
+				navigation.Title = strings.TrimSpace(GetTextContent(c))
+			}
+		}
+
+		navigations = append(navigations, navigation)
+	}
+	return
+}
+
+// Navigation returns the first nav element whose epub:type contains the
+// given value, or nil when there is none.
+func (r *Reader) Navigation(epubType string) *Navigation {
+	for _, navigation := range r.Navigations() {
+		if slices.Contains(strings.Fields(navigation.Type), epubType) {
+			return &navigation
+		}
+	}
+	return nil
+}
+
+// Landmarks returns the landmarks of the publication. The landmarks nav of
+// the navigation document is used when present; otherwise the EPUB 2 guide
+// references are mapped onto the same vocabulary, e.g. "text" becomes
+// "bodymatter".
+func (r *Reader) Landmarks() (landmarks []Landmark) {
+	if navigation := r.Navigation("landmarks"); navigation != nil {
+		var collect func(items []NavigationItem)
+		collect = func(items []NavigationItem) {
+			for _, item := range items {
+				if item.Href != "" {
+					landmarks = append(landmarks, Landmark{
+						Type:  item.Type,
+						Title: item.Title,
+						Href:  item.Href,
+					})
+				}
+				collect(item.Items)
+			}
+		}
+		collect(navigation.Items)
+		return
+	}
+
+	packagePub := r.CurrentSelectedPackage()
+	if packagePub == nil || packagePub.Guide == nil {
+		return
+	}
+
+	for _, ref := range packagePub.Guide.References {
+		landmarks = append(landmarks, Landmark{
+			Type:  landmarkTypeForGuide(ref.Type),
+			Title: ref.Title,
+			Href:  ref.Href,
+		})
+	}
+	return
+}
+
+// Landmark returns the first landmark of the given type, or nil.
+func (r *Reader) Landmark(epubType string) *Landmark {
+	for _, landmark := range r.Landmarks() {
+		if slices.Contains(strings.Fields(landmark.Type), epubType) {
+			return &landmark
+		}
+	}
+	return nil
+}
+
+func landmarkTypeForGuide(guideType pkg.GuideReferenceType) string {
+	if epubType, ok := guideLandmarkTypes[guideType]; ok {
+		return epubType
+	}
+	return strings.TrimPrefix(string(guideType), "other.")
+}
+
+func parseNavigationList(listNode *html.Node, navDir string, packageDir string) (items []NavigationItem) {
+	for li := listNode.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.Data != "li" {
+			continue
+		}
+
+		item := NavigationItem{}
+		labelFound := false
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+
+			switch {
+			case (c.Data == "a" || c.Data == "span") && !labelFound:
+				labelFound = true
+				item.Title = strings.TrimSpace(GetTextContent(c))
+				item.Type = getAttribute(c, "epub:type")
+				if href := getAttribute(c, "href"); href != "" {
+					item.Href = rebaseHref(href, navDir, packageDir)
+				}
+
+			case c.Data == "ol" || c.Data == "ul":
+				item.Items = parseNavigationList(c, navDir, packageDir)
+			}
+		}
+
+		items = append(items, item)
+	}
+	return
+}
+
+// replaceNav replaces the nav element of the given epub:type in the
+// navigation document of the selected rendition, when there is one. build
+// receives the directory of the navigation document and returns the new
+// nav element, or nil to only remove the existing one.
+func (w *Writer) replaceNav(epubType string, build func(navDir string) *html.Node) (err error) {
+	for _, res := range w.epub.resources {
+		if !hasManifestProperty(res.Properties, pkg.NavProperty) {
+			continue
+		}
+
+		doc, err := parseXHTML(res.Content)
+		if err != nil {
+			return err
+		}
+
+		if existing := findNavByType(doc, epubType); existing != nil {
+			existing.Parent.RemoveChild(existing)
+		}
+
+		if nav := build(path.Dir(res.Filepath)); nav != nil {
+			body := FindNode(doc, func(n *html.Node) bool {
+				return n.Type == html.ElementNode && n.Data == "body"
+			})
+			if body == nil {
+				return fmt.Errorf("navigation document %s has no body", res.Filepath)
+			}
+			body.AppendChild(nav)
+		}
+
+		content, err := renderXHTML(doc)
+		if err != nil {
+			return err
+		}
+		w.replaceResourceContent(res.ID, content)
+	}
+	return
+}
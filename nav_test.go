@@ -0,0 +1,56 @@
+package epub
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReader_Navigations(t *testing.T) {
+	r := newTestReader(t, testEPUB3Files)
+
+	navigations := r.Navigations()
+	types := []string{}
+	for _, navigation := range navigations {
+		types = append(types, navigation.Type)
+	}
+	if !reflect.DeepEqual(types, []string{"toc", "page-list", "landmarks"}) {
+		t.Fatalf("unexpected nav types %v", types)
+	}
+
+	toc := r.Navigation("toc")
+	if toc == nil || toc.Title != "Contents" || toc.ID != "toc" {
+		t.Fatalf("unexpected toc nav %+v", toc)
+	}
+	if href := toc.Items[0].Items[0].Items[0].Href; href != "text/chapter-1.xhtml#s1-1" {
+		t.Errorf("expected href relative to the package document, got %q", href)
+	}
+
+	if pageList := r.Navigation("page-list"); pageList == nil || !pageList.Hidden || len(pageList.Items) != 2 {
+		t.Errorf("unexpected page-list nav %+v", pageList)
+	}
+
+	if r.Navigation("loi") != nil {
+		t.Errorf("expected no loi nav")
+	}
+
+	expected := []Landmark{
+		{Type: "bodymatter", Title: "Start", Href: "text/chapter-1.xhtml"},
+		{Type: "cover", Title: "Cover", Href: "text/chapter-1.xhtml#cover"},
+	}
+	if landmarks := r.Landmarks(); !reflect.DeepEqual(landmarks, expected) {
+		t.Errorf("expected landmarks %+v, got %+v", expected, landmarks)
+	}
+}
+
+func TestReader_LandmarksFromGuide(t *testing.T) {
+	r := newTestReader(t, testEPUB2Files)
+
+	if r.Navigations() != nil {
+		t.Errorf("expected no navigation document")
+	}
+
+	bodymatter := r.Landmark("bodymatter")
+	if bodymatter == nil || bodymatter.Href != "text/chapter-1.xhtml" || bodymatter.Title != "Start" {
+		t.Errorf("unexpected bodymatter landmark %+v", bodymatter)
+	}
+}
@@ -70,24 +70,28 @@ type PageList struct {
 
 // PageTarget represents a page target in the pageList
 type PageTarget struct {
-	ID       string   `xml:"id,attr"`
-	Type     string   `xml:"type,attr,omitempty"`
-	Value    string   `xml:"value,attr"`
-	NavLabel NavLabel `xml:"navLabel"`
-	Content  Content  `xml:"content"`
+	ID        string   `xml:"id,attr"`
+	Type      string   `xml:"type,attr,omitempty"`
+	Value     string   `xml:"value,attr"`
+	PlayOrder string   `xml:"playOrder,attr,omitempty"`
+	NavLabel  NavLabel `xml:"navLabel"`
+	Content   Content  `xml:"content"`
 }
 
 // NavList represents additional navigation lists (illustrations, tables, etc.)
 type NavList struct {
+	ID         string      `xml:"id,attr,omitempty"`
+	Class      string      `xml:"class,attr,omitempty"`
 	NavLabel   NavLabel    `xml:"navLabel"`
 	NavTargets []NavTarget `xml:"navTarget"`
 }
 
 // NavTarget represents a target in a navList
 type NavTarget struct {
-	ID       string   `xml:"id,attr"`
-	NavLabel NavLabel `xml:"navLabel"`
-	Content  Content  `xml:"content"`
+	ID        string   `xml:"id,attr"`
+	PlayOrder string   `xml:"playOrder,attr,omitempty"`
+	NavLabel  NavLabel `xml:"navLabel"`
+	Content   Content  `xml:"content"`
 }
 
 // NCXProcessor provides functionality to work with NCX data
@@ -0,0 +1,311 @@
+package epub
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+)
+
+// Note types of the EPUB Structural Semantics Vocabulary. Rearnote is
+// deprecated in favor of endnote but still found in older publications.
+const (
+	NoteFootnote = "footnote"
+	NoteEndnote  = "endnote"
+	NoteRearnote = "rearnote"
+)
+
+// Note is a footnote, endnote or rearnote together with the reference
+// pointing to it.
+type Note struct {
+	// Type is the epub:type or DPUB-ARIA role of the note. Notes without
+	// semantics are footnotes when they are in the document of their
+	// reference and endnotes otherwise.
+	Type      string        `json:"type"`
+	Reference NoteReference `json:"reference"`
+
+	// Href is the document holding the note, relative to the package
+	// document. Fragment is the id of the note element.
+	Href       string `json:"href"`
+	Fragment   string `json:"fragment,omitempty"`
+	ResourceID string `json:"resourceId,omitempty"`
+
+	// HTML, Text and Markdown are the note content without its backlinks.
+	HTML     string `json:"html"`
+	Text     string `json:"text"`
+	Markdown string `json:"markdown"`
+}
+
+// NoteReference is the place of a content document referencing a note,
+// such as a superscript number.
+type NoteReference struct {
+	Label string `json:"label"`
+
+	// Href is the document holding the reference, relative to the package
+	// document. Fragment is the id of the reference, when it has one.
+	Href       string `json:"href"`
+	Fragment   string `json:"fragment,omitempty"`
+	ResourceID string `json:"resourceId,omitempty"`
+
+	// SpineIndex is the spine position of the referencing document.
+	SpineIndex int `json:"spineIndex"`
+}
+
+// Notes returns the notes referenced from the content documents of the
+// spine, one per reference in reading order. References are noteref
+// links, links to elements typed as notes, and superscript links to
+// another element such as the entry of a notes file. Notes nobody
+// references are left out.
+func (r *Reader) Notes() (notes []Note) {
+	packageDir := path.Dir(r.CurrentSelectedPackagePath())
+	extractor := noteExtractor{
+		packageDir: packageDir,
+		resources:  map[string]PublicationResource{},
+		docs:       map[string]*html.Node{},
+		notes:      map[*html.Node]bool{},
+	}
+	for _, res := range r.epub.resources {
+		if res.MIMEType == pkg.MediaTypeXHTML {
+			extractor.resources[unescapePath(res.Filepath)] = res
+		}
+	}
+
+	for i, itemRef := range r.CurrentSelectedPackage().Spine.ItemRefs {
+		res := r.SelectResourceById(itemRef.IDRef)
+		if res == nil || res.MIMEType != pkg.MediaTypeXHTML {
+			continue
+		}
+		notes = append(notes, extractor.extract(unescapePath(res.Filepath), i)...)
+	}
+	return
+}
+
+// ContentNotes returns the notes referenced from the content document with
+// the given manifest ID, in document order.
+func (r *Reader) ContentNotes(id string) (notes []Note) {
+	for _, note := range r.Notes() {
+		if note.Reference.ResourceID == id {
+			notes = append(notes, note)
+		}
+	}
+	return
+}
+
+// noteExtractor finds notes across the content documents of a package,
+// parsing each document once.
+type noteExtractor struct {
+	packageDir string
+	resources  map[string]PublicationResource
+	docs       map[string]*html.Node
+
+	// notes are the note elements already referenced, whose own links are
+	// backlinks rather than references.
+	notes map[*html.Node]bool
+}
+
+func (e *noteExtractor) doc(containerPath string) *html.Node {
+	if doc, found := e.docs[containerPath]; found {
+		return doc
+	}
+
+	var doc *html.Node
+	if res, found := e.resources[containerPath]; found {
+		doc, _ = parseXHTML(res.Content)
+	}
+	e.docs[containerPath] = doc
+	return doc
+}
+
+func (e *noteExtractor) extract(docPath string, spineIndex int) (notes []Note) {
+	doc := e.doc(docPath)
+	if doc == nil {
+		return
+	}
+
+	var anchors []*html.Node
+	for n := range doc.Descendants() {
+		if n.Type == html.ElementNode && n.Data == "a" && strings.Contains(getAttribute(n, "href"), "#") {
+			anchors = append(anchors, n)
+		}
+	}
+
+	for _, anchor := range anchors {
+		if isBacklink(anchor) || e.insideNote(anchor) {
+			continue
+		}
+
+		targetPath, fragment := resolveHref(path.Dir(docPath), unescapePath(getAttribute(anchor, "href")))
+		if targetPath == "" {
+			targetPath = docPath
+		}
+		targetDoc := e.doc(targetPath)
+		if targetDoc == nil || fragment == "" {
+			continue
+		}
+		target := findElementByID(targetDoc, fragment)
+		if target == nil {
+			continue
+		}
+
+		element := noteElement(target)
+		noteType := noteTypeOf(element)
+		if noteType == "" && !isNoteref(anchor) {
+			continue
+		}
+		if noteType == "" {
+			noteType = NoteEndnote
+			if targetPath == docPath {
+				noteType = NoteFootnote
+			}
+		}
+		e.notes[element] = true
+
+		reference := NoteReference{
+			Label:      normalizeSpace(GetTextContent(anchor)),
+			Href:       relativeHref(e.packageDir, docPath, ""),
+			Fragment:   referenceID(anchor),
+			ResourceID: e.resources[docPath].ID,
+			SpineIndex: spineIndex,
+		}
+		notes = append(notes, e.note(element, noteType, targetPath, reference))
+	}
+	return
+}
+
+func (e *noteExtractor) note(element *html.Node, noteType string, notePath string, reference NoteReference) (note Note) {
+	note = Note{
+		Type:       noteType,
+		Reference:  reference,
+		Href:       relativeHref(e.packageDir, notePath, ""),
+		Fragment:   getAttribute(element, "id"),
+		ResourceID: e.resources[notePath].ID,
+	}
+
+	removeBacklinks(element, reference.Fragment)
+
+	var buf bytes.Buffer
+	for c := element.FirstChild; c != nil; c = c.NextSibling {
+		html.Render(&buf, c)
+	}
+	note.HTML = strings.TrimSpace(buf.String())
+	note.Text = normalizeSpace(GetTextContent(element))
+	if md, err := htmltomarkdown.ConvertString(note.HTML); err == nil {
+		note.Markdown = strings.TrimSpace(md)
+	}
+	return
+}
+
+// insideNote reports whether anchor is a link of a note, such as its
+// backlink, rather than a reference. Notes may still reference other
+// notes with noteref links.
+func (e *noteExtractor) insideNote(anchor *html.Node) bool {
+	if hasEpubType(anchor, "noteref") || hasRole(anchor, "doc-noteref") {
+		return false
+	}
+	for p := anchor.Parent; p != nil; p = p.Parent {
+		if e.notes[p] || noteTypeOf(p) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// noteTypeOf returns the note type declared by n or by the notes section
+// holding it, or an empty string.
+func noteTypeOf(n *html.Node) string {
+	if n.Type != html.ElementNode {
+		return ""
+	}
+
+	for _, noteType := range []string{NoteFootnote, NoteEndnote, NoteRearnote} {
+		if hasEpubType(n, noteType) || hasRole(n, "doc-"+noteType) {
+			return noteType
+		}
+	}
+
+	if n.Data == "li" {
+		for p := n.Parent; p != nil; p = p.Parent {
+			if hasEpubType(p, "endnotes") || hasEpubType(p, "rearnotes") || hasRole(p, "doc-endnotes") {
+				return NoteEndnote
+			}
+		}
+	}
+	return ""
+}
+
+// isNoteref reports whether anchor is marked as a note reference, or
+// presented as one with a superscript.
+func isNoteref(anchor *html.Node) bool {
+	if hasEpubType(anchor, "noteref") || hasRole(anchor, "doc-noteref") {
+		return true
+	}
+	if anchor.Parent != nil && anchor.Parent.Type == html.ElementNode && anchor.Parent.Data == "sup" {
+		return true
+	}
+	return FindNode(anchor, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "sup"
+	}) != nil
+}
+
+func isBacklink(anchor *html.Node) bool {
+	return hasEpubType(anchor, "backlink") || hasRole(anchor, "doc-backlink")
+}
+
+// isNoteBacklink reports whether n is a link of a note back to the
+// reference with the given id.
+func isNoteBacklink(n *html.Node, referenceID string) bool {
+	if n.Type != html.ElementNode || n.Data != "a" {
+		return false
+	}
+	if isBacklink(n) {
+		return true
+	}
+	_, fragment, _ := strings.Cut(getAttribute(n, "href"), "#")
+	return referenceID != "" && fragment == referenceID
+}
+
+// removeBacklinks detaches the backlinks below n.
+func removeBacklinks(n *html.Node, referenceID string) {
+	var backlinks []*html.Node
+	for desc := range n.Descendants() {
+		if isNoteBacklink(desc, referenceID) {
+			backlinks = append(backlinks, desc)
+		}
+	}
+	for _, backlink := range backlinks {
+		if backlink.Parent != nil {
+			backlink.Parent.RemoveChild(backlink)
+		}
+	}
+}
+
+// noteElement returns the element holding the note content for a link
+// target: the target itself unless it is an inline anchor, such as the
+// number of a note paragraph, in which case its enclosing block.
+func noteElement(target *html.Node) *html.Node {
+	element := target
+	for element.Parent != nil && element.Parent.Type == html.ElementNode && noteTypeOf(element) == "" {
+		switch element.Data {
+		case "a", "span", "sup", "sub", "b", "strong", "i", "em", "small":
+			element = element.Parent
+			continue
+		}
+		break
+	}
+	return element
+}
+
+// referenceID returns the id of a reference link, or of the superscript
+// wrapping it.
+func referenceID(anchor *html.Node) string {
+	if id := getAttribute(anchor, "id"); id != "" {
+		return id
+	}
+	if anchor.Parent != nil && anchor.Parent.Type == html.ElementNode && anchor.Parent.Data == "sup" {
+		return getAttribute(anchor.Parent, "id")
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package epub
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestReader_Notes(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/content.opf"] = strings.Replace(files["OEBPS/content.opf"], "</manifest>", `<item id="notes" href="text/notes.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>`, 1)
+	files["OEBPS/text/chapter-1.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><head><title>Chapter 1</title></head>
+<body>
+<p>Semantic<a epub:type="noteref" id="r1" href="#fn1">1</a> and plain<sup><a id="r2" href="notes.xhtml#n2">2</a></sup> notes, a <a href="#p1">link</a>.</p>
+<p id="p1">Target.</p>
+<aside epub:type="footnote" id="fn1"><p><a epub:type="backlink" href="#r1">↩</a> A <em>semantic</em> footnote.</p></aside>
+</body></html>`
+	files["OEBPS/text/chapter-2.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><head><title>Chapter 2</title></head>
+<body>
+<p>See<a role="doc-noteref" href="#en1">i</a>.</p>
+<section epub:type="endnotes"><ol><li id="en1"><p>An endnote.</p></li></ol></section>
+</body></html>`
+	files["OEBPS/text/notes.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Notes</title></head>
+<body><p class="note"><a id="n2" href="chapter-1.xhtml#r2"><sup>2</sup></a> A plain note.</p></body></html>`
+
+	r := newTestReader(t, files)
+
+	notes := r.Notes()
+	if len(notes) != 3 {
+		t.Fatalf("expected three notes, got %+v", notes)
+	}
+
+	semantic := notes[0]
+	if semantic.Type != NoteFootnote || semantic.Fragment != "fn1" || semantic.Href != "text/chapter-1.xhtml" || semantic.ResourceID != "chapter-1" {
+		t.Errorf("unexpected note %+v", semantic)
+	}
+	if ref := semantic.Reference; ref.Label != "1" || ref.Fragment != "r1" || ref.Href != "text/chapter-1.xhtml" || ref.ResourceID != "chapter-1" || ref.SpineIndex != 0 {
+		t.Errorf("unexpected reference %+v", ref)
+	}
+	if semantic.Text != "A semantic footnote." || semantic.HTML != "<p> A <em>semantic</em> footnote.</p>" || semantic.Markdown != "A *semantic* footnote." {
+		t.Errorf("unexpected content %q, %q, %q", semantic.Text, semantic.HTML, semantic.Markdown)
+	}
+
+	plain := notes[1]
+	if plain.Type != NoteEndnote || plain.ResourceID != "notes" || plain.Reference.Fragment != "r2" || plain.Reference.Label != "2" || plain.Text != "A plain note." {
+		t.Errorf("unexpected plain note %+v", plain)
+	}
+
+	endnote := notes[2]
+	if endnote.Type != NoteEndnote || endnote.Fragment != "en1" || endnote.Reference.SpineIndex != 1 || endnote.Text != "An endnote." {
+		t.Errorf("unexpected endnote %+v", endnote)
+	}
+
+	if notes := r.ContentNotes("chapter-2"); len(notes) != 1 || notes[0].Fragment != "en1" {
+		t.Errorf("unexpected chapter notes %+v", notes)
+	}
+}
@@ -23,6 +23,12 @@ func (z *OCFZipContainer) AddFile(filePath string, content []byte) {
 	z.files[filePath] = content
 }
 
+// RemoveFile drops a file from the container, e.g. when a resource is
+// renamed.
+func (z *OCFZipContainer) RemoveFile(filePath string) {
+	delete(z.files, filePath)
+}
+
 func (z *OCFZipContainer) AddMimeType() {
 	z.AddFile("mimetype", []byte(MimeType))
 }
@@ -40,7 +46,6 @@ func (z *OCFZipContainer) AddPackage(filename string, packageData pkg.Package) (
 
 func (z *OCFZipContainer) AddContainerXML(rootFiles ...string) (err error) {
 	container := Container{Version: "1.0"}
-	container.XMLName.Space = "urn:oasis:names:tc:opendocument:xmlns:container"
 	for _, rootFile := range rootFiles {
 		container.RootFiles.RootFile = append(container.RootFiles.RootFile, RootFile{
 			FullPath:  rootFile,
@@ -48,6 +53,13 @@ func (z *OCFZipContainer) AddContainerXML(rootFiles ...string) (err error) {
 		})
 	}
 
+	return z.AddContainer(container)
+}
+
+// AddContainer writes META-INF/container.xml from a full container
+// description, including rendition selection attributes and links.
+func (z *OCFZipContainer) AddContainer(container Container) (err error) {
+	container.XMLName.Space = "urn:oasis:names:tc:opendocument:xmlns:container"
 	content, err := xml.MarshalIndent(container, "", "  ")
 	if err != nil {
 		return
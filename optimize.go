@@ -0,0 +1,366 @@
+package epub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/image/draw"
+)
+
+// ImageOptions configures the image optimization pass.
+type ImageOptions struct {
+	// MaxPixels is the largest width × height kept; larger images are
+	// downscaled keeping their aspect ratio. Zero keeps every size.
+	MaxPixels int
+
+	// Quality is the quality of re-encoded JPEG images, from 1 to 100,
+	// jpeg.DefaultQuality when zero.
+	Quality int
+
+	// PNGToJPEG re-encodes PNG images without transparency as JPEG,
+	// renaming them and updating the references to them.
+	PNGToJPEG bool
+
+	// Grayscale converts images to grayscale, for e-ink readers.
+	Grayscale bool
+
+	// StripMetadata drops EXIF, XMP, IPTC and comments from JPEG images
+	// and text and time chunks from PNG images, without re-encoding them.
+	// Re-encoded images never carry metadata.
+	StripMetadata bool
+}
+
+// OptimizedImage reports the optimization of one image resource.
+type OptimizedImage struct {
+	ID string `json:"id"`
+
+	// Href and MediaType differ from OriginalHref and OriginalMediaType
+	// when the image changed format.
+	Href              string `json:"href"`
+	OriginalHref      string `json:"originalHref"`
+	MediaType         string `json:"mediaType"`
+	OriginalMediaType string `json:"originalMediaType"`
+
+	Size         int `json:"size"`
+	OriginalSize int `json:"originalSize"`
+}
+
+// OptimizeImages runs the image optimization pass over an opened
+// publication and returns a Writer holding the result.
+func OptimizeImages(r *Reader, options ImageOptions) (w *Writer, report []OptimizedImage, err error) {
+	w = newWriterFromReader(r)
+	report, err = w.OptimizeImages(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return w, report, nil
+}
+
+// OptimizeImages downscales, converts and strips the JPEG and PNG images
+// of the publication according to options. Images that cannot be decoded
+// are left untouched, as are other formats, and a re-encoded image that is
+// neither downscaled, converted nor turned gray is only kept when smaller.
+// When an image changes format its href, manifest item and the references
+// of content documents, style sheets and the guide are updated. The report
+// lists changed images only.
+func (w *Writer) OptimizeImages(options ImageOptions) (report []OptimizedImage, err error) {
+	for i := range w.epub.resources {
+		res := w.epub.resources[i]
+		if res.MIMEType != pkg.MediaTypeJPEG && res.MIMEType != pkg.MediaTypePNG {
+			continue
+		}
+
+		content, mediaType, err := optimizeImage(res.Content, res.MIMEType, options)
+		if err != nil {
+			return report, fmt.Errorf("optimize %s: %w", res.Href, err)
+		}
+		if content == nil {
+			continue
+		}
+
+		optimized := OptimizedImage{
+			ID:                res.ID,
+			Href:              res.Href,
+			OriginalHref:      res.Href,
+			MediaType:         mediaType,
+			OriginalMediaType: res.MIMEType,
+			Size:              len(content),
+			OriginalSize:      len(res.Content),
+		}
+
+		if mediaType != res.MIMEType {
+			optimized.Href = w.renameImage(i, mediaType)
+		}
+		w.epub.resources[i].MIMEType = mediaType
+		w.epub.resources[i].Content = content
+		w.epub.zipContainer.AddFile(w.epub.resources[i].Filepath, content)
+		report = append(report, optimized)
+	}
+	return
+}
+
+// optimizeImage returns the optimized image and its media type, or nil
+// when the image is left untouched. A re-encoded image is only kept when
+// it is smaller, unless its dimensions, format or colors had to change.
+func optimizeImage(content []byte, mediaType string, options ImageOptions) ([]byte, string, error) {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", nil
+	}
+
+	reencode, required := false, false
+	bounds := img.Bounds()
+	if pixels := bounds.Dx() * bounds.Dy(); options.MaxPixels > 0 && pixels > options.MaxPixels {
+		scale := math.Sqrt(float64(options.MaxPixels) / float64(pixels))
+		img = fitImage(img, int(float64(bounds.Dx())*scale), int(float64(bounds.Dy())*scale))
+		reencode, required = true, true
+	}
+
+	if options.Grayscale && !isGray(img) {
+		img = grayscale(img)
+		reencode, required = true, true
+	}
+
+	targetType := mediaType
+	if options.PNGToJPEG && mediaType == pkg.MediaTypePNG && isOpaque(img) {
+		targetType = pkg.MediaTypeJPEG
+		reencode, required = true, true
+	}
+
+	if reencode {
+		format := ThumbnailPNG
+		if targetType == pkg.MediaTypeJPEG {
+			format = ThumbnailJPEG
+		}
+		encoded, err := encodeImage(img, format, options.Quality, png.BestCompression)
+		if err != nil {
+			return nil, "", err
+		}
+		if required || len(encoded) < len(content) {
+			return encoded, targetType, nil
+		}
+	}
+
+	if !options.StripMetadata {
+		return nil, "", nil
+	}
+
+	var stripped []byte
+	switch mediaType {
+	case pkg.MediaTypeJPEG:
+		stripped = stripJPEGMetadata(content)
+	case pkg.MediaTypePNG:
+		stripped = stripPNGMetadata(content)
+	}
+	if stripped == nil || len(stripped) == len(content) {
+		return nil, "", nil
+	}
+	return stripped, mediaType, nil
+}
+
+// renameImage changes the extension of the image at index i for its new
+// media type and updates everything referencing it. It returns the new
+// href.
+func (w *Writer) renameImage(i int, mediaType string) string {
+	res := w.epub.resources[i]
+	ext := ".png"
+	if mediaType == pkg.MediaTypeJPEG {
+		ext = ".jpg"
+	}
+
+	base := strings.TrimSuffix(res.Href, path.Ext(res.Href))
+	href := base + ext
+	for n := 2; w.resourceByHref(href) != nil; n++ {
+		href = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+	filePath := path.Join(path.Dir(res.Filepath), path.Base(href))
+
+	w.epub.zipContainer.RemoveFile(res.Filepath)
+	w.epub.resources[i].Href = href
+	w.epub.resources[i].Filepath = filePath
+
+	packagePub := w.epub.SelectedPackage()
+	for j, item := range packagePub.Manifest.Items {
+		if item.ID == res.ID {
+			packagePub.Manifest.Items[j].Href = href
+			packagePub.Manifest.Items[j].MediaType = mediaType
+		}
+	}
+	if packagePub.Guide != nil {
+		for j, ref := range packagePub.Guide.References {
+			if ref.Href == res.Href {
+				packagePub.Guide.References[j].Href = href
+			}
+		}
+	}
+	packagePub.Collections = renameCollectionLinks(packagePub.Collections, res.Href, href)
+
+	oldPath, newPath := unescapePath(res.Filepath), unescapePath(filePath)
+	for j, doc := range w.epub.resources {
+		switch doc.MIMEType {
+		case pkg.MediaTypeXHTML, pkg.MediaTypeSVG, pkg.MediaTypeCSS, pkg.MediaTypeSMIL:
+		default:
+			continue
+		}
+
+		docDir := path.Dir(unescapePath(doc.Filepath))
+		content := replaceReference(doc.Content, relativeHref(docDir, oldPath, ""), relativeHref(docDir, newPath, ""))
+		if !bytes.Equal(content, doc.Content) {
+			w.epub.resources[j].Content = content
+			w.epub.zipContainer.AddFile(doc.Filepath, content)
+		}
+	}
+	return href
+}
+
+// replaceReference replaces the quoted, parenthesized or fragment
+// suffixed occurrences of oldHref in content.
+func replaceReference(content []byte, oldHref string, newHref string) []byte {
+	for _, delimiters := range [][2]string{{`"`, `"`}, {`'`, `'`}, {`(`, `)`}, {`"`, `#`}, {`'`, `#`}, {`(`, `#`}} {
+		content = bytes.ReplaceAll(content,
+			[]byte(delimiters[0]+oldHref+delimiters[1]),
+			[]byte(delimiters[0]+newHref+delimiters[1]))
+	}
+	return content
+}
+
+// renameCollectionLinks returns a copy of collections with the links to
+// oldHref pointing at newHref.
+func renameCollectionLinks(collections []pkg.Collection, oldHref string, newHref string) []pkg.Collection {
+	if collections == nil {
+		return nil
+	}
+
+	renamed := make([]pkg.Collection, len(collections))
+	for i, collection := range collections {
+		collection.Links = append([]pkg.Link(nil), collection.Links...)
+		for j, link := range collection.Links {
+			if href, fragment, found := strings.Cut(link.Href, "#"); href == oldHref {
+				collection.Links[j].Href = newHref
+				if found {
+					collection.Links[j].Href += "#" + fragment
+				}
+			}
+		}
+		collection.Collections = renameCollectionLinks(collection.Collections, oldHref, newHref)
+		renamed[i] = collection
+	}
+	return renamed
+}
+
+func isOpaque(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return opaque.Opaque()
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isGray(img image.Image) bool {
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// grayscale converts img to gray levels, keeping transparency.
+func grayscale(img image.Image) image.Image {
+	bounds := img.Bounds()
+	if isOpaque(img) {
+		gray := image.NewGray(bounds)
+		draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+		return gray
+	}
+
+	gray := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			level := color.GrayModel.Convert(color.RGBA{c.R, c.G, c.B, 0xff}).(color.Gray).Y
+			gray.SetNRGBA(x, y, color.NRGBA{level, level, level, c.A})
+		}
+	}
+	return gray
+}
+
+// jpegMetadataMarkers are the JPEG segments dropped when stripping
+// metadata: APP1 (EXIF, XMP), APP12, APP13 (IPTC) and comments. APP0,
+// APP2 (ICC profiles) and APP14 affect decoding and are kept.
+var jpegMetadataMarkers = map[byte]bool{0xe1: true, 0xec: true, 0xed: true, 0xfe: true}
+
+// stripJPEGMetadata removes the metadata segments of a JPEG image, or
+// returns nil when the image is malformed.
+func stripJPEGMetadata(content []byte) []byte {
+	if len(content) < 4 || content[0] != 0xff || content[1] != 0xd8 {
+		return nil
+	}
+
+	stripped := []byte{0xff, 0xd8}
+	for i := 2; i+4 <= len(content); {
+		if content[i] != 0xff {
+			return nil
+		}
+		marker := content[i+1]
+		if marker == 0xda {
+			// Entropy coded data follows the start of scan.
+			return append(stripped, content[i:]...)
+		}
+
+		length := int(binary.BigEndian.Uint16(content[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(content) {
+			return nil
+		}
+		if !jpegMetadataMarkers[marker] {
+			stripped = append(stripped, content[i:end]...)
+		}
+		i = end
+	}
+	return nil
+}
+
+// pngMetadataChunks are the PNG chunks dropped when stripping metadata.
+var pngMetadataChunks = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "tIME": true, "eXIf": true}
+
+// stripPNGMetadata removes the metadata chunks of a PNG image, or returns
+// nil when the image is malformed.
+func stripPNGMetadata(content []byte) []byte {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(content, []byte(signature)) {
+		return nil
+	}
+
+	stripped := []byte(signature)
+	for i := len(signature); i < len(content); {
+		if i+8 > len(content) {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint32(content[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(content) {
+			return nil
+		}
+		if !pngMetadataChunks[string(content[i+4:i+8])] {
+			stripped = append(stripped, content[i:end]...)
+		}
+		i = end
+	}
+	return stripped
+}
@@ -0,0 +1,183 @@
+package epub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+func encodeTestImage(t *testing.T, img image.Image, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriter_OptimizeImages(t *testing.T) {
+	photo := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for y := range 300 {
+		for x := range 400 {
+			photo.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x20, 0xff})
+		}
+	}
+	transparent := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	transparent.Set(1, 1, color.NRGBA{0xff, 0, 0, 0x80})
+
+	w := New("urn:uuid:optimize")
+	w.Title("Images")
+	w.Languages("en")
+	w.Cover(encodeTestImage(t, image.NewRGBA(image.Rect(0, 0, 20, 30)), "png"))
+	w.AddImage("photo.png", encodeTestImage(t, photo, "png"))
+	logoContent := encodeTestImage(t, transparent, "png")
+	w.AddImage("logo.png", logoContent)
+	w.AddContent("text/chapter.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>C</title></head><body><img src="../images/photo.png" alt=""/><img src='../images/logo.png' alt=""/></body></html>`))
+	w.AddResource("styles/main.css", pkg.MediaTypeCSS, []byte(`body { background: url(../images/photo.png); }`))
+	if err := w.TableOfContents("toc", TOC{Items: []TOC{{Title: "Chapter", Href: "text/chapter.xhtml"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report, err := w.OptimizeImages(ImageOptions{MaxPixels: 100 * 75, PNGToJPEG: true, Grayscale: true, Quality: 80})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report) != 3 {
+		t.Fatalf("expected three optimized images, got %+v", report)
+	}
+
+	var optimized, logoReport OptimizedImage
+	for _, item := range report {
+		switch item.ID {
+		case "photo.png":
+			optimized = item
+		case "logo.png":
+			logoReport = item
+		}
+	}
+	// Turning the logo gray is required even though it does not get smaller.
+	if logoReport.Size <= logoReport.OriginalSize || logoReport.MediaType != pkg.MediaTypePNG {
+		t.Errorf("unexpected report %+v", logoReport)
+	}
+	if optimized.Href != "images/photo.jpg" || optimized.OriginalHref != "images/photo.png" || optimized.MediaType != pkg.MediaTypeJPEG || optimized.Size >= optimized.OriginalSize {
+		t.Errorf("unexpected report %+v", optimized)
+	}
+
+	r := writeAndReopen(t, w)
+
+	res := r.SelectResourceById("photo.png")
+	if res == nil || res.Href != "images/photo.jpg" || res.MIMEType != pkg.MediaTypeJPEG {
+		t.Fatalf("unexpected photo resource %+v", res)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(res.Content))
+	if err != nil || format != "jpeg" || config.Width != 100 || config.Height != 75 || config.ColorModel != color.GrayModel {
+		t.Errorf("unexpected photo %s %+v, %v", format, config, err)
+	}
+	if _, found := r.epub.zipContainer.AllFiles()["epub/images/photo.png"]; found {
+		t.Errorf("expected the PNG file to be removed")
+	}
+
+	logo := r.SelectResourceById("logo.png")
+	if logo.MIMEType != pkg.MediaTypePNG || logo.Href != "images/logo.png" {
+		t.Errorf("expected transparent images to stay PNG, got %+v", logo)
+	}
+	if bytes.Equal(logo.Content, logoContent) {
+		t.Errorf("expected the logo to be turned gray")
+	}
+	logoImage, err := png.Decode(bytes.NewReader(logo.Content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r, g, b, a := logoImage.At(1, 1).RGBA(); r != g || g != b || a == 0 {
+		t.Errorf("expected a gray pixel, got %d %d %d %d", r, g, b, a)
+	}
+
+	chapter := string(r.SelectResourceById("chapter.xhtml").Content)
+	if !strings.Contains(chapter, `src="../images/photo.jpg"`) || !strings.Contains(chapter, `src='../images/logo.png'`) {
+		t.Errorf("unexpected references in %s", chapter)
+	}
+	if css := string(r.SelectResourceByHref("styles/main.css").Content); !strings.Contains(css, "url(../images/photo.jpg)") {
+		t.Errorf("unexpected references in %s", css)
+	}
+}
+
+func TestOptimizeImages(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/images/cover.png"] = string(testPNG(t, 20, 30))
+
+	r := newTestReader(t, files)
+	w, report, err := OptimizeImages(&r, ImageOptions{PNGToJPEG: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report) != 1 || report[0].Href != "images/cover.jpg" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if res := r.SelectResourceById("cover"); res.MIMEType != pkg.MediaTypePNG {
+		t.Errorf("expected the opened book to be left untouched, got %+v", res)
+	}
+
+	if res := w.resourceByID("cover"); res.Href != "images/cover.jpg" || res.Filepath != "OEBPS/images/cover.jpg" {
+		t.Errorf("unexpected cover resource %+v", res)
+	}
+	for _, item := range w.epub.SelectedPackage().Manifest.Items {
+		if item.ID == "cover" && (item.Href != "images/cover.jpg" || item.MediaType != pkg.MediaTypeJPEG) {
+			t.Errorf("unexpected manifest item %+v", item)
+		}
+	}
+	if _, found := w.epub.zipContainer.AllFiles()["OEBPS/images/cover.png"]; found {
+		t.Errorf("expected the PNG file to be removed")
+	}
+}
+
+func TestStripImageMetadata(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+	encoded := encodeTestImage(t, img, "jpeg")
+	comment := []byte("\xff\xfe\x00\x08secret")
+	withComment := append(append(append([]byte{}, encoded[:2]...), comment...), encoded[2:]...)
+
+	stripped, mediaType, err := optimizeImage(withComment, pkg.MediaTypeJPEG, ImageOptions{StripMetadata: true})
+	if err != nil || mediaType != pkg.MediaTypeJPEG || !bytes.Equal(stripped, encoded) {
+		t.Errorf("expected the JPEG comment to be stripped, got %d bytes, %v", len(stripped), err)
+	}
+
+	encoded = encodeTestImage(t, img, "png")
+	chunk := pngChunk("tEXt", []byte("Author\x00Jane"))
+	withText := append(append(append([]byte{}, encoded[:33]...), chunk...), encoded[33:]...)
+	if _, err := png.Decode(bytes.NewReader(withText)); err != nil {
+		t.Fatalf("invalid test PNG: %v", err)
+	}
+
+	stripped, _, err = optimizeImage(withText, pkg.MediaTypePNG, ImageOptions{StripMetadata: true})
+	if err != nil || !bytes.Equal(stripped, encoded) {
+		t.Errorf("expected the PNG text chunk to be stripped, got %d bytes, %v", len(stripped), err)
+	}
+
+	if content, _, _ := optimizeImage(encoded, pkg.MediaTypePNG, ImageOptions{StripMetadata: true}); content != nil {
+		t.Errorf("expected images without metadata to be left untouched")
+	}
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
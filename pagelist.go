@@ -0,0 +1,319 @@
+package epub
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+)
+
+// Page maps a print page label to the location where the page starts.
+type Page struct {
+	// Label is the page number as printed, e.g. "12" or "xiv".
+	Label string `json:"label"`
+
+	// Href is the content document path relative to the package document.
+	Href string `json:"href"`
+
+	// Fragment is the id of the page break marker inside the document.
+	Fragment string `json:"fragment,omitempty"`
+
+	// Anchor and Offset locate where the page starts when Writer.PageList
+	// injects a marker: before the element with the Anchor id, or Offset
+	// characters into the text of that element, or of the body when
+	// Anchor is empty. Neither is read from navigation documents.
+	Anchor string `json:"anchor,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+}
+
+// PageList returns the print page mappings of the publication. The EPUB 3
+// navigation document page-list is preferred; the NCX pageList is used
+// when the navigation document has none.
+func (r *Reader) PageList() (pages []Page) {
+	packageDir := path.Dir(r.CurrentSelectedPackagePath())
+
+	if navRes := r.navigationResource(); navRes != nil {
+		doc := r.ReadContentHTMLById(navRes.ID)
+		if navNode := findNavByType(doc, "page-list"); navNode != nil {
+			toc := TOC{reader: r}
+			toc.parseNav(navNode)
+
+			items := rebaseTOCHrefs(toc.Items, path.Dir(navRes.Filepath), packageDir)
+			toc = TOC{Items: items}
+			visitTOC(&toc, func(item *TOC, depth int) {
+				if depth > 0 && item.Href != "" {
+					pages = append(pages, newPage(item.Title, item.Href))
+				}
+			})
+			return
+		}
+	}
+
+	navigation := r.epub.navigationCenterEXtended
+	if navigation == nil || navigation.PageList == nil {
+		return
+	}
+
+	ncxDir := packageDir
+	if ncxRes := r.ncxResource(); ncxRes != nil {
+		ncxDir = path.Dir(ncxRes.Filepath)
+	}
+
+	for _, target := range navigation.PageList.PageTargets {
+		href := target.Content.Src
+		if href != "" && !isRemoteHref(href) {
+			containerPath, fragment := resolveHref(ncxDir, href)
+			href = relativeHref(packageDir, containerPath, fragment)
+		}
+
+		label := strings.TrimSpace(target.NavLabel.Text)
+		if label == "" {
+			label = target.Value
+		}
+		pages = append(pages, newPage(label, href))
+	}
+	return
+}
+
+func newPage(label string, href string) Page {
+	href, fragment, _ := strings.Cut(href, "#")
+	return Page{Label: strings.TrimSpace(label), Href: href, Fragment: fragment}
+}
+
+// pagesToTOC converts pages into TOC items pointing at the page locations.
+func pagesToTOC(pages []Page) (items []TOC) {
+	for _, page := range pages {
+		href := page.Href
+		if page.Fragment != "" {
+			href += "#" + page.Fragment
+		}
+		items = append(items, TOC{Title: page.Label, Href: href})
+	}
+	return
+}
+
+// PageList sets the print page mappings emitted as the navigation document
+// page-list and the NCX pageList. It may be called before or after
+// TableOfContents.
+//
+// When injectMarkers is true, a pagebreak marker is added to the content
+// document of each page with an Anchor or Offset whose fragment is not
+// present yet. Pages without a fragment get one derived from their label,
+// made unique within the document. Other pages are expected to point at
+// existing fragments, or at the start of the document; a fragment that is
+// missing with no position to insert its marker at is an error.
+func (w *Writer) PageList(pages []Page, injectMarkers bool) (err error) {
+	pages = slices.Clone(pages)
+
+	if injectMarkers {
+		if err = w.injectPageBreaks(pages); err != nil {
+			return
+		}
+	}
+
+	w.pageList = pages
+	return w.updatePageList()
+}
+
+// updatePageList rewrites the page lists of navigation documents that were
+// already added to the publication.
+func (w *Writer) updatePageList() (err error) {
+	items := pagesToTOC(w.pageList)
+
+	for _, res := range w.epub.resources {
+		if res.MIMEType == pkg.MediaTypeNCX && w.epub.navigationCenterEXtended != nil {
+			// Regenerate the whole NCX so page targets share playOrder
+			// values with the navMap.
+			previous := w.epub.navigationCenterEXtended
+			uid := ""
+			for _, meta := range previous.Head.Meta {
+				if meta.Name == "dtb:uid" {
+					uid = meta.Content
+				}
+			}
+
+			navigation := tocToNCX(
+				uid,
+				previous.DocTitle.Text,
+				previous.DocAuthor.Text,
+				(&TOC{}).convertNavPointsToTOCItems(previous.NavMap.NavPoints),
+				rebaseTOCHrefs(items, w.contentDir, path.Dir(res.Filepath)),
+				w.readingPosition(path.Dir(res.Filepath)),
+			)
+			w.epub.navigationCenterEXtended = navigation
+
+			content, err := marshalNCX(navigation)
+			if err != nil {
+				return err
+			}
+			w.replaceResourceContent(res.ID, content)
+		}
+	}
+
+	return w.replaceNav("page-list", func(navDir string) *html.Node {
+		if len(items) == 0 {
+			return nil
+		}
+		return createNav("page-list", "page-list", "Pages", true, rebaseTOCHrefs(items, w.contentDir, navDir))
+	})
+}
+
+// injectPageBreaks adds pagebreak markers for pages whose fragment is not
+// present in the target content document, at the position given by their
+// Anchor and Offset.
+func (w *Writer) injectPageBreaks(pages []Page) (err error) {
+	docs := map[string]*html.Node{}
+	order := []string{}
+
+	for i, page := range pages {
+		res := w.resourceByHref(page.Href)
+		if res == nil || res.MIMEType != pkg.MediaTypeXHTML {
+			continue
+		}
+
+		doc, ok := docs[res.ID]
+		if !ok {
+			if doc, err = parseXHTML(res.Content); err != nil {
+				return
+			}
+			docs[res.ID] = doc
+			order = append(order, res.ID)
+		}
+
+		positioned := page.Anchor != "" || page.Offset > 0
+		if page.Fragment != "" && findElementByID(doc, page.Fragment) != nil {
+			continue
+		}
+		if !positioned {
+			if page.Fragment != "" {
+				return fmt.Errorf("page %q: fragment %q not found in %s and no anchor or offset given", page.Label, page.Fragment, page.Href)
+			}
+			continue
+		}
+
+		if page.Fragment == "" {
+			pages[i].Fragment = uniqueElementID(doc, pageBreakID(page.Label))
+		}
+		marker := &html.Node{
+			Type: html.ElementNode,
+			Data: "span",
+			Attr: []html.Attribute{
+				{Key: "id", Val: pages[i].Fragment},
+				{Key: "epub:type", Val: "pagebreak"},
+				{Key: "role", Val: "doc-pagebreak"},
+				{Key: "aria-label", Val: page.Label},
+			},
+		}
+		if err = insertAtPosition(doc, marker, page.Anchor, page.Offset); err != nil {
+			return fmt.Errorf("page %q: %w", page.Label, err)
+		}
+	}
+
+	for _, id := range order {
+		content, err := renderXHTML(docs[id])
+		if err != nil {
+			return err
+		}
+		w.replaceResourceContent(id, content)
+	}
+	return
+}
+
+// insertAtPosition inserts node before the element with the anchor id, or
+// offset characters into the text of that element, or of the body when
+// anchor is empty. Text nodes are split as needed.
+func insertAtPosition(doc *html.Node, node *html.Node, anchor string, offset int) error {
+	var root *html.Node
+	if anchor != "" {
+		root = findElementByID(doc, anchor)
+		if root == nil {
+			return fmt.Errorf("anchor %q not found", anchor)
+		}
+		if offset == 0 {
+			root.Parent.InsertBefore(node, root)
+			return nil
+		}
+	} else {
+		root = FindNode(doc, func(n *html.Node) bool {
+			return n.Type == html.ElementNode && n.Data == "body"
+		})
+		if root == nil {
+			return errors.New("document has no body")
+		}
+	}
+
+	remaining := offset
+	var last *html.Node
+	for text := range root.Descendants() {
+		if text.Type != html.TextNode {
+			continue
+		}
+		last = text
+
+		runes := []rune(text.Data)
+		if remaining >= len(runes) {
+			remaining -= len(runes)
+			continue
+		}
+		if remaining > 0 {
+			before := &html.Node{Type: html.TextNode, Data: string(runes[:remaining])}
+			text.Parent.InsertBefore(before, text)
+			text.Data = string(runes[remaining:])
+		}
+		text.Parent.InsertBefore(node, text)
+		return nil
+	}
+
+	if remaining > 0 {
+		return fmt.Errorf("offset %d is past the end of the text", offset)
+	}
+	if last != nil {
+		last.Parent.InsertBefore(node, last.NextSibling)
+	} else {
+		root.AppendChild(node)
+	}
+	return nil
+}
+
+// resourceByHref returns the writer resource with the given package
+// relative href, ignoring any fragment.
+func (w *Writer) resourceByHref(href string) *PublicationResource {
+	href, _, _ = strings.Cut(href, "#")
+	for i, res := range w.epub.resources {
+		if res.Href == href {
+			return &w.epub.resources[i]
+		}
+	}
+	return nil
+}
+
+// uniqueElementID returns id, or id with a numeric suffix when an element
+// of doc already uses it.
+func uniqueElementID(doc *html.Node, id string) string {
+	unique := id
+	for n := 2; findElementByID(doc, unique) != nil; n++ {
+		unique = fmt.Sprintf("%s-%d", id, n)
+	}
+	return unique
+}
+
+// pageBreakID derives a marker id from a page label.
+func pageBreakID(label string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, strings.TrimSpace(label))
+	return "page-" + id
+}
+
+func findElementByID(doc *html.Node, id string) *html.Node {
+	return FindNode(doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && getAttribute(n, "id") == id
+	})
+}
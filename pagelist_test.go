@@ -0,0 +1,119 @@
+package epub
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReader_PageList(t *testing.T) {
+	t.Run("navigation document", func(t *testing.T) {
+		r := newTestReader(t, testEPUB3Files)
+
+		expected := []Page{
+			{Label: "1", Href: "text/chapter-1.xhtml", Fragment: "p1"},
+			{Label: "2", Href: "text/chapter-2.xhtml", Fragment: "p2"},
+		}
+		if pages := r.PageList(); !reflect.DeepEqual(pages, expected) {
+			t.Errorf("expected %+v, got %+v", expected, pages)
+		}
+	})
+
+	t.Run("ncx", func(t *testing.T) {
+		r := newTestReader(t, testEPUB2Files)
+
+		expected := []Page{{Label: "1", Href: "text/chapter-1.xhtml", Fragment: "page1"}}
+		if pages := r.PageList(); !reflect.DeepEqual(pages, expected) {
+			t.Errorf("expected %+v, got %+v", expected, pages)
+		}
+	})
+}
+
+func TestWriter_PageList(t *testing.T) {
+	newWriter := func() *Writer {
+		w := New("urn:uuid:pages")
+		w.Title("Paged Book")
+		w.Languages("en")
+		w.Cover([]byte("\x89PNG\r\n\x1a\n"))
+		w.AddContent("chapter-1.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>One</title></head><body><p id="existing">One</p></body></html>`))
+		w.AddContent("chapter-2.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Two</title></head><body><p id="para">Two</p><p>Three four</p></body></html>`))
+		return w
+	}
+
+	pages := []Page{
+		{Label: "i", Href: "chapter-1.xhtml", Fragment: "existing"},
+		{Label: "1", Href: "chapter-2.xhtml"},
+		{Label: "2", Href: "chapter-2.xhtml", Anchor: "para"},
+		{Label: "3 a", Href: "chapter-2.xhtml", Offset: 5},
+		{Label: "3.a", Href: "chapter-2.xhtml", Offset: 7},
+	}
+	toc := TOC{Title: "Contents", Items: []TOC{
+		{Title: "One", Href: "chapter-1.xhtml"},
+		{Title: "Two", Href: "chapter-2.xhtml"},
+	}}
+
+	for _, beforeTOC := range []bool{true, false} {
+		w := newWriter()
+		if !beforeTOC {
+			if err := w.TableOfContents("toc", toc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if err := w.PageList(pages, true); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if beforeTOC {
+			if err := w.TableOfContents("toc", toc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		r := writeAndReopen(t, w)
+
+		expected := []Page{
+			{Label: "i", Href: "chapter-1.xhtml", Fragment: "existing"},
+			{Label: "1", Href: "chapter-2.xhtml"},
+			{Label: "2", Href: "chapter-2.xhtml", Fragment: "page-2"},
+			{Label: "3 a", Href: "chapter-2.xhtml", Fragment: "page-3-a"},
+			{Label: "3.a", Href: "chapter-2.xhtml", Fragment: "page-3-a-2"},
+		}
+		if got := r.PageList(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("beforeTOC=%v: expected nav page list %+v, got %+v", beforeTOC, expected, got)
+		}
+
+		pageList := r.NavigationCenterExtended().PageList
+		if pageList == nil || len(pageList.PageTargets) != 5 {
+			t.Fatalf("beforeTOC=%v: expected five NCX page targets, got %+v", beforeTOC, pageList)
+		}
+		if target := pageList.PageTargets[0]; target.Type != "front" || target.PlayOrder != "2" {
+			t.Errorf("beforeTOC=%v: unexpected first page target %+v", beforeTOC, target)
+		}
+
+		content := string(r.SelectResourceById("chapter-2.xhtml").Content)
+		expectedBody := `<body><span id="page-2" epub:type="pagebreak" role="doc-pagebreak" aria-label="2"></span><p id="para">Two</p>` +
+			`<p>Th<span id="page-3-a" epub:type="pagebreak" role="doc-pagebreak" aria-label="3 a"></span>re` +
+			`<span id="page-3-a-2" epub:type="pagebreak" role="doc-pagebreak" aria-label="3.a"></span>e four</p></body>`
+		if !strings.Contains(content, expectedBody) {
+			t.Errorf("beforeTOC=%v: expected page break markers at their positions, got %s", beforeTOC, content)
+		}
+
+		if strings.Contains(string(r.SelectResourceById("chapter-1.xhtml").Content), "pagebreak") {
+			t.Errorf("beforeTOC=%v: expected existing fragment to be reused", beforeTOC)
+		}
+	}
+}
+
+func TestWriter_PageListMissingFragment(t *testing.T) {
+	w := New("urn:uuid:pages")
+	w.AddContent("chapter-1.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>One</title></head><body><p>One</p></body></html>`))
+
+	for _, page := range []Page{
+		{Label: "1", Href: "chapter-1.xhtml", Fragment: "missing"},
+		{Label: "1", Href: "chapter-1.xhtml", Anchor: "missing"},
+		{Label: "1", Href: "chapter-1.xhtml", Offset: 4},
+	} {
+		if err := w.PageList([]Page{page}, true); err == nil {
+			t.Errorf("expected error for %+v", page)
+		}
+	}
+}
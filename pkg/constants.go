@@ -11,6 +11,9 @@ const (
 	PropertySwitch             = "switch"
 	PropertyLayoutPrePaginated = "layout-pre-paginated"
 
+	// Namespaces
+	NamespaceDC = "http://purl.org/dc/elements/1.1/"
+
 	// Media types
 	MediaTypeXHTML = "application/xhtml+xml"
 	MediaTypeSVG   = "image/svg+xml"
@@ -20,6 +23,18 @@ const (
 	MediaTypePNG   = "image/png"
 	MediaTypeCSS   = "text/css"
 	MediaTypeNCX   = "application/x-dtbncx+xml"
+	MediaTypeSMIL  = "application/smil+xml"
+	MediaTypeMP3   = "audio/mpeg"
+	MediaTypeMP4   = "audio/mp4"
+	MediaTypeOgg   = "audio/ogg"
+	MediaTypeTTF   = "font/ttf"
+	MediaTypeOTF   = "font/otf"
+	MediaTypeWOFF  = "font/woff"
+	MediaTypeWOFF2 = "font/woff2"
+	MediaTypeJS    = "application/javascript"
+	MediaTypePLS   = "application/pls+xml"
+
+	MediaTypeSearchKeyMap = "application/vnd.epub.search-key-map+xml"
 
 	// Spine directions
 	SpineDirectionLTR     = "ltr"
@@ -29,6 +44,43 @@ const (
 	// Linear values
 	LinearYes = "yes"
 	LinearNo  = "no"
+
+	// Rendition properties
+	RenditionLayout      = "rendition:layout"
+	RenditionOrientation = "rendition:orientation"
+	RenditionSpread      = "rendition:spread"
+	RenditionViewport    = "rendition:viewport"
+
+	// Rendition layout values
+	LayoutReflowable   = "reflowable"
+	LayoutPrePaginated = "pre-paginated"
+
+	// Rendition orientation values
+	OrientationAuto      = "auto"
+	OrientationLandscape = "landscape"
+	OrientationPortrait  = "portrait"
+
+	// Rendition spread values
+	SpreadAuto      = "auto"
+	SpreadNone      = "none"
+	SpreadLandscape = "landscape"
+	SpreadBoth      = "both"
+
+	// Spine itemref page spread values
+	PageSpreadLeft   = "left"
+	PageSpreadRight  = "right"
+	PageSpreadCenter = "center"
+
+	// Media overlay properties
+	MediaDuration            = "media:duration"
+	MediaNarrator            = "media:narrator"
+	MediaActiveClass         = "media:active-class"
+	MediaPlaybackActiveClass = "media:playback-active-class"
+
+	// Dictionary properties
+	DictionaryType = "dictionary-type"
+	SourceLanguage = "source-language"
+	TargetLanguage = "target-language"
 )
 
 var ImageMediaTypes = []string{
@@ -38,3 +90,39 @@ var ImageMediaTypes = []string{
 	MediaTypeWebP,
 	MediaTypePNG,
 }
+
+// ContentDocumentMediaTypes are the media types a spine item may have
+// without a fallback.
+var ContentDocumentMediaTypes = []string{
+	MediaTypeXHTML,
+	MediaTypeSVG,
+}
+
+// CoreMediaTypes are the media types reading systems must support, and
+// which therefore need no fallback. Legacy font and script media types are
+// included.
+var CoreMediaTypes = []string{
+	MediaTypeXHTML,
+	MediaTypeSVG,
+	MediaTypeJPEG,
+	MediaTypeGIF,
+	MediaTypeWebP,
+	MediaTypePNG,
+	MediaTypeCSS,
+	MediaTypeNCX,
+	MediaTypeSMIL,
+	MediaTypeMP3,
+	MediaTypeMP4,
+	MediaTypeOgg,
+	MediaTypeTTF,
+	MediaTypeOTF,
+	MediaTypeWOFF,
+	MediaTypeWOFF2,
+	MediaTypeJS,
+	MediaTypePLS,
+	"application/font-sfnt",
+	"application/vnd.ms-opentype",
+	"application/font-woff",
+	"application/ecmascript",
+	"text/javascript",
+}
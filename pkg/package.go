@@ -24,6 +24,7 @@ type Package struct {
 // Metadata represents the metadata section
 type Metadata struct {
 	XMLName     xml.Name       `xml:"metadata"`
+	XMLNSDC     string         `xml:"xmlns:dc,attr,omitempty"`
 	Identifiers []DCIdentifier `xml:"dc:identifier"`
 	Titles      []DCTitle      `xml:"dc:title"`
 	Languages   []DCLanguage   `xml:"dc:language"`
@@ -61,7 +62,12 @@ type DCOptional struct {
 	Dir     string `xml:"dir,attr,omitempty"`
 	ID      string `xml:"id,attr,omitempty"`
 	Lang    string `xml:"xml lang,attr,omitempty"`
-	Value   string `xml:",chardata"`
+	// OPF 2 attributes, replaced by refining meta elements in EPUB 3
+	Role   string `xml:"http://www.idpf.org/2007/opf role,attr,omitempty"`
+	FileAs string `xml:"http://www.idpf.org/2007/opf file-as,attr,omitempty"`
+	Scheme string `xml:"http://www.idpf.org/2007/opf scheme,attr,omitempty"`
+	Event  string `xml:"http://www.idpf.org/2007/opf event,attr,omitempty"`
+	Value  string `xml:",chardata"`
 }
 
 // Meta represents the meta element for generic metadata
@@ -87,7 +93,7 @@ type Link struct {
 	MediaType  string   `xml:"media-type,attr,omitempty"`
 	Properties string   `xml:"properties,attr,omitempty"`
 	Refines    string   `xml:"refines,attr,omitempty"`
-	Rel        string   `xml:"rel,attr"`
+	Rel        string   `xml:"rel,attr,omitempty"`
 }
 
 // Manifest represents the manifest section
@@ -108,6 +114,9 @@ const (
 	ScriptedProperty       ManifestProperty = "scripted"
 	SvgProperty            ManifestProperty = "svg"
 	SwitchProperty         ManifestProperty = "switch"
+	DictionaryProperty     ManifestProperty = "dictionary"
+	GlossaryProperty       ManifestProperty = "glossary"
+	SearchKeyMapProperty   ManifestProperty = "search-key-map"
 )
 
 // Item represents a publication resource in the manifest
@@ -252,7 +261,9 @@ type Collection struct {
 // CollectionMetadata represents metadata within a collection
 type CollectionMetadata struct {
 	XMLName xml.Name `xml:"metadata"`
+	XMLNSDC string   `xml:"xmlns:dc,attr,omitempty"`
 	// Can contain similar content to main metadata but scoped to collection
-	Meta  []Meta `xml:"meta,omitempty"`
-	Links []Link `xml:"link,omitempty"`
+	DublinCore []DCOptional `xml:",any"`
+	Meta       []Meta       `xml:"meta,omitempty"`
+	Links      []Link       `xml:"link,omitempty"`
 }
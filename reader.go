@@ -2,6 +2,8 @@ package epub
 
 import (
 	"encoding/xml"
+	"errors"
+	"strconv"
 	"strings"
 
 	"github.com/raitucarp/epub/ocf"
@@ -28,8 +30,7 @@ func newReaderFromZip(zipContainer *ocf.OCFZipContainer) (reader Reader, err err
 		return
 	}
 
-	reader.SelectPackageRendition("default")
-	reader.parseMetadata()
+	reader.SelectPackageRendition(reader.epub.renditions[0].Key)
 	return
 
 }
@@ -71,8 +72,16 @@ func (r *Reader) parseRootFiles(z *ocf.OCFZipContainer) (err error) {
 		}
 
 		renditionVars := strings.Join(rendition, "_")
+		if _, ok := r.epub.packagePubs[renditionVars]; ok {
+			renditionVars += "_" + strconv.Itoa(len(r.epub.renditions))
+		}
 		r.epub.packagePaths[renditionVars] = packageFullPath
 		r.epub.packagePubs[renditionVars] = &packagePub
+		r.epub.renditions = append(r.epub.renditions, newRendition(renditionVars, rootFile))
+	}
+
+	if len(r.epub.renditions) == 0 {
+		return errors.New("container has no package document")
 	}
 
 	return nil
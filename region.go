@@ -0,0 +1,284 @@
+package epub
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Region types of the EPUB Region-Based Navigation vocabulary.
+const (
+	RegionPanel      = "panel"
+	RegionPanelGroup = "panel-group"
+	RegionBalloon    = "balloon"
+	RegionTextArea   = "text-area"
+	RegionSoundArea  = "sound-area"
+)
+
+// regionVocabularyPrefix is the prefix the region-based nav declares for
+// its vocabulary.
+const regionVocabularyPrefix = "ahl: http://idpf.org/epub/vocab/ahl"
+
+// RegionPage groups the regions of one fixed-layout page.
+type RegionPage struct {
+	// Href is the content document relative to the package document.
+	Href    string   `json:"href"`
+	Regions []Region `json:"regions"`
+}
+
+// Region is an area of a page, such as a comic panel, in reading order.
+type Region struct {
+	// Type is one of the Region constants, without vocabulary prefix.
+	// Regions declaring several types list them separated by spaces.
+	Type    string     `json:"type,omitempty"`
+	Label   string     `json:"label,omitempty"`
+	Rect    RegionRect `json:"rect"`
+	Regions []Region   `json:"regions,omitempty"`
+}
+
+// RegionRect is the rectangle of a region, given by a media fragment
+// such as "xywh=percent:5,5,40,30".
+type RegionRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+
+	// Unit is "percent" or "pixel".
+	Unit string `json:"unit"`
+}
+
+// Panels returns the panels of the page in reading order, including those
+// nested in panel groups.
+func (p RegionPage) Panels() (panels []Region) {
+	var collect func(regions []Region)
+	collect = func(regions []Region) {
+		for _, region := range regions {
+			if slices.Contains(strings.Fields(region.Type), RegionPanel) {
+				panels = append(panels, region)
+			}
+			collect(region.Regions)
+		}
+	}
+	collect(p.Regions)
+	return
+}
+
+// String formats the rectangle as a media fragment.
+func (rect RegionRect) String() string {
+	unit := rect.Unit
+	if unit == "" {
+		unit = "pixel"
+	}
+
+	values := []string{}
+	for _, value := range []float64{rect.X, rect.Y, rect.Width, rect.Height} {
+		values = append(values, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	return "xywh=" + unit + ":" + strings.Join(values, ",")
+}
+
+// parseRegionRect parses an xywh media fragment.
+func parseRegionRect(fragment string) (rect RegionRect, err error) {
+	value, ok := strings.CutPrefix(fragment, "xywh=")
+	if !ok {
+		return rect, fmt.Errorf("fragment %q is not an xywh media fragment", fragment)
+	}
+
+	rect.Unit = "pixel"
+	if unit, rest, found := strings.Cut(value, ":"); found {
+		rect.Unit, value = unit, rest
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return rect, fmt.Errorf("fragment %q needs four values", fragment)
+	}
+
+	numbers := make([]float64, len(parts))
+	for i, part := range parts {
+		if numbers[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+			return rect, fmt.Errorf("fragment %q: %w", fragment, err)
+		}
+	}
+
+	rect.X, rect.Y, rect.Width, rect.Height = numbers[0], numbers[1], numbers[2], numbers[3]
+	return
+}
+
+// RegionNavigation returns the region-based navigation of the publication,
+// grouped by page in reading order. Entries without a valid xywh fragment
+// are skipped.
+func (r *Reader) RegionNavigation() (pages []RegionPage) {
+	navRes := r.navigationResource()
+	if navRes == nil {
+		return
+	}
+
+	navNode := findNavByType(r.ReadContentHTMLById(navRes.ID), "region-based")
+	if navNode == nil {
+		return
+	}
+
+	list := FindNode(navNode, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "ol"
+	})
+	if list == nil {
+		return
+	}
+
+	navDir := path.Dir(navRes.Filepath)
+	packageDir := path.Dir(r.CurrentSelectedPackagePath())
+
+	pageIndexes := map[string]int{}
+	for _, entry := range parseRegionList(list, navDir, packageDir) {
+		index, found := pageIndexes[entry.href]
+		if !found {
+			index = len(pages)
+			pageIndexes[entry.href] = index
+			pages = append(pages, RegionPage{Href: entry.href})
+		}
+		pages[index].Regions = append(pages[index].Regions, entry.region)
+	}
+	return
+}
+
+// regionEntry is a parsed region with the page it belongs to.
+type regionEntry struct {
+	href   string
+	region Region
+}
+
+// parseRegionList parses the items of a region list in reading order.
+func parseRegionList(list *html.Node, navDir string, packageDir string) (entries []regionEntry) {
+	for li := list.FirstChild; li != nil; li = li.NextSibling {
+		entries = append(entries, parseRegionItem(li, navDir, packageDir)...)
+	}
+	return
+}
+
+// parseRegionItem parses a region list item. An item without a valid
+// region link, such as a grouping heading, contributes the regions of its
+// sub-list in its place.
+func parseRegionItem(li *html.Node, navDir string, packageDir string) []regionEntry {
+	if li.Type != html.ElementNode || li.Data != "li" {
+		return nil
+	}
+
+	var anchor, subList *html.Node
+	for c := li.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch {
+		case c.Data == "a" && anchor == nil:
+			anchor = c
+		case c.Data == "ol":
+			subList = c
+		}
+	}
+
+	var children []regionEntry
+	if subList != nil {
+		children = parseRegionList(subList, navDir, packageDir)
+	}
+
+	if anchor == nil {
+		return children
+	}
+
+	href, fragment, _ := strings.Cut(rebaseHref(getAttribute(anchor, "href"), navDir, packageDir), "#")
+	rect, err := parseRegionRect(fragment)
+	if err != nil {
+		return children
+	}
+
+	// The region type may be declared on the list item or on its link.
+	regionType := getAttribute(li, "epub:type")
+	if regionType == "" {
+		regionType = getAttribute(anchor, "epub:type")
+	}
+
+	types := strings.Fields(regionType)
+	for i, t := range types {
+		types[i] = strings.TrimPrefix(t, "ahl:")
+	}
+
+	region := Region{
+		Type:  strings.Join(types, " "),
+		Label: strings.TrimSpace(GetTextContent(anchor)),
+		Rect:  rect,
+	}
+	for _, child := range children {
+		region.Regions = append(region.Regions, child.region)
+	}
+	return []regionEntry{{href: href, region: region}}
+}
+
+// RegionNavigation sets the region-based navigation emitted in the
+// navigation document. It may be called before or after TableOfContents.
+// Page hrefs are relative to the package document.
+func (w *Writer) RegionNavigation(pages []RegionPage) error {
+	w.regionPages = pages
+	return w.updateRegionNavigation()
+}
+
+func (w *Writer) updateRegionNavigation() error {
+	return w.replaceNav("region-based", func(navDir string) *html.Node {
+		if len(w.regionPages) == 0 {
+			return nil
+		}
+
+		nav := &html.Node{
+			Type: html.ElementNode,
+			Data: "nav",
+			Attr: []html.Attribute{
+				{Key: "epub:type", Val: "region-based"},
+				{Key: "prefix", Val: regionVocabularyPrefix},
+				{Key: "hidden", Val: ""},
+			},
+		}
+
+		ol := &html.Node{Type: html.ElementNode, Data: "ol"}
+		nav.AppendChild(ol)
+		for _, page := range w.regionPages {
+			href := rebaseHref(page.Href, w.contentDir, navDir)
+			for _, region := range page.Regions {
+				ol.AppendChild(regionItemNode(href, region))
+			}
+		}
+		return nav
+	})
+}
+
+func regionItemNode(href string, region Region) *html.Node {
+	li := &html.Node{Type: html.ElementNode, Data: "li"}
+	if region.Type != "" {
+		types := strings.Fields(region.Type)
+		for i, t := range types {
+			types[i] = "ahl:" + t
+		}
+		li.Attr = append(li.Attr, html.Attribute{Key: "epub:type", Val: strings.Join(types, " ")})
+	}
+
+	a := &html.Node{
+		Type: html.ElementNode,
+		Data: "a",
+		Attr: []html.Attribute{{Key: "href", Val: href + "#" + region.Rect.String()}},
+	}
+	a.AppendChild(&html.Node{Type: html.TextNode, Data: region.Label})
+	li.AppendChild(a)
+
+	if len(region.Regions) > 0 {
+		ol := &html.Node{Type: html.ElementNode, Data: "ol"}
+		for _, child := range region.Regions {
+			ol.AppendChild(regionItemNode(href, child))
+		}
+		li.AppendChild(ol)
+	}
+	return li
+}
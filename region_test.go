@@ -0,0 +1,118 @@
+package epub
+
+import (
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReader_RegionNavigation(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/nav/nav.xhtml"] = strings.Replace(files["OEBPS/nav/nav.xhtml"], "</body>", `
+<nav epub:type="region-based" prefix="ahl: http://idpf.org/epub/vocab/ahl" hidden=""><ol>
+  <li epub:type="ahl:panel"><a href="../text/chapter-1.xhtml#xywh=percent:5,5,40,30">First</a></li>
+  <li epub:type="ahl:panel-group"><a href="../text/chapter-1.xhtml#xywh=percent:5,40,90,55"></a>
+    <ol>
+      <li epub:type="ahl:panel"><a href="../text/chapter-1.xhtml#xywh=percent:5,40,45,55"></a></li>
+      <li><a epub:type="ahl:balloon" href="../text/chapter-1.xhtml#xywh=percent:10,45,10,5"></a></li>
+      <li epub:type="ahl:panel"><a href="../text/chapter-1.xhtml#xywh=percent:50,40,45,55"></a></li>
+    </ol>
+  </li>
+  <li epub:type="ahl:panel"><a href="../text/chapter-2.xhtml#xywh=0,0,600,800"></a></li>
+  <li epub:type="ahl:panel"><a href="../text/chapter-2.xhtml#not-a-region"></a>
+    <ol>
+      <li epub:type="ahl:panel ahl:text-area"><a href="../text/chapter-2.xhtml#xywh=0,800,600,200"></a></li>
+    </ol>
+  </li>
+  <li><span>Extras</span>
+    <ol>
+      <li epub:type="ahl:sound-area"><a href="../text/chapter-2.xhtml#xywh=0,0,10,10"></a></li>
+    </ol>
+  </li>
+</ol></nav>
+</body>`, 1)
+
+	r := newTestReader(t, files)
+
+	pages := r.RegionNavigation()
+	if len(pages) != 2 || pages[0].Href != "text/chapter-1.xhtml" || pages[1].Href != "text/chapter-2.xhtml" {
+		t.Fatalf("unexpected region pages %+v", pages)
+	}
+
+	first := pages[0].Regions[0]
+	expected := Region{Type: RegionPanel, Label: "First", Rect: RegionRect{X: 5, Y: 5, Width: 40, Height: 30, Unit: "percent"}}
+	if !reflect.DeepEqual(first, expected) {
+		t.Errorf("expected %+v, got %+v", expected, first)
+	}
+
+	group := pages[0].Regions[1]
+	if group.Type != RegionPanelGroup || len(group.Regions) != 3 || group.Regions[1].Type != RegionBalloon {
+		t.Errorf("unexpected panel group %+v", group)
+	}
+
+	panels := pages[0].Panels()
+	if len(panels) != 3 || panels[2].Rect.X != 50 {
+		t.Errorf("expected three panels in reading order, got %+v", panels)
+	}
+	if panels := pages[1].Panels(); len(panels) != 2 {
+		t.Errorf("expected the multi-typed region among the panels, got %+v", panels)
+	}
+
+	if rect := pages[1].Regions[0].Rect; rect.Unit != "pixel" || rect.Width != 600 || len(pages[1].Regions) != 3 {
+		t.Fatalf("unexpected second page regions %+v", pages[1].Regions)
+	}
+
+	// Sub-lists of items without a region are kept in their place.
+	if nested := pages[1].Regions[1]; nested.Type != "panel text-area" || nested.Rect.Y != 800 {
+		t.Errorf("unexpected region nested in an invalid item %+v", nested)
+	}
+	if extra := pages[1].Regions[2]; extra.Type != RegionSoundArea || extra.Rect.Width != 10 {
+		t.Errorf("unexpected region nested in a heading item %+v", extra)
+	}
+}
+
+func TestWriter_RegionNavigation(t *testing.T) {
+	pages := []RegionPage{{
+		Href: "page-1.xhtml",
+		Regions: []Region{
+			{Type: RegionPanel, Label: "Opening", Rect: RegionRect{X: 0, Y: 0, Width: 100, Height: 33.5, Unit: "percent"}},
+			{Type: RegionPanelGroup, Rect: RegionRect{X: 0, Y: 33.5, Width: 100, Height: 66.5, Unit: "percent"}, Regions: []Region{
+				{Type: RegionPanel, Rect: RegionRect{X: 0, Y: 33.5, Width: 50, Height: 66.5, Unit: "percent"}},
+			}},
+		},
+	}}
+
+	for _, beforeTOC := range []bool{true, false} {
+		w := New("urn:uuid:regions")
+		w.Title("Regions")
+		w.Languages("en")
+		w.Cover([]byte("\x89PNG\r\n\x1a\n"))
+		w.AddContent("page-1.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>P</title></head><body><p>P</p></body></html>`))
+
+		toc := TOC{Items: []TOC{{Title: "Page 1", Href: "page-1.xhtml"}}}
+		if !beforeTOC {
+			if err := w.TableOfContents("toc", toc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if err := w.RegionNavigation(pages); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if beforeTOC {
+			if err := w.TableOfContents("toc", toc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		r := writeAndReopen(t, w)
+		if got := r.RegionNavigation(); !reflect.DeepEqual(got, pages) {
+			t.Errorf("beforeTOC=%v: expected %+v, got %+v", beforeTOC, pages, got)
+		}
+
+		nav := string(r.SelectResourceById("toc.xhtml").Content)
+		if !strings.Contains(nav, `href="page-1.xhtml#xywh=percent:0,33.5,100,66.5"`) {
+			t.Errorf("beforeTOC=%v: expected media fragment href, got %s", beforeTOC, nav)
+		}
+	}
+}
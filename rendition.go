@@ -0,0 +1,581 @@
+package epub
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/raitucarp/epub/ncx"
+	"github.com/raitucarp/epub/ocf"
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+)
+
+// Rendition describes one package document of a publication together with
+// the selection attributes declared on its rootfile in container.xml.
+type Rendition struct {
+	// Key identifies the rendition for SelectPackageRendition.
+	Key string `json:"key"`
+
+	// Path is the container path of the package document.
+	Path string `json:"path"`
+
+	// Media is a CSS media query, e.g. "(min-width: 1024px)".
+	Media string `json:"media,omitempty"`
+
+	// Layout is pkg.LayoutReflowable or pkg.LayoutPrePaginated.
+	Layout string `json:"layout,omitempty"`
+
+	Language string `json:"language,omitempty"`
+
+	// AccessMode is one of "auditory", "tactile", "textual" or "visual".
+	AccessMode string `json:"accessMode,omitempty"`
+
+	Label string `json:"label,omitempty"`
+}
+
+// RenditionPreferences describes the reading environment used to choose a
+// rendition. Empty fields match any rendition.
+type RenditionPreferences struct {
+	// MediaType is the CSS media type of the device, e.g. "screen".
+	MediaType string
+
+	// Width and Height are the viewport size in CSS pixels, used to
+	// evaluate media queries.
+	Width  int
+	Height int
+
+	Layout     string
+	Language   string
+	AccessMode string
+}
+
+// RenditionMapping is the content of an EPUB Multiple-Rendition mapping
+// document, which links equivalent locations across renditions.
+type RenditionMapping struct {
+	// Path is the container path of the mapping document.
+	Path string `json:"path"`
+
+	// Version is the epub.multiple.renditions.version declared in the head
+	// of the mapping document.
+	Version   string              `json:"version,omitempty"`
+	Locations []RenditionLocation `json:"locations"`
+}
+
+// renditionMappingVersion is the version of the Multiple-Rendition
+// specification the generated mapping documents conform to.
+const renditionMappingVersion = "1.0"
+
+// RenditionLocation is a set of equivalent points in different renditions.
+type RenditionLocation struct {
+	Targets []RenditionTarget `json:"targets"`
+}
+
+// RenditionTarget is a point inside one rendition.
+type RenditionTarget struct {
+	// Rendition is the key of the rendition whose manifest holds the target.
+	Rendition string `json:"rendition"`
+
+	// Path is the container path of the target content document.
+	Path     string `json:"path"`
+	Fragment string `json:"fragment,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+func newRendition(key string, rootFile ocf.RootFile) Rendition {
+	return Rendition{
+		Key:        key,
+		Path:       rootFile.FullPath,
+		Media:      rootFile.Media,
+		Layout:     rootFile.Layout,
+		Language:   rootFile.Language,
+		AccessMode: rootFile.AccessMode,
+		Label:      rootFile.Label,
+	}
+}
+
+// Renditions returns the renditions of the publication in container order.
+// The first one is the default rendition.
+func (r *Reader) Renditions() []Rendition {
+	return slices.Clone(r.epub.renditions)
+}
+
+// CurrentRendition returns the rendition currently selected.
+func (r *Reader) CurrentRendition() Rendition {
+	for _, rendition := range r.epub.renditions {
+		if rendition.Key == r.epub.rendition {
+			return rendition
+		}
+	}
+	return Rendition{}
+}
+
+// BestRendition returns the rendition matching the preferences best. A
+// rendition is eligible when none of its selection attributes contradict
+// the preferences, and the one matching most attributes wins. Ties and the
+// case where nothing is eligible resolve to the earliest rendition, so the
+// default rendition is returned when no preference applies.
+func (r *Reader) BestRendition(preferences RenditionPreferences) Rendition {
+	best, bestScore := r.epub.renditions[0], -1
+	for _, rendition := range r.epub.renditions {
+		score, ok := rendition.match(preferences)
+		if ok && score > bestScore {
+			best, bestScore = rendition, score
+		}
+	}
+	return best
+}
+
+// SelectBestRendition selects the rendition returned by BestRendition.
+func (r *Reader) SelectBestRendition(preferences RenditionPreferences) Rendition {
+	rendition := r.BestRendition(preferences)
+	r.SelectPackageRendition(rendition.Key)
+	return rendition
+}
+
+// match reports whether the rendition is compatible with the preferences
+// and how many of its selection attributes they satisfy.
+func (rendition Rendition) match(preferences RenditionPreferences) (score int, ok bool) {
+	mediaPreferred := preferences.MediaType != "" || preferences.Width > 0 || preferences.Height > 0
+	checks := []struct {
+		declared bool
+		matches  func() bool
+	}{
+		{rendition.Media != "" && mediaPreferred, func() bool { return matchMediaQuery(rendition.Media, preferences) }},
+		{rendition.Layout != "" && preferences.Layout != "", func() bool {
+			return rendition.Layout == preferences.Layout
+		}},
+		{rendition.Language != "" && preferences.Language != "", func() bool {
+			return matchLanguage(rendition.Language, preferences.Language)
+		}},
+		{rendition.AccessMode != "" && preferences.AccessMode != "", func() bool {
+			return rendition.AccessMode == preferences.AccessMode
+		}},
+	}
+
+	for _, check := range checks {
+		if !check.declared {
+			continue
+		}
+		if !check.matches() {
+			return 0, false
+		}
+		score++
+	}
+	return score, true
+}
+
+// matchLanguage reports whether the language tags are equal, or one is a
+// prefix of the other, e.g. "en" and "en-US".
+func matchLanguage(a string, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	return a == b || strings.HasPrefix(a, b+"-") || strings.HasPrefix(b, a+"-")
+}
+
+// matchMediaQuery evaluates a CSS media query list against the preferred
+// media type and viewport. Supported features are width, height and
+// orientation, with their min- and max- forms; unknown features never match.
+func matchMediaQuery(query string, preferences RenditionPreferences) bool {
+	for _, mediaQuery := range strings.Split(query, ",") {
+		if matchSingleMediaQuery(strings.ToLower(strings.TrimSpace(mediaQuery)), preferences) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSingleMediaQuery(query string, preferences RenditionPreferences) bool {
+	negate := false
+	if rest, ok := strings.CutPrefix(query, "not "); ok {
+		negate, query = true, rest
+	}
+	query = strings.TrimPrefix(query, "only ")
+
+	matches := true
+	for _, part := range strings.Split(query, " and ") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(part, "(") {
+			if part != "all" && preferences.MediaType != "" && part != strings.ToLower(preferences.MediaType) {
+				matches = false
+			}
+			continue
+		}
+
+		if !matchMediaFeature(strings.Trim(part, "()"), preferences) {
+			matches = false
+		}
+	}
+
+	return matches != negate
+}
+
+func matchMediaFeature(feature string, preferences RenditionPreferences) bool {
+	name, value, _ := strings.Cut(feature, ":")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+	if name == "orientation" {
+		if preferences.Width == 0 || preferences.Height == 0 {
+			return true
+		}
+		if preferences.Width > preferences.Height {
+			return value == "landscape"
+		}
+		return value == "portrait"
+	}
+
+	prefix, dimension, found := strings.Cut(name, "-")
+	if !found {
+		prefix, dimension = "", name
+	}
+
+	var actual int
+	switch dimension {
+	case "width", "device-width":
+		actual = preferences.Width
+	case "height", "device-height":
+		actual = preferences.Height
+	default:
+		return false
+	}
+
+	// Size features do not rule anything out when the size is unknown.
+	if actual == 0 {
+		return true
+	}
+
+	expected, ok := cssPixels(value)
+	if !ok {
+		return false
+	}
+
+	switch prefix {
+	case "min":
+		return actual >= expected
+	case "max":
+		return actual <= expected
+	default:
+		return actual == expected
+	}
+}
+
+// cssPixels converts a px or em length into CSS pixels.
+func cssPixels(value string) (int, bool) {
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(value, "px"):
+		value = strings.TrimSuffix(value, "px")
+	case strings.HasSuffix(value, "em"):
+		value, multiplier = strings.TrimSuffix(value, "em"), 16
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(number * multiplier), true
+}
+
+// RenditionMapping parses the mapping document linked from container.xml
+// with rel="mapping". It returns nil without error when the publication has
+// no mapping document.
+func (r *Reader) RenditionMapping() (mapping *RenditionMapping, err error) {
+	container := r.epub.zipContainer.Container()
+	if container.Links == nil {
+		return
+	}
+
+	linkIndex := slices.IndexFunc(container.Links.Link, func(link ocf.Link) bool {
+		return slices.Contains(strings.Fields(link.Rel), "mapping")
+	})
+	if linkIndex < 0 {
+		return
+	}
+
+	mappingPath := path.Clean(strings.TrimPrefix(container.Links.Link[linkIndex].Href, "/"))
+	content, err := r.epub.zipContainer.SelectFile(mappingPath)
+	if err != nil {
+		return nil, fmt.Errorf("read mapping document: %w", err)
+	}
+
+	doc, err := parseXHTML(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse mapping document: %w", err)
+	}
+
+	resourceMap := findNavByType(doc, "resource-map")
+	if resourceMap == nil {
+		return nil, errors.New("mapping document has no resource-map nav")
+	}
+
+	renditionOfPath := r.renditionsByPath()
+	mapping = &RenditionMapping{Path: mappingPath}
+
+	version := FindNode(doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "meta" && getAttribute(n, "name") == "epub.multiple.renditions.version"
+	})
+	if version != nil {
+		mapping.Version = strings.TrimSpace(getAttribute(version, "content"))
+	}
+
+	list := FindNode(resourceMap, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "ul"
+	})
+	if list == nil {
+		return
+	}
+
+	for li := list.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.Data != "li" {
+			continue
+		}
+
+		location := RenditionLocation{}
+		for node := range li.Descendants() {
+			if node.Type != html.ElementNode || node.Data != "a" {
+				continue
+			}
+
+			containerPath, fragment := resolveHref(path.Dir(mappingPath), getAttribute(node, "href"))
+			location.Targets = append(location.Targets, RenditionTarget{
+				Rendition: renditionOfPath[containerPath],
+				Path:      containerPath,
+				Fragment:  fragment,
+				Title:     strings.TrimSpace(GetTextContent(node)),
+			})
+		}
+
+		if len(location.Targets) > 0 {
+			mapping.Locations = append(mapping.Locations, location)
+		}
+	}
+	return
+}
+
+// renditionsByPath maps the container path of every manifest item to the
+// key of the rendition declaring it.
+func (r *Reader) renditionsByPath() map[string]string {
+	paths := map[string]string{}
+	for _, rendition := range r.epub.renditions {
+		packagePub := r.epub.packagePubs[rendition.Key]
+		for _, item := range packagePub.Manifest.Items {
+			itemPath, _ := resolveHref(path.Dir(rendition.Path), item.Href)
+			if _, ok := paths[itemPath]; !ok {
+				paths[itemPath] = rendition.Key
+			}
+		}
+	}
+	return paths
+}
+
+// Equivalent returns the point in the given rendition equivalent to the
+// content document at containerPath, or nil when the mapping has none.
+func (m *RenditionMapping) Equivalent(containerPath string, rendition string) *RenditionTarget {
+	for _, location := range m.Locations {
+		if !slices.ContainsFunc(location.Targets, func(target RenditionTarget) bool {
+			return target.Path == containerPath
+		}) {
+			continue
+		}
+
+		for _, target := range location.Targets {
+			if target.Rendition == rendition {
+				return &target
+			}
+		}
+	}
+	return nil
+}
+
+// writerRendition keeps the per rendition state of a Writer while another
+// rendition is selected.
+type writerRendition struct {
+	Rendition
+	contentDir               string
+	resources                []PublicationResource
+	navigationCenterEXtended *ncx.NCX
+	pageList                 []Page
+	regionPages              []RegionPage
+}
+
+// AddRendition adds a package document for another rendition of the
+// publication and selects it, so subsequent calls populate the new
+// rendition. The metadata of the currently selected rendition is copied,
+// as every rendition must carry the full publication metadata. Files of the
+// new rendition are stored under a content directory named after it.
+func (w *Writer) AddRendition(name string, rendition Rendition) (err error) {
+	if _, ok := w.epub.packagePubs[name]; ok {
+		return fmt.Errorf("rendition %q already exists", name)
+	}
+
+	current := w.epub.SelectedPackage()
+	packagePub := &pkg.Package{
+		UniqueIdentifier: current.UniqueIdentifier,
+		Version:          current.Version,
+		Dir:              current.Dir,
+		Metadata:         clonePackage(current).Metadata,
+		Spine:            pkg.Spine{PageProgressionDirection: current.Spine.PageProgressionDirection},
+		Manifest:         pkg.Manifest{},
+	}
+	w.epub.packagePubs[name] = packagePub
+
+	rendition.Key = name
+	w.renditions = append(w.renditions, writerRendition{
+		Rendition:  rendition,
+		contentDir: name,
+	})
+	return w.SelectRendition(name)
+}
+
+// SelectRendition makes the named rendition the target of subsequent
+// Writer calls.
+func (w *Writer) SelectRendition(name string) (err error) {
+	index := slices.IndexFunc(w.renditions, func(rendition writerRendition) bool {
+		return rendition.Key == name
+	})
+	if index < 0 {
+		return fmt.Errorf("rendition %q does not exist", name)
+	}
+
+	w.saveRendition()
+
+	selected := w.renditions[index]
+	w.epub.rendition = name
+	w.contentDir = selected.contentDir
+	w.epub.resources = selected.resources
+	w.epub.navigationCenterEXtended = selected.navigationCenterEXtended
+	w.pageList = selected.pageList
+	w.regionPages = selected.regionPages
+	return
+}
+
+// saveRendition stores the state of the selected rendition.
+func (w *Writer) saveRendition() {
+	for i, rendition := range w.renditions {
+		if rendition.Key != w.epub.rendition {
+			continue
+		}
+
+		w.renditions[i].contentDir = w.contentDir
+		w.renditions[i].resources = w.epub.resources
+		w.renditions[i].navigationCenterEXtended = w.epub.navigationCenterEXtended
+		w.renditions[i].pageList = w.pageList
+		w.renditions[i].regionPages = w.regionPages
+	}
+}
+
+// RenditionMapping adds a mapping document linking equivalent locations of
+// the renditions. Target paths are given relative to the content directory
+// of their rendition, like the hrefs passed to AddContent.
+func (w *Writer) RenditionMapping(locations []RenditionLocation) (err error) {
+	w.saveRendition()
+
+	mappingPath := "mapping.xhtml"
+	list := &html.Node{Type: html.ElementNode, Data: "ul"}
+	for _, location := range locations {
+		li := &html.Node{Type: html.ElementNode, Data: "li"}
+		targets := &html.Node{Type: html.ElementNode, Data: "ul"}
+		li.AppendChild(targets)
+
+		for _, target := range location.Targets {
+			index := slices.IndexFunc(w.renditions, func(rendition writerRendition) bool {
+				return rendition.Key == target.Rendition
+			})
+			if index < 0 {
+				return fmt.Errorf("rendition %q does not exist", target.Rendition)
+			}
+
+			containerPath := path.Join(w.renditions[index].contentDir, target.Path)
+			title := target.Title
+			if title == "" {
+				title = target.Path
+			}
+
+			a := &html.Node{
+				Type: html.ElementNode,
+				Data: "a",
+				Attr: []html.Attribute{
+					{Key: "href", Val: relativeHref(path.Dir(mappingPath), containerPath, target.Fragment)},
+				},
+			}
+			a.AppendChild(&html.Node{Type: html.TextNode, Data: title})
+
+			targetItem := &html.Node{Type: html.ElementNode, Data: "li"}
+			targetItem.AppendChild(a)
+			targets.AppendChild(targetItem)
+		}
+		list.AppendChild(li)
+	}
+
+	nav := &html.Node{
+		Type: html.ElementNode,
+		Data: "nav",
+		Attr: []html.Attribute{{Key: "epub:type", Val: "resource-map"}},
+	}
+	nav.AppendChild(list)
+
+	doc := navDocumentNode("Rendition Mapping", nil, nav)
+	head := FindNode(doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "head"
+	})
+	head.AppendChild(&html.Node{
+		Type: html.ElementNode,
+		Data: "meta",
+		Attr: []html.Attribute{
+			{Key: "name", Val: "epub.multiple.renditions.version"},
+			{Key: "content", Val: renditionMappingVersion},
+		},
+	})
+
+	content, err := renderXHTML(doc)
+	if err != nil {
+		return
+	}
+
+	w.epub.zipContainer.AddFile(mappingPath, content)
+	w.mappingPath = mappingPath
+	return
+}
+
+// writeContainer adds container.xml listing every rendition in the order
+// they were created, the first one being the default rendition.
+func (w *Writer) writeContainer() (err error) {
+	w.saveRendition()
+
+	container := ocf.Container{Version: "1.0"}
+	for _, rendition := range w.renditions {
+		packagePub := w.epub.packagePubs[rendition.Key]
+		packagePub.Metadata.XMLNSDC = pkg.NamespaceDC
+
+		packagePath := path.Join(rendition.contentDir, rendition.Key+".opf")
+		if err = w.epub.zipContainer.AddPackage(packagePath, *packagePub); err != nil {
+			return
+		}
+
+		container.RootFiles.RootFile = append(container.RootFiles.RootFile, ocf.RootFile{
+			FullPath:   packagePath,
+			MediaType:  ocf.EPUBContainerMime,
+			Media:      rendition.Media,
+			Layout:     rendition.Layout,
+			Language:   rendition.Language,
+			AccessMode: rendition.AccessMode,
+			Label:      rendition.Label,
+		})
+	}
+
+	if w.mappingPath != "" {
+		container.Links = &ocf.Links{Link: []ocf.Link{{
+			Href:      w.mappingPath,
+			MediaType: pkg.MediaTypeXHTML,
+			Rel:       "mapping",
+		}}}
+	}
+
+	return w.epub.zipContainer.AddContainer(container)
+}
@@ -0,0 +1,202 @@
+package epub
+
+import (
+	"maps"
+	"testing"
+)
+
+var testMultiRenditionFiles = map[string]string{
+	"mimetype": "application/epub+zip",
+	"META-INF/container.xml": `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:rendition="http://www.idpf.org/2013/rendition">
+  <rootfiles>
+    <rootfile full-path="reflow/content.opf" media-type="application/oebps-package+xml"/>
+    <rootfile full-path="fixed/content.opf" media-type="application/oebps-package+xml"
+      rendition:media="(min-width: 1024px)" rendition:layout="pre-paginated" rendition:label="Print replica"/>
+    <rootfile full-path="audio/content.opf" media-type="application/oebps-package+xml"
+      rendition:accessMode="auditory" rendition:language="fr"/>
+  </rootfiles>
+  <links>
+    <link href="mapping.xhtml" rel="mapping" media-type="application/xhtml+xml"/>
+  </links>
+</container>`,
+	"mapping.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>Mapping</title><meta name="epub.multiple.renditions.version" content="1.0"/></head>
+<body><nav epub:type="resource-map"><ul>
+  <li><ul>
+    <li><a href="reflow/chapter.xhtml#c1">Chapter 1</a></li>
+    <li><a href="fixed/page-1.xhtml">Page 1</a></li>
+  </ul></li>
+</ul></nav></body></html>`,
+	"reflow/content.opf":   testRenditionPackage("Reflowable", "chapter.xhtml"),
+	"reflow/chapter.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter</title></head><body><p id="c1">Text</p></body></html>`,
+	"fixed/content.opf":    testRenditionPackage("Fixed", "page-1.xhtml"),
+	"fixed/page-1.xhtml":   `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Page</title></head><body><p>Page</p></body></html>`,
+	"audio/content.opf":    testRenditionPackage("Audio", "chapter.xhtml"),
+	"audio/chapter.xhtml":  `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Audio</title></head><body><p>Audio</p></body></html>`,
+}
+
+func testRenditionPackage(title string, href string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:renditions</dc:identifier>
+    <dc:title>` + title + `</dc:title>
+    <dc:language>en</dc:language>
+  </metadata>
+  <manifest><item id="content" href="` + href + `" media-type="application/xhtml+xml"/></manifest>
+  <spine><itemref idref="content"/></spine>
+</package>`
+}
+
+func TestReader_Renditions(t *testing.T) {
+	r := newTestReader(t, testMultiRenditionFiles)
+
+	renditions := r.Renditions()
+	if len(renditions) != 3 {
+		t.Fatalf("expected 3 renditions, got %+v", renditions)
+	}
+
+	fixed := renditions[1]
+	if fixed.Path != "fixed/content.opf" || fixed.Layout != "pre-paginated" || fixed.Label != "Print replica" {
+		t.Errorf("unexpected fixed rendition %+v", fixed)
+	}
+
+	if r.CurrentRendition().Key != renditions[0].Key || r.Title() != "Reflowable" {
+		t.Errorf("expected the first rendition to be selected by default")
+	}
+
+	tests := []struct {
+		name        string
+		preferences RenditionPreferences
+		expected    string
+	}{
+		{"no preferences", RenditionPreferences{}, "reflow/content.opf"},
+		{"wide screen", RenditionPreferences{MediaType: "screen", Width: 1280, Height: 800}, "fixed/content.opf"},
+		{"narrow screen", RenditionPreferences{Width: 600, Height: 800}, "reflow/content.opf"},
+		{"layout", RenditionPreferences{Layout: "pre-paginated", Width: 2048}, "fixed/content.opf"},
+		{"auditory", RenditionPreferences{AccessMode: "auditory", Language: "fr-CA", Width: 600}, "audio/content.opf"},
+		{"wrong language", RenditionPreferences{AccessMode: "auditory", Language: "de", Width: 600}, "reflow/content.opf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rendition := r.BestRendition(tt.preferences); rendition.Path != tt.expected {
+				t.Errorf("expected %s, got %+v", tt.expected, rendition)
+			}
+		})
+	}
+
+	r.SelectBestRendition(RenditionPreferences{Layout: "pre-paginated"})
+	if r.Title() != "Fixed" || len(r.Resources()) != 1 || r.Resources()[0].Filepath != "fixed/page-1.xhtml" {
+		t.Errorf("expected the fixed rendition resources, got %+v", r.Resources())
+	}
+
+	mapping, err := r.RenditionMapping()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	target := mapping.Equivalent("reflow/chapter.xhtml", fixed.Key)
+	if target == nil || target.Path != "fixed/page-1.xhtml" || target.Title != "Page 1" {
+		t.Errorf("unexpected equivalent target %+v", target)
+	}
+
+	if source := mapping.Locations[0].Targets[0]; source.Rendition != renditions[0].Key || source.Fragment != "c1" {
+		t.Errorf("unexpected mapping target %+v", source)
+	}
+	if mapping.Version != "1.0" {
+		t.Errorf("expected mapping version 1.0, got %q", mapping.Version)
+	}
+}
+
+func TestReader_SingleRenditionWithAttributes(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["META-INF/container.xml"] = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:rendition="http://www.idpf.org/2013/rendition">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml" rendition:layout="reflowable"/>
+  </rootfiles>
+</container>`
+
+	r := newTestReader(t, files)
+	if r.Title() != "Modern Book" {
+		t.Errorf("expected the only rendition to be selected, got title %q", r.Title())
+	}
+
+	mapping, err := r.RenditionMapping()
+	if mapping != nil || err != nil {
+		t.Errorf("expected no mapping, got %+v, %v", mapping, err)
+	}
+}
+
+func TestWriter_Renditions(t *testing.T) {
+	w := New("urn:uuid:multi")
+	w.Title("Multi")
+	w.Languages("en")
+
+	addRenditionContent := func(href string) {
+		w.Cover([]byte("\x89PNG\r\n\x1a\n"))
+		w.AddContent(href, []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>C</title></head><body><p id="p1">C</p></body></html>`))
+		if err := w.TableOfContents("toc", TOC{Title: "Contents", Items: []TOC{{Title: "C", Href: href}}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	addRenditionContent("chapter.xhtml")
+
+	if err := w.AddRendition("fixed", Rendition{Layout: "pre-paginated", Media: "(min-width: 1024px)"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	addRenditionContent("page-1.xhtml")
+
+	if err := w.AddRendition("fixed", Rendition{}); err == nil {
+		t.Errorf("expected error for duplicate rendition")
+	}
+
+	err := w.RenditionMapping([]RenditionLocation{{Targets: []RenditionTarget{
+		{Rendition: "content", Path: "chapter.xhtml", Fragment: "p1"},
+		{Rendition: "fixed", Path: "page-1.xhtml"},
+	}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := w.SelectRendition("content"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Description("Shown in the reflowable rendition only")
+
+	r := writeAndReopen(t, w)
+
+	renditions := r.Renditions()
+	if len(renditions) != 2 || renditions[0].Path != "epub/content.opf" || renditions[1].Path != "fixed/fixed.opf" {
+		t.Fatalf("unexpected renditions %+v", renditions)
+	}
+
+	if renditions[1].Layout != "pre-paginated" || renditions[1].Media != "(min-width: 1024px)" {
+		t.Errorf("expected selection attributes, got %+v", renditions[1])
+	}
+
+	if r.Description() == "" {
+		t.Errorf("expected description in the default rendition")
+	}
+
+	r.SelectPackageRendition(renditions[1].Key)
+	if r.Title() != "Multi" || r.SelectResourceById("page-1.xhtml") == nil || r.SelectResourceById("chapter.xhtml") != nil {
+		t.Errorf("unexpected fixed rendition resources %+v", r.Resources())
+	}
+
+	mapping, err := r.RenditionMapping()
+	if err != nil || mapping == nil {
+		t.Fatalf("expected mapping, got %v", err)
+	}
+	if mapping.Version != renditionMappingVersion {
+		t.Errorf("expected the mapping version to be declared, got %q", mapping.Version)
+	}
+
+	target := mapping.Equivalent("epub/chapter.xhtml", renditions[1].Key)
+	if target == nil || target.Path != "fixed/page-1.xhtml" {
+		t.Errorf("unexpected equivalent target %+v", target)
+	}
+}
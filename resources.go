@@ -1,7 +1,10 @@
 package epub
 
 import (
+	"net/url"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/raitucarp/epub/ncx"
 	"github.com/raitucarp/epub/pkg"
@@ -71,3 +74,37 @@ func (r *Reader) SelectResourceByHref(href string) (resource *PublicationResourc
 	}
 	return nil
 }
+
+// resolveHref resolves an href found in a file inside baseDir into a
+// container path and its fragment identifier.
+func resolveHref(baseDir string, href string) (containerPath string, fragment string) {
+	href, fragment, _ = strings.Cut(href, "#")
+	if href == "" {
+		return "", fragment
+	}
+	containerPath = path.Clean(path.Join(baseDir, href))
+	return
+}
+
+// unescapePath decodes percent-encoded characters of an href path, leaving
+// it untouched when it is not validly encoded.
+func unescapePath(p string) string {
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		return unescaped
+	}
+	return p
+}
+
+// relativeHref builds an href pointing at a container path (and optional
+// fragment) from a file inside fromDir.
+func relativeHref(fromDir string, containerPath string, fragment string) (href string) {
+	href = containerPath
+	if rel, err := filepath.Rel(filepath.FromSlash(fromDir), filepath.FromSlash(containerPath)); err == nil {
+		href = filepath.ToSlash(rel)
+	}
+
+	if fragment != "" {
+		href += "#" + fragment
+	}
+	return
+}
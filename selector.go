@@ -0,0 +1,706 @@
+package epub
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Selector is a parsed CSS selector list, matched against the elements of
+// parsed content documents.
+type Selector struct {
+	complex []complexSelector
+}
+
+// complexSelector is a sequence of compound selectors joined by
+// combinators: ' ', '>', '+' or '~'.
+type complexSelector struct {
+	compounds   []compoundSelector
+	combinators []byte
+	specificity [3]int
+}
+
+type compoundSelector struct {
+	tag           string
+	id            string
+	classes       []string
+	attributes    []attributeSelector
+	pseudoClasses []pseudoClass
+	pseudoElement string
+}
+
+type attributeSelector struct {
+	name        string
+	operator    string
+	value       string
+	insensitive bool
+}
+
+type pseudoClass struct {
+	name string
+	// a and b are the An+B arguments of the nth pseudo-classes.
+	a, b int
+	// arguments are the selectors of :not, :is and :where, or the
+	// language of :lang.
+	arguments []complexSelector
+	language  string
+}
+
+// pseudoElements are the pseudo-elements, also accepted with one colon
+// for the legacy ones. Selectors with a pseudo-element match no element.
+var pseudoElements = []string{"before", "after", "first-line", "first-letter", "marker", "selection", "placeholder", "backdrop"}
+
+// ParseSelector parses a CSS selector list. Namespace prefixes of type
+// selectors are ignored, and those of attribute selectors joined with a
+// colon, so [epub|type] matches epub:type attributes. Dynamic
+// pseudo-classes such as :hover never match.
+func ParseSelector(selector string) (*Selector, error) {
+	var list []complexSelector
+	for _, part := range splitSelectorList(selector) {
+		complex, err := parseComplexSelector(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+		list = append(list, complex)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("invalid selector %q: empty", selector)
+	}
+	return &Selector{complex: list}, nil
+}
+
+// Match reports whether the element matches one of the selectors.
+func (s *Selector) Match(node *html.Node) bool {
+	return s.match(node) >= 0
+}
+
+// Specificity returns the specificity, as ids, classes and types, of the
+// most specific selector of the list matching the element, and false when
+// none does.
+func (s *Selector) Specificity(node *html.Node) ([3]int, bool) {
+	if i := s.match(node); i >= 0 {
+		return s.complex[i].specificity, true
+	}
+	return [3]int{}, false
+}
+
+// match returns the index of the most specific matching selector, or -1.
+func (s *Selector) match(node *html.Node) (index int) {
+	index = -1
+	for i, complex := range s.complex {
+		if complex.match(node) && (index < 0 || compareSpecificity(complex.specificity, s.complex[index].specificity) > 0) {
+			index = i
+		}
+	}
+	return
+}
+
+// FindAll returns the elements under root matching the selector, in
+// document order.
+func (s *Selector) FindAll(root *html.Node) (nodes []*html.Node) {
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode && s.Match(node) {
+			nodes = append(nodes, node)
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+	return
+}
+
+func compareSpecificity(a, b [3]int) int {
+	return slices.Compare(a[:], b[:])
+}
+
+// splitSelectorList splits a selector list on the commas outside
+// parentheses, brackets and strings.
+func splitSelectorList(selector string) (parts []string) {
+	var quote rune
+	depth, start := 0, 0
+	for i, r := range selector {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			depth = max(0, depth-1)
+		case r == ',' && depth == 0:
+			parts = append(parts, selector[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, selector[start:])
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return
+}
+
+// selectorParser reads a complex selector.
+type selectorParser struct {
+	s string
+	i int
+}
+
+func parseComplexSelector(s string) (complex complexSelector, err error) {
+	p := &selectorParser{s: strings.TrimSpace(s)}
+	if p.s == "" {
+		return complex, errors.New("empty")
+	}
+
+	for {
+		compound, err := p.compound()
+		if err != nil {
+			return complex, err
+		}
+		complex.compounds = append(complex.compounds, compound)
+
+		spaced := p.skipSpace()
+		if p.i >= len(p.s) {
+			break
+		}
+		if compound.pseudoElement != "" {
+			return complex, errors.New("pseudo-element must come last")
+		}
+
+		combinator := byte(' ')
+		if c := p.s[p.i]; c == '>' || c == '+' || c == '~' {
+			combinator = c
+			p.i++
+			p.skipSpace()
+		} else if !spaced {
+			return complex, fmt.Errorf("unexpected %q", p.s[p.i:])
+		}
+		complex.combinators = append(complex.combinators, combinator)
+	}
+
+	for _, compound := range complex.compounds {
+		specificity := compound.specificity()
+		for i := range specificity {
+			complex.specificity[i] += specificity[i]
+		}
+	}
+	return complex, nil
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.i
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n\f", p.s[p.i]) >= 0 {
+		p.i++
+	}
+	return p.i > start
+}
+
+func (p *selectorParser) compound() (compound compoundSelector, err error) {
+	start := p.i
+	if p.i < len(p.s) && (p.s[p.i] == '*' || p.s[p.i] == '|' || isIdentStart(p.s[p.i])) {
+		name := p.qualifiedName()
+		if _, local, found := strings.Cut(name, ":"); found {
+			name = local
+		}
+		if name != "*" {
+			compound.tag = strings.ToLower(name)
+		}
+	}
+
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case '#':
+			p.i++
+			if compound.id = p.ident(); compound.id == "" {
+				return compound, errors.New("empty id selector")
+			}
+		case '.':
+			p.i++
+			class := p.ident()
+			if class == "" {
+				return compound, errors.New("empty class selector")
+			}
+			compound.classes = append(compound.classes, class)
+		case '[':
+			attribute, err := p.attribute()
+			if err != nil {
+				return compound, err
+			}
+			compound.attributes = append(compound.attributes, attribute)
+		case ':':
+			if compound.pseudoElement != "" {
+				return compound, errors.New("pseudo-element must come last")
+			}
+			if err := p.pseudo(&compound); err != nil {
+				return compound, err
+			}
+		default:
+			if p.i == start {
+				return compound, fmt.Errorf("unexpected %q", p.s[p.i:])
+			}
+			return compound, nil
+		}
+	}
+	if p.i == start {
+		return compound, errors.New("missing selector")
+	}
+	return compound, nil
+}
+
+// qualifiedName reads a name with an optional namespace prefix, returned
+// joined with a colon.
+func (p *selectorParser) qualifiedName() string {
+	var name string
+	if p.s[p.i] == '*' {
+		p.i++
+		name = "*"
+	} else {
+		name = p.ident()
+	}
+
+	if p.i+1 < len(p.s) && p.s[p.i] == '|' && p.s[p.i+1] != '=' {
+		p.i++
+		local := "*"
+		if p.i < len(p.s) && p.s[p.i] == '*' {
+			p.i++
+		} else {
+			local = p.ident()
+		}
+		if name == "" || name == "*" {
+			return local
+		}
+		return name + ":" + local
+	}
+	return name
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '-' || c == '\\' || c >= 0x80 || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
+
+// ident reads an identifier, decoding escapes.
+func (p *selectorParser) ident() string {
+	var ident strings.Builder
+	for p.i < len(p.s) && isIdentChar(p.s[p.i]) {
+		if p.s[p.i] != '\\' {
+			ident.WriteByte(p.s[p.i])
+			p.i++
+			continue
+		}
+
+		p.i++
+		hexEnd := p.i
+		for hexEnd < len(p.s) && hexEnd-p.i < 6 && strings.IndexByte("0123456789abcdefABCDEF", p.s[hexEnd]) >= 0 {
+			hexEnd++
+		}
+		if hexEnd > p.i {
+			code, _ := strconv.ParseUint(p.s[p.i:hexEnd], 16, 32)
+			ident.WriteRune(rune(code))
+			p.i = hexEnd
+			if p.i < len(p.s) && p.s[p.i] == ' ' {
+				p.i++
+			}
+		} else if p.i < len(p.s) {
+			ident.WriteByte(p.s[p.i])
+			p.i++
+		}
+	}
+	return ident.String()
+}
+
+func (p *selectorParser) attribute() (attribute attributeSelector, err error) {
+	p.i++
+	p.skipSpace()
+	if p.i >= len(p.s) {
+		return attribute, errors.New("unterminated attribute selector")
+	}
+	if attribute.name = strings.ToLower(p.qualifiedName()); attribute.name == "" {
+		return attribute, errors.New("empty attribute selector")
+	}
+	p.skipSpace()
+
+	for _, operator := range []string{"=", "~=", "|=", "^=", "$=", "*="} {
+		if strings.HasPrefix(p.s[p.i:], operator) {
+			attribute.operator = operator
+			p.i += len(operator)
+			break
+		}
+	}
+	if attribute.operator != "" {
+		p.skipSpace()
+		if p.i < len(p.s) && (p.s[p.i] == '"' || p.s[p.i] == '\'') {
+			end := strings.IndexByte(p.s[p.i+1:], p.s[p.i])
+			if end < 0 {
+				return attribute, errors.New("unterminated string")
+			}
+			attribute.value = p.s[p.i+1 : p.i+1+end]
+			p.i += end + 2
+		} else {
+			attribute.value = p.ident()
+		}
+		p.skipSpace()
+		if p.i < len(p.s) && (p.s[p.i] == 'i' || p.s[p.i] == 'I' || p.s[p.i] == 's' || p.s[p.i] == 'S') {
+			attribute.insensitive = p.s[p.i] == 'i' || p.s[p.i] == 'I'
+			p.i++
+			p.skipSpace()
+		}
+	}
+
+	if p.i >= len(p.s) || p.s[p.i] != ']' {
+		return attribute, errors.New("unterminated attribute selector")
+	}
+	p.i++
+	return attribute, nil
+}
+
+func (p *selectorParser) pseudo(compound *compoundSelector) error {
+	p.i++
+	element := false
+	if p.i < len(p.s) && p.s[p.i] == ':' {
+		element = true
+		p.i++
+	}
+
+	name := strings.ToLower(p.ident())
+	var argument string
+	if p.i < len(p.s) && p.s[p.i] == '(' {
+		depth, end := 0, -1
+		for j := p.i; j < len(p.s) && end < 0; j++ {
+			switch p.s[j] {
+			case '(':
+				depth++
+			case ')':
+				if depth--; depth == 0 {
+					end = j
+				}
+			}
+		}
+		if end < 0 {
+			return errors.New("unterminated pseudo-class")
+		}
+		argument = strings.TrimSpace(p.s[p.i+1 : end])
+		p.i = end + 1
+	}
+
+	if element || slices.Contains(pseudoElements[:4], name) {
+		if !slices.Contains(pseudoElements, name) {
+			return fmt.Errorf("unsupported pseudo-element %q", name)
+		}
+		compound.pseudoElement = name
+		return nil
+	}
+
+	pseudo := pseudoClass{name: name}
+	switch name {
+	case "root", "empty", "first-child", "last-child", "only-child", "first-of-type", "last-of-type", "only-of-type",
+		"link", "any-link", "visited", "hover", "active", "focus", "focus-within", "focus-visible", "target",
+		"checked", "disabled", "enabled":
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		var err error
+		if pseudo.a, pseudo.b, err = parseNth(argument); err != nil {
+			return err
+		}
+	case "not", "is", "matches", "where", "-webkit-any", "-moz-any":
+		for _, part := range splitSelectorList(argument) {
+			complex, err := parseComplexSelector(part)
+			if err != nil {
+				return err
+			}
+			pseudo.arguments = append(pseudo.arguments, complex)
+		}
+		if len(pseudo.arguments) == 0 {
+			return fmt.Errorf("empty :%s", name)
+		}
+	case "lang":
+		if pseudo.language = strings.ToLower(strings.Trim(argument, `"'`)); pseudo.language == "" {
+			return errors.New("empty :lang")
+		}
+	default:
+		return fmt.Errorf("unsupported pseudo-class %q", name)
+	}
+	compound.pseudoClasses = append(compound.pseudoClasses, pseudo)
+	return nil
+}
+
+// parseNth parses the An+B argument of the nth pseudo-classes.
+func parseNth(argument string) (a int, b int, err error) {
+	argument = strings.ToLower(strings.Join(strings.Fields(argument), ""))
+	switch argument {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+
+	coefficient, constant, found := strings.Cut(argument, "n")
+	if !found {
+		b, err = strconv.Atoi(argument)
+		return 0, b, err
+	}
+
+	switch coefficient {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		if a, err = strconv.Atoi(coefficient); err != nil {
+			return 0, 0, fmt.Errorf("invalid nth argument %q", argument)
+		}
+	}
+	if constant != "" {
+		if b, err = strconv.Atoi(constant); err != nil {
+			return 0, 0, fmt.Errorf("invalid nth argument %q", argument)
+		}
+	}
+	return a, b, nil
+}
+
+func (compound *compoundSelector) specificity() (specificity [3]int) {
+	if compound.id != "" {
+		specificity[0]++
+	}
+	specificity[1] += len(compound.classes) + len(compound.attributes)
+	if compound.tag != "" {
+		specificity[2]++
+	}
+	if compound.pseudoElement != "" {
+		specificity[2]++
+	}
+
+	for _, pseudo := range compound.pseudoClasses {
+		switch pseudo.name {
+		case "where":
+		case "not", "is", "matches", "-webkit-any", "-moz-any":
+			var highest [3]int
+			for _, argument := range pseudo.arguments {
+				if compareSpecificity(argument.specificity, highest) > 0 {
+					highest = argument.specificity
+				}
+			}
+			for i := range highest {
+				specificity[i] += highest[i]
+			}
+		default:
+			specificity[1]++
+		}
+	}
+	return
+}
+
+func (complex *complexSelector) match(node *html.Node) bool {
+	return complex.matchAt(node, len(complex.compounds)-1)
+}
+
+func (complex *complexSelector) matchAt(node *html.Node, i int) bool {
+	if !complex.compounds[i].match(node) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+
+	switch complex.combinators[i-1] {
+	case '>':
+		parent := parentElement(node)
+		return parent != nil && complex.matchAt(parent, i-1)
+	case '+':
+		sibling := previousElement(node)
+		return sibling != nil && complex.matchAt(sibling, i-1)
+	case '~':
+		for sibling := previousElement(node); sibling != nil; sibling = previousElement(sibling) {
+			if complex.matchAt(sibling, i-1) {
+				return true
+			}
+		}
+	default:
+		for ancestor := parentElement(node); ancestor != nil; ancestor = parentElement(ancestor) {
+			if complex.matchAt(ancestor, i-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (compound *compoundSelector) match(node *html.Node) bool {
+	if node == nil || node.Type != html.ElementNode || compound.pseudoElement != "" {
+		return false
+	}
+	if compound.tag != "" && !strings.EqualFold(node.Data, compound.tag) {
+		return false
+	}
+	if compound.id != "" && getAttribute(node, "id") != compound.id {
+		return false
+	}
+
+	classes := strings.Fields(getAttribute(node, "class"))
+	for _, class := range compound.classes {
+		if !slices.Contains(classes, class) {
+			return false
+		}
+	}
+
+	for _, attribute := range compound.attributes {
+		if !attribute.match(node) {
+			return false
+		}
+	}
+	for _, pseudo := range compound.pseudoClasses {
+		if !pseudo.match(node) {
+			return false
+		}
+	}
+	return true
+}
+
+func (attribute *attributeSelector) match(node *html.Node) bool {
+	i := slices.IndexFunc(node.Attr, func(attr html.Attribute) bool {
+		key := attr.Key
+		if attr.Namespace != "" {
+			key = attr.Namespace + ":" + key
+		}
+		return strings.EqualFold(key, attribute.name)
+	})
+	if i < 0 {
+		return false
+	}
+
+	value, expected := node.Attr[i].Val, attribute.value
+	if attribute.insensitive {
+		value, expected = strings.ToLower(value), strings.ToLower(expected)
+	}
+
+	switch attribute.operator {
+	case "":
+		return true
+	case "=":
+		return value == expected
+	case "~=":
+		return slices.Contains(strings.Fields(value), expected)
+	case "|=":
+		return value == expected || strings.HasPrefix(value, expected+"-")
+	case "^=":
+		return expected != "" && strings.HasPrefix(value, expected)
+	case "$=":
+		return expected != "" && strings.HasSuffix(value, expected)
+	case "*=":
+		return expected != "" && strings.Contains(value, expected)
+	}
+	return false
+}
+
+func (pseudo *pseudoClass) match(node *html.Node) bool {
+	switch pseudo.name {
+	case "root":
+		return node.Parent != nil && node.Parent.Type == html.DocumentNode
+	case "empty":
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode || child.Type == html.TextNode && child.Data != "" {
+				return false
+			}
+		}
+		return true
+	case "first-child":
+		return previousElement(node) == nil
+	case "last-child":
+		return nextElement(node) == nil
+	case "only-child":
+		return previousElement(node) == nil && nextElement(node) == nil
+	case "first-of-type":
+		return elementPosition(node, false, true) == 1
+	case "last-of-type":
+		return elementPosition(node, true, true) == 1
+	case "only-of-type":
+		return elementPosition(node, false, true) == 1 && elementPosition(node, true, true) == 1
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		position := elementPosition(node, strings.Contains(pseudo.name, "last"), strings.HasSuffix(pseudo.name, "of-type"))
+		if pseudo.a == 0 {
+			return position == pseudo.b
+		}
+		n := position - pseudo.b
+		return n/pseudo.a >= 0 && n%pseudo.a == 0
+	case "link", "any-link":
+		return (node.Data == "a" || node.Data == "area") && getAttribute(node, "href") != ""
+	case "not":
+		for _, argument := range pseudo.arguments {
+			if argument.match(node) {
+				return false
+			}
+		}
+		return true
+	case "is", "matches", "where", "-webkit-any", "-moz-any":
+		for _, argument := range pseudo.arguments {
+			if argument.match(node) {
+				return true
+			}
+		}
+		return false
+	case "lang":
+		for element := node; element != nil; element = parentElement(element) {
+			for _, attr := range element.Attr {
+				if attr.Key == "lang" && (attr.Namespace == "" || attr.Namespace == "xml") || attr.Key == "xml:lang" {
+					language := strings.ToLower(attr.Val)
+					return language == pseudo.language || strings.HasPrefix(language, pseudo.language+"-")
+				}
+			}
+		}
+		return false
+	}
+	// Dynamic and form pseudo-classes do not apply to static documents.
+	return false
+}
+
+func parentElement(node *html.Node) *html.Node {
+	if node.Parent != nil && node.Parent.Type == html.ElementNode {
+		return node.Parent
+	}
+	return nil
+}
+
+func previousElement(node *html.Node) *html.Node {
+	for sibling := node.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+		if sibling.Type == html.ElementNode {
+			return sibling
+		}
+	}
+	return nil
+}
+
+func nextElement(node *html.Node) *html.Node {
+	for sibling := node.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type == html.ElementNode {
+			return sibling
+		}
+	}
+	return nil
+}
+
+// elementPosition returns the 1-based position of an element among its
+// element siblings, from the end when fromEnd is set, counting only the
+// siblings with the same name when ofType is set.
+func elementPosition(node *html.Node, fromEnd bool, ofType bool) int {
+	position := 1
+	sibling := previousElement
+	if fromEnd {
+		sibling = nextElement
+	}
+	for other := sibling(node); other != nil; other = sibling(other) {
+		if !ofType || other.Data == node.Data {
+			position++
+		}
+	}
+	return position
+}
@@ -0,0 +1,96 @@
+package epub
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const testSelectorDocument = `<html xmlns:epub="http://www.idpf.org/2007/ops" lang="en-GB"><head><title>T</title></head>
+<body><section id="main" class="chapter first" epub:type="chapter">
+<h1>Title</h1>
+<p class="lead">One <em>two</em></p>
+<p>Three</p>
+<p lang="fr" data-note="a b">Quatre</p>
+<div></div>
+<a href="#x">link</a>
+</section></body></html>`
+
+func selectorNames(nodes []*html.Node) string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Data+":"+GetTextContent(node))
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSelector_FindAll(t *testing.T) {
+	doc, err := parseXHTML([]byte(testSelectorDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"p":                         "p:One two,p:Three,p:Quatre",
+		"#main > h1":                "h1:Title",
+		"section.chapter.first em":  "em:two",
+		".lead + p":                 "p:Three",
+		"h1 ~ p:not(.lead)":         "p:Three,p:Quatre",
+		"p:nth-child(2n+1)":         "p:Three",
+		"p:nth-of-type(odd)":        "p:One two,p:Quatre",
+		"p:last-of-type":            "p:Quatre",
+		"[epub|type~=chapter] > h1": "h1:Title",
+		`[data-note~="b"]`:          "p:Quatre",
+		"p:lang(fr)":                "p:Quatre",
+		"h1:lang(en)":               "h1:Title",
+		"div:empty":                 "div:",
+		"a:link, h1":                "h1:Title,a:link",
+		":is(h1, em)":               "h1:Title,em:two",
+		"P.LEAD":                    "",
+		"p::first-line":             "",
+		"a:hover":                   "",
+	}
+	for selector, expected := range tests {
+		s, err := ParseSelector(selector)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", selector, err)
+			continue
+		}
+		if got := selectorNames(s.FindAll(doc)); got != expected {
+			t.Errorf("%s: expected %q, got %q", selector, expected, got)
+		}
+	}
+
+	for _, selector := range []string{"", "p >", "p[", "p:unknown", "p,,a"} {
+		if _, err := ParseSelector(selector); err == nil {
+			t.Errorf("%q: expected error", selector)
+		}
+	}
+}
+
+func TestSelector_Specificity(t *testing.T) {
+	doc, err := parseXHTML([]byte(testSelectorDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lead := FindNode(doc, func(n *html.Node) bool { return getAttribute(n, "class") == "lead" })
+
+	tests := map[string][3]int{
+		"p":                         {0, 0, 1},
+		"#main p.lead":              {1, 1, 1},
+		"p, .lead":                  {0, 1, 0},
+		":where(#main) p":           {0, 0, 1},
+		":not(#x, .lead) p":         {1, 0, 1},
+		"section > :is(p.lead, #x)": {1, 0, 1},
+	}
+	for selector, expected := range tests {
+		s, err := ParseSelector(selector)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", selector, err)
+		}
+		if specificity, matched := s.Specificity(lead); !matched || specificity != expected {
+			t.Errorf("%s: expected %v, got %v %v", selector, expected, specificity, matched)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package epub
+
+import (
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+)
+
+// Semantic is a term of the EPUB Structural Semantics Vocabulary used in
+// epub:type attributes. Terms of other vocabularies are prefixed, such as
+// "z3998:poem", or absolute IRIs.
+type Semantic string
+
+// Terms of the EPUB Structural Semantics Vocabulary.
+const (
+	// Document partitions
+	SemanticCover       Semantic = "cover"
+	SemanticFrontmatter Semantic = "frontmatter"
+	SemanticBodymatter  Semantic = "bodymatter"
+	SemanticBackmatter  Semantic = "backmatter"
+
+	// Document divisions
+	SemanticVolume   Semantic = "volume"
+	SemanticPart     Semantic = "part"
+	SemanticChapter  Semantic = "chapter"
+	SemanticDivision Semantic = "division"
+
+	// Document sections and components
+	SemanticAbstract     Semantic = "abstract"
+	SemanticForeword     Semantic = "foreword"
+	SemanticPreface      Semantic = "preface"
+	SemanticPrologue     Semantic = "prologue"
+	SemanticIntroduction Semantic = "introduction"
+	SemanticPreamble     Semantic = "preamble"
+	SemanticConclusion   Semantic = "conclusion"
+	SemanticEpilogue     Semantic = "epilogue"
+	SemanticAfterword    Semantic = "afterword"
+	SemanticEpigraph     Semantic = "epigraph"
+
+	// Document navigation
+	SemanticTOC       Semantic = "toc"
+	SemanticTOCBrief  Semantic = "toc-brief"
+	SemanticLandmarks Semantic = "landmarks"
+	SemanticLOA       Semantic = "loa"
+	SemanticLOI       Semantic = "loi"
+	SemanticLOT       Semantic = "lot"
+	SemanticLOV       Semantic = "lov"
+	SemanticPageList  Semantic = "page-list"
+
+	// Reference sections
+	SemanticAppendix     Semantic = "appendix"
+	SemanticColophon     Semantic = "colophon"
+	SemanticCredits      Semantic = "credits"
+	SemanticKeywords     Semantic = "keywords"
+	SemanticIndex        Semantic = "index"
+	SemanticGlossary     Semantic = "glossary"
+	SemanticGlossTerm    Semantic = "glossterm"
+	SemanticGlossDef     Semantic = "glossdef"
+	SemanticBibliography Semantic = "bibliography"
+	SemanticBiblioEntry  Semantic = "biblioentry"
+
+	// Preliminary sections and components
+	SemanticTitlePage       Semantic = "titlepage"
+	SemanticHalfTitlePage   Semantic = "halftitlepage"
+	SemanticCopyrightPage   Semantic = "copyright-page"
+	SemanticSeriesPage      Semantic = "seriespage"
+	SemanticAcknowledgments Semantic = "acknowledgments"
+	SemanticImprint         Semantic = "imprint"
+	SemanticImprimatur      Semantic = "imprimatur"
+	SemanticContributors    Semantic = "contributors"
+	SemanticOtherCredits    Semantic = "other-credits"
+	SemanticErrata          Semantic = "errata"
+	SemanticDedication      Semantic = "dedication"
+	SemanticRevisionHistory Semantic = "revision-history"
+
+	// Complementary content
+	SemanticCaseStudy Semantic = "case-study"
+	SemanticNotice    Semantic = "notice"
+	SemanticPullquote Semantic = "pullquote"
+	SemanticTip       Semantic = "tip"
+	SemanticWarning   Semantic = "warning"
+
+	// Titles and headings
+	SemanticHalfTitle  Semantic = "halftitle"
+	SemanticFullTitle  Semantic = "fulltitle"
+	SemanticCoverTitle Semantic = "covertitle"
+	SemanticTitle      Semantic = "title"
+	SemanticSubtitle   Semantic = "subtitle"
+	SemanticLabel      Semantic = "label"
+	SemanticOrdinal    Semantic = "ordinal"
+	SemanticBridgehead Semantic = "bridgehead"
+
+	// Notes and references
+	SemanticFootnote  Semantic = "footnote"
+	SemanticEndnote   Semantic = "endnote"
+	SemanticFootnotes Semantic = "footnotes"
+	SemanticEndnotes  Semantic = "endnotes"
+	SemanticNoteref   Semantic = "noteref"
+	SemanticBacklink  Semantic = "backlink"
+	SemanticBiblioref Semantic = "biblioref"
+	SemanticGlossref  Semantic = "glossref"
+
+	// Document text
+	SemanticCredit    Semantic = "credit"
+	SemanticKeyword   Semantic = "keyword"
+	SemanticPagebreak Semantic = "pagebreak"
+
+	// Educational content
+	SemanticAssessment Semantic = "assessment"
+	SemanticQnA        Semantic = "qna"
+	SemanticQuestion   Semantic = "question"
+	SemanticAnswer     Semantic = "answer"
+
+	// Comics
+	SemanticPanel      Semantic = "panel"
+	SemanticPanelGroup Semantic = "panel-group"
+	SemanticBalloon    Semantic = "balloon"
+	SemanticTextArea   Semantic = "text-area"
+	SemanticSoundArea  Semantic = "sound-area"
+)
+
+// ARIARole is a role of the Digital Publishing WAI-ARIA Module, the HTML
+// role attribute counterpart of epub:type.
+type ARIARole string
+
+// Roles of the Digital Publishing WAI-ARIA Module.
+const (
+	RoleDocAbstract        ARIARole = "doc-abstract"
+	RoleDocAcknowledgments ARIARole = "doc-acknowledgments"
+	RoleDocAfterword       ARIARole = "doc-afterword"
+	RoleDocAppendix        ARIARole = "doc-appendix"
+	RoleDocBacklink        ARIARole = "doc-backlink"
+	RoleDocBiblioEntry     ARIARole = "doc-biblioentry"
+	RoleDocBibliography    ARIARole = "doc-bibliography"
+	RoleDocBiblioref       ARIARole = "doc-biblioref"
+	RoleDocChapter         ARIARole = "doc-chapter"
+	RoleDocColophon        ARIARole = "doc-colophon"
+	RoleDocConclusion      ARIARole = "doc-conclusion"
+	RoleDocCover           ARIARole = "doc-cover"
+	RoleDocCredit          ARIARole = "doc-credit"
+	RoleDocCredits         ARIARole = "doc-credits"
+	RoleDocDedication      ARIARole = "doc-dedication"
+	RoleDocEndnote         ARIARole = "doc-endnote"
+	RoleDocEndnotes        ARIARole = "doc-endnotes"
+	RoleDocEpigraph        ARIARole = "doc-epigraph"
+	RoleDocEpilogue        ARIARole = "doc-epilogue"
+	RoleDocErrata          ARIARole = "doc-errata"
+	RoleDocExample         ARIARole = "doc-example"
+	RoleDocFootnote        ARIARole = "doc-footnote"
+	RoleDocForeword        ARIARole = "doc-foreword"
+	RoleDocGlossary        ARIARole = "doc-glossary"
+	RoleDocGlossref        ARIARole = "doc-glossref"
+	RoleDocIndex           ARIARole = "doc-index"
+	RoleDocIntroduction    ARIARole = "doc-introduction"
+	RoleDocNoteref         ARIARole = "doc-noteref"
+	RoleDocNotice          ARIARole = "doc-notice"
+	RoleDocPagebreak       ARIARole = "doc-pagebreak"
+	RoleDocPagefooter      ARIARole = "doc-pagefooter"
+	RoleDocPageheader      ARIARole = "doc-pageheader"
+	RoleDocPageList        ARIARole = "doc-pagelist"
+	RoleDocPart            ARIARole = "doc-part"
+	RoleDocPreface         ARIARole = "doc-preface"
+	RoleDocPrologue        ARIARole = "doc-prologue"
+	RoleDocPullquote       ARIARole = "doc-pullquote"
+	RoleDocQnA             ARIARole = "doc-qna"
+	RoleDocSubtitle        ARIARole = "doc-subtitle"
+	RoleDocTip             ARIARole = "doc-tip"
+	RoleDocTOC             ARIARole = "doc-toc"
+)
+
+var ariaRoles = []ARIARole{
+	RoleDocAbstract, RoleDocAcknowledgments, RoleDocAfterword, RoleDocAppendix, RoleDocBacklink,
+	RoleDocBiblioEntry, RoleDocBibliography, RoleDocBiblioref, RoleDocChapter, RoleDocColophon,
+	RoleDocConclusion, RoleDocCover, RoleDocCredit, RoleDocCredits, RoleDocDedication,
+	RoleDocEndnote, RoleDocEndnotes, RoleDocEpigraph, RoleDocEpilogue, RoleDocErrata,
+	RoleDocExample, RoleDocFootnote, RoleDocForeword, RoleDocGlossary, RoleDocGlossref,
+	RoleDocIndex, RoleDocIntroduction, RoleDocNoteref, RoleDocNotice, RoleDocPagebreak,
+	RoleDocPagefooter, RoleDocPageheader, RoleDocPageList, RoleDocPart, RoleDocPreface,
+	RoleDocPrologue, RoleDocPullquote, RoleDocQnA, RoleDocSubtitle, RoleDocTip, RoleDocTOC,
+}
+
+// ariaSemantics maps the roles whose name differs from their structural
+// semantics term. Other roles are the term prefixed with "doc-", and
+// roles without a term, such as doc-example, map to nothing.
+var ariaSemantics = map[ARIARole]Semantic{
+	RoleDocPageList:   SemanticPageList,
+	RoleDocExample:    "",
+	RoleDocPagefooter: "",
+	RoleDocPageheader: "",
+}
+
+// Semantic returns the structural semantics term equivalent to the role,
+// or an empty string when there is none.
+func (role ARIARole) Semantic() Semantic {
+	if semantic, found := ariaSemantics[role]; found {
+		return semantic
+	}
+	if !slices.Contains(ariaRoles, role) {
+		return ""
+	}
+	return Semantic(strings.TrimPrefix(string(role), "doc-"))
+}
+
+// Role returns the DPUB-ARIA role equivalent to the term, or an empty
+// string when there is none.
+func (s Semantic) Role() ARIARole {
+	for _, role := range ariaRoles {
+		if s != "" && role.Semantic() == s {
+			return role
+		}
+	}
+	return ""
+}
+
+// structureVocabulary is the IRI of the structural semantics vocabulary,
+// the default vocabulary of epub:type.
+const structureVocabulary = "http://idpf.org/epub/vocab/structure/#"
+
+// reservedSemanticPrefixes are the prefixes usable in epub:type without an
+// epub:prefix declaration.
+var reservedSemanticPrefixes = map[string]string{
+	"msv":   "http://www.idpf.org/epub/vocab/structure/magazine/#",
+	"prism": "http://www.prismstandard.org/specifications/3.0/PRISM_CV_Spec_3.0.htm#",
+	"z3998": "http://www.daisy.org/z3998/2012/vocab/structure/#",
+}
+
+// SemanticElement is an element of a content document carrying a
+// structural semantic.
+type SemanticElement struct {
+	Node *html.Node `json:"-"`
+
+	// Types are the epub:type values of the element and Role its role
+	// attribute.
+	Types []string `json:"types,omitempty"`
+	Role  string   `json:"role,omitempty"`
+
+	// Href is the content document, relative to the package document.
+	// Fragment is the id of the element, when it has one.
+	Href       string `json:"href"`
+	Fragment   string `json:"fragment,omitempty"`
+	ResourceID string `json:"resourceId"`
+	SpineIndex int    `json:"spineIndex"`
+}
+
+// FindBySemantics returns the elements of the spine content documents
+// carrying any of the given semantics, in reading order. Values of the
+// space separated epub:type attribute are matched after resolving their
+// prefixes, and DPUB-ARIA roles are treated as their equivalent terms.
+func (r *Reader) FindBySemantics(semantics ...Semantic) (elements []SemanticElement) {
+	packageDir := path.Dir(r.CurrentSelectedPackagePath())
+
+	for i, itemRef := range r.CurrentSelectedPackage().Spine.ItemRefs {
+		res := r.SelectResourceById(itemRef.IDRef)
+		if res == nil || res.MIMEType != pkg.MediaTypeXHTML {
+			continue
+		}
+
+		doc, err := parseXHTML(res.Content)
+		if err != nil {
+			continue
+		}
+		prefixes := semanticPrefixes(doc)
+
+		for n := range doc.Descendants() {
+			if n.Type != html.ElementNode || !hasAnySemantic(n, prefixes, semantics) {
+				continue
+			}
+			elements = append(elements, SemanticElement{
+				Node:       n,
+				Types:      strings.Fields(getAttribute(n, "epub:type")),
+				Role:       getAttribute(n, "role"),
+				Href:       relativeHref(packageDir, unescapePath(res.Filepath), ""),
+				Fragment:   getAttribute(n, "id"),
+				ResourceID: res.ID,
+				SpineIndex: i,
+			})
+		}
+	}
+	return
+}
+
+// HasSemantic reports whether node carries the semantic in its epub:type
+// or role attribute. Prefixes are resolved with the epub:prefix attribute
+// of the document root.
+func HasSemantic(node *html.Node, semantic Semantic) bool {
+	root := node
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	return hasAnySemantic(node, semanticPrefixes(root), []Semantic{semantic})
+}
+
+func hasAnySemantic(node *html.Node, prefixes map[string]string, semantics []Semantic) bool {
+	if node.Type != html.ElementNode {
+		return false
+	}
+
+	types := strings.Fields(getAttribute(node, "epub:type"))
+	for _, role := range strings.Fields(getAttribute(node, "role")) {
+		if semantic := ARIARole(role).Semantic(); semantic != "" {
+			types = append(types, string(semantic))
+		}
+	}
+
+	for _, value := range types {
+		iri := resolveSemantic(value, prefixes)
+		for _, semantic := range semantics {
+			if iri == resolveSemantic(string(semantic), prefixes) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveSemantic expands an epub:type value into an IRI. Unprefixed
+// values are terms of the structural semantics vocabulary; values with an
+// unknown prefix are left untouched.
+func resolveSemantic(value string, prefixes map[string]string) string {
+	prefix, reference, found := strings.Cut(value, ":")
+	if !found {
+		return structureVocabulary + value
+	}
+	if iri, found := prefixes[prefix]; found {
+		return iri + reference
+	}
+	return value
+}
+
+// semanticPrefixes returns the reserved prefixes together with the ones
+// declared by the epub:prefix attribute of the html element of doc.
+func semanticPrefixes(doc *html.Node) map[string]string {
+	prefixes := maps.Clone(reservedSemanticPrefixes)
+
+	root := FindNode(doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "html"
+	})
+	if root == nil {
+		return prefixes
+	}
+
+	fields := strings.Fields(getAttribute(root, "epub:prefix"))
+	for i := 0; i+1 < len(fields); i += 2 {
+		if prefix, found := strings.CutSuffix(fields[i], ":"); found {
+			prefixes[prefix] = fields[i+1]
+		}
+	}
+	return prefixes
+}
@@ -0,0 +1,75 @@
+package epub
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestReader_FindBySemantics(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/text/chapter-1.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" epub:prefix="z: http://www.daisy.org/z3998/2012/vocab/structure/#"><head><title>Chapter 1</title></head>
+<body>
+<section epub:type="bodymatter chapter" id="c1">
+  <blockquote epub:type="epigraph"><p>Quote</p></blockquote>
+  <p epub:type="z:poem">Verse</p>
+</section>
+</body></html>`
+	files["OEBPS/text/chapter-2.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter 2</title></head>
+<body>
+<section role="doc-chapter"><p role="doc-epigraph">Another quote</p></section>
+<section role="doc-example" epub:type="colophon"><p>Set in Go.</p></section>
+</body></html>`
+
+	r := newTestReader(t, files)
+
+	chapters := r.FindBySemantics(SemanticChapter)
+	if len(chapters) != 2 {
+		t.Fatalf("expected two chapters, got %+v", chapters)
+	}
+	if c := chapters[0]; c.Fragment != "c1" || c.Href != "text/chapter-1.xhtml" || c.ResourceID != "chapter-1" || c.SpineIndex != 0 || len(c.Types) != 2 {
+		t.Errorf("unexpected chapter %+v", c)
+	}
+	if c := chapters[1]; c.Role != "doc-chapter" || c.SpineIndex != 1 || c.Node.Data != "section" {
+		t.Errorf("unexpected chapter %+v", c)
+	}
+
+	if elements := r.FindBySemantics(SemanticEpigraph, SemanticColophon); len(elements) != 3 {
+		t.Errorf("expected epigraphs and colophon, got %+v", elements)
+	}
+
+	for _, query := range []Semantic{"z3998:poem", "http://www.daisy.org/z3998/2012/vocab/structure/#poem"} {
+		if poems := r.FindBySemantics(query); len(poems) != 1 || GetTextContent(poems[0].Node) != "Verse" {
+			t.Errorf("expected a poem for %q, got %+v", query, poems)
+		}
+	}
+	if poems := r.FindBySemantics("poem"); len(poems) != 0 {
+		t.Errorf("expected the unprefixed term to be another vocabulary, got %+v", poems)
+	}
+}
+
+func TestSemanticRoles(t *testing.T) {
+	tests := []struct {
+		semantic Semantic
+		role     ARIARole
+	}{
+		{SemanticChapter, RoleDocChapter},
+		{SemanticPageList, RoleDocPageList},
+		{SemanticTOC, RoleDocTOC},
+		{SemanticVolume, ""},
+	}
+	for _, test := range tests {
+		if role := test.semantic.Role(); role != test.role {
+			t.Errorf("expected role %q for %q, got %q", test.role, test.semantic, role)
+		}
+		if test.role != "" && test.role.Semantic() != test.semantic {
+			t.Errorf("expected semantic %q for %q, got %q", test.semantic, test.role, test.role.Semantic())
+		}
+	}
+
+	if semantic := RoleDocExample.Semantic(); semantic != "" {
+		t.Errorf("expected no semantic for doc-example, got %q", semantic)
+	}
+	if semantic := ARIARole("doc-unknown").Semantic(); semantic != "" {
+		t.Errorf("expected no semantic for an unknown role, got %q", semantic)
+	}
+}
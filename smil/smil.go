@@ -0,0 +1,266 @@
+package smil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NamespaceEPUB is the namespace of the epub:type and epub:textref
+// attributes.
+const NamespaceEPUB = "http://www.idpf.org/2007/ops"
+
+// SMIL represents a media overlay document.
+type SMIL struct {
+	XMLName xml.Name `xml:"http://www.w3.org/ns/SMIL smil"`
+	ID      string   `xml:"id,attr,omitempty"`
+	Version string   `xml:"version,attr"`
+	Body    Seq      `xml:"body"`
+}
+
+// Seq represents a seq element, or the body element, grouping pars and
+// nested seqs in playback order.
+type Seq struct {
+	ID      string
+	Type    string
+	TextRef string
+	Items   []Item
+}
+
+// Item is either a Seq or a Par.
+type Item struct {
+	Seq *Seq
+	Par *Par
+}
+
+// Par represents a par element synchronizing a text fragment with an
+// audio clip.
+type Par struct {
+	ID    string `xml:"id,attr,omitempty"`
+	Type  string `xml:"http://www.idpf.org/2007/ops type,attr,omitempty"`
+	Text  Text   `xml:"text"`
+	Audio *Audio `xml:"audio,omitempty"`
+}
+
+// Text references the content document fragment of a par.
+type Text struct {
+	ID  string `xml:"id,attr,omitempty"`
+	Src string `xml:"src,attr"`
+}
+
+// Audio references a clip of an audio file. ClipBegin and ClipEnd are
+// SMIL clock values.
+type Audio struct {
+	ID        string `xml:"id,attr,omitempty"`
+	Src       string `xml:"src,attr"`
+	ClipBegin string `xml:"clipBegin,attr,omitempty"`
+	ClipEnd   string `xml:"clipEnd,attr,omitempty"`
+}
+
+// Parse decodes a SMIL media overlay document.
+func Parse(data []byte) (smil *SMIL, err error) {
+	err = xml.Unmarshal(data, &smil)
+	return
+}
+
+// UnmarshalXML decodes the children of a seq or body element keeping the
+// order of pars and seqs.
+func (s *Seq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Local == "id" && attr.Name.Space == "":
+			s.ID = attr.Value
+		case attr.Name.Local == "type" && attr.Name.Space == NamespaceEPUB:
+			s.Type = attr.Value
+		case attr.Name.Local == "textref" && attr.Name.Space == NamespaceEPUB:
+			s.TextRef = attr.Value
+		}
+	}
+
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "seq":
+				seq := &Seq{}
+				if err := d.DecodeElement(seq, &t); err != nil {
+					return err
+				}
+				s.Items = append(s.Items, Item{Seq: seq})
+			case "par":
+				par := &Par{}
+				if err := d.DecodeElement(par, &t); err != nil {
+					return err
+				}
+				s.Items = append(s.Items, Item{Par: par})
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// ParseClockValue parses a SMIL clock value, such as "0:01:02.5",
+// "01:02.5", "62.5s", "1500ms", "2min", "1h" or "62.5".
+func ParseClockValue(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("empty clock value")
+	}
+
+	if strings.Contains(value, ":") {
+		parts := strings.Split(value, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("invalid clock value %q", value)
+		}
+
+		var total float64
+		for i, part := range parts {
+			number, err := strconv.ParseFloat(part, 64)
+			if err != nil || number < 0 || (i < len(parts)-1 && strings.Contains(part, ".")) {
+				return 0, fmt.Errorf("invalid clock value %q", value)
+			}
+			total = total*60 + number
+		}
+		return seconds(total), nil
+	}
+
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"ms", 0.001},
+		{"min", 60},
+		{"h", 3600},
+		{"s", 1},
+	}
+
+	scale := 1.0
+	for _, unit := range units {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, scale = number, unit.scale
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid clock value %q", value)
+	}
+	return seconds(number * scale), nil
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value*float64(time.Second) + 0.5)
+}
+
+// MarshalXML encodes a seq, or the body element, with its pars and nested
+// seqs in order.
+func (s Seq) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = appendAttrs(start.Attr, "id", s.ID, "epub:type", s.Type, "epub:textref", s.TextRef)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, item := range s.Items {
+		var err error
+		switch {
+		case item.Seq != nil:
+			err = e.EncodeElement(item.Seq, xml.StartElement{Name: xml.Name{Local: "seq"}})
+		case item.Par != nil:
+			err = e.EncodeElement(item.Par, xml.StartElement{Name: xml.Name{Local: "par"}})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML encodes a par using the epub prefix for its type.
+func (p Par) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = appendAttrs(start.Attr, "id", p.ID, "epub:type", p.Type)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if err := e.EncodeElement(p.Text, xml.StartElement{Name: xml.Name{Local: "text"}}); err != nil {
+		return err
+	}
+	if p.Audio != nil {
+		if err := e.EncodeElement(p.Audio, xml.StartElement{Name: xml.Name{Local: "audio"}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// appendAttrs appends the non-empty values of name/value pairs.
+func appendAttrs(attrs []xml.Attr, pairs ...string) []xml.Attr {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: pairs[i]}, Value: pairs[i+1]})
+		}
+	}
+	return attrs
+}
+
+// Marshal encodes a media overlay document with the SMIL and EPUB
+// namespaces declared on its root.
+func Marshal(smil *SMIL) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	e := xml.NewEncoder(&buf)
+	e.Indent("", "  ")
+
+	version := smil.Version
+	if version == "" {
+		version = "3.0"
+	}
+	start := xml.StartElement{
+		Name: xml.Name{Local: "smil"},
+		Attr: appendAttrs(nil,
+			"xmlns", "http://www.w3.org/ns/SMIL",
+			"xmlns:epub", NamespaceEPUB,
+			"id", smil.ID,
+			"version", version,
+		),
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return nil, err
+	}
+	if err := e.EncodeElement(smil.Body, xml.StartElement{Name: xml.Name{Local: "body"}}); err != nil {
+		return nil, err
+	}
+	if err := e.EncodeToken(start.End()); err != nil {
+		return nil, err
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FormatClockValue formats a duration as a full SMIL clock value with
+// millisecond precision, such as "0:01:02.500".
+func FormatClockValue(d time.Duration) string {
+	d = d.Round(time.Millisecond)
+	hours := d / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	secs := (d % time.Minute) / time.Second
+	millis := (d % time.Second) / time.Millisecond
+	return fmt.Sprintf("%d:%02d:%02d.%03d", hours, minutes, secs, millis)
+}
@@ -0,0 +1,101 @@
+package smil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<smil xmlns="http://www.w3.org/ns/SMIL" xmlns:epub="http://www.idpf.org/2007/ops" version="3.0">
+  <head><metadata/></head>
+  <body epub:textref="chapter.xhtml">
+    <par id="p1"><text src="chapter.xhtml#h1"/><audio src="audio/chapter.mp3" clipBegin="0s" clipEnd="2.5s"/></par>
+    <seq id="s1" epub:type="aside" epub:textref="chapter.xhtml#aside">
+      <par id="p2"><text src="chapter.xhtml#w1"/><audio src="audio/chapter.mp3" clipBegin="2.5s" clipEnd="3s"/></par>
+    </seq>
+    <par id="p3" epub:type="pagebreak"><text src="chapter.xhtml#pg1"/></par>
+  </body>
+</smil>`)
+
+	doc, err := Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if doc.Version != "3.0" || doc.Body.TextRef != "chapter.xhtml" || len(doc.Body.Items) != 3 {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+
+	first := doc.Body.Items[0].Par
+	if first == nil || first.Text.Src != "chapter.xhtml#h1" || first.Audio.ClipEnd != "2.5s" {
+		t.Errorf("unexpected first par %+v", first)
+	}
+
+	seq := doc.Body.Items[1].Seq
+	if seq == nil || seq.Type != "aside" || seq.TextRef != "chapter.xhtml#aside" || len(seq.Items) != 1 || seq.Items[0].Par.ID != "p2" {
+		t.Errorf("unexpected seq %+v", seq)
+	}
+
+	last := doc.Body.Items[2].Par
+	if last == nil || last.Type != "pagebreak" || last.Audio != nil {
+		t.Errorf("unexpected last par %+v", last)
+	}
+}
+
+func TestParseClockValue(t *testing.T) {
+	tests := map[string]time.Duration{
+		"0:01:02.5": 62500 * time.Millisecond,
+		"01:02.5":   62500 * time.Millisecond,
+		"1:00:00":   time.Hour,
+		"62.5s":     62500 * time.Millisecond,
+		"1500ms":    1500 * time.Millisecond,
+		"2min":      2 * time.Minute,
+		"1.5h":      90 * time.Minute,
+		"3.25":      3250 * time.Millisecond,
+	}
+
+	for value, expected := range tests {
+		got, err := ParseClockValue(value)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", value, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("%s: expected %v, got %v", value, expected, got)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "1:2:3:4", "-1s", "1.5:00"} {
+		if _, err := ParseClockValue(value); err == nil {
+			t.Errorf("%q: expected error", value)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	doc := &SMIL{Body: Seq{TextRef: "chapter.xhtml", Items: []Item{
+		{Par: &Par{ID: "p1", Text: Text{Src: "chapter.xhtml#s1"}, Audio: &Audio{Src: "a.mp3", ClipBegin: FormatClockValue(0), ClipEnd: FormatClockValue(62500 * time.Millisecond)}}},
+		{Seq: &Seq{Type: "aside", Items: []Item{{Par: &Par{Type: "pagebreak", Text: Text{Src: "chapter.xhtml#pg"}}}}}},
+	}}}
+
+	data, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, data)
+	}
+
+	if parsed.Version != "3.0" || parsed.Body.TextRef != "chapter.xhtml" || len(parsed.Body.Items) != 2 {
+		t.Fatalf("unexpected document %s", data)
+	}
+	if audio := parsed.Body.Items[0].Par.Audio; audio.ClipEnd != "0:01:02.500" || audio.ClipBegin != "0:00:00.000" {
+		t.Errorf("unexpected audio %+v", audio)
+	}
+	if seq := parsed.Body.Items[1].Seq; seq.Type != "aside" || seq.Items[0].Par.Type != "pagebreak" {
+		t.Errorf("unexpected seq %s", data)
+	}
+}
@@ -0,0 +1,80 @@
+package epub
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+// SpineItem is a spine entry together with the attributes of its itemref.
+type SpineItem struct {
+	PublicationResource
+
+	// ItemRefID is the id attribute of the itemref, if any.
+	ItemRefID string `json:"itemRefId,omitempty"`
+
+	// Index is the position of the itemref in the spine.
+	Index int `json:"index"`
+
+	// Linear is false for items marked linear="no", such as popup
+	// footnotes or answer keys, which are outside the default reading
+	// order.
+	Linear bool `json:"linear"`
+
+	// Properties are the itemref properties, e.g. "page-spread-left".
+	Properties []string `json:"properties,omitempty"`
+
+	// Rendition is the rendition in effect for the item after applying its
+	// overrides, including the page spread.
+	Rendition SpineItemRendition `json:"rendition"`
+}
+
+// SpineItems returns every spine entry with its itemref attributes, in
+// spine order. Itemrefs pointing at missing resources are skipped.
+func (r *Reader) SpineItems() (items []SpineItem) {
+	renditions := r.SpineRenditions()
+	for index, itemRef := range r.CurrentSelectedPackage().Spine.ItemRefs {
+		res := r.SelectResourceById(itemRef.IDRef)
+		if res == nil {
+			continue
+		}
+
+		items = append(items, SpineItem{
+			PublicationResource: *res,
+			ItemRefID:           itemRef.ID,
+			Index:               index,
+			Linear:              strings.TrimSpace(itemRef.Linear) != pkg.LinearNo,
+			Properties:          strings.Fields(itemRef.Properties),
+			Rendition:           renditions[index],
+		})
+	}
+	return
+}
+
+// LinearSpine returns the linear reading order, leaving out spine items
+// marked linear="no".
+func (r *Reader) LinearSpine() (items []SpineItem) {
+	return slices.DeleteFunc(r.SpineItems(), func(item SpineItem) bool {
+		return !item.Linear
+	})
+}
+
+// SpineItemLinear marks the spine item as part of the linear reading
+// order or not.
+func (w *Writer) SpineItemLinear(idref string, linear bool) error {
+	spine := &w.epub.SelectedPackage().Spine
+	index := slices.IndexFunc(spine.ItemRefs, func(itemRef pkg.ItemRef) bool {
+		return itemRef.IDRef == idref
+	})
+	if index < 0 {
+		return fmt.Errorf("spine item %q does not exist", idref)
+	}
+
+	spine.ItemRefs[index].Linear = ""
+	if !linear {
+		spine.ItemRefs[index].Linear = pkg.LinearNo
+	}
+	return nil
+}
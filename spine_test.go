@@ -0,0 +1,61 @@
+package epub
+
+import (
+	"testing"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+func TestWriter_SpineItems(t *testing.T) {
+	w := New("urn:uuid:spine")
+	w.Title("Spine")
+	w.Languages("en")
+	w.Cover([]byte("\x89PNG\r\n\x1a\n"))
+
+	page := func(title string) []byte {
+		return []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>` + title + `</title></head><body><p>` + title + `</p></body></html>`)
+	}
+	w.AddContent("chapter.xhtml", page("Chapter"))
+	w.AddContent("notes.xhtml", page("Notes"))
+	answers := w.AddResource("answers.xhtml", pkg.MediaTypeXHTML, page("Answers"))
+	w.AddSpineItem(answers, pkg.ItemRef{ID: "answers-ref", Linear: pkg.LinearNo, Properties: "page-spread-left"})
+
+	if err := w.SpineItemLinear("notes.xhtml", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.SpineItemLinear("missing", false); err == nil {
+		t.Errorf("expected error for a missing spine item")
+	}
+	if err := w.SpineItemRendition("chapter.xhtml", "layout", pkg.LayoutPrePaginated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.TableOfContents("toc", TOC{Items: []TOC{{Title: "Chapter", Href: "chapter.xhtml"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+
+	items := r.SpineItems()
+	if len(items) != 3 {
+		t.Fatalf("expected three spine items, got %+v", items)
+	}
+
+	chapter := items[0]
+	if !chapter.Linear || chapter.Index != 0 || chapter.Rendition.Layout != pkg.LayoutPrePaginated || len(chapter.Content) == 0 {
+		t.Errorf("unexpected chapter %+v", chapter)
+	}
+
+	if items[1].Linear {
+		t.Errorf("expected notes to be non-linear")
+	}
+
+	last := items[2]
+	if last.Linear || last.ItemRefID != "answers-ref" || last.Rendition.PageSpread != pkg.PageSpreadLeft || len(last.Properties) != 1 {
+		t.Errorf("unexpected answers item %+v", last)
+	}
+
+	linear := r.LinearSpine()
+	if len(linear) != 1 || linear[0].ID != "chapter.xhtml" {
+		t.Errorf("unexpected linear spine %+v", linear)
+	}
+}
@@ -0,0 +1,531 @@
+package epub
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ComputedStyle is the computed value of the properties style inspection
+// supports, for an element.
+type ComputedStyle struct {
+	// Display is the display value, such as "block", "inline" or "none".
+	Display string `json:"display"`
+
+	// FontFamily are the family names in order of preference, unquoted.
+	FontFamily []string `json:"fontFamily,omitempty"`
+
+	// FontStyle is "normal", "italic" or "oblique", and FontWeight the
+	// numeric weight, 400 for normal and 700 for bold.
+	FontStyle  string `json:"fontStyle"`
+	FontWeight int    `json:"fontWeight"`
+
+	TextAlign string `json:"textAlign"`
+
+	// PageBreakBefore, PageBreakAfter and PageBreakInside use the
+	// page-break-* values; break-* declarations are mapped to them.
+	PageBreakBefore string `json:"pageBreakBefore"`
+	PageBreakAfter  string `json:"pageBreakAfter"`
+	PageBreakInside string `json:"pageBreakInside"`
+
+	// Hidden reports whether the element or one of its ancestors has
+	// display none, so that it is not rendered.
+	Hidden bool `json:"hidden,omitempty"`
+
+	// Declared holds the cascaded value of every property declared for the
+	// element, shorthands included, before inheritance.
+	Declared map[string]string `json:"declared,omitempty"`
+}
+
+// IsItalic reports whether the element is rendered in an italic or
+// oblique face.
+func (style *ComputedStyle) IsItalic() bool {
+	return style.FontStyle == "italic" || style.FontStyle == "oblique"
+}
+
+// IsBold reports whether the element is rendered in a bold face.
+func (style *ComputedStyle) IsBold() bool {
+	return style.FontWeight >= 600
+}
+
+var initialStyle = ComputedStyle{
+	Display:         "inline",
+	FontStyle:       "normal",
+	FontWeight:      400,
+	TextAlign:       "start",
+	PageBreakBefore: "auto",
+	PageBreakAfter:  "auto",
+	PageBreakInside: "auto",
+}
+
+// userAgentCSS is the subset of the default style sheet of reading systems
+// needed for the supported properties.
+const userAgentCSS = `
+head, script, style, title, meta, link, base, template, noscript, datalist, param, area, [hidden] { display: none }
+html, body, address, article, aside, blockquote, center, details, dialog, dd, div, dl, dt,
+fieldset, figcaption, figure, footer, form, h1, h2, h3, h4, h5, h6, header, hgroup, hr,
+legend, main, menu, nav, ol, p, pre, section, summary, ul { display: block }
+li { display: list-item }
+table { display: table }
+caption { display: table-caption }
+colgroup { display: table-column-group }
+col { display: table-column }
+thead { display: table-header-group }
+tbody { display: table-row-group }
+tfoot { display: table-footer-group }
+tr { display: table-row }
+td, th { display: table-cell }
+address, cite, dfn, em, i, var { font-style: italic }
+b, strong, th, h1, h2, h3, h4, h5, h6 { font-weight: bolder }
+center, th { text-align: center }
+`
+
+// userAgentRules are the compiled rules of userAgentCSS.
+var userAgentRules = compileStyleRules([]*StyleSheet{ParseCSS(userAgentCSS)}, false)
+
+// inheritedProperties are the supported properties inherited by default.
+var inheritedProperties = []string{"font-family", "font-style", "font-weight", "text-align"}
+
+// StyledDocument computes the style of the elements of a content document
+// from its style sheets.
+type StyledDocument struct {
+	Document    *html.Node
+	StyleSheets []*StyleSheet
+
+	rules  []styleRule
+	styles map[*html.Node]*ComputedStyle
+}
+
+// styleRule is a rule with its selector parsed, in cascade order.
+type styleRule struct {
+	selector     *Selector
+	declarations []CSSDeclaration
+	author       bool
+	order        int
+}
+
+// NewStyledDocument returns a StyledDocument for a parsed content document
+// and its style sheets in cascade order, as StyleSheets returns them.
+// Style sheets and rules whose media does not apply to screens are left
+// out, and so are rules with unsupported selectors.
+func NewStyledDocument(doc *html.Node, sheets []*StyleSheet) *StyledDocument {
+	return &StyledDocument{
+		Document:    doc,
+		StyleSheets: sheets,
+		rules:       compileStyleRules(sheets, true),
+		styles:      make(map[*html.Node]*ComputedStyle),
+	}
+}
+
+func compileStyleRules(sheets []*StyleSheet, author bool) (rules []styleRule) {
+	for _, sheet := range sheets {
+		if !mediaApplies(sheet.Media) {
+			continue
+		}
+		for _, rule := range sheet.Rules {
+			if !mediaApplies(rule.Media) {
+				continue
+			}
+			selector, err := ParseSelector(rule.Selector)
+			if err != nil {
+				continue
+			}
+			rules = append(rules, styleRule{selector: selector, declarations: rule.Declarations, author: author, order: len(rules)})
+		}
+	}
+	return
+}
+
+// StyledDocument parses the content document with the given manifest ID
+// and resolves its style sheets.
+func (r *Reader) StyledDocument(id string) (*StyledDocument, error) {
+	res, doc, err := r.contentDocument(id)
+	if err != nil {
+		return nil, err
+	}
+	return NewStyledDocument(doc, r.styleSource().documentStyleSheets(*res, doc)), nil
+}
+
+// ComputedStyle returns the computed style of an element of the document.
+// Other nodes get the style of their parent element.
+func (d *StyledDocument) ComputedStyle(node *html.Node) *ComputedStyle {
+	if node == nil {
+		style := initialStyle
+		return &style
+	}
+	if node.Type != html.ElementNode {
+		return d.ComputedStyle(node.Parent)
+	}
+	if style, found := d.styles[node]; found {
+		return style
+	}
+
+	parent := d.ComputedStyle(parentElement(node))
+	style := computeStyle(d.cascade(node), parent)
+	d.styles[node] = style
+	return style
+}
+
+// cascadedDeclaration is a declaration with its cascade precedence.
+type cascadedDeclaration struct {
+	CSSDeclaration
+	priority    int
+	inline      bool
+	specificity [3]int
+	order       int
+}
+
+// cascade returns the cascaded value of the properties declared for an
+// element, shorthands expanded.
+func (d *StyledDocument) cascade(node *html.Node) map[string]string {
+	var declarations []cascadedDeclaration
+	collect := func(rules []styleRule) {
+		for _, rule := range rules {
+			specificity, matched := rule.selector.Specificity(node)
+			if !matched {
+				continue
+			}
+			for _, declaration := range rule.declarations {
+				declarations = append(declarations, cascadedDeclaration{
+					CSSDeclaration: declaration,
+					priority:       declarationPriority(rule.author, declaration.Important),
+					specificity:    specificity,
+					order:          rule.order,
+				})
+			}
+		}
+	}
+	collect(userAgentRules)
+	collect(d.rules)
+
+	if style := getAttribute(node, "style"); style != "" {
+		for _, declaration := range ParseCSSDeclarations(style) {
+			declarations = append(declarations, cascadedDeclaration{
+				CSSDeclaration: declaration,
+				priority:       declarationPriority(true, declaration.Important),
+				inline:         true,
+			})
+		}
+	}
+
+	slices.SortStableFunc(declarations, func(a, b cascadedDeclaration) int {
+		if c := cmp.Compare(a.priority, b.priority); c != 0 {
+			return c
+		}
+		if a.inline != b.inline {
+			if a.inline {
+				return 1
+			}
+			return -1
+		}
+		if c := compareSpecificity(a.specificity, b.specificity); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.order, b.order)
+	})
+
+	cascaded := make(map[string]string)
+	for _, declaration := range declarations {
+		cascaded[declaration.Property] = declaration.Value
+		for property, value := range expandShorthand(declaration.Property, declaration.Value) {
+			cascaded[property] = value
+		}
+	}
+	return cascaded
+}
+
+// declarationPriority orders the origins and importance of declarations:
+// user agent normal, author normal, author important, then user agent
+// important.
+func declarationPriority(author bool, important bool) int {
+	switch {
+	case !author && !important:
+		return 0
+	case !important:
+		return 1
+	case author:
+		return 2
+	}
+	return 3
+}
+
+// expandShorthand returns the longhand declarations of the supported
+// shorthands and legacy aliases.
+func expandShorthand(property string, value string) map[string]string {
+	switch property {
+	case "font":
+		return expandFont(value)
+	case "break-before", "break-after", "break-inside":
+		return map[string]string{"page-" + property: pageBreakValue(value)}
+	}
+	return nil
+}
+
+// expandFont expands a font shorthand into font-style, font-weight and
+// font-family. System font keywords are ignored.
+func expandFont(value string) map[string]string {
+	switch value = strings.TrimSpace(value); strings.ToLower(value) {
+	case "inherit", "initial", "unset":
+		return map[string]string{"font-style": value, "font-weight": value, "font-family": value}
+	}
+
+	expanded := map[string]string{"font-style": "normal", "font-weight": "normal"}
+	fields := strings.Fields(value)
+	for i, field := range fields {
+		lower := strings.ToLower(field)
+		switch {
+		case lower == "italic" || lower == "oblique":
+			expanded["font-style"] = lower
+		case lower == "bold" || lower == "bolder" || lower == "lighter":
+			expanded["font-weight"] = lower
+		case isFontWeightNumber(lower) && i < len(fields)-1:
+			expanded["font-weight"] = lower
+		case isFontSize(lower):
+			rest := fields[i+1:]
+			// Skip the line height, written "/1.5", "/ 1.5" or "size/ 1.5".
+			switch {
+			case len(rest) > 1 && rest[0] == "/":
+				rest = rest[2:]
+			case len(rest) > 0 && (strings.HasPrefix(rest[0], "/") || strings.HasSuffix(lower, "/")):
+				rest = rest[1:]
+			}
+			if family := strings.Join(rest, " "); family != "" {
+				expanded["font-family"] = family
+				return expanded
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+var fontSizeKeywords = []string{"xx-small", "x-small", "small", "medium", "large", "x-large", "xx-large", "xxx-large", "smaller", "larger"}
+
+func isFontSize(value string) bool {
+	size, _, _ := strings.Cut(value, "/")
+	return slices.Contains(fontSizeKeywords, size) || size != "" && (size[0] >= '0' && size[0] <= '9' || size[0] == '.')
+}
+
+func isFontWeightNumber(value string) bool {
+	weight, err := strconv.Atoi(value)
+	return err == nil && weight >= 1 && weight <= 1000
+}
+
+// pageBreakValue maps a break-* value to the page-break-* one.
+func pageBreakValue(value string) string {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "page", "recto", "verso", "always":
+		return "always"
+	case "avoid", "avoid-page", "avoid-column":
+		return "avoid"
+	case "column", "region", "avoid-region":
+		return "auto"
+	}
+	return value
+}
+
+// computeStyle computes the style of an element from its cascaded values
+// and the style of its parent.
+func computeStyle(cascaded map[string]string, parent *ComputedStyle) *ComputedStyle {
+	style := initialStyle
+	style.Declared = cascaded
+	style.FontFamily = parent.FontFamily
+	style.FontStyle = parent.FontStyle
+	style.FontWeight = parent.FontWeight
+	style.TextAlign = parent.TextAlign
+
+	for property, value := range cascaded {
+		value = strings.TrimSpace(value)
+		keyword := strings.ToLower(value)
+		switch keyword {
+		case "inherit":
+			copyProperty(&style, parent, property)
+			continue
+		case "initial":
+			copyProperty(&style, &initialStyle, property)
+			continue
+		case "unset":
+			if slices.Contains(inheritedProperties, property) {
+				copyProperty(&style, parent, property)
+			} else {
+				copyProperty(&style, &initialStyle, property)
+			}
+			continue
+		}
+
+		switch property {
+		case "display":
+			style.Display = strings.Fields(keyword + " ")[0]
+		case "font-family":
+			style.FontFamily = nil
+			for _, family := range strings.Split(value, ",") {
+				if family = unquoteCSS(strings.Join(strings.Fields(family), " ")); family != "" {
+					style.FontFamily = append(style.FontFamily, family)
+				}
+			}
+		case "font-style":
+			if keyword == "normal" || keyword == "italic" || strings.HasPrefix(keyword, "oblique") {
+				style.FontStyle = strings.Fields(keyword)[0]
+			}
+		case "font-weight":
+			style.FontWeight = fontWeight(keyword, parent.FontWeight)
+		case "text-align":
+			style.TextAlign = keyword
+		case "page-break-before":
+			style.PageBreakBefore = keyword
+		case "page-break-after":
+			style.PageBreakAfter = keyword
+		case "page-break-inside":
+			style.PageBreakInside = keyword
+		}
+	}
+
+	style.Hidden = parent.Hidden || style.Display == "none"
+	return &style
+}
+
+func copyProperty(style *ComputedStyle, from *ComputedStyle, property string) {
+	switch property {
+	case "display":
+		style.Display = from.Display
+	case "font-family":
+		style.FontFamily = from.FontFamily
+	case "font-style":
+		style.FontStyle = from.FontStyle
+	case "font-weight":
+		style.FontWeight = from.FontWeight
+	case "text-align":
+		style.TextAlign = from.TextAlign
+	case "page-break-before":
+		style.PageBreakBefore = from.PageBreakBefore
+	case "page-break-after":
+		style.PageBreakAfter = from.PageBreakAfter
+	case "page-break-inside":
+		style.PageBreakInside = from.PageBreakInside
+	}
+}
+
+// fontWeight computes a font-weight value. Relative weights follow the
+// table of CSS Fonts Level 4.
+func fontWeight(value string, parent int) int {
+	switch value {
+	case "normal":
+		return 400
+	case "bold":
+		return 700
+	case "bolder":
+		switch {
+		case parent < 350:
+			return 400
+		case parent < 550:
+			return 700
+		case parent < 900:
+			return 900
+		}
+		return parent
+	case "lighter":
+		switch {
+		case parent < 100:
+			return parent
+		case parent < 550:
+			return 100
+		case parent < 750:
+			return 400
+		}
+		return 700
+	}
+
+	if weight, err := strconv.Atoi(value); err == nil && weight >= 1 && weight <= 1000 {
+		return weight
+	}
+	return parent
+}
+
+// emphasisElements are the elements the Markdown conversion already
+// renders with emphasis, or whose emphasis comes from the default style.
+var emphasisElements = []atom.Atom{
+	atom.I, atom.Em, atom.Cite, atom.Var, atom.Dfn, atom.Address,
+	atom.B, atom.Strong, atom.Th, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6,
+}
+
+// applyTextStyles prepares the body of a styled document for text
+// conversion: elements with display none are removed, and italic or bold
+// faces given by style sheets are marked up with em and strong elements.
+func (d *StyledDocument) applyTextStyles() {
+	body := FindNode(d.Document, func(n *html.Node) bool { return n.DataAtom == atom.Body })
+	if body == nil {
+		return
+	}
+
+	var hidden, italic, bold []*html.Node
+	marked := map[atom.Atom]map[*html.Node]bool{atom.Em: {}, atom.Strong: {}}
+	for node := range body.Descendants() {
+		if node.Type != html.ElementNode {
+			continue
+		}
+		style := d.ComputedStyle(node)
+		if style.Hidden {
+			if !d.ComputedStyle(node.Parent).Hidden {
+				hidden = append(hidden, node)
+			}
+			continue
+		}
+		if slices.Contains(emphasisElements, node.DataAtom) || node.FirstChild == nil {
+			continue
+		}
+
+		// Emphasis is marked on the outermost elements with inline content
+		// only, below the element the style sheets emphasize.
+		parent := d.ComputedStyle(node.Parent)
+		inline := d.hasInlineContent(node)
+		if style.IsItalic() && (!parent.IsItalic() || !marked[atom.Em][node.Parent] && !d.hasInlineContent(node.Parent)) {
+			if marked[atom.Em][node] = inline; inline {
+				italic = append(italic, node)
+			}
+		}
+		if style.IsBold() && (!parent.IsBold() || !marked[atom.Strong][node.Parent] && !d.hasInlineContent(node.Parent)) {
+			if marked[atom.Strong][node] = inline; inline {
+				bold = append(bold, node)
+			}
+		}
+	}
+
+	for _, node := range hidden {
+		node.Parent.RemoveChild(node)
+	}
+	for _, node := range italic {
+		wrapChildren(node, atom.Em)
+	}
+	for _, node := range bold {
+		wrapChildren(node, atom.Strong)
+	}
+}
+
+// hasInlineContent reports whether the visible child elements of node
+// are all inline.
+func (d *StyledDocument) hasInlineContent(node *html.Node) bool {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		if style := d.ComputedStyle(child); !style.Hidden && !strings.HasPrefix(style.Display, "inline") {
+			return false
+		}
+	}
+	return true
+}
+
+// wrapChildren moves the children of node into a new element.
+func wrapChildren(node *html.Node, element atom.Atom) {
+	wrapper := &html.Node{Type: html.ElementNode, DataAtom: element, Data: element.String()}
+	for node.FirstChild != nil {
+		child := node.FirstChild
+		node.RemoveChild(child)
+		wrapper.AppendChild(child)
+	}
+	node.AppendChild(wrapper)
+}
@@ -0,0 +1,120 @@
+package epub
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestReader_StyledDocument(t *testing.T) {
+	r := newTestReader(t, testStyleFiles())
+
+	d, err := r.StyledDocument("chapter-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	find := func(text string) *html.Node {
+		t.Helper()
+		node := FindNode(d.Document, func(n *html.Node) bool {
+			return n.Type == html.ElementNode && n.Data != "title" && n.FirstChild != nil && strings.HasPrefix(n.FirstChild.Data, text)
+		})
+		if node == nil {
+			t.Fatalf("no element with text %q", text)
+		}
+		return node
+	}
+
+	if style := d.ComputedStyle(find("Chapter")); style.Display != "block" || style.TextAlign != "center" || !style.IsBold() || style.PageBreakAfter != "avoid" ||
+		!slices.Equal(style.FontFamily, []string{"Title Face", "serif"}) {
+		t.Errorf("unexpected heading style %+v", style)
+	}
+	if style := d.ComputedStyle(find("1").FirstChild); style.Display != "inline" || style.FontWeight != 700 || style.TextAlign != "center" {
+		t.Errorf("expected the text node to get the style of its span, got %+v", style)
+	}
+
+	first := find("Plain")
+	if style := d.ComputedStyle(first); style.Hidden || style.TextAlign != "justify" || style.IsItalic() || !slices.Equal(style.FontFamily, []string{"Body", "serif"}) {
+		t.Errorf("unexpected paragraph style %+v", style)
+	}
+	if style := d.ComputedStyle(find("quoted")); !style.IsItalic() || style.TextAlign != "justify" {
+		t.Errorf("unexpected span style %+v", style)
+	}
+
+	if style := d.ComputedStyle(find("Hidden aside")); !style.Hidden || style.Display != "none" {
+		t.Errorf("expected the aside to be hidden, got %+v", style)
+	}
+	if style := d.ComputedStyle(find("Hidden attribute")); !style.Hidden {
+		t.Errorf("expected the hidden attribute to hide, got %+v", style)
+	}
+	if style := d.ComputedStyle(find("Quoted paragraph")); !style.IsItalic() || style.TextAlign != "right" {
+		t.Errorf("expected italics to be inherited, got %+v", style)
+	}
+
+	inline := find("Inline")
+	if style := d.ComputedStyle(inline); style.FontWeight != 400 || style.PageBreakBefore != "always" || !slices.Equal(style.FontFamily, []string{"Inline Face", "serif"}) ||
+		style.Declared["font"] == "" {
+		t.Errorf("unexpected inline style %+v", style)
+	}
+
+	if style := d.ComputedStyle(FindNode(d.Document, func(n *html.Node) bool { return n.Data == "head" })); !style.Hidden {
+		t.Errorf("expected the head to be hidden, got %+v", style)
+	}
+}
+
+func TestComputeStyle(t *testing.T) {
+	parent := initialStyle
+	parent.FontWeight = 700
+	parent.FontStyle = "italic"
+	parent.TextAlign = "right"
+
+	style := computeStyle(map[string]string{
+		"font-weight": "bolder",
+		"font-style":  "initial",
+		"text-align":  "unset",
+		"display":     "INLINE-BLOCK",
+	}, &parent)
+	if style.FontWeight != 900 || style.FontStyle != "normal" || style.TextAlign != "right" || style.Display != "inline-block" {
+		t.Errorf("unexpected style %+v", style)
+	}
+
+	if style := computeStyle(map[string]string{"font-weight": "lighter", "display": "inherit"}, &parent); style.FontWeight != 400 || style.Display != "inline" {
+		t.Errorf("unexpected style %+v", style)
+	}
+
+	tests := map[string]map[string]string{
+		"italic small-caps 600 12px/1.5 Georgia, serif": {"font-style": "italic", "font-weight": "600", "font-family": "Georgia, serif"},
+		"1em sans-serif":       {"font-style": "normal", "font-weight": "normal", "font-family": "sans-serif"},
+		"bold large / 2 'A B'": {"font-style": "normal", "font-weight": "bold", "font-family": "'A B'"},
+		"caption":              nil,
+	}
+	for value, expected := range tests {
+		expanded := expandFont(value)
+		if len(expanded) != len(expected) {
+			t.Errorf("%q: expected %v, got %v", value, expected, expanded)
+			continue
+		}
+		for property, v := range expected {
+			if expanded[property] != v {
+				t.Errorf("%q: expected %v, got %v", value, expected, expanded)
+			}
+		}
+	}
+}
+
+func TestReader_ContentDocumentMarkdownStyles(t *testing.T) {
+	r := newTestReader(t, testStyleFiles())
+
+	md := r.ContentDocumentMarkdown()["chapter-1"]
+	for _, hidden := range []string{"Hidden aside", "Hidden attribute"} {
+		if strings.Contains(md, hidden) {
+			t.Errorf("expected %q to be left out of:\n%s", hidden, md)
+		}
+	}
+	for _, expected := range []string{"Plain *quoted* text.", "*Quoted paragraph.*", "*Second **bold**.*", "# Chapter 1"} {
+		if !strings.Contains(md, expected) {
+			t.Errorf("expected %q in:\n%s", expected, md)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package epub
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// ThumbnailFormat is the encoding of a generated thumbnail.
+type ThumbnailFormat string
+
+const (
+	ThumbnailJPEG ThumbnailFormat = "jpeg"
+	ThumbnailPNG  ThumbnailFormat = "png"
+)
+
+// ThumbnailOptions configures thumbnail generation.
+type ThumbnailOptions struct {
+	// Width and Height bound the thumbnail, which keeps the aspect ratio
+	// of the image. A zero dimension is unconstrained, but not both.
+	Width  int
+	Height int
+
+	// Format defaults to JPEG. Quality is the JPEG quality from 1 to 100,
+	// jpeg.DefaultQuality when zero. Compression applies to PNG.
+	Format      ThumbnailFormat
+	Quality     int
+	Compression png.CompressionLevel
+
+	// Placeholder makes Reader.Thumbnail render a placeholder cover with
+	// the title and author when the publication has no decodable cover.
+	Placeholder bool
+}
+
+// Thumbnail returns the cover of the publication resized to fit the
+// options bounding box and encoded. Without Placeholder, ErrNoCover is
+// returned for publications without cover and an error for covers that
+// cannot be decoded, such as SVG covers.
+func (r *Reader) Thumbnail(options ThumbnailOptions) ([]byte, error) {
+	info, err := r.CoverInfo()
+	if err == nil && info.IsSVG() {
+		err = errors.New("cannot decode SVG cover")
+	}
+
+	var cover image.Image
+	if err == nil {
+		cover, _, err = image.Decode(bytes.NewReader(info.Content))
+	}
+
+	if err != nil {
+		if !options.Placeholder {
+			return nil, err
+		}
+
+		width, height := placeholderSize(options.Width, options.Height)
+		cover, err = PlaceholderCover(r.Title(), r.Author(), width, height)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return Thumbnail(cover, options)
+}
+
+// Thumbnail resizes img to fit the options bounding box with Catmull-Rom
+// resampling and encodes it.
+func Thumbnail(img image.Image, options ThumbnailOptions) ([]byte, error) {
+	if options.Width < 0 || options.Height < 0 || options.Width == 0 && options.Height == 0 {
+		return nil, fmt.Errorf("invalid thumbnail size %dx%d", options.Width, options.Height)
+	}
+	return encodeImage(fitImage(img, options.Width, options.Height), options.Format, options.Quality, options.Compression)
+}
+
+// fitImage scales img to the largest size fitting in width and height,
+// a zero dimension being unconstrained.
+func fitImage(img image.Image, width int, height int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return img
+	}
+
+	scale := 0.0
+	if width > 0 {
+		scale = float64(width) / float64(bounds.Dx())
+	}
+	if height > 0 {
+		if heightScale := float64(height) / float64(bounds.Dy()); scale == 0 || heightScale < scale {
+			scale = heightScale
+		}
+	}
+
+	size := image.Rect(0, 0, max(1, int(float64(bounds.Dx())*scale+0.5)), max(1, int(float64(bounds.Dy())*scale+0.5)))
+	if size.Dx() == bounds.Dx() && size.Dy() == bounds.Dy() {
+		return img
+	}
+
+	dst := image.NewRGBA(size)
+	draw.CatmullRom.Scale(dst, size, img, bounds, draw.Src, nil)
+	return dst
+}
+
+func encodeImage(img image.Image, format ThumbnailFormat, quality int, compression png.CompressionLevel) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case ThumbnailJPEG, "":
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: min(quality, 100)}); err != nil {
+			return nil, err
+		}
+	case ThumbnailPNG:
+		encoder := png.Encoder{CompressionLevel: compression}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported thumbnail format %q", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// placeholderSize completes a bounding box into a 2:3 cover size.
+func placeholderSize(width int, height int) (int, int) {
+	switch {
+	case width > 0 && height > 0:
+		return width, height
+	case width > 0:
+		return width, width * 3 / 2
+	case height > 0:
+		return height * 2 / 3, height
+	}
+	return 600, 900
+}
+
+// placeholderBackgrounds are the background colors of placeholder covers,
+// picked by title so a book keeps the same color.
+var placeholderBackgrounds = []color.RGBA{
+	{0x2c, 0x3e, 0x50, 0xff},
+	{0x6d, 0x21, 0x4f, 0xff},
+	{0x1e, 0x5f, 0x74, 0xff},
+	{0x7b, 0x3f, 0x00, 0xff},
+	{0x2d, 0x5a, 0x27, 0xff},
+	{0x4a, 0x23, 0x5a, 0xff},
+}
+
+var (
+	placeholderTitleFont  = sync.OnceValues(func() (*opentype.Font, error) { return opentype.Parse(gobold.TTF) })
+	placeholderAuthorFont = sync.OnceValues(func() (*opentype.Font, error) { return opentype.Parse(goregular.TTF) })
+)
+
+// PlaceholderCover renders a cover of the given size showing the title and
+// the author, for publications without a cover image.
+func PlaceholderCover(title string, author string, width int, height int) (image.Image, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid cover size %dx%d", width, height)
+	}
+
+	titleFont, err := placeholderTitleFont()
+	if err != nil {
+		return nil, err
+	}
+	authorFont, err := placeholderAuthorFont()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(title))
+	background := placeholderBackgrounds[hash.Sum32()%uint32(len(placeholderBackgrounds))]
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	margin := width / 10
+	textWidth := width - 2*margin
+	foreground := image.NewUniform(color.White)
+
+	titleFace, titleLines, err := fitText(titleFont, title, float64(width)/9, textWidth, 5)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+
+	lineHeight := titleFace.Metrics().Height.Ceil()
+	y := height/3 - len(titleLines)*lineHeight/2 + titleFace.Metrics().Ascent.Ceil()
+	y = max(y, margin+titleFace.Metrics().Ascent.Ceil())
+	for _, line := range titleLines {
+		drawCentered(img, titleFace, foreground, line, width, y)
+		y += lineHeight
+	}
+
+	if author = strings.TrimSpace(author); author != "" {
+		rule := image.Rect(width/2-margin, y, width/2+margin, y+max(1, height/300))
+		draw.Draw(img, rule, foreground, image.Point{}, draw.Src)
+
+		authorFace, authorLines, err := fitText(authorFont, author, float64(width)/16, textWidth, 2)
+		if err != nil {
+			return nil, err
+		}
+		defer authorFace.Close()
+
+		y += authorFace.Metrics().Height.Ceil() * 2
+		for _, line := range authorLines {
+			drawCentered(img, authorFace, foreground, line, width, y)
+			y += authorFace.Metrics().Height.Ceil()
+		}
+	}
+	return img, nil
+}
+
+// fitText wraps text into at most maxLines lines of maxWidth pixels,
+// shrinking the font size from size until it fits.
+func fitText(f *opentype.Font, text string, size float64, maxWidth int, maxLines int) (face font.Face, lines []string, err error) {
+	words := strings.Fields(text)
+	for ; ; size *= 0.9 {
+		face, err = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		lines = wrapText(face, words, maxWidth)
+		if size < 8 || len(lines) <= maxLines && textFits(face, lines, maxWidth) {
+			return face, lines, nil
+		}
+		face.Close()
+	}
+}
+
+func wrapText(face font.Face, words []string, maxWidth int) (lines []string) {
+	var line string
+	for _, word := range words {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && font.MeasureString(face, candidate).Ceil() > maxWidth {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return
+}
+
+func textFits(face font.Face, lines []string, maxWidth int) bool {
+	for _, line := range lines {
+		if font.MeasureString(face, line).Ceil() > maxWidth {
+			return false
+		}
+	}
+	return true
+}
+
+func drawCentered(dst draw.Image, face font.Face, src image.Image, text string, width int, baseline int) {
+	drawer := font.Drawer{Dst: dst, Src: src, Face: face}
+	x := (width - drawer.MeasureString(text).Ceil()) / 2
+	drawer.Dot = fixed.P(x, baseline)
+	drawer.DrawString(text)
+}
+
+// CoverPlaceholder sets a generated cover showing the publication title
+// and first creator, for publications without cover art.
+func (w *Writer) CoverPlaceholder() error {
+	packagePub := w.epub.SelectedPackage()
+	cover, err := PlaceholderCover(firstTitle(packagePub), firstCreator(packagePub), 1200, 1800)
+	if err != nil {
+		return err
+	}
+	return w.CoverPNG(cover)
+}
@@ -0,0 +1,121 @@
+package epub
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"maps"
+	"testing"
+)
+
+func decodeConfig(t *testing.T, content []byte) (image.Config, string) {
+	t.Helper()
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return config, format
+}
+
+func TestThumbnail(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+
+	thumbnail, err := Thumbnail(img, ThumbnailOptions{Width: 40, Height: 40, Quality: 60})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config, format := decodeConfig(t, thumbnail); format != "jpeg" || config.Width != 40 || config.Height != 20 {
+		t.Errorf("unexpected %s thumbnail %dx%d", format, config.Width, config.Height)
+	}
+
+	thumbnail, err = Thumbnail(img, ThumbnailOptions{Height: 100, Format: ThumbnailPNG, Compression: png.BestCompression})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config, format := decodeConfig(t, thumbnail); format != "png" || config.Width != 200 || config.Height != 100 {
+		t.Errorf("unexpected %s thumbnail %dx%d", format, config.Width, config.Height)
+	}
+
+	if _, err := Thumbnail(img, ThumbnailOptions{}); err == nil {
+		t.Errorf("expected error without bounding box")
+	}
+	if _, err := Thumbnail(img, ThumbnailOptions{Width: 10, Format: "gif"}); err == nil {
+		t.Errorf("expected error for an unsupported format")
+	}
+}
+
+func TestReader_Thumbnail(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/images/cover.png"] = string(testPNG(t, 200, 300))
+
+	r := newTestReader(t, files)
+	thumbnail, err := r.Thumbnail(ThumbnailOptions{Width: 100, Height: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config, _ := decodeConfig(t, thumbnail); config.Width != 67 || config.Height != 100 {
+		t.Errorf("unexpected thumbnail %dx%d", config.Width, config.Height)
+	}
+
+	// The fixture cover is only a PNG signature and cannot be decoded.
+	r = newTestReader(t, testEPUB3Files)
+	if _, err := r.Thumbnail(ThumbnailOptions{Width: 60}); err == nil {
+		t.Errorf("expected error for an undecodable cover")
+	}
+
+	thumbnail, err = r.Thumbnail(ThumbnailOptions{Width: 60, Format: ThumbnailPNG, Placeholder: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config, _ := decodeConfig(t, thumbnail); config.Width != 60 || config.Height != 90 {
+		t.Errorf("unexpected placeholder thumbnail %dx%d", config.Width, config.Height)
+	}
+}
+
+func TestPlaceholderCover(t *testing.T) {
+	cover, err := PlaceholderCover("A Very Long Title That Needs To Be Wrapped On Several Lines", "Jane Writer", 300, 450)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cover.Bounds().Dx() != 300 || cover.Bounds().Dy() != 450 {
+		t.Errorf("unexpected size %v", cover.Bounds())
+	}
+
+	background := cover.At(0, 0)
+	drawn := false
+	for y := 0; y < 450 && !drawn; y++ {
+		for x := 0; x < 300; x++ {
+			if cover.At(x, y) != background {
+				drawn = true
+				break
+			}
+		}
+	}
+	if !drawn {
+		t.Errorf("expected text on the placeholder")
+	}
+
+	if _, err := PlaceholderCover("Title", "", 0, 10); err == nil {
+		t.Errorf("expected error for an empty size")
+	}
+}
+
+func TestWriter_CoverPlaceholder(t *testing.T) {
+	w := New("urn:uuid:placeholder")
+	w.Title("Untitled Draft")
+	w.Languages("en")
+	w.Author("Jane Writer")
+	w.AddContent("chapter.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>C</title></head><body><p>C</p></body></html>`))
+	if err := w.CoverPlaceholder(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.TableOfContents("toc", TOC{Items: []TOC{{Title: "Chapter", Href: "chapter.xhtml"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+	cover := r.Cover()
+	if cover == nil || (*cover).Bounds().Dx() != 1200 {
+		t.Errorf("expected a decodable placeholder cover")
+	}
+}
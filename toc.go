@@ -1,11 +1,19 @@
 package epub
 
 import (
+	"cmp"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"math"
+	"path"
+	"regexp"
 	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/raitucarp/epub/ncx"
+	"github.com/raitucarp/epub/pkg"
 	"golang.org/x/net/html"
 )
 
@@ -17,10 +25,32 @@ type TOC struct {
 	Href  string `json:"href,omitempty"`
 	Items []TOC  `json:"items,omitempty"`
 
+	// Target is the resolved location of Href. It is set on entries read
+	// by Reader.TableOfContents that point inside the publication.
+	Target *TOCTarget `json:"target,omitempty"`
+
 	reader *Reader
 	ncx    *ncx.NCX
 }
 
+// TOCTarget is the location a table of contents entry points at. TOC.Href
+// is kept exactly as written, relative to the nav document or NCX, while
+// the target is independent of where those files live.
+type TOCTarget struct {
+	// Path is the container path of the target content document.
+	Path string `json:"path"`
+
+	// ResourceID is the manifest id of the target, or empty when the
+	// document is missing from the manifest.
+	ResourceID string `json:"resourceId,omitempty"`
+
+	// SpineIndex is the position of the target in the spine, or -1 when it
+	// is not part of the reading order.
+	SpineIndex int `json:"spineIndex"`
+
+	Fragment string `json:"fragment,omitempty"`
+}
+
 // JSON marshals the table of contents structure into JSON format. This is useful
 // for external tools, logging, debugging, or serialization to other formats.
 func (t *TOC) JSON() ([]byte, error) {
@@ -31,8 +61,136 @@ func (t *TOC) JSON() ([]byte, error) {
 // selected table of contents entry. The returned document is parsed into an
 // html.Node tree. Behavior depends on TOC internal navigation selection state.
 func (t *TOC) ReadContentHTML() (content *html.Node) {
+	if t.reader == nil {
+		return
+	}
+
+	if t.Target != nil && t.Target.ResourceID != "" {
+		return t.reader.ReadContentHTMLById(t.Target.ResourceID)
+	}
+
 	if t.Href != "" {
-		t.reader.ReadContentHTMLByHref(t.Href)
+		content = t.reader.ReadContentHTMLByHref(t.Href)
+	}
+	return
+}
+
+// resolveTargets resolves the href of every entry, written in the file at
+// docPath, to its target resource and spine position.
+func (t *TOC) resolveTargets(docPath string) {
+	resources := map[string]PublicationResource{}
+	for _, res := range t.reader.epub.resources {
+		resources[unescapePath(res.Filepath)] = res
+	}
+
+	spineIndexes := map[string]int{}
+	for i, res := range t.reader.Spine() {
+		if _, ok := spineIndexes[res.ID]; !ok {
+			spineIndexes[res.ID] = i
+		}
+	}
+
+	visitTOC(t, func(item *TOC, depth int) {
+		item.reader = t.reader
+		if depth == 0 || item.Href == "" || isRemoteHref(item.Href) {
+			return
+		}
+
+		containerPath, fragment := resolveHref(path.Dir(docPath), item.Href)
+		if containerPath == "" {
+			containerPath = docPath
+		}
+		containerPath = unescapePath(containerPath)
+
+		target := &TOCTarget{Path: containerPath, SpineIndex: -1, Fragment: fragment}
+		if res, ok := resources[containerPath]; ok {
+			target.ResourceID = res.ID
+			if index, ok := spineIndexes[res.ID]; ok {
+				target.SpineIndex = index
+			}
+		}
+		item.Target = target
+	})
+}
+
+// EntryForSpine returns the table of contents entry covering the given spine
+// position, i.e. the chapter a reader at that position is in. When fragment
+// is set, the entries pointing into the same document are ordered by where
+// their fragments occur, so the deepest section preceding the fragment is
+// returned, or the first entry of the document when none precedes it.
+// Spine items without entries of their own belong to the last entry
+// before them. It returns nil for positions before the first entry.
+func (t *TOC) EntryForSpine(spineIndex int, fragment string) (entry *TOC) {
+	var candidates []*TOC
+	previousIndex := -1
+
+	visitTOC(t, func(item *TOC, depth int) {
+		if depth == 0 || item.Target == nil || item.Target.SpineIndex < 0 {
+			return
+		}
+
+		switch index := item.Target.SpineIndex; {
+		case index == spineIndex:
+			candidates = append(candidates, item)
+		case index < spineIndex && index >= previousIndex:
+			previousIndex = index
+			entry = item
+		}
+	})
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	positions := t.fragmentPositions(candidates[0].Target.ResourceID)
+	position := func(fragment string) int {
+		if fragment == "" {
+			return -1
+		}
+		if p, ok := positions[fragment]; ok {
+			return p
+		}
+		return -1
+	}
+
+	current := position(fragment)
+	best := -2
+	entry = candidates[0]
+	for _, candidate := range candidates {
+		if fragment != "" && candidate.Target.Fragment == fragment {
+			return candidate
+		}
+
+		if p := position(candidate.Target.Fragment); p <= current && p >= best {
+			best = p
+			entry = candidate
+		}
+	}
+	return
+}
+
+// fragmentPositions maps the element ids of a content document to their
+// order of appearance.
+func (t *TOC) fragmentPositions(resourceID string) (positions map[string]int) {
+	positions = map[string]int{}
+	if t.reader == nil || resourceID == "" {
+		return
+	}
+
+	doc := t.reader.ReadContentHTMLById(resourceID)
+	if doc == nil {
+		return
+	}
+
+	for node := range doc.Descendants() {
+		if node.Type != html.ElementNode {
+			continue
+		}
+		if id := getAttribute(node, "id"); id != "" {
+			if _, ok := positions[id]; !ok {
+				positions[id] = len(positions)
+			}
+		}
 	}
 	return
 }
@@ -60,6 +218,14 @@ func (t *TOC) findNavNode(node *html.Node) *html.Node {
 	})
 }
 
+// findNavByType returns the first nav element whose epub:type contains the
+// given value.
+func findNavByType(node *html.Node, epubType string) *html.Node {
+	return FindNode(node, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "nav" && hasEpubType(n, epubType)
+	})
+}
+
 func (t *TOC) parseNav(navNode *html.Node) {
 	// Extract title from h2
 	for c := navNode.FirstChild; c != nil; c = c.NextSibling {
@@ -233,6 +399,21 @@ func (t *TOC) parseNCX() {
 	t.Items = t.convertNavPointsToTOCItems(t.ncx.NavMap.NavPoints)
 }
 
+// rebaseTOCHrefs rewrites the hrefs of items, relative to fromDir, so they
+// are relative to toDir instead.
+func rebaseTOCHrefs(items []TOC, fromDir string, toDir string) []TOC {
+	rebased := make([]TOC, 0, len(items))
+	for _, item := range items {
+		if item.Href != "" && !strings.HasPrefix(item.Href, "#") && !isRemoteHref(item.Href) {
+			containerPath, fragment := resolveHref(fromDir, item.Href)
+			item.Href = relativeHref(toDir, containerPath, fragment)
+		}
+		item.Items = rebaseTOCHrefs(item.Items, fromDir, toDir)
+		rebased = append(rebased, item)
+	}
+	return rebased
+}
+
 func visitTOC(toc *TOC, visitor func(*TOC, int)) {
 	var visit func(*TOC, int)
 	visit = func(item *TOC, depth int) {
@@ -252,7 +433,220 @@ func visitTOC(toc *TOC, visitor func(*TOC, int)) {
 	visit(toc, 0)
 }
 
+// tocToNCX converts TOC items into an NCX document. The TOC tree maps one
+// to one onto nested navPoints. playOrder numbers navPoints and pageTargets
+// together in reading order, as given by position, with entries pointing
+// at the same target sharing the same playOrder. Entries at the same
+// position, or all of them when position is nil, keep their order in the
+// navMap followed by the pageList.
+func tocToNCX(uid string, title string, author string, items []TOC, pages []TOC, position func(href string) [2]int) *ncx.NCX {
+	navigation := &ncx.NCX{
+		Version:   "2005-1",
+		DocTitle:  ncx.TextElement{Text: title},
+		DocAuthor: ncx.TextElement{Text: author},
+		NavMap:    ncx.NavMap{ID: "navmap"},
+	}
+
+	navPointCount := 0
+	depth := 0
+	var convert func(items []TOC, level int) []ncx.NavPoint
+	convert = func(items []TOC, level int) (navPoints []ncx.NavPoint) {
+		for _, item := range items {
+			src := firstTOCHref(item)
+			if src == "" {
+				continue
+			}
+
+			depth = max(depth, level)
+			navPointCount++
+			navPoint := ncx.NavPoint{
+				ID:       "nav-point-" + strconv.Itoa(navPointCount),
+				NavLabel: ncx.NavLabel{Text: item.Title},
+				Content:  ncx.Content{Src: src},
+			}
+			navPoint.NavPoints = convert(item.Items, level+1)
+			navPoints = append(navPoints, navPoint)
+		}
+		return
+	}
+	navigation.NavMap.NavPoints = convert(items, 1)
+
+	maxPageNumber := 0
+	if len(pages) > 0 {
+		navigation.PageList = &ncx.PageList{}
+		for i, page := range pages {
+			pageType := "normal"
+			if number, err := strconv.Atoi(page.Title); err == nil {
+				maxPageNumber = max(maxPageNumber, number)
+			} else if isRomanNumeral(page.Title) {
+				pageType = "front"
+			} else {
+				pageType = "special"
+			}
+
+			navigation.PageList.PageTargets = append(navigation.PageList.PageTargets, ncx.PageTarget{
+				ID:       "page-" + strconv.Itoa(i+1),
+				Type:     pageType,
+				Value:    page.Title,
+				NavLabel: ncx.NavLabel{Text: page.Title},
+				Content:  ncx.Content{Src: page.Href},
+			})
+		}
+	}
+	numberPlayOrder(navigation, position)
+
+	navigation.Head.Meta = []ncx.Meta{
+		{Name: "dtb:uid", Content: uid},
+		{Name: "dtb:depth", Content: strconv.Itoa(depth)},
+		{Name: "dtb:totalPageCount", Content: strconv.Itoa(len(pages))},
+		{Name: "dtb:maxPageNumber", Content: strconv.Itoa(maxPageNumber)},
+	}
+
+	return navigation
+}
+
+// numberPlayOrder sets the playOrder of the navPoints and pageTargets of an
+// NCX document, as described by tocToNCX.
+func numberPlayOrder(navigation *ncx.NCX, position func(href string) [2]int) {
+	type entry struct {
+		src       string
+		playOrder *string
+	}
+
+	var entries []entry
+	var collect func(navPoints []ncx.NavPoint)
+	collect = func(navPoints []ncx.NavPoint) {
+		for i := range navPoints {
+			entries = append(entries, entry{navPoints[i].Content.Src, &navPoints[i].PlayOrder})
+			collect(navPoints[i].NavPoints)
+		}
+	}
+	collect(navigation.NavMap.NavPoints)
+	if navigation.PageList != nil {
+		for i := range navigation.PageList.PageTargets {
+			target := &navigation.PageList.PageTargets[i]
+			entries = append(entries, entry{target.Content.Src, &target.PlayOrder})
+		}
+	}
+
+	if position != nil {
+		positions := make(map[string][2]int)
+		for _, e := range entries {
+			if _, found := positions[e.src]; !found {
+				positions[e.src] = position(e.src)
+			}
+		}
+		slices.SortStableFunc(entries, func(a, b entry) int {
+			pa, pb := positions[a.src], positions[b.src]
+			if c := cmp.Compare(pa[0], pb[0]); c != 0 {
+				return c
+			}
+			return cmp.Compare(pa[1], pb[1])
+		})
+	}
+
+	playOrders := make(map[string]int)
+	for _, e := range entries {
+		if _, found := playOrders[e.src]; !found {
+			playOrders[e.src] = len(playOrders) + 1
+		}
+		*e.playOrder = strconv.Itoa(playOrders[e.src])
+	}
+}
+
+// readingPosition returns a function locating hrefs relative to baseDir in
+// reading order: the spine index of their document, and the document order
+// index of the element their fragment identifies, 0 for the document
+// itself or an unknown fragment. Documents outside the spine come last.
+func (w *Writer) readingPosition(baseDir string) func(href string) [2]int {
+	spine := make(map[string]int)
+	for i, itemRef := range w.epub.SelectedPackage().Spine.ItemRefs {
+		if res := w.resourceByID(itemRef.IDRef); res != nil {
+			if _, found := spine[unescapePath(res.Filepath)]; !found {
+				spine[unescapePath(res.Filepath)] = i
+			}
+		}
+	}
+
+	elements := make(map[string]map[string]int)
+	return func(href string) [2]int {
+		containerPath, fragment := resolveHref(baseDir, href)
+		containerPath = unescapePath(containerPath)
+		index, found := spine[containerPath]
+		if !found {
+			return [2]int{math.MaxInt, 0}
+		}
+		if fragment == "" {
+			return [2]int{index, 0}
+		}
+
+		ids, parsed := elements[containerPath]
+		if !parsed {
+			ids = make(map[string]int)
+			res := w.resourceByID(w.epub.SelectedPackage().Spine.ItemRefs[index].IDRef)
+			if doc, err := parseXHTML(res.Content); err == nil {
+				count := 0
+				for node := range doc.Descendants() {
+					if node.Type != html.ElementNode {
+						continue
+					}
+					count++
+					if id := getAttribute(node, "id"); id != "" {
+						if _, found := ids[id]; !found {
+							ids[id] = count
+						}
+					}
+				}
+			}
+			elements[containerPath] = ids
+		}
+		return [2]int{index, ids[fragment]}
+	}
+}
+
+// firstTOCHref returns the href of the item, or of its first descendant
+// with an href when the item itself is only a heading.
+func firstTOCHref(item TOC) string {
+	if item.Href != "" {
+		return item.Href
+	}
+
+	for _, child := range item.Items {
+		if href := firstTOCHref(child); href != "" {
+			return href
+		}
+	}
+	return ""
+}
+
+var romanNumeralPattern = regexp.MustCompile(`(?i)^[ivxlcdm]+$`)
+
+func isRomanNumeral(value string) bool {
+	return romanNumeralPattern.MatchString(value)
+}
+
+// marshalNCX serializes an NCX document including the XML declaration.
+func marshalNCX(navigation *ncx.NCX) ([]byte, error) {
+	content, err := xml.MarshalIndent(navigation, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), content...), nil
+}
+
 func tocToHTMLNode(toc TOC, lang []string) (*html.Node, error) {
+	// Create main TOC nav
+	tocNav := createTOCNav(toc.Items)
+
+	// Create landmarks nav
+	landmarksNav := createLandmarksNav(toc.Items)
+
+	return navDocumentNode(toc.Title, lang, tocNav, landmarksNav), nil
+}
+
+// navDocumentNode wraps the given nav elements into an XHTML navigation document.
+func navDocumentNode(docTitle string, lang []string, navs ...*html.Node) *html.Node {
 	// Create the root html node
 	doc := &html.Node{
 		Type: html.DocumentNode,
@@ -264,6 +658,7 @@ func tocToHTMLNode(toc TOC, lang []string) (*html.Node, error) {
 		Data: "html",
 		Attr: []html.Attribute{
 			{Key: "xmlns", Val: "http://www.w3.org/1999/xhtml"},
+			{Key: "xmlns:epub", Val: "http://www.idpf.org/2007/ops"},
 		},
 	}
 
@@ -289,7 +684,7 @@ func tocToHTMLNode(toc TOC, lang []string) (*html.Node, error) {
 
 	titleText := &html.Node{
 		Type: html.TextNode,
-		Data: toc.Title,
+		Data: docTitle,
 	}
 	title.AppendChild(titleText)
 
@@ -303,15 +698,48 @@ func tocToHTMLNode(toc TOC, lang []string) (*html.Node, error) {
 	}
 	htmlNode.AppendChild(body)
 
-	// Create main TOC nav
-	tocNav := createTOCNav(toc.Items)
-	body.AppendChild(tocNav)
+	for _, nav := range navs {
+		body.AppendChild(nav)
+	}
 
-	// Create landmarks nav
-	landmarksNav := createLandmarksNav(toc.Items)
-	body.AppendChild(landmarksNav)
+	return doc
+}
+
+// createNav builds a nav element of the given epub:type holding the items
+// as a nested ordered list.
+func createNav(id string, epubType string, heading string, hidden bool, items []TOC) *html.Node {
+	nav := &html.Node{
+		Type: html.ElementNode,
+		Data: "nav",
+		Attr: []html.Attribute{
+			{Key: "id", Val: id},
+			{Key: "epub:type", Val: epubType},
+		},
+	}
 
-	return doc, nil
+	if hidden {
+		nav.Attr = append(nav.Attr, html.Attribute{Key: "hidden", Val: ""})
+	}
+
+	h2 := &html.Node{
+		Type: html.ElementNode,
+		Data: "h2",
+		Attr: []html.Attribute{
+			{Key: "epub:type", Val: "title"},
+		},
+	}
+	nav.AppendChild(h2)
+	h2.AppendChild(&html.Node{Type: html.TextNode, Data: heading})
+
+	ol := &html.Node{
+		Type: html.ElementNode,
+		Data: "ol",
+	}
+	nav.AppendChild(ol)
+
+	addTOCItems(ol, items)
+
+	return nav
 }
 
 func createTOCNav(toc []TOC) *html.Node {
@@ -464,25 +892,49 @@ func addTOCItems(parent *html.Node, items []TOC) {
 	}
 }
 
+// navigationResource returns the resource flagged with the nav manifest
+// property, or nil when the publication has no navigation document.
+func (r *Reader) navigationResource() *PublicationResource {
+	for i, res := range r.epub.resources {
+		if hasManifestProperty(res.Properties, pkg.NavProperty) {
+			return &r.epub.resources[i]
+		}
+	}
+	return nil
+}
+
+// ncxResource returns the NCX resource, or nil when there is none.
+func (r *Reader) ncxResource() *PublicationResource {
+	for i, res := range r.epub.resources {
+		if res.MIMEType == pkg.MediaTypeNCX {
+			return &r.epub.resources[i]
+		}
+	}
+	return nil
+}
+
 // TableOfContents returns the TOC version present (e.g., NAV or NCX).
 // If both exist, behavior depends on publication version and priority rules.
 func (r *Reader) TableOfContents() (toc TOC, err error) {
 	toc.reader = r
-	resourceWithNavIndex := slices.IndexFunc(r.epub.resources, func(res PublicationResource) bool {
-		return res.Properties == "nav"
-	})
-
-	if resourceWithNavIndex > -1 {
-		tocRes := r.epub.resources[resourceWithNavIndex]
 
+	if tocRes := r.navigationResource(); tocRes != nil {
 		html := r.ReadContentHTMLById(tocRes.ID)
-		err = toc.parseFromHTML(html)
+		if err = toc.parseFromHTML(html); err == nil {
+			toc.resolveTargets(tocRes.Filepath)
+		}
 		return
 	}
 
 	if r.epub.navigationCenterEXtended != nil {
 		toc.ncx = r.epub.navigationCenterEXtended
 		toc.parseNCX()
+
+		ncxPath := r.CurrentSelectedPackagePath()
+		if ncxRes := r.ncxResource(); ncxRes != nil {
+			ncxPath = ncxRes.Filepath
+		}
+		toc.resolveTargets(ncxPath)
 	}
 
 	return
@@ -1,6 +1,10 @@
 package epub
 
 import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -64,3 +68,124 @@ func TestTOC_JSON(t *testing.T) {
 		})
 	}
 }
+
+func TestReader_TableOfContentsTargets(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/text/chapter-1.xhtml"] = `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter 1</title></head>
+<body><h1>Chapter 1</h1><p id="intro">Intro</p>
+<h2 id="s1">Section 1.1</h2><p id="para">Text</p>
+<h3 id="s1-1">Section 1.1.1</h3><p id="late">More</p></body></html>`
+	r := newTestReader(t, files)
+
+	toc, err := r.TableOfContents()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	chapter := toc.Items[0]
+	if chapter.Href != "../text/chapter-1.xhtml" {
+		t.Errorf("expected href as written in the nav document, got %q", chapter.Href)
+	}
+
+	expected := &TOCTarget{Path: "OEBPS/text/chapter-1.xhtml", ResourceID: "chapter-1", SpineIndex: 0}
+	if !reflect.DeepEqual(chapter.Target, expected) {
+		t.Errorf("expected target %+v, got %+v", expected, chapter.Target)
+	}
+
+	if target := chapter.Items[0].Target; target == nil || target.Fragment != "s1" {
+		t.Errorf("unexpected nested target %+v", target)
+	}
+
+	if chapter.ReadContentHTML() == nil {
+		t.Errorf("expected content of the entry")
+	}
+
+	tests := []struct {
+		spineIndex int
+		fragment   string
+		expected   string
+	}{
+		{0, "", "Chapter 1"},
+		{0, "intro", "Chapter 1"},
+		{0, "s1", "Section 1.1"},
+		{0, "para", "Section 1.1"},
+		{0, "late", "Section 1.1.1"},
+		{1, "", "Chapter 2"},
+		{5, "", "Chapter 2"},
+	}
+
+	for _, tt := range tests {
+		entry := toc.EntryForSpine(tt.spineIndex, tt.fragment)
+		if entry == nil || entry.Title != tt.expected {
+			t.Errorf("EntryForSpine(%d, %q): expected %q, got %+v", tt.spineIndex, tt.fragment, tt.expected, entry)
+		}
+	}
+
+	if entry := toc.EntryForSpine(-1, ""); entry != nil {
+		t.Errorf("expected no entry before the first spine item, got %+v", entry)
+	}
+}
+
+func TestTOC_EntryForSpineFragmentLink(t *testing.T) {
+	files := maps.Clone(testEPUB3Files)
+	files["OEBPS/nav/nav.xhtml"] = strings.Replace(files["OEBPS/nav/nav.xhtml"],
+		`<a href="../text/chapter-2.xhtml">Chapter 2</a>`, `<a href="../text/chapter-2.xhtml#p2">Chapter 2</a>`, 1)
+	r := newTestReader(t, files)
+
+	toc, err := r.TableOfContents()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, fragment := range []string{"", "p2", "unknown"} {
+		if entry := toc.EntryForSpine(1, fragment); entry == nil || entry.Title != "Chapter 2" {
+			t.Errorf("EntryForSpine(1, %q): expected %q, got %+v", fragment, "Chapter 2", entry)
+		}
+	}
+}
+
+func TestReader_TableOfContentsTargetsFromNCX(t *testing.T) {
+	r := newTestReader(t, testEPUB2Files)
+
+	toc, err := r.TableOfContents()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := &TOCTarget{Path: "OEBPS/text/chapter-2.xhtml", ResourceID: "chapter-2", SpineIndex: 1}
+	if !reflect.DeepEqual(toc.Items[1].Target, expected) {
+		t.Errorf("expected target %+v, got %+v", expected, toc.Items[1].Target)
+	}
+
+	if toc.Items[1].ReadContentHTML() == nil {
+		t.Errorf("expected content of the entry")
+	}
+}
+
+func TestTOCToNCX_PlayOrder(t *testing.T) {
+	w := New("urn:uuid:play-order")
+	w.AddContent("chapter-1.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>One</title></head><body>
+<h1>One</h1><span id="p1"/><p>Text</p><h2 id="s1">Section</h2><span id="p2"/></body></html>`))
+	w.AddContent("chapter-2.xhtml", []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Two</title></head><body><h1>Two</h1></body></html>`))
+
+	items := []TOC{
+		{Title: "One", Href: "chapter-1.xhtml", Items: []TOC{{Title: "Section", Href: "chapter-1.xhtml#s1"}}},
+		{Title: "Two", Href: "chapter-2.xhtml"},
+	}
+	pages := []TOC{
+		{Title: "1", Href: "chapter-1.xhtml#p1"},
+		{Title: "2", Href: "chapter-1.xhtml#p2"},
+		{Title: "3", Href: "chapter-2.xhtml"},
+	}
+	navigation := tocToNCX("urn:uuid:play-order", "Title", "", items, pages, w.readingPosition(w.contentDir))
+
+	navPoints := navigation.NavMap.NavPoints
+	got := []string{navPoints[0].PlayOrder, navPoints[0].NavPoints[0].PlayOrder, navPoints[1].PlayOrder}
+	for _, target := range navigation.PageList.PageTargets {
+		got = append(got, target.PlayOrder)
+	}
+	// One, page 1, Section, page 2, then Two shared with page 3.
+	if expected := []string{"1", "3", "5", "2", "4", "5"}; !slices.Equal(got, expected) {
+		t.Errorf("expected playOrder %q, got %q", expected, got)
+	}
+}
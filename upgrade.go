@@ -0,0 +1,386 @@
+package epub
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/raitucarp/epub/ncx"
+	"github.com/raitucarp/epub/pkg"
+	"golang.org/x/net/html"
+)
+
+// guideLandmarkTypes maps EPUB 2 guide reference types to the structural
+// semantics used by the EPUB 3 landmarks nav.
+var guideLandmarkTypes = map[pkg.GuideReferenceType]string{
+	pkg.GuideRefCover:            "cover",
+	pkg.GuideRefTitlePage:        "titlepage",
+	pkg.GuideRefToc:              "toc",
+	pkg.GuideRefIndex:            "index",
+	pkg.GuideRefGlossary:         "glossary",
+	pkg.GuideRefAcknowledgements: "acknowledgments",
+	pkg.GuideRefBibliography:     "bibliography",
+	pkg.GuideRefColophon:         "colophon",
+	pkg.GuideRefCopyrightPage:    "copyright-page",
+	pkg.GuideRefDedication:       "dedication",
+	pkg.GuideRefEpigraph:         "epigraph",
+	pkg.GuideRefForeword:         "foreword",
+	pkg.GuideRefLoi:              "loi",
+	pkg.GuideRefLot:              "lot",
+	pkg.GuideRefNotes:            "endnotes",
+	pkg.GuideRefPreface:          "preface",
+	pkg.GuideRefText:             "bodymatter",
+}
+
+// navListTypes lists the epub:type values an NCX navList may map to.
+var navListTypes = []string{"loi", "lot", "loa", "lov"}
+
+// UpgradeToEPUB3 converts an EPUB 2 publication into EPUB 3. The returned
+// Writer holds every resource of the publication plus a navigation document
+// generated from the NCX, which is kept for backward compatibility.
+func UpgradeToEPUB3(r *Reader) (w *Writer, err error) {
+	if strings.HasPrefix(r.Version(), "3") {
+		return nil, fmt.Errorf("publication is already EPUB %s", r.Version())
+	}
+
+	navigation := r.NavigationCenterExtended()
+	if navigation == nil {
+		return nil, errors.New("publication has no NCX to upgrade from")
+	}
+
+	w = newWriterFromReader(r)
+	packagePub := w.epub.SelectedPackage()
+	packagePub.Version = "3.0"
+
+	upgradeOPF2Attributes(&packagePub.Metadata)
+	setModified(&packagePub.Metadata, time.Now())
+	upgradeCoverMeta(packagePub)
+	w.detectManifestProperties()
+
+	err = w.addNavFromNCX(navigation)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// upgradeOPF2Attributes turns opf:role and opf:file-as attributes into
+// refining meta elements, assigning ids to the refined elements as needed.
+func upgradeOPF2Attributes(metadata *pkg.Metadata) {
+	ids := map[string]bool{}
+	for _, dc := range metadata.OptionalDC {
+		ids[dc.ID] = true
+	}
+
+	for i := range metadata.OptionalDC {
+		dc := &metadata.OptionalDC[i]
+		if dc.Role == "" && dc.FileAs == "" {
+			dc.Scheme, dc.Event = "", ""
+			continue
+		}
+
+		if dc.ID == "" {
+			base := strings.TrimPrefix(dc.XMLName.Local, "dc:")
+			for counter := 1; dc.ID == "" || ids[dc.ID]; counter++ {
+				dc.ID = base + "-" + strconv.Itoa(counter)
+			}
+			ids[dc.ID] = true
+		}
+
+		refines := "#" + dc.ID
+		if dc.Role != "" {
+			metadata.Meta = append(metadata.Meta, pkg.Meta{
+				Refines:  refines,
+				Property: "role",
+				Scheme:   "marc:relators",
+				Value:    dc.Role,
+			})
+		}
+
+		if dc.FileAs != "" {
+			metadata.Meta = append(metadata.Meta, pkg.Meta{
+				Refines:  refines,
+				Property: "file-as",
+				Value:    dc.FileAs,
+			})
+		}
+
+		dc.Role, dc.FileAs, dc.Scheme, dc.Event = "", "", "", ""
+	}
+}
+
+// setModified replaces the dcterms:modified meta with the given time.
+func setModified(metadata *pkg.Metadata, modified time.Time) {
+	metadata.Meta = slices.DeleteFunc(metadata.Meta, func(meta pkg.Meta) bool {
+		return meta.Property == "dcterms:modified"
+	})
+
+	metadata.Meta = append(metadata.Meta, pkg.Meta{
+		Property: "dcterms:modified",
+		Value:    modified.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// upgradeCoverMeta marks the manifest item referenced by the EPUB 2
+// cover meta with the cover-image property.
+func upgradeCoverMeta(packagePub *pkg.Package) {
+	for _, meta := range packagePub.Metadata.Meta {
+		if meta.Name != "cover" {
+			continue
+		}
+
+		for i, item := range packagePub.Manifest.Items {
+			if item.ID == meta.Content && slices.Contains(pkg.ImageMediaTypes, item.MediaType) {
+				packagePub.Manifest.Items[i].Properties = addManifestProperties(item.Properties, pkg.CoverImageProperty)
+			}
+		}
+	}
+}
+
+// detectManifestProperties inspects every XHTML content document and sets
+// the svg, mathml, scripted and remote-resources manifest properties.
+func (w *Writer) detectManifestProperties() {
+	contents := make(map[string][]byte, len(w.epub.resources))
+	for _, res := range w.epub.resources {
+		contents[res.ID] = res.Content
+	}
+
+	items := w.epub.SelectedPackage().Manifest.Items
+	for i, item := range items {
+		if item.MediaType != pkg.MediaTypeXHTML {
+			continue
+		}
+
+		properties := detectContentProperties(contents[item.ID])
+		items[i].Properties = addManifestProperties(item.Properties, properties...)
+	}
+
+	for i, res := range w.epub.resources {
+		index := slices.IndexFunc(items, func(item pkg.Item) bool { return item.ID == res.ID })
+		if index > -1 {
+			w.epub.resources[i].Properties = items[index].Properties
+		}
+	}
+}
+
+// detectContentProperties reports which manifest properties a content
+// document needs based on the elements and attributes it uses.
+func detectContentProperties(content []byte) (properties []pkg.ManifestProperty) {
+	doc, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return
+	}
+
+	found := map[pkg.ManifestProperty]bool{}
+	for node := range doc.Descendants() {
+		if node.Type != html.ElementNode {
+			continue
+		}
+
+		switch node.Data {
+		case "svg":
+			found[pkg.SvgProperty] = true
+		case "math":
+			found[pkg.MathMLProperty] = true
+		case "script", "form":
+			found[pkg.ScriptedProperty] = true
+		}
+
+		for _, attr := range node.Attr {
+			if len(attr.Key) > 2 && strings.HasPrefix(attr.Key, "on") {
+				found[pkg.ScriptedProperty] = true
+			}
+
+			isResource := attr.Key == "src" ||
+				(attr.Key == "href" && node.Data == "link") ||
+				(attr.Key == "data" && node.Data == "object")
+			if isResource && isRemoteHref(attr.Val) {
+				found[pkg.RemoteResourceProperty] = true
+			}
+		}
+	}
+
+	for _, property := range []pkg.ManifestProperty{
+		pkg.MathMLProperty,
+		pkg.RemoteResourceProperty,
+		pkg.ScriptedProperty,
+		pkg.SvgProperty,
+	} {
+		if found[property] {
+			properties = append(properties, property)
+		}
+	}
+	return
+}
+
+func isRemoteHref(href string) bool {
+	href = strings.ToLower(strings.TrimSpace(href))
+	return strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://")
+}
+
+// addManifestProperties merges properties into a space separated
+// manifest properties value, skipping those already present.
+func addManifestProperties(current pkg.ManifestProperty, properties ...pkg.ManifestProperty) pkg.ManifestProperty {
+	values := strings.Fields(string(current))
+	for _, property := range properties {
+		if !slices.Contains(values, string(property)) {
+			values = append(values, string(property))
+		}
+	}
+	return pkg.ManifestProperty(strings.Join(values, " "))
+}
+
+// uniqueManifestID returns base, or base suffixed with a counter, so that
+// it does not clash with an existing manifest item id.
+func uniqueManifestID(packagePub *pkg.Package, base string) string {
+	exists := func(id string) bool {
+		return slices.ContainsFunc(packagePub.Manifest.Items, func(item pkg.Item) bool {
+			return item.ID == id
+		})
+	}
+
+	id := base
+	for i := 1; exists(id); i++ {
+		id = base + "-" + strconv.Itoa(i)
+	}
+	return id
+}
+
+// addNavFromNCX generates the EPUB 3 navigation document next to the NCX
+// so that the NCX hrefs stay valid, and registers it in the manifest.
+func (w *Writer) addNavFromNCX(navigation *ncx.NCX) (err error) {
+	packagePub := w.epub.SelectedPackage()
+	packageDir := w.contentDir
+
+	navDir := packageDir
+	ncxIndex := slices.IndexFunc(w.epub.resources, func(res PublicationResource) bool {
+		return res.MIMEType == pkg.MediaTypeNCX
+	})
+	if ncxIndex > -1 {
+		navDir = path.Dir(w.epub.resources[ncxIndex].Filepath)
+	}
+
+	toc := TOC{}
+	toc.Items = toc.convertNavPointsToTOCItems(navigation.NavMap.NavPoints)
+	navs := []*html.Node{createTOCNav(toc.Items)}
+
+	if packagePub.Guide != nil && len(packagePub.Guide.References) > 0 {
+		navs = append(navs, createGuideLandmarksNav(packagePub.Guide.References, packageDir, navDir))
+	}
+
+	if navigation.PageList != nil && len(navigation.PageList.PageTargets) > 0 {
+		pages := []TOC{}
+		for _, target := range navigation.PageList.PageTargets {
+			pages = append(pages, TOC{Title: target.NavLabel.Text, Href: target.Content.Src})
+		}
+		navs = append(navs, createNav("page-list", "page-list", "Pages", true, pages))
+	}
+
+	for i, navList := range navigation.NavLists {
+		items := []TOC{}
+		for _, target := range navList.NavTargets {
+			items = append(items, TOC{Title: target.NavLabel.Text, Href: target.Content.Src})
+		}
+
+		epubType := navListType(navList)
+		id := navList.ID
+		if id == "" {
+			id = epubType + "-" + strconv.Itoa(i+1)
+		}
+		navs = append(navs, createNav(id, epubType, navList.NavLabel.Text, false, items))
+	}
+
+	languages := []string{}
+	for _, lang := range packagePub.Metadata.Languages {
+		languages = append(languages, lang.Value)
+	}
+
+	docTitle := navigation.DocTitle.Text
+	if docTitle == "" && len(packagePub.Metadata.Titles) > 0 {
+		docTitle = packagePub.Metadata.Titles[0].Value
+	}
+
+	var content bytes.Buffer
+	err = html.Render(&content, navDocumentNode(docTitle, languages, navs...))
+	if err != nil {
+		return
+	}
+
+	id := uniqueManifestID(packagePub, "nav")
+	filePath := path.Join(navDir, id+".xhtml")
+	w.addResource(
+		id,
+		filePath,
+		relativeHref(packageDir, filePath, ""),
+		pkg.NavProperty,
+		pkg.MediaTypeXHTML,
+		content.Bytes(),
+	)
+	return
+}
+
+// createGuideLandmarksNav builds the landmarks nav from guide references,
+// rebasing their hrefs from the package document to the nav document.
+func createGuideLandmarksNav(references []pkg.GuideReference, packageDir string, navDir string) *html.Node {
+	nav := createNav("landmarks", "landmarks", "Landmarks", true, nil)
+	ol := nav.LastChild
+
+	for _, ref := range references {
+		epubType, ok := guideLandmarkTypes[ref.Type]
+		if !ok {
+			epubType = string(ref.Type)
+		}
+
+		title := ref.Title
+		if title == "" {
+			title = epubType
+		}
+
+		containerPath, fragment := resolveHref(packageDir, ref.Href)
+
+		li := &html.Node{Type: html.ElementNode, Data: "li"}
+		a := &html.Node{
+			Type: html.ElementNode,
+			Data: "a",
+			Attr: []html.Attribute{
+				{Key: "epub:type", Val: epubType},
+				{Key: "href", Val: relativeHref(navDir, containerPath, fragment)},
+			},
+		}
+		a.AppendChild(&html.Node{Type: html.TextNode, Data: title})
+		li.AppendChild(a)
+		ol.AppendChild(li)
+	}
+
+	return nav
+}
+
+// navListType guesses the epub:type of an NCX navList from its class or label.
+func navListType(navList ncx.NavList) string {
+	if slices.Contains(navListTypes, navList.Class) {
+		return navList.Class
+	}
+
+	label := strings.ToLower(navList.NavLabel.Text)
+	switch {
+	case strings.Contains(label, "illustration") || strings.Contains(label, "figure"):
+		return "loi"
+	case strings.Contains(label, "table"):
+		return "lot"
+	case strings.Contains(label, "audio"):
+		return "loa"
+	case strings.Contains(label, "video"):
+		return "lov"
+	}
+
+	if navList.Class != "" {
+		return navList.Class
+	}
+	return "lot"
+}
@@ -0,0 +1,224 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/raitucarp/epub/pkg"
+)
+
+// newTestReader zips the given files into an EPUB container and opens it.
+func newTestReader(t *testing.T, files map[string]string) Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+
+	r, err := NewReader(buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to open test epub: %v", err)
+	}
+	return r
+}
+
+// writeAndReopen writes w into a temporary file and opens the result.
+func writeAndReopen(t *testing.T, w *Writer) Reader {
+	t.Helper()
+
+	name := filepath.Join(t.TempDir(), "book.epub")
+	if err := w.Write(name); err != nil {
+		t.Fatalf("failed to write epub: %v", err)
+	}
+
+	r, err := OpenReader(name)
+	if err != nil {
+		t.Fatalf("failed to reopen epub: %v", err)
+	}
+	return r
+}
+
+const testContainerXML = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+var testEPUB2Files = map[string]string{
+	"mimetype":               "application/epub+zip",
+	"META-INF/container.xml": testContainerXML,
+	"OEBPS/content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:opf="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="bookid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="bookid">urn:uuid:epub2-book</dc:identifier>
+    <dc:title>Legacy Book</dc:title>
+    <dc:language>en</dc:language>
+    <dc:creator opf:role="aut" opf:file-as="Writer, Jane">Jane Writer</dc:creator>
+    <meta name="cover" content="cover-image"/>
+  </metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="cover-image" href="images/cover.png" media-type="image/png"/>
+    <item id="chapter-1" href="text/chapter-1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="chapter-2" href="text/chapter-2.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx">
+    <itemref idref="chapter-1"/>
+    <itemref idref="chapter-2"/>
+  </spine>
+  <guide>
+    <reference type="text" title="Start" href="text/chapter-1.xhtml"/>
+  </guide>
+</package>`,
+	"OEBPS/toc.ncx": `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head><meta name="dtb:uid" content="urn:uuid:epub2-book"/></head>
+  <docTitle><text>Legacy Book</text></docTitle>
+  <navMap>
+    <navPoint id="np-1" playOrder="1">
+      <navLabel><text>Chapter 1</text></navLabel>
+      <content src="text/chapter-1.xhtml"/>
+      <navPoint id="np-2" playOrder="2">
+        <navLabel><text>Section 1.1</text></navLabel>
+        <content src="text/chapter-1.xhtml#s1"/>
+      </navPoint>
+    </navPoint>
+    <navPoint id="np-3" playOrder="3">
+      <navLabel><text>Chapter 2</text></navLabel>
+      <content src="text/chapter-2.xhtml"/>
+    </navPoint>
+  </navMap>
+  <pageList>
+    <pageTarget id="p1" type="normal" value="1">
+      <navLabel><text>1</text></navLabel>
+      <content src="text/chapter-1.xhtml#page1"/>
+    </pageTarget>
+  </pageList>
+  <navList class="lot">
+    <navLabel><text>Tables</text></navLabel>
+    <navTarget id="t1">
+      <navLabel><text>Table 1</text></navLabel>
+      <content src="text/chapter-2.xhtml#table1"/>
+    </navTarget>
+  </navList>
+</ncx>`,
+	"OEBPS/images/cover.png": "\x89PNG\r\n\x1a\n",
+	"OEBPS/text/chapter-1.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter 1</title></head>
+<body><h1>Chapter 1</h1><span id="page1"/><h2 id="s1">Section</h2>
+<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"/></svg></body></html>`,
+	"OEBPS/text/chapter-2.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter 2</title>
+<script type="text/javascript">var a = 1;</script></head>
+<body><h1>Chapter 2</h1><math xmlns="http://www.w3.org/1998/Math/MathML"><mi>x</mi></math>
+<img src="https://example.com/remote.png" alt=""/><table id="table1"><tr><td>1</td></tr></table></body></html>`,
+}
+
+func TestUpgradeToEPUB3(t *testing.T) {
+	source := newTestReader(t, testEPUB2Files)
+
+	w, err := UpgradeToEPUB3(&source)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := writeAndReopen(t, w)
+
+	if r.Version() != "3.0" {
+		t.Errorf("expected version 3.0, got %q", r.Version())
+	}
+
+	t.Run("nav document", func(t *testing.T) {
+		toc, err := r.TableOfContents()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(toc.Items) != 2 || len(toc.Items[0].Items) != 1 {
+			t.Fatalf("unexpected toc structure: %+v", toc.Items)
+		}
+
+		if toc.Items[0].Items[0].Href != "text/chapter-1.xhtml#s1" {
+			t.Errorf("unexpected nested href %q", toc.Items[0].Items[0].Href)
+		}
+
+		nav := r.SelectResourceById("nav")
+		if nav == nil {
+			t.Fatalf("expected nav resource")
+		}
+
+		content := string(nav.Content)
+		for _, expected := range []string{
+			`epub:type="landmarks"`,
+			`epub:type="bodymatter" href="text/chapter-1.xhtml"`,
+			`epub:type="page-list"`,
+			`href="text/chapter-1.xhtml#page1"`,
+			`epub:type="lot"`,
+		} {
+			if !strings.Contains(content, expected) {
+				t.Errorf("expected nav to contain %s", expected)
+			}
+		}
+	})
+
+	t.Run("ncx kept", func(t *testing.T) {
+		if r.NavigationCenterExtended() == nil {
+			t.Errorf("expected NCX to be kept")
+		}
+	})
+
+	t.Run("refines", func(t *testing.T) {
+		refines := r.Refines()
+		creator := refines["creator-1"]
+		if !slices.Contains(creator["role"], "aut") {
+			t.Errorf("expected role refine, got %v", creator)
+		}
+		if !slices.Contains(creator["file-as"], "Writer, Jane") {
+			t.Errorf("expected file-as refine, got %v", creator)
+		}
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		meta := r.Metadata()["meta"].(map[string]any)
+		if _, ok := meta["dcterms:modified"]; !ok {
+			t.Errorf("expected dcterms:modified meta")
+		}
+	})
+
+	t.Run("manifest properties", func(t *testing.T) {
+		expected := map[string]pkg.ManifestProperty{
+			"cover-image": pkg.CoverImageProperty,
+			"chapter-1":   pkg.SvgProperty,
+			"chapter-2":   "mathml remote-resources scripted",
+		}
+
+		for id, properties := range expected {
+			res := r.SelectResourceById(id)
+			if res == nil {
+				t.Fatalf("missing resource %s", id)
+			}
+			if res.Properties != properties {
+				t.Errorf("expected %s properties %q, got %q", id, properties, res.Properties)
+			}
+		}
+	})
+
+	t.Run("already epub 3", func(t *testing.T) {
+		_, err := UpgradeToEPUB3(&r)
+		if err == nil {
+			t.Errorf("expected error when upgrading an EPUB 3 publication")
+		}
+	})
+}
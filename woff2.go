@@ -0,0 +1,919 @@
+package epub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"slices"
+
+	"github.com/raitucarp/epub/internal/brotli"
+)
+
+// woff2KnownTags are the tags a WOFF2 table directory refers to by index.
+var woff2KnownTags = [63]string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post", "cvt ",
+	"fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT", "EBLC", "gasp",
+	"hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea", "vmtx", "BASE", "GDEF",
+	"GPOS", "GSUB", "EBSC", "JSTF", "MATH", "CBDT", "CBLC", "COLR", "CPAL",
+	"SVG ", "sbix", "acnt", "avar", "bdat", "bloc", "bsln", "cvar", "fdsc",
+	"feat", "fmtx", "fvar", "gvar", "hsty", "just", "lcar", "mort", "morx",
+	"opbd", "prop", "trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+// woff2ArbitraryTag is the tag index of tables whose tag follows the flags.
+const woff2ArbitraryTag = 63
+
+// woff2NullTransform is the transform version leaving glyf and loca
+// untransformed. Version 0 is the glyf transform for them, and the null
+// transform for other tables.
+const woff2NullTransform = 3
+
+// woff2GlyfHeaderSize is the size of the header of a transformed glyf
+// table, which gives the sizes of its seven streams.
+const woff2GlyfHeaderSize = 36
+
+// Simple glyph flags.
+const (
+	glyfOnCurve       = 0x01
+	glyfXShort        = 0x02
+	glyfYShort        = 0x04
+	glyfRepeat        = 0x08
+	glyfXSame         = 0x10
+	glyfYSame         = 0x20
+	glyfOverlapSimple = 0x40
+)
+
+var errMalformedGlyf = errors.New("malformed WOFF2 glyf table")
+
+// woff2Table is an entry of a WOFF2 table directory with its data.
+type woff2Table struct {
+	tag        string
+	version    int
+	origLength uint32
+	data       []byte
+}
+
+// transformed reports whether the table is stored transformed.
+func (table *woff2Table) transformed() bool {
+	if table.tag == "glyf" || table.tag == "loca" {
+		return table.version != woff2NullTransform
+	}
+	return table.version != 0
+}
+
+// supported reports whether decodeWOFF2 can undo the transform of the
+// table.
+func (table *woff2Table) supported() bool {
+	switch table.tag {
+	case "glyf", "loca":
+		return table.version == 0 || table.version == woff2NullTransform
+	case "hmtx":
+		return table.version <= 1
+	default:
+		return table.version == 0
+	}
+}
+
+func (file *fontFile) decodeWOFF2(data []byte) error {
+	if len(data) < 48 {
+		return errors.New("malformed WOFF2 header")
+	}
+
+	file.flavor = binary.BigEndian.Uint32(data[4:])
+	if file.flavor == 0x74746366 {
+		return errors.New("WOFF2 font collections are not supported")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[12:]))
+	file.wOFFVersion = [2]uint16{binary.BigEndian.Uint16(data[24:]), binary.BigEndian.Uint16(data[26:])}
+
+	block := func(offset, length uint32) ([]byte, error) {
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, errors.New("malformed WOFF2 block")
+		}
+		return data[offset : offset+length], nil
+	}
+
+	var err error
+	if file.wOFFMetadata, err = block(binary.BigEndian.Uint32(data[28:]), binary.BigEndian.Uint32(data[32:])); err != nil {
+		return err
+	}
+	file.wOFFMetadataLength = binary.BigEndian.Uint32(data[36:])
+	if file.wOFFPrivate, err = block(binary.BigEndian.Uint32(data[40:]), binary.BigEndian.Uint32(data[44:])); err != nil {
+		return err
+	}
+
+	directory := fontStream{data: data[48:]}
+	tables := make([]woff2Table, numTables)
+	lengths := make([]uint32, numTables)
+	var total uint64
+	for i := range tables {
+		flags := directory.u8()
+		table := &tables[i]
+		if index := flags & 0x3f; index == woff2ArbitraryTag {
+			table.tag = string(directory.bytes(4))
+		} else {
+			table.tag = woff2KnownTags[index]
+		}
+		table.version = int(flags >> 6)
+		if !table.supported() {
+			return fmt.Errorf("unsupported WOFF2 transform %d of table %q", table.version, table.tag)
+		}
+		table.origLength = directory.uintBase128()
+		lengths[i] = table.origLength
+		if table.transformed() {
+			lengths[i] = directory.uintBase128()
+		}
+		total += uint64(lengths[i])
+	}
+	if directory.overrun {
+		return errors.New("malformed WOFF2 table directory")
+	}
+
+	compressed, err := block(uint32(len(data)-len(directory.data)), binary.BigEndian.Uint32(data[20:]))
+	if err != nil {
+		return err
+	}
+	stream, err := brotli.Decode(compressed, int(min(total, 1<<31)))
+	if err != nil {
+		return fmt.Errorf("WOFF2 tables: %w", err)
+	}
+	if uint64(len(stream)) != total {
+		return errors.New("malformed WOFF2 tables")
+	}
+	for i := range tables {
+		tables[i].data, stream = stream[:lengths[i]], stream[lengths[i]:]
+	}
+
+	return file.reconstructWOFF2(tables)
+}
+
+// reconstructWOFF2 sets the tables of the font, undoing the transforms of
+// the glyf, loca and hmtx tables.
+func (file *fontFile) reconstructWOFF2(tables []woff2Table) error {
+	find := func(tag string) *woff2Table {
+		for i := range tables {
+			if tables[i].tag == tag {
+				return &tables[i]
+			}
+		}
+		return nil
+	}
+
+	var xMins []int16
+	if glyf := find("glyf"); glyf != nil && glyf.transformed() {
+		loca := find("loca")
+		if loca == nil || !loca.transformed() {
+			return errors.New("WOFF2 glyf table transformed without its loca table")
+		}
+
+		var err error
+		if glyf.data, loca.data, xMins, err = reconstructGlyf(glyf.data); err != nil {
+			return err
+		}
+		if uint32(len(loca.data)) != loca.origLength {
+			return errors.New("malformed WOFF2 loca table")
+		}
+	}
+
+	if hmtx := find("hmtx"); hmtx != nil && hmtx.version == 1 {
+		hhea := find("hhea")
+		if xMins == nil || hhea == nil || len(hhea.data) < 36 {
+			return errors.New("WOFF2 hmtx table transformed without its glyf and hhea tables")
+		}
+
+		var err error
+		if hmtx.data, err = reconstructHmtx(hmtx.data, xMins, int(binary.BigEndian.Uint16(hhea.data[34:]))); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range tables {
+		file.tables = append(file.tables, fontTable{table.tag, table.data})
+	}
+	return nil
+}
+
+// reconstructGlyf rebuilds the glyf and loca tables of a transformed glyf
+// table, and returns the xMin of every glyph.
+func reconstructGlyf(data []byte) (glyf, loca []byte, xMins []int16, err error) {
+	if len(data) < woff2GlyfHeaderSize {
+		return nil, nil, nil, errMalformedGlyf
+	}
+	optionFlags := binary.BigEndian.Uint16(data[2:])
+	numGlyphs := int(binary.BigEndian.Uint16(data[4:]))
+	longLoca := binary.BigEndian.Uint16(data[6:]) != 0
+
+	var streams [7]fontStream
+	offset := woff2GlyfHeaderSize
+	for i := range streams {
+		size := int(binary.BigEndian.Uint32(data[8+4*i:]))
+		if size < 0 || size > len(data)-offset {
+			return nil, nil, nil, errMalformedGlyf
+		}
+		streams[i].data = data[offset : offset+size]
+		offset += size
+	}
+	contours, points, flags, glyphs, composites, bboxes, instructions := &streams[0], &streams[1], &streams[2], &streams[3], &streams[4], &streams[5], &streams[6]
+
+	bboxBitmap := bboxes.bytes(4 * ((numGlyphs + 31) / 32))
+	var overlapBitmap []byte
+	if optionFlags&1 != 0 {
+		size := (numGlyphs + 7) >> 3
+		if size > len(data)-offset {
+			return nil, nil, nil, errMalformedGlyf
+		}
+		overlapBitmap = data[offset : offset+size]
+	}
+
+	offsets := make([]int, 0, numGlyphs+1)
+	xMins = make([]int16, numGlyphs)
+	for i := range numGlyphs {
+		offsets = append(offsets, len(glyf))
+		bit := byte(0x80 >> (i & 7))
+		explicit := bboxBitmap[i>>3]&bit != 0
+		nContours := int16(contours.u16())
+
+		var bbox [4]int16
+		if explicit {
+			for j := range bbox {
+				bbox[j] = int16(bboxes.u16())
+			}
+		}
+
+		var glyph []byte
+		switch {
+		case nContours == 0:
+			if explicit {
+				return nil, nil, nil, errMalformedGlyf
+			}
+			continue
+
+		case nContours < 0:
+			length, _, hasInstructions, err := compositeRecords(composites.data)
+			if err != nil || !explicit {
+				return nil, nil, nil, errMalformedGlyf
+			}
+			glyph = appendGlyfHeader(nil, nContours, bbox)
+			glyph = append(glyph, composites.bytes(length)...)
+			if hasInstructions {
+				n := glyphs.uint255()
+				glyph = binary.BigEndian.AppendUint16(glyph, uint16(n))
+				glyph = append(glyph, instructions.bytes(n)...)
+			}
+
+		default:
+			endPoints := make([]int, nContours)
+			total := 0
+			for j := range endPoints {
+				total += points.uint255()
+				endPoints[j] = total - 1
+			}
+			if total > 0xffff {
+				return nil, nil, nil, errMalformedGlyf
+			}
+
+			glyphPoints := decodeTriplets(flags.bytes(total), glyphs)
+			code := instructions.bytes(glyphs.uint255())
+			if !explicit {
+				bbox = pointsBBox(glyphPoints)
+			}
+			overlap := overlapBitmap != nil && overlapBitmap[i>>3]&bit != 0
+			glyph = appendSimpleGlyph(nil, bbox, endPoints, code, glyphPoints, overlap)
+		}
+
+		xMins[i] = bbox[0]
+		glyf = append(glyf, glyph...)
+		glyf = append(glyf, make([]byte, pad4(len(glyph))-len(glyph))...)
+	}
+	offsets = append(offsets, len(glyf))
+
+	for _, stream := range streams {
+		if stream.overrun {
+			return nil, nil, nil, errMalformedGlyf
+		}
+	}
+	if !longLoca && len(glyf) > 0x1fffe {
+		return nil, nil, nil, errMalformedGlyf
+	}
+
+	for _, offset := range offsets {
+		if longLoca {
+			loca = binary.BigEndian.AppendUint32(loca, uint32(offset))
+		} else {
+			loca = binary.BigEndian.AppendUint16(loca, uint16(offset/2))
+		}
+	}
+	return glyf, loca, xMins, nil
+}
+
+// reconstructHmtx rebuilds a transformed hmtx table, whose left side
+// bearings may be left out when they equal the xMin of the glyphs.
+func reconstructHmtx(data []byte, xMins []int16, numHMetrics int) ([]byte, error) {
+	if len(data) < 1 || numHMetrics > len(xMins) {
+		return nil, errors.New("malformed WOFF2 hmtx table")
+	}
+	flags := data[0]
+	stream := fontStream{data: data[1:]}
+
+	advances := make([]uint16, numHMetrics)
+	for i := range advances {
+		advances[i] = stream.u16()
+	}
+	bearing := func(i int) int16 {
+		if i < numHMetrics && flags&1 != 0 || i >= numHMetrics && flags&2 != 0 {
+			return xMins[i]
+		}
+		return int16(stream.u16())
+	}
+
+	var hmtx []byte
+	for i := range xMins {
+		if i < numHMetrics {
+			hmtx = binary.BigEndian.AppendUint16(hmtx, advances[i])
+		}
+		hmtx = binary.BigEndian.AppendUint16(hmtx, uint16(bearing(i)))
+	}
+	if stream.overrun {
+		return nil, errors.New("malformed WOFF2 hmtx table")
+	}
+	return hmtx, nil
+}
+
+// glyphPoint is a point of a simple glyph outline.
+type glyphPoint struct {
+	x, y    int
+	onCurve bool
+}
+
+// decodeTriplets decodes the points of a glyph from their flags and the
+// coordinate triplets of the glyph stream.
+func decodeTriplets(flags []byte, glyphs *fontStream) []glyphPoint {
+	withSign := func(flag byte, value int) int {
+		if flag&1 != 0 {
+			return value
+		}
+		return -value
+	}
+
+	points := make([]glyphPoint, len(flags))
+	x, y := 0, 0
+	for i, flag := range flags {
+		onCurve := flag>>7 == 0
+		flag &= 0x7f
+
+		var dx, dy int
+		switch {
+		case flag < 10:
+			dy = withSign(flag, int(flag&14)<<7+int(glyphs.u8()))
+		case flag < 20:
+			dx = withSign(flag, int((flag-10)&14)<<7+int(glyphs.u8()))
+		case flag < 84:
+			b0, b1 := int(flag-20), int(glyphs.u8())
+			dx = withSign(flag, 1+b0&0x30+b1>>4)
+			dy = withSign(flag>>1, 1+(b0&0x0c)<<2+b1&0x0f)
+		case flag < 120:
+			b0, data := int(flag-84), glyphs.bytes(2)
+			dx = withSign(flag, 1+(b0/12)<<8+int(data[0]))
+			dy = withSign(flag>>1, 1+((b0%12)>>2)<<8+int(data[1]))
+		case flag < 124:
+			data := glyphs.bytes(3)
+			dx = withSign(flag, int(data[0])<<4+int(data[1])>>4)
+			dy = withSign(flag>>1, int(data[1]&0x0f)<<8+int(data[2]))
+		default:
+			data := glyphs.bytes(4)
+			dx = withSign(flag, int(data[0])<<8+int(data[1]))
+			dy = withSign(flag>>1, int(data[2])<<8+int(data[3]))
+		}
+
+		x += dx
+		y += dy
+		points[i] = glyphPoint{x, y, onCurve}
+	}
+	return points
+}
+
+// appendTriplet appends the coordinate triplet of a point relative to the
+// previous one, and returns its flag.
+func appendTriplet(glyphs []byte, onCurve bool, dx, dy int) ([]byte, byte) {
+	var flag byte
+	if !onCurve {
+		flag = 0x80
+	}
+	absX, absY := max(dx, -dx), max(dy, -dy)
+	var xSign, ySign byte
+	if dx >= 0 {
+		xSign = 1
+	}
+	if dy >= 0 {
+		ySign = 2
+	}
+
+	switch {
+	case dx == 0 && absY < 1280:
+		flag += byte(absY&0xf00>>7) + ySign>>1
+		glyphs = append(glyphs, byte(absY))
+	case dy == 0 && absX < 1280:
+		flag += 10 + byte(absX&0xf00>>7) + xSign
+		glyphs = append(glyphs, byte(absX))
+	case absX < 65 && absY < 65:
+		flag += 20 + byte((absX-1)&0x30) + byte((absY-1)&0x30>>2) + xSign + ySign
+		glyphs = append(glyphs, byte((absX-1)&0xf<<4|(absY-1)&0xf))
+	case absX < 769 && absY < 769:
+		flag += 84 + byte(12*((absX-1)&0x300>>8)) + byte((absY-1)&0x300>>6) + xSign + ySign
+		glyphs = append(glyphs, byte(absX-1), byte(absY-1))
+	case absX < 4096 && absY < 4096:
+		flag += 120 + xSign + ySign
+		glyphs = append(glyphs, byte(absX>>4), byte(absX&0xf<<4|absY>>8), byte(absY))
+	default:
+		flag += 124 + xSign + ySign
+		glyphs = append(glyphs, byte(absX>>8), byte(absX), byte(absY>>8), byte(absY))
+	}
+	return glyphs, flag
+}
+
+func pointsBBox(points []glyphPoint) (bbox [4]int16) {
+	for i, p := range points {
+		if i == 0 {
+			bbox = [4]int16{int16(p.x), int16(p.y), int16(p.x), int16(p.y)}
+			continue
+		}
+		bbox = [4]int16{min(bbox[0], int16(p.x)), min(bbox[1], int16(p.y)), max(bbox[2], int16(p.x)), max(bbox[3], int16(p.y))}
+	}
+	return
+}
+
+func appendGlyfHeader(glyph []byte, nContours int16, bbox [4]int16) []byte {
+	glyph = binary.BigEndian.AppendUint16(glyph, uint16(nContours))
+	for _, value := range bbox {
+		glyph = binary.BigEndian.AppendUint16(glyph, uint16(value))
+	}
+	return glyph
+}
+
+// appendSimpleGlyph appends a simple glyph, with the shortest coordinate
+// encoding and repeated flags.
+func appendSimpleGlyph(glyph []byte, bbox [4]int16, endPoints []int, instructions []byte, points []glyphPoint, overlap bool) []byte {
+	glyph = appendGlyfHeader(glyph, int16(len(endPoints)), bbox)
+	for _, end := range endPoints {
+		glyph = binary.BigEndian.AppendUint16(glyph, uint16(end))
+	}
+	glyph = binary.BigEndian.AppendUint16(glyph, uint16(len(instructions)))
+	glyph = append(glyph, instructions...)
+
+	coordinate := func(coordinates []byte, flag *byte, delta int, short, same byte) []byte {
+		switch {
+		case delta == 0:
+			*flag |= same
+		case delta > -256 && delta < 256:
+			*flag |= short
+			if delta > 0 {
+				*flag |= same
+			}
+			coordinates = append(coordinates, byte(max(delta, -delta)))
+		default:
+			coordinates = binary.BigEndian.AppendUint16(coordinates, uint16(delta))
+		}
+		return coordinates
+	}
+
+	var flags, xs, ys []byte
+	last, repeats := -1, 0
+	x, y := 0, 0
+	for i, p := range points {
+		var flag byte
+		if p.onCurve {
+			flag |= glyfOnCurve
+		}
+		if i == 0 && overlap {
+			flag |= glyfOverlapSimple
+		}
+		xs = coordinate(xs, &flag, p.x-x, glyfXShort, glyfXSame)
+		ys = coordinate(ys, &flag, p.y-y, glyfYShort, glyfYSame)
+		x, y = p.x, p.y
+
+		if last >= 0 && flags[last]&^glyfRepeat == flag && repeats < 255 {
+			if repeats == 0 {
+				flags[last] |= glyfRepeat
+				flags = append(flags, 0)
+			}
+			repeats++
+			flags[len(flags)-1] = byte(repeats)
+			continue
+		}
+		flags = append(flags, flag)
+		last, repeats = len(flags)-1, 0
+	}
+
+	glyph = append(glyph, flags...)
+	glyph = append(glyph, xs...)
+	return append(glyph, ys...)
+}
+
+// parseSimpleGlyph reads the contours, instructions and points of a simple
+// glyph.
+func parseSimpleGlyph(data []byte) (endPoints []int, instructions []byte, points []glyphPoint, overlap bool, err error) {
+	glyph := fontStream{data: data}
+	nContours := int(int16(glyph.u16()))
+	glyph.bytes(8)
+
+	endPoints = make([]int, max(nContours, 0))
+	for i := range endPoints {
+		endPoints[i] = int(glyph.u16())
+		if i > 0 && endPoints[i] < endPoints[i-1] {
+			return nil, nil, nil, false, errors.New("malformed simple glyph")
+		}
+	}
+	numPoints := 0
+	if nContours > 0 {
+		numPoints = endPoints[nContours-1] + 1
+	}
+	instructions = glyph.bytes(int(glyph.u16()))
+
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints && !glyph.overrun {
+		flag := glyph.u8()
+		flags = append(flags, flag)
+		if flag&glyfRepeat != 0 {
+			for range glyph.u8() {
+				flags = append(flags, flag)
+			}
+		}
+	}
+	if len(flags) > numPoints {
+		return nil, nil, nil, false, errors.New("malformed simple glyph")
+	}
+
+	coordinate := func(flag byte, short, same byte) int {
+		switch {
+		case flag&short != 0 && flag&same != 0:
+			return int(glyph.u8())
+		case flag&short != 0:
+			return -int(glyph.u8())
+		case flag&same != 0:
+			return 0
+		default:
+			return int(int16(glyph.u16()))
+		}
+	}
+
+	points = make([]glyphPoint, numPoints)
+	x, y := 0, 0
+	for i, flag := range flags {
+		x += coordinate(flag, glyfXShort, glyfXSame)
+		points[i] = glyphPoint{x: x, onCurve: flag&glyfOnCurve != 0}
+	}
+	for i, flag := range flags {
+		y += coordinate(flag, glyfYShort, glyfYSame)
+		points[i].y = y
+	}
+	if glyph.overrun {
+		return nil, nil, nil, false, errors.New("malformed simple glyph")
+	}
+	return endPoints, instructions, points, len(flags) > 0 && flags[0]&glyfOverlapSimple != 0, nil
+}
+
+// transformGlyf applies the WOFF2 glyf transform to the glyphs at the
+// given offsets.
+func transformGlyf(glyf []byte, offsets []int, longLoca bool) ([]byte, error) {
+	numGlyphs := len(offsets) - 1
+	var contours, points, flags, glyphs, composites, bboxes, instructions []byte
+	bboxBitmap := make([]byte, 4*((numGlyphs+31)/32))
+	overlapBitmap := make([]byte, (numGlyphs+7)>>3)
+	overlaps := false
+
+	for i := range numGlyphs {
+		data := glyf[offsets[i]:offsets[i+1]]
+		bit := byte(0x80 >> (i & 7))
+		if len(data) == 0 {
+			contours = binary.BigEndian.AppendUint16(contours, 0)
+			continue
+		}
+		if len(data) < glyfHeaderSize {
+			return nil, fmt.Errorf("malformed glyph %d", i)
+		}
+
+		nContours := int16(binary.BigEndian.Uint16(data))
+		contours = append(contours, data[:2]...)
+		switch {
+		case nContours == 0:
+			continue
+
+		case nContours < 0:
+			length, _, hasInstructions, err := compositeRecords(data[glyfHeaderSize:])
+			if err != nil {
+				return nil, fmt.Errorf("glyph %d: %w", i, err)
+			}
+			composites = append(composites, data[glyfHeaderSize:glyfHeaderSize+length]...)
+			bboxBitmap[i>>3] |= bit
+			bboxes = append(bboxes, data[2:glyfHeaderSize]...)
+			if hasInstructions {
+				rest := fontStream{data: data[glyfHeaderSize+length:]}
+				code := rest.bytes(int(rest.u16()))
+				if rest.overrun {
+					return nil, fmt.Errorf("malformed glyph %d", i)
+				}
+				glyphs = appendUint255(glyphs, len(code))
+				instructions = append(instructions, code...)
+			}
+
+		default:
+			endPoints, code, glyphPoints, overlap, err := parseSimpleGlyph(data)
+			if err != nil {
+				return nil, fmt.Errorf("glyph %d: %w", i, err)
+			}
+			previous := -1
+			for _, end := range endPoints {
+				points = appendUint255(points, end-previous)
+				previous = end
+			}
+
+			x, y := 0, 0
+			for _, p := range glyphPoints {
+				var flag byte
+				glyphs, flag = appendTriplet(glyphs, p.onCurve, p.x-x, p.y-y)
+				flags = append(flags, flag)
+				x, y = p.x, p.y
+			}
+			glyphs = appendUint255(glyphs, len(code))
+			instructions = append(instructions, code...)
+
+			bbox := pointsBBox(glyphPoints)
+			if !bytes.Equal(appendGlyfHeader(nil, nContours, bbox), data[:glyfHeaderSize]) {
+				bboxBitmap[i>>3] |= bit
+				bboxes = append(bboxes, data[2:glyfHeaderSize]...)
+			}
+			if overlap {
+				overlapBitmap[i>>3] |= bit
+				overlaps = true
+			}
+		}
+	}
+
+	var optionFlags, indexFormat uint16
+	if overlaps {
+		optionFlags = 1
+	}
+	if longLoca {
+		indexFormat = 1
+	}
+	transformed := binary.BigEndian.AppendUint16(nil, 0)
+	transformed = binary.BigEndian.AppendUint16(transformed, optionFlags)
+	transformed = binary.BigEndian.AppendUint16(transformed, uint16(numGlyphs))
+	transformed = binary.BigEndian.AppendUint16(transformed, indexFormat)
+	streams := [][]byte{contours, points, flags, glyphs, composites, slices.Concat(bboxBitmap, bboxes), instructions}
+	for _, stream := range streams {
+		transformed = binary.BigEndian.AppendUint32(transformed, uint32(len(stream)))
+	}
+	for _, stream := range streams {
+		transformed = append(transformed, stream...)
+	}
+	if overlaps {
+		transformed = append(transformed, overlapBitmap...)
+	}
+	return transformed, nil
+}
+
+// encodeWOFF2 compresses the tables with the glyf transform, leaving the
+// other tables untransformed.
+func (file *fontFile) encodeWOFF2() ([]byte, error) {
+	// Round trip through SFNT for sorted tables and the head checksum.
+	_, tables, err := parseSFNT(buildSFNT(file.flavor, file.tables))
+	if err != nil {
+		return nil, err
+	}
+
+	// The loca table follows the glyf table it is rebuilt with.
+	if i := slices.IndexFunc(tables, func(table fontTable) bool { return table.tag == "loca" }); i >= 0 {
+		loca := tables[i]
+		tables = slices.Delete(tables, i, i+1)
+		if j := slices.IndexFunc(tables, func(table fontTable) bool { return table.tag == "glyf" }); j >= 0 {
+			tables = slices.Insert(tables, j+1, loca)
+		} else {
+			tables = append(tables, loca)
+		}
+	}
+	glyf, xMins := file.transformedGlyf()
+	var hmtx []byte
+	if hhea := file.table("hhea"); glyf != nil && len(hhea) >= 36 {
+		hmtx = transformHmtx(file.table("hmtx"), int(binary.BigEndian.Uint16(hhea[34:])), xMins)
+	}
+
+	totalSfntSize := 12 + 16*len(tables)
+	var directory, stream []byte
+	for _, table := range tables {
+		totalSfntSize += pad4(len(table.data))
+
+		entry := woff2Table{tag: table.tag, origLength: uint32(len(table.data)), data: table.data}
+		switch {
+		case table.tag == "glyf" && glyf != nil:
+			entry.data = glyf
+		case table.tag == "loca" && glyf != nil:
+			entry.data = nil
+		case table.tag == "glyf" || table.tag == "loca":
+			entry.version = woff2NullTransform
+		case table.tag == "hmtx" && hmtx != nil:
+			entry.version, entry.data = 1, hmtx
+		}
+
+		index := slices.Index(woff2KnownTags[:], table.tag)
+		if index < 0 {
+			directory = append(directory, woff2ArbitraryTag|byte(entry.version)<<6)
+			directory = append(directory, table.tag...)
+		} else {
+			directory = append(directory, byte(index)|byte(entry.version)<<6)
+		}
+		directory = appendUIntBase128(directory, entry.origLength)
+		if entry.transformed() {
+			directory = appendUIntBase128(directory, uint32(len(entry.data)))
+		}
+		stream = append(stream, entry.data...)
+	}
+	compressed := brotli.Encode(stream)
+
+	offset := 48 + len(directory)
+	body := compressed
+	var metadataOffset, privateOffset int
+	if len(file.wOFFMetadata) > 0 {
+		body = append(body, make([]byte, pad4(len(body))-len(body))...)
+		metadataOffset = offset + len(body)
+		body = append(body, file.wOFFMetadata...)
+	}
+	if len(file.wOFFPrivate) > 0 {
+		body = append(body, make([]byte, pad4(len(body))-len(body))...)
+		privateOffset = offset + len(body)
+		body = append(body, file.wOFFPrivate...)
+	}
+
+	woff2 := []byte("wOF2")
+	woff2 = binary.BigEndian.AppendUint32(woff2, file.flavor)
+	woff2 = binary.BigEndian.AppendUint32(woff2, uint32(offset+len(body)))
+	woff2 = binary.BigEndian.AppendUint16(woff2, uint16(len(tables)))
+	woff2 = binary.BigEndian.AppendUint16(woff2, 0)
+	woff2 = binary.BigEndian.AppendUint32(woff2, uint32(totalSfntSize))
+	woff2 = binary.BigEndian.AppendUint32(woff2, uint32(len(compressed)))
+	woff2 = binary.BigEndian.AppendUint16(woff2, file.wOFFVersion[0])
+	woff2 = binary.BigEndian.AppendUint16(woff2, file.wOFFVersion[1])
+	woff2 = binary.BigEndian.AppendUint32(woff2, uint32(metadataOffset))
+	woff2 = binary.BigEndian.AppendUint32(woff2, uint32(len(file.wOFFMetadata)))
+	woff2 = binary.BigEndian.AppendUint32(woff2, file.wOFFMetadataLength)
+	woff2 = binary.BigEndian.AppendUint32(woff2, uint32(privateOffset))
+	woff2 = binary.BigEndian.AppendUint32(woff2, uint32(len(file.wOFFPrivate)))
+	woff2 = append(woff2, directory...)
+	return append(woff2, body...), nil
+}
+
+// transformedGlyf returns the transformed glyf table and the xMin of
+// every glyph, or nil when the font has no glyf table or its glyphs cannot
+// be read.
+func (file *fontFile) transformedGlyf() ([]byte, []int16) {
+	head, maxp, loca, glyf := file.table("head"), file.table("maxp"), file.table("loca"), file.table("glyf")
+	if glyf == nil || loca == nil || len(head) < 54 || len(maxp) < 6 {
+		return nil, nil
+	}
+
+	longLoca := int16(binary.BigEndian.Uint16(head[50:])) == 1
+	offsets, err := glyphOffsets(loca, longLoca, int(binary.BigEndian.Uint16(maxp[4:])), len(glyf))
+	if err != nil {
+		return nil, nil
+	}
+	transformed, err := transformGlyf(glyf, offsets, longLoca)
+	if err != nil {
+		return nil, nil
+	}
+
+	xMins := make([]int16, len(offsets)-1)
+	for i := range xMins {
+		if offsets[i+1]-offsets[i] >= glyfHeaderSize {
+			xMins[i] = int16(binary.BigEndian.Uint16(glyf[offsets[i]+2:]))
+		}
+	}
+	return transformed, xMins
+}
+
+// transformHmtx applies the WOFF2 hmtx transform, leaving out the left
+// side bearings that equal the xMin of the glyphs, or returns nil when
+// none can be left out.
+func transformHmtx(hmtx []byte, numHMetrics int, xMins []int16) []byte {
+	numGlyphs := len(xMins)
+	if numHMetrics == 0 || numHMetrics > numGlyphs || len(hmtx) != 2*numHMetrics+2*numGlyphs {
+		return nil
+	}
+	bearing := func(i int) []byte {
+		if i < numHMetrics {
+			return hmtx[4*i+2 : 4*i+4]
+		}
+		offset := 4*numHMetrics + 2*(i-numHMetrics)
+		return hmtx[offset : offset+2]
+	}
+
+	flags := byte(3)
+	for i, xMin := range xMins {
+		if int16(binary.BigEndian.Uint16(bearing(i))) == xMin {
+			continue
+		}
+		if i < numHMetrics {
+			flags &^= 1
+		} else {
+			flags &^= 2
+		}
+	}
+	if flags == 0 {
+		return nil
+	}
+
+	transformed := []byte{flags}
+	for i := range numHMetrics {
+		transformed = append(transformed, hmtx[4*i:4*i+2]...)
+	}
+	for i := range numGlyphs {
+		if i < numHMetrics && flags&1 == 0 || i >= numHMetrics && flags&2 == 0 {
+			transformed = append(transformed, bearing(i)...)
+		}
+	}
+	return transformed
+}
+
+// fontStream reads big-endian values from font data, remembering
+// overruns and reading zeros past the end.
+type fontStream struct {
+	data    []byte
+	overrun bool
+}
+
+func (s *fontStream) bytes(n int) []byte {
+	if n > len(s.data) {
+		s.overrun = true
+		s.data = nil
+		return make([]byte, n)
+	}
+	b := s.data[:n]
+	s.data = s.data[n:]
+	return b
+}
+
+func (s *fontStream) u8() byte {
+	return s.bytes(1)[0]
+}
+
+func (s *fontStream) u16() uint16 {
+	return binary.BigEndian.Uint16(s.bytes(2))
+}
+
+// uint255 reads a 255UInt16 value.
+func (s *fontStream) uint255() int {
+	switch code := s.u8(); code {
+	case 253:
+		return int(s.u16())
+	case 254:
+		return 506 + int(s.u8())
+	case 255:
+		return 253 + int(s.u8())
+	default:
+		return int(code)
+	}
+}
+
+// uintBase128 reads a UIntBase128 value, of at most five bytes without
+// leading zeros.
+func (s *fontStream) uintBase128() (value uint32) {
+	for i := range 5 {
+		b := s.u8()
+		if i == 0 && b == 0x80 || value&0xfe000000 != 0 {
+			s.overrun = true
+			return 0
+		}
+		value = value<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			return value
+		}
+	}
+	s.overrun = true
+	return 0
+}
+
+func appendUint255(b []byte, value int) []byte {
+	switch {
+	case value < 253:
+		return append(b, byte(value))
+	case value < 506:
+		return append(b, 255, byte(value-253))
+	case value < 762:
+		return append(b, 254, byte(value-506))
+	default:
+		return append(b, 253, byte(value>>8), byte(value))
+	}
+}
+
+func appendUIntBase128(b []byte, value uint32) []byte {
+	n := max(1, (bits.Len32(value)+6)/7)
+	for i := n - 1; i >= 0; i-- {
+		c := byte(value>>(7*i)) & 0x7f
+		if i > 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+	}
+	return b
+}
@@ -12,11 +12,11 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/raitucarp/epub/ncx"
 	"github.com/raitucarp/epub/ocf"
 	"github.com/raitucarp/epub/pkg"
 	"golang.org/x/net/html"
@@ -30,7 +30,13 @@ type Writer struct {
 	textDir    string
 	contentDir string
 	imagesDir  string
+	audioDir   string
 	direction  string
+	pageList   []Page
+
+	regionPages []RegionPage
+	renditions  []writerRendition
+	mappingPath string
 }
 
 // New creates a new Writer with the given publication identifier.
@@ -45,10 +51,15 @@ func New(pubId string) *Writer {
 		textDir:    "text",
 		contentDir: "epub",
 		imagesDir:  "images",
+		audioDir:   "audio",
 		direction:  "ltr",
 	}
 
 	epubWriter.epub.rendition = "content"
+	epubWriter.renditions = []writerRendition{{
+		Rendition:  Rendition{Key: "content"},
+		contentDir: epubWriter.contentDir,
+	}}
 	epubWriter.epub.packagePubs["content"] = &pkg.Package{
 		UniqueIdentifier: "pub-id",
 		Version:          "3.0",
@@ -68,6 +79,93 @@ func New(pubId string) *Writer {
 	return epubWriter
 }
 
+// newWriterFromReader creates a Writer seeded with the selected package and
+// all container files of an opened publication, so it can be rewritten.
+func newWriterFromReader(r *Reader) *Writer {
+	packagePath := r.CurrentSelectedPackagePath()
+	name := strings.TrimSuffix(path.Base(packagePath), path.Ext(packagePath))
+
+	epubWriter := &Writer{
+		identifier: r.UID(),
+		epub: &Epub{
+			packagePubs:              make(map[string]*pkg.Package),
+			zipContainer:             ocf.NewOCFZipContainer(),
+			rendition:                name,
+			resources:                slices.Clone(r.epub.resources),
+			navigationCenterEXtended: r.epub.navigationCenterEXtended,
+		},
+		textDir:    "text",
+		contentDir: path.Dir(packagePath),
+		imagesDir:  "images",
+		audioDir:   "audio",
+		direction:  r.CurrentSelectedPackage().Dir,
+	}
+
+	packagePub := clonePackage(r.CurrentSelectedPackage())
+	normalizeDublinCore(&packagePub.Metadata)
+	epubWriter.epub.packagePubs[name] = packagePub
+	epubWriter.renditions = []writerRendition{{
+		Rendition:  Rendition{Key: name},
+		contentDir: epubWriter.contentDir,
+	}}
+
+	for filePath, content := range r.epub.zipContainer.AllFiles() {
+		if filePath == packagePath || filePath == "META-INF/container.xml" {
+			continue
+		}
+		epubWriter.epub.zipContainer.AddFile(filePath, content)
+	}
+	epubWriter.epub.zipContainer.AddMimeType()
+
+	return epubWriter
+}
+
+// clonePackage copies a package deep enough that editing its metadata,
+// manifest, spine or guide leaves the original untouched.
+func clonePackage(p *pkg.Package) *pkg.Package {
+	clone := *p
+	clone.Metadata.Identifiers = slices.Clone(p.Metadata.Identifiers)
+	clone.Metadata.Titles = slices.Clone(p.Metadata.Titles)
+	clone.Metadata.Languages = slices.Clone(p.Metadata.Languages)
+	clone.Metadata.OptionalDC = slices.Clone(p.Metadata.OptionalDC)
+	clone.Metadata.Meta = slices.Clone(p.Metadata.Meta)
+	clone.Metadata.Links = slices.Clone(p.Metadata.Links)
+	clone.Manifest.Items = slices.Clone(p.Manifest.Items)
+	clone.Spine.ItemRefs = slices.Clone(p.Spine.ItemRefs)
+	clone.Collections = slices.Clone(p.Collections)
+	if p.Guide != nil {
+		guide := *p.Guide
+		guide.References = slices.Clone(p.Guide.References)
+		clone.Guide = &guide
+	}
+	return &clone
+}
+
+// normalizeDublinCore moves parsed Dublin Core elements into the shape the
+// Writer produces, so required elements land in their dedicated fields.
+func normalizeDublinCore(metadata *pkg.Metadata) {
+	optional := metadata.OptionalDC[:0:0]
+	for _, dc := range metadata.OptionalDC {
+		if dc.XMLName.Space != pkg.NamespaceDC && dc.XMLName.Space != "dc" {
+			optional = append(optional, dc)
+			continue
+		}
+
+		switch dc.XMLName.Local {
+		case "identifier":
+			metadata.Identifiers = append(metadata.Identifiers, pkg.DCIdentifier{ID: dc.ID, Value: dc.Value})
+		case "title":
+			metadata.Titles = append(metadata.Titles, pkg.DCTitle{Dir: dc.Dir, ID: dc.ID, Lang: dc.Lang, Value: dc.Value})
+		case "language":
+			metadata.Languages = append(metadata.Languages, pkg.DCLanguage{ID: dc.ID, Value: dc.Value})
+		default:
+			dc.XMLName = xml.Name{Local: "dc:" + dc.XMLName.Local}
+			optional = append(optional, dc)
+		}
+	}
+	metadata.OptionalDC = optional
+}
+
 // Direction sets the writing direction (ltr or rtl) used by the spine.
 func (w *Writer) Direction(dir string) {
 	w.epub.SelectedPackage().Dir = dir
@@ -88,6 +186,11 @@ func (w *Writer) SetImageDir(dir string) {
 	w.imagesDir = dir
 }
 
+// SetAudioDir sets the directory used for storing audio resources.
+func (w *Writer) SetAudioDir(dir string) {
+	w.audioDir = dir
+}
+
 // Title sets one or more title entries in the metadata.
 func (w *Writer) Title(title ...string) {
 	if len(title) <= 0 {
@@ -277,16 +380,20 @@ func (w *Writer) AddContentFile(name string) (res PublicationResource, err error
 // Cover sets the publication cover from a raw image byte slice.
 func (w *Writer) Cover(cover []byte) (err error) {
 	name := "cover"
-	mime := http.DetectContentType(cover)
+	mime := imageMediaType(cover)
 
 	content := name
 	switch mime {
-	case "image/png":
+	case pkg.MediaTypePNG:
 		content += ".png"
-
-	case "image/jpeg":
-	case "image/jpg":
+	case pkg.MediaTypeJPEG:
 		content += ".jpeg"
+	case pkg.MediaTypeGIF:
+		content += ".gif"
+	case pkg.MediaTypeWebP:
+		content += ".webp"
+	case pkg.MediaTypeSVG:
+		content += ".svg"
 	}
 
 	w.addImageCover(content, cover)
@@ -345,7 +452,7 @@ func (w *Writer) CoverFile(name string) error {
 func (w *Writer) addImageCover(name string, content []byte) (res PublicationResource) {
 	href := path.Join(w.imagesDir, name)
 	filePath := path.Join(w.contentDir, href)
-	mimeType := http.DetectContentType(content)
+	mimeType := imageMediaType(content)
 	base := filepath.Base(href)
 	res = w.addResource(
 		base,
@@ -359,6 +466,16 @@ func (w *Writer) addImageCover(name string, content []byte) (res PublicationReso
 	return res
 }
 
+// imageMediaType sniffs the media type of an image, recognizing SVG
+// documents which http.DetectContentType reports as text.
+func imageMediaType(content []byte) string {
+	mimeType := http.DetectContentType(content)
+	if strings.HasPrefix(mimeType, "text/") && bytes.Contains(content, []byte("<svg")) {
+		return pkg.MediaTypeSVG
+	}
+	return mimeType
+}
+
 // AddImage adds an image resource from raw bytes to the publication.
 func (w *Writer) AddImage(name string, content []byte) (res PublicationResource) {
 	href := path.Join(w.imagesDir, name)
@@ -444,54 +561,59 @@ func (w *Writer) addResource(
 	return pubRes
 }
 
+// replaceResourceContent swaps the content of an already added resource,
+// both in the resource list and in the container.
+func (w *Writer) replaceResourceContent(id string, content []byte) {
+	for i, res := range w.epub.resources {
+		if res.ID != id {
+			continue
+		}
+
+		w.epub.resources[i].Content = content
+		w.epub.zipContainer.AddFile(res.Filepath, content)
+	}
+}
+
 // AddSpineItem appends the given resource to the spine reading order.
-func (w *Writer) AddSpineItem(res PublicationResource) {
-	itemRef := pkg.ItemRef{IDRef: res.ID}
+// Optional attributes set the id, linear and properties of the itemref.
+func (w *Writer) AddSpineItem(res PublicationResource, attributes ...pkg.ItemRef) {
+	itemRef := pkg.ItemRef{}
+	for _, attribute := range attributes {
+		itemRef = attribute
+	}
+	itemRef.IDRef = res.ID
 	w.epub.SelectedPackage().Spine.ItemRefs = append(
 		w.epub.SelectedPackage().Spine.ItemRefs,
 		itemRef,
 	)
 }
 
+// TableOfContents adds both the EPUB 3 navigation document and the EPUB 2
+// NCX generated from toc. Nested TOC items become nested navPoints, so the
+// table of contents may be arbitrarily deep.
 func (w *Writer) TableOfContents(name string, toc TOC) (err error) {
-	navigation := ncx.NCX{
-		NavMap: ncx.NavMap{
-			ID:        "navmap",
-			NavPoints: []ncx.NavPoint{},
-		},
-	}
-	currentNavPoint := 0
-	visitTOC(&toc, func(t *TOC, depth int) {
-		if t.Href == "" && depth == 0 {
-			navigation.DocTitle.Text = t.Title
-			return
-		}
-
-		label := ncx.NavLabel{Text: t.Title}
-		playOrder := strconv.Itoa(currentNavPoint + 1)
-		navPoint := ncx.NavPoint{
-			ID:        "nav-point-" + playOrder,
-			PlayOrder: playOrder,
-			NavLabel:  label,
-			Content:   ncx.Content{Src: t.Href},
-			NavPoints: []ncx.NavPoint{},
-		}
+	packagePub := w.epub.SelectedPackage()
 
-		if depth <= 1 {
-			navigation.NavMap.NavPoints = append(navigation.NavMap.NavPoints, navPoint)
-		} else {
-			navigation.NavMap.NavPoints[depth+1].NavPoints = append(
-				navigation.NavMap.NavPoints[depth+1].NavPoints,
-				navPoint,
-			)
-		}
+	docTitle := firstTitle(packagePub)
+	if docTitle == "" {
+		docTitle = toc.Title
+	}
 
-		currentNavPoint++
+	navigation := tocToNCX(
+		packageUID(packagePub),
+		docTitle,
+		firstCreator(packagePub),
+		toc.Items,
+		nil,
+		w.readingPosition(w.contentDir),
+	)
 
-	})
+	w.epub.navigationCenterEXtended = navigation
+	ncxContent, err := marshalNCX(navigation)
+	if err != nil {
+		return
+	}
 
-	w.epub.navigationCenterEXtended = &navigation
-	ncxContent, err := xml.MarshalIndent(navigation, "", " ")
 	ncxBase := name + ".ncx"
 	ncxFilePath := path.Join(w.contentDir, ncxBase)
 	w.addResource(
@@ -502,6 +624,7 @@ func (w *Writer) TableOfContents(name string, toc TOC) (err error) {
 		pkg.MediaTypeNCX,
 		ncxContent,
 	)
+	packagePub.Spine.TOC = name
 
 	filePath := path.Join(w.contentDir, name+".xhtml")
 	base := filepath.Base(filePath)
@@ -525,6 +648,16 @@ func (w *Writer) TableOfContents(name string, toc TOC) (err error) {
 		pkg.MediaTypeXHTML,
 		content.Bytes(),
 	)
+
+	if len(w.pageList) > 0 {
+		if err = w.updatePageList(); err != nil {
+			return
+		}
+	}
+
+	if len(w.regionPages) > 0 {
+		err = w.updateRegionNavigation()
+	}
 	return
 }
 
@@ -548,12 +681,19 @@ func (w *Writer) guardCheck() (err error) {
 		} else {
 			var content int
 			var cover int
+			coverIDs := []string{}
+			for _, meta := range p.Metadata.Meta {
+				if meta.Name == "cover" {
+					coverIDs = append(coverIDs, meta.Content)
+				}
+			}
+
 			for _, item := range p.Manifest.Items {
 				if item.MediaType == pkg.MediaTypeXHTML {
 					content++
 				}
 
-				if item.Properties == pkg.CoverImageProperty {
+				if item.Properties == pkg.CoverImageProperty || slices.Contains(coverIDs, item.ID) {
 					cover++
 				}
 			}
@@ -583,15 +723,11 @@ func (w *Writer) Write(filename string) (err error) {
 		return err
 	}
 
-	rootFiles := []string{}
-	for name, p := range w.epub.packagePubs {
-		containerFilePath := path.Join(w.contentDir, name+".opf")
-		w.epub.zipContainer.AddPackage(containerFilePath, *p)
-		rootFiles = append(rootFiles, containerFilePath)
+	err = w.writeContainer()
+	if err != nil {
+		return
 	}
 
-	w.epub.zipContainer.AddContainerXML(rootFiles...)
-
 	err = w.epub.zipContainer.Write(filename)
 	if err != nil {
 		return
@@ -1,6 +1,7 @@
 package epub
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/raitucarp/epub/ncx"
@@ -162,3 +163,78 @@ func TestWriter_PathTraversal(t *testing.T) {
 		})
 	}
 }
+
+func TestWriter_TableOfContentsNested(t *testing.T) {
+	w := New("urn:uuid:nested")
+	w.Title("Technical Book")
+	w.Author("Jane Writer")
+
+	toc := TOC{
+		Title: "Contents",
+		Items: []TOC{
+			{Title: "Part 1", Href: "part-1.xhtml", Items: []TOC{
+				{Title: "Chapter 1", Href: "chapter-1.xhtml", Items: []TOC{
+					{Title: "1.1", Href: "chapter-1.xhtml#s1", Items: []TOC{
+						{Title: "1.1.1", Href: "chapter-1.xhtml#s1-1"},
+						{Title: "1.1.2", Href: "chapter-1.xhtml#s1-2"},
+					}},
+				}},
+				{Title: "Chapter 2", Href: "chapter-2.xhtml"},
+			}},
+			{Title: "Appendix", Href: "appendix.xhtml"},
+		},
+	}
+
+	err := w.TableOfContents("toc", toc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	navigation := w.epub.navigationCenterEXtended
+	navPoints := navigation.NavMap.NavPoints
+	if len(navPoints) != 2 {
+		t.Fatalf("expected 2 top level navPoints, got %d", len(navPoints))
+	}
+
+	part := navPoints[0]
+	if len(part.NavPoints) != 2 || part.NavPoints[1].NavLabel.Text != "Chapter 2" {
+		t.Fatalf("children attached to the wrong parent: %+v", part.NavPoints)
+	}
+
+	section := part.NavPoints[0].NavPoints[0]
+	if len(section.NavPoints) != 2 || section.NavPoints[1].Content.Src != "chapter-1.xhtml#s1-2" {
+		t.Fatalf("unexpected level 4 navPoints: %+v", section.NavPoints)
+	}
+
+	playOrders := []string{}
+	var visitNavPoints func(navPoints []ncx.NavPoint)
+	visitNavPoints = func(navPoints []ncx.NavPoint) {
+		for _, navPoint := range navPoints {
+			playOrders = append(playOrders, navPoint.PlayOrder)
+			visitNavPoints(navPoint.NavPoints)
+		}
+	}
+	visitNavPoints(navPoints)
+
+	expected := []string{"1", "2", "3", "4", "5", "6", "7"}
+	if !reflect.DeepEqual(playOrders, expected) {
+		t.Errorf("expected playOrder %v, got %v", expected, playOrders)
+	}
+
+	head := map[string]string{}
+	for _, meta := range navigation.Head.Meta {
+		head[meta.Name] = meta.Content
+	}
+
+	if head["dtb:uid"] != "urn:uuid:nested" || head["dtb:depth"] != "4" || head["dtb:totalPageCount"] != "0" {
+		t.Errorf("unexpected head metas %v", head)
+	}
+
+	if navigation.DocTitle.Text != "Technical Book" || navigation.DocAuthor.Text != "Jane Writer" {
+		t.Errorf("unexpected docTitle %q or docAuthor %q", navigation.DocTitle.Text, navigation.DocAuthor.Text)
+	}
+
+	if w.epub.SelectedPackage().Spine.TOC != "toc" {
+		t.Errorf("expected spine to reference the NCX, got %q", w.epub.SelectedPackage().Spine.TOC)
+	}
+}